@@ -2,15 +2,21 @@
 package configcmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 
 	"github.com/go-ports/echovault/cmd/memory/shared"
 	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/embeddings"
+	"github.com/go-ports/echovault/internal/models"
+	"github.com/go-ports/echovault/internal/service"
 )
 
 const configTemplate = `# EchoVault configuration
@@ -19,15 +25,80 @@ const configTemplate = `# EchoVault configuration
 # Embedding provider for semantic search.
 # Without this, keyword search (FTS5) still works.
 embedding:
-  provider: ollama              # ollama | openai | openrouter
-  model: nomic-embed-text
-  # api_key: sk-...            # required for openai/openrouter
+  provider: ollama              # ollama | openai | openrouter | azure-openai | cohere | tei | local | exec
+  model: nomic-embed-text       # local: embedding table under the memory home (GloVe/word2vec file or model2vec directory)
+  # api_key_env: OPENAI_API_KEY  # openai/openrouter/azure-openai/cohere: variable holding the API key
+  # api_key_cmd: pass show openai  # or a command printing it; config.yaml may be synced, so avoid api_key
+  # command: python3 embed.py  # exec: kept running; reads {"texts": [...]} lines, prints {"vectors": [...]} lines
+  # deployment: my-embeddings  # azure-openai: defaults to model; base_url is the resource endpoint
+  # api_version: 2024-10-21    # azure-openai
+  # timeout: 30s               # per request; default 2m for ollama (model loading), 30s otherwise
+  retries: 3                    # retry timeouts, dropped connections, 429 and 502-504 with backoff (at most 10)
+  # headers:                   # added to every request, e.g. for a corporate gateway
+  #   X-Gateway-Key: ...
+  # proxy: http://proxy:3128   # default: HTTPS_PROXY / HTTP_PROXY
+  # ca_cert: corp-ca.pem       # extra CAs to trust (PEM), relative to the memory home
+  # fallbacks:                 # tried in order when the provider fails; searches only use providers of the indexed model
+  #   - provider: openai
+  #     model: text-embedding-3-small
+  #     api_key_env: OPENAI_API_KEY
+  chunk_size: 1000              # details are embedded in chunks of this many characters; 0 = don't embed details
+  chunk_overlap: 200
+  chunk_aggregate: max          # max | sum: how a memory's summary and chunk matches combine
+  distance_metric: cosine       # cosine | l2 | dot; applies to an existing index after 'memory reindex'
+  # prefixes:                  # query/document templates of asymmetric models; "{text}" is the text, else a prefix
+  #   my-model:                # built in for nomic-embed-text, e5, bge, mxbai-embed-large and snowflake-arctic-embed
+  #     query: "query: {text}"
+  #     document: "passage: {text}"
+  dimensions: 0                 # shorten vectors to this many dimensions; 0 = the model's own
+  quantization: float           # float | int8 | bit: smaller vectors, less precise ranking
+  rescore: 0                    # with int8/bit, keep float copies and re-score this many top candidates
+  batch_size: 32                # texts per embedding request during reindex
+  concurrency: 4                # embedding requests in flight during reindex
+  auto_reindex: false           # let the MCP server rebuild vectors in the background after a model change
 
 # How memories are retrieved at session start.
 # "auto" uses vectors when available, falls back to keywords.
 context:
   semantic: auto                # auto | always | never
   topup_recent: true            # also include recent memories
+
+# How search results are ranked. Override per query with
+# memory search --set key=value.
+search:
+  mode: tiered                  # tiered (embed only when keyword hits < min_fts) | hybrid
+  fusion: weighted              # weighted | rrf (reciprocal rank fusion)
+  fts_weight: 0.3
+  vector_weight: 0.7
+  rrf_k: 60
+  min_fts: 3
+  min_score: 0                  # drop results scoring below this
+  diversity: 0                  # 0 (off) to 1: prefer varied results over near-duplicates
+
+# Score modifiers applied after ranking, off by default. Uncomment the
+# suggested values to favor recent memories, key categories and the
+# current project.
+scoring:
+  half_life_days:               # age at which scores halve; 0 = no decay
+    # context: 180
+    # learning: 365
+    # bug: 365
+  category_boost:               # score multiplier; 1 = no change
+    # decision: 1.2
+    # bug: 1.1
+    # pattern: 1.1
+    # context: 0.9
+  project_boost: 1              # memories from the current project; suggested: 1.2
+
+# Optional reranker that rescores the top search candidates.
+# Failures fall back to the fused ranking.
+rerank:
+  provider: none                # none | tei | jina | cohere | exec
+  # base_url: http://localhost:8080
+  # model: jina-reranker-v2-base-multilingual
+  # api_key: ...               # for jina/cohere
+  # command: [python3, rerank.py]  # exec: JSON {query, documents} on stdin, {scores} on stdout
+  top_n: 20                     # candidates passed to the reranker
 `
 
 // Command implements `memory config`.
@@ -46,6 +117,7 @@ func New(ctx *shared.Context) *Command {
 	}
 	c.cmd.AddCommand(
 		newConfigInit(ctx),
+		newMigrateKey(ctx),
 		newSetHome(ctx),
 		newClearHome(ctx),
 	)
@@ -65,20 +137,90 @@ func (c *Command) runShow(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	emb := map[string]any{
+		"provider":        cfg.Embedding.Provider,
+		"model":           cfg.Embedding.Model,
+		"base_url":        cfg.Embedding.BaseURL,
+		"api_key":         redactAPIKey(cfg.Embedding.APIKey),
+		"api_key_env":     cfg.Embedding.APIKeyEnv,
+		"api_key_cmd":     cfg.Embedding.APIKeyCmd,
+		"command":         cfg.Embedding.Command,
+		"deployment":      cfg.Embedding.Deployment,
+		"api_version":     cfg.Embedding.APIVersion,
+		"timeout":         cfg.Embedding.Timeout.String(),
+		"retries":         cfg.Embedding.Retries,
+		"headers":         redactHeaders(cfg.Embedding.Headers),
+		"proxy":           cfg.Embedding.Proxy,
+		"ca_cert":         cfg.Embedding.CACert,
+		"chunk_size":      cfg.Embedding.ChunkSize,
+		"chunk_overlap":   cfg.Embedding.ChunkOverlap,
+		"chunk_aggregate": cfg.Embedding.ChunkAggregate,
+		"distance_metric": cfg.Embedding.DistanceMetric,
+		"dimensions":      cfg.Embedding.Dimensions,
+		"quantization":    cfg.Embedding.Quantization,
+		"rescore":         cfg.Embedding.Rescore,
+		"batch_size":      cfg.Embedding.BatchSize,
+		"concurrency":     cfg.Embedding.Concurrency,
+		"auto_reindex":    cfg.Embedding.AutoReindex,
+	}
+	// Show the templates the configured model is embedded with, built-in
+	// or configured.
+	if t := embeddings.PrefixesFor(cfg); t != (config.PrefixTemplates{}) {
+		emb["prefixes"] = map[string]config.PrefixTemplates{cfg.Embedding.Model: t}
+	}
+	if len(cfg.Embedding.Fallbacks) > 0 {
+		fallbacks := make([]map[string]any, len(cfg.Embedding.Fallbacks))
+		for i, fb := range cfg.Embedding.Fallbacks {
+			fallbacks[i] = map[string]any{
+				"provider":    fb.Provider,
+				"model":       fb.Model,
+				"base_url":    fb.BaseURL,
+				"api_key":     redactAPIKey(fb.APIKey),
+				"api_key_env": fb.APIKeyEnv,
+				"api_key_cmd": fb.APIKeyCmd,
+				"command":     fb.Command,
+				"deployment":  fb.Deployment,
+				"api_version": fb.APIVersion,
+				"timeout":     fb.Timeout.String(),
+				"retries":     fb.Retries,
+				"headers":     redactHeaders(fb.Headers),
+				"proxy":       fb.Proxy,
+				"ca_cert":     fb.CACert,
+				"dimensions":  fb.Dimensions,
+			}
+		}
+		emb["fallbacks"] = fallbacks
+	}
 	data := map[string]any{
-		"embedding": map[string]any{
-			"provider": cfg.Embedding.Provider,
-			"model":    cfg.Embedding.Model,
-			"base_url": cfg.Embedding.BaseURL,
-			"api_key":  redactAPIKey(cfg.Embedding.APIKey),
-		},
+		"embedding": emb,
 		"context": map[string]any{
 			"semantic":     cfg.Context.Semantic,
 			"topup_recent": cfg.Context.TopupRecent,
 		},
+		"search":  cfg.Search,
+		"scoring": cfg.Scoring,
+		"rerank": map[string]any{
+			"provider": cfg.Rerank.Provider,
+			"model":    cfg.Rerank.Model,
+			"base_url": cfg.Rerank.BaseURL,
+			"api_key":  redactAPIKey(cfg.Rerank.APIKey),
+			"command":  cfg.Rerank.Command,
+			"top_n":    cfg.Rerank.TopN,
+		},
 		"memory_home":        home,
 		"memory_home_source": source,
 	}
+	warnLiteralKeys(cmd, cfg)
+	if st, ok, err := vectorStatus(home); err != nil {
+		return err
+	} else if ok {
+		vectors := map[string]any{"model": st.Model, "stale": st.Stale}
+		if st.Stale {
+			vectors["warning"] = st.Warning
+			fmt.Fprintf(cmd.ErrOrStderr(), "WARNING: %s.\n", st.Warning)
+		}
+		data["vectors"] = vectors
+	}
 	b, err := yaml.Marshal(data)
 	if err != nil {
 		return err
@@ -87,12 +229,52 @@ func (c *Command) runShow(cmd *cobra.Command, _ []string) error {
 	return nil
 }
 
+// vectorStatus reports whether the vectors in the index under home fit the
+// configured embedding model; ok is false when there is no index yet.
+func vectorStatus(home string) (st models.VectorStatus, ok bool, err error) {
+	if _, err := os.Stat(filepath.Join(home, "index.db")); err != nil {
+		return st, false, nil
+	}
+	svc, err := service.New(home)
+	if err != nil {
+		return st, false, err
+	}
+	defer svc.Close()
+	return svc.VectorStatus(), true, nil
+}
+
 // ---------------------------------------------------------------------------
 // config init
 // ---------------------------------------------------------------------------
 
+// initModels are the models config init selects for each provider when
+// --model is not given.
+var initModels = map[string]string{
+	"ollama":       "nomic-embed-text",
+	"openai":       "text-embedding-3-small",
+	"openrouter":   "openai/text-embedding-3-small",
+	"azure-openai": "text-embedding-3-small",
+	"cohere":       "embed-english-v3.0",
+	"tei":          "BAAI/bge-small-en-v1.5",
+	"local":        "embeddings",
+	"exec":         "custom",
+}
+
+// apiKeyEnvs are the environment variables config init and migrate-key
+// read each provider's API key from, following the providers' own tools.
+var apiKeyEnvs = map[string]string{
+	"openai":       "OPENAI_API_KEY",
+	"openrouter":   "OPENROUTER_API_KEY",
+	"azure-openai": "AZURE_OPENAI_API_KEY",
+	"cohere":       "COHERE_API_KEY",
+}
+
+// defaultAPIKeyEnv is the variable migrate-key uses for other providers.
+const defaultAPIKeyEnv = "EMBEDDING_API_KEY"
+
 func newConfigInit(ctx *shared.Context) *cobra.Command {
 	var force bool
+	var provider, model string
 	cmd := &cobra.Command{
 		Use:   "init",
 		Short: "Generate a starter config.yaml",
@@ -103,26 +285,291 @@ func newConfigInit(ctx *shared.Context) *cobra.Command {
 			}
 			cfgPath := filepath.Join(home, "config.yaml")
 			out := cmd.OutOrStdout()
+			def, ok := initModels[provider]
+			if !ok {
+				return fmt.Errorf("unknown embedding provider: %s", provider)
+			}
+			if model == "" {
+				model = def
+			}
 			if _, err := os.Stat(cfgPath); err == nil && !force {
 				fmt.Fprintf(out, "Config already exists at %s\n", cfgPath)
 				fmt.Fprintln(out, "Use --force to overwrite.")
+				if cfg, err := config.Load(cfgPath); err == nil {
+					warnLiteralKeys(cmd, cfg)
+				}
 				return nil
 			}
 			if err := os.MkdirAll(home, 0o755); err != nil {
 				return err
 			}
-			if err := os.WriteFile(cfgPath, []byte(configTemplate), 0o600); err != nil {
+			if err := os.WriteFile(cfgPath, []byte(initConfig(provider, model)), 0o600); err != nil {
 				return err
 			}
 			fmt.Fprintf(out, "Created %s\n", cfgPath)
+			if provider == "local" && !filepath.IsAbs(model) {
+				model = filepath.Join(home, model)
+			}
+			if provider == "local" {
+				if _, err := os.Stat(model); err != nil {
+					fmt.Fprintf(out, "Put a GloVe or word2vec text file, or a model2vec model directory, at %s.\n", model)
+				}
+				return nil
+			}
+			if env, ok := apiKeyEnvs[provider]; ok {
+				fmt.Fprintf(out, "Set %s to your API key, or edit api_key_env or api_key_cmd in the file.\n", env)
+				return nil
+			}
 			fmt.Fprintln(out, "Edit the file to configure your embedding provider.")
 			return nil
 		},
 	}
 	cmd.Flags().BoolVar(&force, "force", false, "Overwrite existing config")
+	cmd.Flags().StringVar(&provider, "provider", "ollama", "Embedding provider to configure")
+	cmd.Flags().StringVar(&model, "model", "", "Embedding model; for local, the path of the embedding table (default: the provider's usual model)")
+	return cmd
+}
+
+// ---------------------------------------------------------------------------
+// config migrate-key
+// ---------------------------------------------------------------------------
+
+func newMigrateKey(ctx *shared.Context) *cobra.Command {
+	var env, keyCmd string
+	cmd := &cobra.Command{
+		Use:   "migrate-key",
+		Short: "Move a literal embedding API key out of config.yaml",
+		Long: `Replaces each literal api_key under embedding (and its fallbacks) in
+config.yaml with api_key_env, naming the environment variable to read the
+key from, or with api_key_cmd, a command printing it. With --cmd the
+command must already print the key. With --env, when the variable does not
+hold the key yet, the export line to add to your shell profile is printed.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			home := ctx.MemoryHome
+			if home == "" {
+				home = config.GetMemoryHome()
+			}
+			return migrateKeys(cmd, filepath.Join(home, "config.yaml"), env, keyCmd)
+		},
+	}
+	cmd.Flags().StringVar(&env, "env", "", "Environment variable to read the key from (default: the provider's usual one)")
+	cmd.Flags().StringVar(&keyCmd, "cmd", "", "Command printing the key, e.g. \"pass show openai\"")
+	cmd.MarkFlagsMutuallyExclusive("env", "cmd")
 	return cmd
 }
 
+// literalKey is an api_key set in config.yaml.
+type literalKey struct {
+	path     string // e.g. embedding.fallbacks[0].api_key
+	provider string
+	key      *yaml.Node
+	value    *yaml.Node
+}
+
+// migrateKeys rewrites the literal keys in the config file at path as
+// described on the migrate-key command.
+func migrateKeys(cmd *cobra.Command, path, env, keyCmd string) error {
+	out := cmd.OutOrStdout()
+	data, err := os.ReadFile(path) // #nosec G304 -- path is the config file under the memory home
+	if err != nil {
+		return fmt.Errorf("config migrate-key: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("config migrate-key: %w", err)
+	}
+	keys := findLiteralKeys(&doc)
+	if len(keys) == 0 {
+		fmt.Fprintf(out, "No literal API key in %s.\n", path)
+		return nil
+	}
+	if (env != "" || keyCmd != "") && len(keys) > 1 {
+		return fmt.Errorf("config migrate-key: --env and --cmd apply to a single key, but %s has %d; run without them", path, len(keys))
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	var exports []string
+	for _, k := range keys {
+		name, value := "api_key_env", env
+		switch {
+		case keyCmd != "":
+			name, value = "api_key_cmd", keyCmd
+			got, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{APIKeyCmd: strings.Fields(keyCmd)})
+			if err != nil {
+				return fmt.Errorf("config migrate-key: %w", err)
+			}
+			if got != k.value.Value {
+				return fmt.Errorf("config migrate-key: %q does not print the key in %s; store the key where it reads it first", keyCmd, k.path)
+			}
+		case value == "":
+			value = defaultAPIKeyEnv
+			if e, ok := apiKeyEnvs[k.provider]; ok {
+				value = e
+			}
+		}
+		if name == "api_key_env" && os.Getenv(value) != k.value.Value {
+			exports = append(exports, fmt.Sprintf("export %s=%s", value, shellQuote(k.value.Value)))
+		}
+		if err := replaceScalar(lines, k, name, yamlScalar(value)); err != nil {
+			return fmt.Errorf("config migrate-key: %s: %w", k.path, err)
+		}
+		fmt.Fprintf(out, "Replaced %s with %s: %s\n", k.path, name, value)
+	}
+
+	if err := writeFileAtomic(path, []byte(strings.Join(lines, ""))); err != nil {
+		return fmt.Errorf("config migrate-key: %w", err)
+	}
+	if len(exports) > 0 {
+		fmt.Fprintln(out, "Add this to your shell profile (outside the synced memory home):")
+		for _, e := range exports {
+			fmt.Fprintln(out, "  "+e)
+		}
+	}
+	return nil
+}
+
+// findLiteralKeys returns the non-empty api_key entries of embedding and
+// its fallbacks in doc.
+func findLiteralKeys(doc *yaml.Node) []literalKey {
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	emb := mappingValue(doc.Content[0], "embedding")
+	if emb == nil || emb.Kind != yaml.MappingNode {
+		return nil
+	}
+	var keys []literalKey
+	add := func(m *yaml.Node, path string) {
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			if k, v := m.Content[i], m.Content[i+1]; k.Value == "api_key" && v.Kind == yaml.ScalarNode && v.Value != "" {
+				provider := ""
+				if p := mappingValue(m, "provider"); p != nil {
+					provider = p.Value
+				}
+				keys = append(keys, literalKey{path: path + ".api_key", provider: provider, key: k, value: v})
+			}
+		}
+	}
+	add(emb, "embedding")
+	if fbs := mappingValue(emb, "fallbacks"); fbs != nil && fbs.Kind == yaml.SequenceNode {
+		for i, fb := range fbs.Content {
+			if fb.Kind == yaml.MappingNode {
+				add(fb, fmt.Sprintf("embedding.fallbacks[%d]", i))
+			}
+		}
+	}
+	return keys
+}
+
+// mappingValue returns the value of key in the mapping m, or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// replaceScalar rewrites the line of k in lines to set name to value,
+// keeping its indentation and trailing comment.
+func replaceScalar(lines []string, k literalKey, name, value string) error {
+	if k.key.Line != k.value.Line || k.value.Line > len(lines) {
+		return errors.New("the key does not fit on one line; edit config.yaml by hand")
+	}
+	line := lines[k.value.Line-1]
+	start, valueStart := k.key.Column-1, k.value.Column-1
+	end, err := scalarEnd(line, valueStart, k.value)
+	if err != nil {
+		return err
+	}
+	lines[k.value.Line-1] = line[:start] + name + ": " + value + line[end:]
+	return nil
+}
+
+// scalarEnd returns the offset in line just past the scalar v starting at
+// offset start.
+func scalarEnd(line string, start int, v *yaml.Node) (int, error) {
+	switch v.Style {
+	case yaml.DoubleQuotedStyle:
+		for i := start + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1, nil
+			}
+		}
+	case yaml.SingleQuotedStyle:
+		for i := start + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1, nil
+			}
+		}
+	case 0:
+		end := len(strings.TrimRight(line, "\r\n"))
+		if i := strings.Index(line[start:], " #"); i >= 0 {
+			end = start + i
+		}
+		return start + len(strings.TrimRight(line[start:end], " \t")), nil
+	}
+	return 0, errors.New("unexpected value style; edit config.yaml by hand")
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// writeFileAtomic replaces the file at path with data, keeping its
+// permissions.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o600)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// warnLiteralKeys warns on stderr about API keys stored in cfg in plain
+// text.
+func warnLiteralKeys(cmd *cobra.Command, cfg *config.MemoryConfig) {
+	var paths []string
+	if cfg.Embedding.APIKey != "" {
+		paths = append(paths, "embedding.api_key")
+	}
+	for i, fb := range cfg.Embedding.Fallbacks {
+		if fb.APIKey != "" {
+			paths = append(paths, fmt.Sprintf("embedding.fallbacks[%d].api_key", i))
+		}
+	}
+	if len(paths) > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "WARNING: plain-text API key in config.yaml (%s); run 'memory config migrate-key' to read it from an environment variable or a command instead.\n", strings.Join(paths, ", "))
+	}
+}
+
 // ---------------------------------------------------------------------------
 // config set-home
 // ---------------------------------------------------------------------------
@@ -173,9 +620,43 @@ func newClearHome(_ *shared.Context) *cobra.Command {
 	}
 }
 
+// initConfig returns the config template with the given embedding provider
+// and model selected.
+func initConfig(provider, model string) string {
+	out := configTemplate
+	out = strings.Replace(out, "  provider: ollama              #", fmt.Sprintf("  provider: %-19s #", yamlScalar(provider)), 1)
+	out = strings.Replace(out, "  model: nomic-embed-text       #", fmt.Sprintf("  model: %-22s #", yamlScalar(model)), 1)
+	if env, ok := apiKeyEnvs[provider]; ok {
+		out = strings.Replace(out, "  # api_key_env: OPENAI_API_KEY  #", fmt.Sprintf("  api_key_env: %-16s #", env), 1)
+	}
+	return out
+}
+
+// yamlScalar renders s as a YAML scalar, quoted only when it needs to be.
+func yamlScalar(s string) string {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return strconv.Quote(s)
+	}
+	return strings.TrimSpace(string(b))
+}
+
 func redactAPIKey(key string) string {
 	if key != "" {
 		return "<redacted>"
 	}
 	return ""
 }
+
+// redactHeaders hides the values of configured headers, which often carry
+// credentials.
+func redactHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = redactAPIKey(v)
+	}
+	return out
+}
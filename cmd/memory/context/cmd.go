@@ -27,6 +27,7 @@ type Command struct {
 	semanticMode string
 	showConfig   bool
 	outputFormat string
+	pager        shared.Pager
 }
 
 // New creates the context command.
@@ -48,6 +49,7 @@ func New(ctx *shared.Context) *Command {
 
 	// --semantic controls the search mode: always|never|auto.
 	f.StringVar(&c.semanticMode, "semantic", "", "Force semantic search (always|never|auto)")
+	c.pager.AddFlags(c.cmd)
 
 	return c
 }
@@ -99,15 +101,21 @@ func (c *Command) run(cmd *cobra.Command, _ []string) error {
 	defer svc.Close()
 
 	topupRecent := svc.Config.Context.TopupRecent
-	results, total, err := svc.GetContext(
-		cmd.Context(),
-		c.limit,
-		projectName,
-		c.source,
-		c.query,
-		c.semanticMode,
-		topupRecent,
-	)
+	var total int
+	results, next, err := shared.FetchPage(c.pager, func(cur string) ([]map[string]any, string, error) {
+		page, n, next, err := svc.GetContext(
+			cmd.Context(),
+			c.limit,
+			projectName,
+			c.source,
+			c.query,
+			c.semanticMode,
+			cur,
+			topupRecent,
+		)
+		total = n
+		return page, next, err
+	})
 	if err != nil {
 		return err
 	}
@@ -151,9 +159,17 @@ func (c *Command) run(cmd *cobra.Command, _ []string) error {
 			tagsPart = " [" + joinStrings(tagsList, ",") + "]"
 		}
 
-		fmt.Fprintf(out, "- [%s] %s%s%s\n", dateDisplay, title, catPart, tagsPart)
+		stalePart := ""
+		if stale, _ := r["possibly_stale"].(bool); stale {
+			stalePart = " (possibly stale)"
+		}
+
+		fmt.Fprintf(out, "- [%s] %s%s%s%s\n", dateDisplay, title, catPart, tagsPart, stalePart)
 	}
 
+	if hint := c.pager.NextHint(next); hint != "" {
+		fmt.Fprintln(out, hint)
+	}
 	if c.outputFormat == "agents-md" {
 		fmt.Fprintln(out)
 	}
@@ -0,0 +1,54 @@
+// Package doctorcmd implements the `memory doctor` command.
+package doctorcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/service"
+)
+
+// Command implements `memory doctor`.
+type Command struct {
+	ctx *shared.Context
+	cmd *cobra.Command
+}
+
+// New creates the doctor command.
+func New(ctx *shared.Context) *Command {
+	c := &Command{ctx: ctx}
+	c.cmd = &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the embedding provider and vector index",
+		RunE:  c.run,
+	}
+	return c
+}
+
+// Cmd returns the cobra command.
+func (c *Command) Cmd() *cobra.Command { return c.cmd }
+
+func (c *Command) run(cmd *cobra.Command, _ []string) error {
+	svc, err := service.New(c.ctx.MemoryHome)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	out := cmd.OutOrStdout()
+	problems := 0
+	for _, chk := range svc.Doctor(cmd.Context()) {
+		mark := "ok"
+		if !chk.OK {
+			mark = "!!"
+			problems++
+		}
+		fmt.Fprintf(out, "[%s] %s: %s\n", mark, chk.Name, chk.Detail)
+	}
+	if problems > 0 {
+		return fmt.Errorf("%d problem(s) found", problems)
+	}
+	return nil
+}
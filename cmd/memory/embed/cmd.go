@@ -0,0 +1,66 @@
+// Package embedcmd implements the `memory embed` command.
+package embedcmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/service"
+)
+
+// Command implements `memory embed`.
+type Command struct {
+	ctx *shared.Context
+	cmd *cobra.Command
+
+	pending bool
+	limit   int
+}
+
+// New creates the embed command.
+func New(ctx *shared.Context) *Command {
+	c := &Command{ctx: ctx}
+	c.cmd = &cobra.Command{
+		Use:   "embed --pending",
+		Short: "Embed memories saved without vectors",
+		Long: `Embed the memories whose vectors could not be computed when they were
+saved, e.g. because the embedding provider was down. Memories without a
+vector that were never queued, such as those saved while embedding was
+disabled, are queued too.
+
+Queued memories are also embedded when the MCP server starts and after
+each successful embedding. The run stops at the first memory that fails,
+which stays queued for the next run.`,
+		RunE: c.run,
+	}
+	c.cmd.Flags().BoolVar(&c.pending, "pending", false, "Embed the memories queued for embedding")
+	c.cmd.Flags().IntVar(&c.limit, "limit", 0, "Embed at most this many memories (0 for all)")
+	return c
+}
+
+// Cmd returns the cobra command.
+func (c *Command) Cmd() *cobra.Command { return c.cmd }
+
+func (c *Command) run(cmd *cobra.Command, _ []string) error {
+	if !c.pending {
+		return errors.New("nothing to embed; pass --pending")
+	}
+	svc, err := service.New(c.ctx.MemoryHome)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	res, err := svc.EmbedPending(cmd.Context(), c.limit)
+	if res != nil {
+		out := cmd.OutOrStdout()
+		if res.Queued > 0 {
+			fmt.Fprintf(out, "Queued %d memories without a vector.\n", res.Queued)
+		}
+		fmt.Fprintf(out, "Embedded %d memories, %d still pending.\n", res.Embedded, res.Pending)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+// Package forfilecmd implements the `memory for-file` command.
+package forfilecmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/service"
+)
+
+// Command implements `memory for-file`.
+type Command struct {
+	ctx *shared.Context
+	cmd *cobra.Command
+
+	limit   int
+	project bool
+	source  string
+}
+
+// New creates the for-file command.
+func New(ctx *shared.Context) *Command {
+	c := &Command{ctx: ctx}
+	c.cmd = &cobra.Command{
+		Use:   "for-file <path...>",
+		Short: "List memories related to files (globs and directory prefixes supported)",
+		Long: `List memories whose related files match the given paths.
+
+Paths may be repo-relative or absolute, glob patterns (quote them so the shell
+does not expand them), or directories, which match every file beneath them.
+Results are ranked by category (decisions first) and recency.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: c.run,
+	}
+
+	f := c.cmd.Flags()
+	f.IntVar(&c.limit, "limit", 10, "Maximum number of results")
+	f.BoolVar(&c.project, "project", false, "Filter to current project (current directory name)")
+	f.StringVar(&c.source, "source", "", "Filter by source")
+
+	return c
+}
+
+// Cmd returns the cobra command.
+func (c *Command) Cmd() *cobra.Command { return c.cmd }
+
+func (c *Command) run(cmd *cobra.Command, args []string) error {
+	var projectName string
+	if c.project {
+		if cwd, err := os.Getwd(); err == nil {
+			projectName = filepath.Base(cwd)
+		}
+	}
+
+	svc, err := service.New(c.ctx.MemoryHome)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	results, err := svc.ForFiles(args, c.limit, projectName, c.source)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(results) == 0 {
+		fmt.Fprintln(out, "No memories found for these files.")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n Memories for files (%d found) \n", len(results))
+
+	for i, r := range results {
+		id, _ := r["id"].(string)
+		title, _ := r["title"].(string)
+		what, _ := r["what"].(string)
+		category, _ := r["category"].(string)
+		project, _ := r["project"].(string)
+		matched, _ := r["matched_files"].([]string)
+
+		createdAt, _ := r["created_at"].(string)
+		if len(createdAt) > 10 {
+			createdAt = createdAt[:10]
+		}
+
+		fmt.Fprintf(out, "\n [%d] %s\n", i+1, title)
+		fmt.Fprintf(out, "     %s | %s | %s\n", category, createdAt, project)
+		fmt.Fprintf(out, "     Files: %s\n", strings.Join(matched, ", "))
+		fmt.Fprintf(out, "     What: %s\n", what)
+		if why, _ := r["why"].(string); why != "" {
+			fmt.Fprintf(out, "     Why: %s\n", why)
+		}
+		if hasDetails, _ := r["has_details"].(int64); hasDetails != 0 && len(id) >= 12 {
+			fmt.Fprintf(out, "\n     Details: available (use `memory details %s`)\n", id[:12])
+		}
+	}
+	return nil
+}
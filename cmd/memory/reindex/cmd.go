@@ -3,6 +3,7 @@ package reindexcmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -14,6 +15,8 @@ import (
 type Command struct {
 	ctx *shared.Context
 	cmd *cobra.Command
+
+	changedOnly bool
 }
 
 // New creates the reindex command.
@@ -22,8 +25,17 @@ func New(ctx *shared.Context) *Command {
 	c.cmd = &cobra.Command{
 		Use:   "reindex",
 		Short: "Rebuild vector index with current embedding provider",
-		RunE:  c.run,
+		Long: `Rebuild the vector index with the current embedding provider.
+
+The new vectors are built next to the current ones, which keep serving
+searches until the rebuild completes. An interrupted reindex picks up where
+it stopped when run again with the same provider and model.
+
+With --changed-only, memories whose content and embedding model are
+unchanged since they were last embedded keep their vectors.`,
+		RunE: c.run,
 	}
+	c.cmd.Flags().BoolVar(&c.changedOnly, "changed-only", false, "Only re-embed memories whose content or model changed")
 	return c
 }
 
@@ -51,8 +63,9 @@ func (c *Command) run(cmd *cobra.Command, _ []string) error {
 	fmt.Fprintf(out, "Reindexing %d memories with %s/%s...\n",
 		total, svc.Config.Embedding.Provider, svc.Config.Embedding.Model)
 
-	result, err := svc.Reindex(cmd.Context(), func(current, count int) {
-		fmt.Fprintf(out, "\r  %d/%d", current, count)
+	start := time.Now()
+	result, err := svc.Reindex(cmd.Context(), c.changedOnly, func(current, count int) {
+		fmt.Fprintf(out, "\r  %s", progressLine(current, count, time.Since(start)))
 		if current == count {
 			fmt.Fprintln(out)
 		}
@@ -61,7 +74,21 @@ func (c *Command) run(cmd *cobra.Command, _ []string) error {
 		return err
 	}
 
-	fmt.Fprintf(out, "Re-indexed %d memories with %s (%d dims)\n",
-		result.Count, result.Model, result.Dim)
+	fmt.Fprintf(out, "Re-indexed %d memories with %s (%d dims): %d embedded, %d unchanged in %s\n",
+		result.Count, result.Model, result.Dim, result.Embedded, result.Reused,
+		time.Since(start).Round(time.Second))
 	return nil
 }
+
+// progressLine reports how many of count memories are embedded after
+// elapsed, the throughput so far and the estimated time left.
+func progressLine(current, count int, elapsed time.Duration) string {
+	line := fmt.Sprintf("%d/%d embedded", current, count)
+	if secs := elapsed.Seconds(); current > 0 && secs > 0 {
+		rate := float64(current) / secs
+		eta := time.Duration(float64(count-current) / rate * float64(time.Second))
+		line += fmt.Sprintf(", %.1f/s, ETA %s", rate, eta.Round(time.Second))
+	}
+	// Pad to overwrite a longer previous line.
+	return fmt.Sprintf("%-48s", line)
+}
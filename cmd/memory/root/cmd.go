@@ -8,6 +8,9 @@ import (
 	contextcmd "github.com/go-ports/echovault/cmd/memory/context"
 	deletecmd "github.com/go-ports/echovault/cmd/memory/delete"
 	detailscmd "github.com/go-ports/echovault/cmd/memory/details"
+	doctorcmd "github.com/go-ports/echovault/cmd/memory/doctor"
+	embedcmd "github.com/go-ports/echovault/cmd/memory/embed"
+	forfilecmd "github.com/go-ports/echovault/cmd/memory/forfile"
 	initcmd "github.com/go-ports/echovault/cmd/memory/init"
 	mcpcmd "github.com/go-ports/echovault/cmd/memory/mcp"
 	reindexcmd "github.com/go-ports/echovault/cmd/memory/reindex"
@@ -16,6 +19,8 @@ import (
 	sessionscmd "github.com/go-ports/echovault/cmd/memory/sessions"
 	setupcmd "github.com/go-ports/echovault/cmd/memory/setup"
 	"github.com/go-ports/echovault/cmd/memory/shared"
+	stalecmd "github.com/go-ports/echovault/cmd/memory/stale"
+	statscmd "github.com/go-ports/echovault/cmd/memory/stats"
 	uninstallcmd "github.com/go-ports/echovault/cmd/memory/uninstall"
 )
 
@@ -41,10 +46,15 @@ func New() *cobra.Command {
 		savecmd.New(ctx).Cmd(),
 		searchcmd.New(ctx).Cmd(),
 		detailscmd.New(ctx).Cmd(),
+		forfilecmd.New(ctx).Cmd(),
 		deletecmd.New(ctx).Cmd(),
 		contextcmd.New(ctx).Cmd(),
 		reindexcmd.New(ctx).Cmd(),
+		embedcmd.New(ctx).Cmd(),
+		doctorcmd.New(ctx).Cmd(),
+		statscmd.New(ctx).Cmd(),
 		sessionscmd.New(ctx).Cmd(),
+		stalecmd.New(ctx).Cmd(),
 		configcmd.New(ctx).Cmd(),
 		setupcmd.New(ctx).Cmd(),
 		uninstallcmd.New(ctx).Cmd(),
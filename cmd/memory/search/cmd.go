@@ -2,13 +2,17 @@
 package searchcmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/search"
 	"github.com/go-ports/echovault/internal/service"
 )
 
@@ -17,25 +21,70 @@ type Command struct {
 	ctx *shared.Context
 	cmd *cobra.Command
 
-	limit   int
-	project bool
-	source  string
+	limit    int
+	project  bool
+	source   string
+	category string
+	tag      string
+	since    string
+	until    string
+	settings []string
+	explain  bool
+	pager    shared.Pager
 }
 
 // New creates the search command.
 func New(ctx *shared.Context) *Command {
 	c := &Command{ctx: ctx}
 	c.cmd = &cobra.Command{
-		Use:   "search <query>",
+		Use:   "search [query]",
 		Short: "Search memories using hybrid FTS5 + semantic search",
-		Args:  cobra.ExactArgs(1),
-		RunE:  c.run,
+		Long: `Search memories using hybrid FTS5 + semantic search.
+
+Bare terms match any word (as a prefix) in the title, summary fields or
+details; details matches rank lower. The query also accepts:
+
+  "connection pool"       exact phrase
+  redis AND timeout       require both terms; OR and (grouping) also work
+  -redis                  exclude memories containing the word (-redis* for any word starting with it)
+  title:pool why:race     restrict a term to the title, what, why, impact or details field
+  category:bug,decision   filter by category (likewise tag:, source:, project:)
+  since:2025-01 until:30d created date range (YYYY, YYYY-MM, YYYY-MM-DD or 7d/2w/6m/1y ago)
+  has:details             only memories with details (or has:files)
+
+Filters narrow the whole query wherever they appear, so they cannot be
+combined with OR; list alternative values after a comma instead.
+
+The --category, --tag, --since and --until flags add the same filters.
+
+--set overrides a setting of the search: section of config.yaml for this
+query: mode (tiered|hybrid), fusion (weighted|rrf), fts_weight,
+vector_weight, rrf_k, min_fts, min_score or diversity.
+
+--explain shows how each score was computed: the keyword (BM25) and vector
+(distance) rank and raw score, the normalized value and weight fusion gave
+each, any reranker score, the recency/category/project multipliers and the
+query terms the memory matched.`,
+		Example: `  memory search "category:bug tag:auth since:2025-01"
+  memory search 'title:"rate limit" -redis'
+  memory search --category decision --since 30d
+  memory search "cache invalidation" --set fusion=rrf --set min_score=0.01
+  memory search "connection pool" --explain`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: c.run,
 	}
 
 	f := c.cmd.Flags()
 	f.IntVar(&c.limit, "limit", 5, "Maximum number of results")
 	f.BoolVar(&c.project, "project", false, "Filter to current project (current directory name)")
 	f.StringVar(&c.source, "source", "", "Filter by source")
+	f.StringVar(&c.category, "category", "", "Filter by category (comma-separated for any of several)")
+	f.StringVar(&c.tag, "tag", "", "Filter by tag (comma-separated for any of several)")
+	f.StringVar(&c.since, "since", "", "Only memories created on or after this date (YYYY[-MM[-DD]] or e.g. 30d)")
+	f.StringVar(&c.until, "until", "", "Only memories created up to the end of this date (YYYY[-MM[-DD]] or e.g. 30d)")
+	f.StringArrayVar(&c.settings, "set", nil, "Override a search setting for this query (key=value, repeatable)")
+	f.BoolVar(&c.explain, "explain", false, "Show how each result was scored")
+	c.pager.AddFlags(c.cmd)
 
 	return c
 }
@@ -44,7 +93,21 @@ func New(ctx *shared.Context) *Command {
 func (c *Command) Cmd() *cobra.Command { return c.cmd }
 
 func (c *Command) run(cmd *cobra.Command, args []string) error {
-	query := args[0]
+	var terms []string
+	if len(args) > 0 && strings.TrimSpace(args[0]) != "" {
+		terms = append(terms, args[0])
+	}
+	for _, f := range []struct{ field, value string }{
+		{"category", c.category}, {"tag", c.tag}, {"since", c.since}, {"until", c.until},
+	} {
+		if f.value != "" {
+			terms = append(terms, f.field+":"+quoteValue(f.value))
+		}
+	}
+	if len(terms) == 0 {
+		return errors.New("search needs a query or at least one filter flag")
+	}
+	query := strings.Join(terms, " ")
 
 	var projectName string
 	if c.project {
@@ -58,8 +121,19 @@ func (c *Command) run(cmd *cobra.Command, args []string) error {
 		return err
 	}
 	defer svc.Close()
+	for _, kv := range c.settings {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			return fmt.Errorf("--set %q: expected key=value", kv)
+		}
+		if err := svc.Config.Search.Set(strings.TrimSpace(key), value); err != nil {
+			return fmt.Errorf("--set: %w", err)
+		}
+	}
 
-	results, err := svc.Search(cmd.Context(), query, c.limit, projectName, c.source, true)
+	results, next, err := shared.FetchPage(c.pager, func(cur string) ([]search.Result, string, error) {
+		return svc.Search(cmd.Context(), query, c.limit, projectName, c.source, cur, true)
+	})
 	if err != nil {
 		return err
 	}
@@ -71,6 +145,18 @@ func (c *Command) run(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Fprintf(out, "\n Results (%d found) \n", len(results))
+	if c.explain {
+		sc, e := svc.Config.Search, results[0].Explain
+		vectors := "skipped"
+		if e.VectorSearched {
+			vectors = "ran"
+		}
+		mode := sc.Mode
+		if mode != "hybrid" {
+			mode += fmt.Sprintf(", min_fts %d", sc.MinFTS)
+		}
+		fmt.Fprintf(out, " Mode: %s | keyword hits: %d | vector search: %s\n", mode, e.FTSHits, vectors)
+	}
 
 	for i, r := range results {
 		src := ""
@@ -87,18 +173,68 @@ func (c *Command) run(cmd *cobra.Command, args []string) error {
 			createdAt = createdAt[:10]
 		}
 
-		fmt.Fprintf(out, "\n [%d] %s (score: %.2f)\n", i+1, r.Title, r.Score)
+		staleHint := ""
+		if r.PossiblyStale {
+			staleHint = " [possibly stale]"
+		}
+
+		fmt.Fprintf(out, "\n [%d] %s (score: %.2f)%s\n", i+1, r.Title, r.Score, staleHint)
 		fmt.Fprintf(out, "     %s | %s | %s%s\n", r.Category, createdAt, r.Project, src)
 		fmt.Fprintf(out, "     What: %s\n", r.What)
+		if r.Snippet != "" {
+			fmt.Fprintf(out, "     Match: %s\n", strings.Join(strings.Fields(r.Snippet), " "))
+		}
 		if r.Why != "" {
 			fmt.Fprintf(out, "     Why: %s\n", r.Why)
 		}
 		if r.Impact != "" {
 			fmt.Fprintf(out, "     Impact: %s\n", r.Impact)
 		}
+		if c.explain {
+			printExplain(out, r.Score, r.Explain)
+		}
 		if detailsHint != "" {
 			fmt.Fprintln(out, detailsHint)
 		}
 	}
+	if hint := c.pager.NextHint(next); hint != "" {
+		fmt.Fprintf(out, "\n %s\n", hint)
+	}
 	return nil
 }
+
+// printExplain writes the score breakdown of one result.
+func printExplain(out io.Writer, score float64, e search.Explanation) {
+	var sources []string
+	for _, src := range []struct {
+		name, raw string
+		s         *search.SourceScore
+	}{{"keyword", "bm25", e.FTS}, {"vector", "distance", e.Vector}} {
+		if src.s != nil {
+			sources = append(sources, fmt.Sprintf("%s #%d (%s %.3g, normalized %.3g × weight %.3g = %.3g)",
+				src.name, src.s.Rank, src.raw, src.s.Raw, src.s.Normalized, src.s.Weight, src.s.Contribution()))
+		}
+	}
+	if len(sources) == 0 {
+		sources = append(sources, "filter match")
+	}
+	fmt.Fprintf(out, "     Explain: %s = fused %.3g (%s)\n", strings.Join(sources, " + "), e.Fused, e.Fusion)
+	if e.Reranked {
+		fmt.Fprintf(out, "              reranked: %.3g\n", e.Rerank)
+	}
+	if e.Decay != 0 {
+		fmt.Fprintf(out, "              %.3g × decay %.3g × category %.3g × project %.3g = %.3g\n",
+			e.Base, e.Decay, e.CategoryBoost, e.ProjectBoost, score)
+	}
+	if len(e.Terms) > 0 {
+		fmt.Fprintf(out, "              terms: %s\n", strings.Join(e.Terms, ", "))
+	}
+}
+
+// quoteValue wraps a filter value in double quotes when it contains spaces.
+func quoteValue(v string) string {
+	if strings.ContainsAny(v, " \t") {
+		return `"` + strings.ReplaceAll(v, `"`, "") + `"`
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package shared
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// Pager holds the --page and --cursor flags of commands whose results are
+// paged.
+type Pager struct {
+	Page   int
+	Cursor string
+}
+
+// AddFlags registers --page and --cursor on cmd.
+func (p *Pager) AddFlags(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.IntVar(&p.Page, "page", 1, "Page of results to show (--limit results per page)")
+	f.StringVar(&p.Cursor, "cursor", "", "Continue from the cursor printed after a previous page")
+	cmd.MarkFlagsMutuallyExclusive("page", "cursor")
+}
+
+// FetchPage returns the page selected by p, calling fetch with successive
+// cursors. fetch returns one page and the cursor of the next ("" at the end).
+// --page fetches from the start on every run, so unlike --cursor it does not
+// exclude memories saved since the previous page was shown.
+func FetchPage[T any](p Pager, fetch func(cur string) ([]T, string, error)) ([]T, string, error) {
+	if p.Page < 1 {
+		return nil, "", fmt.Errorf("--page must be at least 1, got %d", p.Page)
+	}
+	cur := p.Cursor
+	for page := 1; ; page++ {
+		items, next, err := fetch(cur)
+		if err != nil || page >= p.Page {
+			return items, next, err
+		}
+		if next == "" {
+			return nil, "", nil
+		}
+		cur = next
+	}
+}
+
+// NextHint returns the footer pointing at the next page, or "" when next is
+// empty.
+func (p Pager) NextHint(next string) string {
+	if next == "" {
+		return ""
+	}
+	if p.Cursor != "" {
+		return "More results: rerun with --cursor " + next
+	}
+	return fmt.Sprintf("More results: rerun with --page %d (or --cursor %s)", p.Page+1, next)
+}
@@ -0,0 +1,110 @@
+// Package stalecmd implements the `memory stale` command.
+package stalecmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/filepaths"
+	"github.com/go-ports/echovault/internal/service"
+	"github.com/go-ports/echovault/internal/staleness"
+)
+
+// Command implements `memory stale`.
+type Command struct {
+	ctx *shared.Context
+	cmd *cobra.Command
+
+	project   bool
+	threshold float64
+}
+
+// New creates the stale command.
+func New(ctx *shared.Context) *Command {
+	c := &Command{ctx: ctx}
+	c.cmd = &cobra.Command{
+		Use:   "stale",
+		Short: "Report memories whose related files changed since they were written",
+		Long: `Report memories whose related files were deleted, renamed, or changed
+substantially since the memory was saved.
+
+Each related file is pinned to the git commit that was checked out at save
+time; this command diffs that commit against the working tree of the
+repository containing the current directory. Memories saved outside this
+repository are skipped.`,
+		RunE: c.run,
+	}
+
+	f := c.cmd.Flags()
+	f.BoolVar(&c.project, "project", false, "Filter to current project (current directory name)")
+	f.Float64Var(&c.threshold, "threshold", staleness.DefaultThreshold,
+		"Fraction of a file (or its recorded line range) that must change to count as stale")
+
+	return c
+}
+
+// Cmd returns the cobra command.
+func (c *Command) Cmd() *cobra.Command { return c.cmd }
+
+func (c *Command) run(cmd *cobra.Command, _ []string) error {
+	var projectName string
+	if c.project {
+		if cwd, err := os.Getwd(); err == nil {
+			projectName = filepath.Base(cwd)
+		}
+	}
+
+	svc, err := service.New(c.ctx.MemoryHome)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	stale, err := svc.Stale(cmd.Context(), projectName, c.threshold)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	if len(stale) == 0 {
+		fmt.Fprintln(out, "No stale memories found.")
+		return nil
+	}
+
+	fmt.Fprintf(out, "\n Stale memories (%d found) \n", len(stale))
+
+	for i, m := range stale {
+		createdAt := m.CreatedAt
+		if len(createdAt) > 10 {
+			createdAt = createdAt[:10]
+		}
+		id := m.ID
+		if len(id) > 12 {
+			id = id[:12]
+		}
+
+		fmt.Fprintf(out, "\n [%d] %s (id: %s)\n", i+1, m.Title, id)
+		fmt.Fprintf(out, "     %s | %s | %s\n", m.Category, createdAt, m.Project)
+		for _, f := range m.Files {
+			ref := filepaths.FormatRef(f.Path, f.LineStart, f.LineEnd)
+			sha := f.CommitSHA
+			if len(sha) > 8 {
+				sha = sha[:8]
+			}
+			switch f.State {
+			case staleness.StateRenamed:
+				fmt.Fprintf(out, "     %s: renamed to %s (since %s)\n", ref, f.RenamedTo, sha)
+			case staleness.StateDeleted:
+				fmt.Fprintf(out, "     %s: deleted (since %s)\n", ref, sha)
+			default:
+				fmt.Fprintf(out, "     %s: %.0f%% changed (since %s)\n", ref, f.ChangeRatio*100, sha)
+			}
+		}
+	}
+	fmt.Fprintln(out, "\nReview these with `memory details <id>` and update them with memory_replace or `memory delete`.")
+	return nil
+}
@@ -0,0 +1,85 @@
+// Package statscmd implements the `memory stats` command.
+package statscmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/go-ports/echovault/cmd/memory/shared"
+	"github.com/go-ports/echovault/internal/service"
+)
+
+// Command implements `memory stats`.
+type Command struct {
+	ctx *shared.Context
+	cmd *cobra.Command
+}
+
+// New creates the stats command.
+func New(ctx *shared.Context) *Command {
+	c := &Command{ctx: ctx}
+	c.cmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show index size, vector storage and coverage",
+		RunE:  c.run,
+	}
+	return c
+}
+
+// Cmd returns the cobra command.
+func (c *Command) Cmd() *cobra.Command { return c.cmd }
+
+func (c *Command) run(cmd *cobra.Command, _ []string) error {
+	svc, err := service.New(c.ctx.MemoryHome)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+
+	st, err := svc.Stats()
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Memories:     %d\n", st.Memories)
+	if st.Dim == 0 {
+		fmt.Fprintln(out, "Vectors:      none")
+	} else {
+		fmt.Fprintf(out, "Vectors:      %d memories, %d detail chunks\n", st.Vectors, st.ChunkVectors)
+		fmt.Fprintf(out, "Model:        %s (%d dims)\n", st.Model, st.Dim)
+		fmt.Fprintf(out, "Storage:      %s, %s\n", st.Metric, st.Storage)
+		fmt.Fprintf(out, "Vector data:  %s (estimated)\n", formatBytes(st.VectorBytes))
+	}
+	if st.Memories > 0 && (st.Dim > 0 || st.Pending > 0) {
+		fmt.Fprintf(out, "Coverage:     %s\n", coverage(st.Vectors, st.Memories, st.Pending))
+	}
+	fmt.Fprintf(out, "Index size:   %s\n", formatBytes(st.IndexBytes))
+	return nil
+}
+
+// coverage describes how many of memories have a vector, e.g.
+// "97% embedded", with the number still queued for embedding. The percentage is rounded down so that it
+// only reads 100% when every memory has one.
+func coverage(vectors, memories, pending int) string {
+	line := fmt.Sprintf("%d%% embedded", vectors*100/memories)
+	if pending > 0 {
+		line += fmt.Sprintf(" (%d pending; run 'memory embed --pending')", pending)
+	}
+	return line
+}
+
+// formatBytes renders n in binary units, e.g. "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -2,9 +2,14 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,12 +18,80 @@ import (
 // Config types
 // ---------------------------------------------------------------------------
 
+// MaxRetries is the most embedding.retries may be set to.
+const MaxRetries = 10
+
 // EmbeddingConfig holds settings for the embedding provider.
 type EmbeddingConfig struct {
-	Provider string `yaml:"provider"` // "ollama" | "openai" | "openrouter"
-	Model    string `yaml:"model"`
-	BaseURL  string `yaml:"base_url"`
-	APIKey   string `yaml:"api_key"` // #nosec G117 -- APIKey is an intentional field name for the embedding provider's authentication token
+	Provider string   `yaml:"provider"` // "ollama" | "openai" | "openrouter" | "azure-openai" | "cohere" | "tei" | "local" | "exec"
+	Model    string   `yaml:"model"`
+	BaseURL  string   `yaml:"base_url"`
+	APIKey   string   `yaml:"api_key"` // #nosec G117 -- APIKey is an intentional field name for the embedding provider's authentication token
+	Command  []string `yaml:"command"` // exec: program and arguments
+	// APIKeyEnv names an environment variable holding the API key, and
+	// APIKeyCmd a command printing it; either keeps the key out of
+	// config.yaml and takes precedence over APIKey.
+	APIKeyEnv string   `yaml:"api_key_env"`
+	APIKeyCmd []string `yaml:"api_key_cmd"`
+	// Deployment and APIVersion address an Azure OpenAI deployment;
+	// Deployment defaults to Model.
+	Deployment string `yaml:"deployment"`
+	APIVersion string `yaml:"api_version"`
+	// Timeout bounds each HTTP request or exec call; 0 uses the provider's
+	// default. Requests that time out, lose their connection or are
+	// answered with 429 or 502-504 are retried up to Retries times (at most
+	// MaxRetries) with exponential backoff.
+	Timeout time.Duration `yaml:"timeout"`
+	Retries int           `yaml:"retries"`
+	// Headers are added to every HTTP request, e.g. for a corporate
+	// gateway. Proxy is the URL of an HTTP proxy, overriding HTTPS_PROXY;
+	// CACert is a PEM file of extra certificate authorities to trust,
+	// relative to the memory home.
+	Headers map[string]string `yaml:"headers"`
+	Proxy   string            `yaml:"proxy"`
+	CACert  string            `yaml:"ca_cert"`
+	// ChunkSize is the maximum length in characters of the overlapping
+	// chunks detail bodies are split into, each embedded separately. 0 stops
+	// details from being embedded.
+	ChunkSize    int `yaml:"chunk_size"`
+	ChunkOverlap int `yaml:"chunk_overlap"`
+	// ChunkAggregate combines a memory's vector hits: "max" | "sum".
+	ChunkAggregate string `yaml:"chunk_aggregate"`
+	// DistanceMetric is how vectors are compared: "cosine" | "l2" | "dot".
+	// An existing index keeps its metric until it is reindexed.
+	DistanceMetric string `yaml:"distance_metric"`
+	// Dimensions shortens vectors to this many dimensions: requested from
+	// OpenAI, truncated and renormalized for other providers. 0 keeps the
+	// model's own.
+	Dimensions int `yaml:"dimensions"`
+	// Quantization is how vector components are stored: "float" | "int8" |
+	// "bit". Rescore > 0 keeps float copies of quantized vectors and
+	// re-scores that many of the nearest candidates with them.
+	Quantization string `yaml:"quantization"`
+	Rescore      int    `yaml:"rescore"`
+	// Prefixes maps model names to the templates their queries and
+	// documents are embedded with, overriding the built-in defaults for
+	// known asymmetric models.
+	Prefixes map[string]PrefixTemplates `yaml:"prefixes"`
+	// Fallbacks are tried in order when the provider fails. Each holds the
+	// provider settings of one fallback; the rest are the primary's.
+	Fallbacks []EmbeddingConfig `yaml:"fallbacks"`
+	// BatchSize is the number of texts sent per embedding request when
+	// reindexing; Concurrency is how many requests run at once.
+	BatchSize   int `yaml:"batch_size"`
+	Concurrency int `yaml:"concurrency"`
+	// AutoReindex lets the MCP server rebuild stale vectors in the
+	// background after the embedding model changes.
+	AutoReindex bool `yaml:"auto_reindex"`
+}
+
+// PrefixTemplates hold how an asymmetric embedding model expects search
+// queries and the documents they should find to be presented. "{text}"
+// stands for the text; a template without it is a prefix. Empty leaves the
+// text unchanged.
+type PrefixTemplates struct {
+	Query    string `yaml:"query"`
+	Document string `yaml:"document"`
 }
 
 // ContextConfig controls how memories are retrieved for context injection.
@@ -27,24 +100,88 @@ type ContextConfig struct {
 	TopupRecent bool   `yaml:"topup_recent"` // also include recent memories
 }
 
+// SearchConfig controls how search results are ranked.
+type SearchConfig struct {
+	Mode         string  `yaml:"mode"`          // "tiered" | "hybrid"
+	Fusion       string  `yaml:"fusion"`        // "weighted" | "rrf"
+	FTSWeight    float64 `yaml:"fts_weight"`    // weight of keyword results in fusion
+	VectorWeight float64 `yaml:"vector_weight"` // weight of semantic results in fusion
+	RRFK         int     `yaml:"rrf_k"`         // rank constant for rrf fusion
+	MinFTS       int     `yaml:"min_fts"`       // tiered mode: keyword hits that skip the vector search
+	MinScore     float64 `yaml:"min_score"`     // drop results scoring below this
+	Diversity    float64 `yaml:"diversity"`     // 0 (off) to 1: trade relevance for variety (MMR)
+}
+
+// ScoringConfig holds the modifiers applied to search relevance scores.
+type ScoringConfig struct {
+	// HalfLifeDays is the age in days at which a category's scores halve;
+	// "default" covers categories not listed. 0 disables decay.
+	HalfLifeDays  map[string]float64 `yaml:"half_life_days"`
+	CategoryBoost map[string]float64 `yaml:"category_boost"` // score multiplier per category
+	ProjectBoost  float64            `yaml:"project_boost"`  // multiplier for the current project's memories
+}
+
+// RerankConfig holds settings for the optional reranking stage.
+type RerankConfig struct {
+	Provider string   `yaml:"provider"` // "none" | "tei" | "jina" | "cohere" | "exec"
+	Model    string   `yaml:"model"`
+	BaseURL  string   `yaml:"base_url"`
+	APIKey   string   `yaml:"api_key"` // #nosec G117 -- APIKey is an intentional field name for the rerank provider's authentication token
+	Command  []string `yaml:"command"` // exec: program and arguments
+	TopN     int      `yaml:"top_n"`   // candidates passed to the reranker
+}
+
 // MemoryConfig is the root per-vault configuration.
 type MemoryConfig struct {
 	Embedding EmbeddingConfig `yaml:"embedding"`
 	Context   ContextConfig   `yaml:"context"`
+	Search    SearchConfig    `yaml:"search"`
+	Scoring   ScoringConfig   `yaml:"scoring"`
+	Rerank    RerankConfig    `yaml:"rerank"`
+
+	// Home is the memory home the config was loaded from; relative paths
+	// in the config resolve against it.
+	Home string `yaml:"-"`
 }
 
 // Default returns a MemoryConfig populated with sensible defaults.
 func Default() *MemoryConfig {
 	return &MemoryConfig{
 		Embedding: EmbeddingConfig{
-			Provider: "ollama",
-			Model:    "nomic-embed-text",
-			BaseURL:  "http://localhost:11434",
+			Provider:       "ollama",
+			Model:          "nomic-embed-text",
+			BaseURL:        "http://localhost:11434",
+			ChunkSize:      1000,
+			ChunkOverlap:   200,
+			ChunkAggregate: "max",
+			DistanceMetric: "cosine",
+			Quantization:   "float",
+			Retries:        3,
+			BatchSize:      32,
+			Concurrency:    4,
 		},
 		Context: ContextConfig{
 			Semantic:    "auto",
 			TopupRecent: true,
 		},
+		Search: SearchConfig{
+			Mode:         "tiered",
+			Fusion:       "weighted",
+			FTSWeight:    0.3,
+			VectorWeight: 0.7,
+			RRFK:         60,
+			MinFTS:       3,
+		},
+		// Scores are left as ranked unless modifiers are configured.
+		Scoring: ScoringConfig{
+			HalfLifeDays:  map[string]float64{},
+			CategoryBoost: map[string]float64{},
+			ProjectBoost:  1,
+		},
+		Rerank: RerankConfig{
+			Provider: "none",
+			TopN:     20,
+		},
 	}
 }
 
@@ -53,6 +190,7 @@ func Default() *MemoryConfig {
 // Missing keys retain their default values.
 func Load(path string) (*MemoryConfig, error) {
 	cfg := Default()
+	cfg.Home = filepath.Dir(path)
 
 	data, err := os.ReadFile(path)
 	if os.IsNotExist(err) {
@@ -69,17 +207,81 @@ func Load(path string) (*MemoryConfig, error) {
 	}
 
 	if emb, ok := raw["embedding"].(map[string]any); ok {
-		if v, ok := emb["provider"].(string); ok && v != "" {
-			cfg.Embedding.Provider = v
+		if err := parseProvider(emb, "embedding", &cfg.Embedding); err != nil {
+			return nil, err
+		}
+		for _, f := range []struct {
+			key string
+			dst *int
+		}{
+			{"chunk_size", &cfg.Embedding.ChunkSize},
+			{"chunk_overlap", &cfg.Embedding.ChunkOverlap},
+			{"rescore", &cfg.Embedding.Rescore},
+		} {
+			if v, ok := emb[f.key]; ok {
+				n, err := strconv.Atoi(fmt.Sprint(v))
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("embedding.%s: %v is not a non-negative integer", f.key, v)
+				}
+				*f.dst = n
+			}
+		}
+		if v, ok := emb["chunk_aggregate"].(string); ok && v != "" {
+			if v != "max" && v != "sum" {
+				return nil, fmt.Errorf("embedding.chunk_aggregate: %q is not max or sum", v)
+			}
+			cfg.Embedding.ChunkAggregate = v
+		}
+		if v, ok := emb["distance_metric"].(string); ok && v != "" {
+			if v != "cosine" && v != "l2" && v != "dot" {
+				return nil, fmt.Errorf("embedding.distance_metric: %q is not cosine, l2 or dot", v)
+			}
+			cfg.Embedding.DistanceMetric = v
 		}
-		if v, ok := emb["model"].(string); ok && v != "" {
-			cfg.Embedding.Model = v
+		if v, ok := emb["quantization"].(string); ok && v != "" {
+			if v != "float" && v != "int8" && v != "bit" {
+				return nil, fmt.Errorf("embedding.quantization: %q is not float, int8 or bit", v)
+			}
+			cfg.Embedding.Quantization = v
 		}
-		if v, ok := emb["base_url"].(string); ok {
-			cfg.Embedding.BaseURL = v
+		if v, ok := emb["auto_reindex"].(bool); ok {
+			cfg.Embedding.AutoReindex = v
 		}
-		if v, ok := emb["api_key"].(string); ok {
-			cfg.Embedding.APIKey = v
+		for _, f := range []struct {
+			key string
+			dst *int
+		}{
+			{"batch_size", &cfg.Embedding.BatchSize},
+			{"concurrency", &cfg.Embedding.Concurrency},
+		} {
+			if v, ok := emb[f.key]; ok {
+				n, err := strconv.Atoi(fmt.Sprint(v))
+				if err != nil || n < 1 {
+					return nil, fmt.Errorf("embedding.%s: %v is not a positive integer", f.key, v)
+				}
+				*f.dst = n
+			}
+		}
+		if v, ok := emb["fallbacks"]; ok {
+			list, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("embedding.fallbacks: expected a list of providers")
+			}
+			for i, item := range list {
+				m, ok := item.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("embedding.fallbacks[%d]: expected a provider", i)
+				}
+				fb := cfg.Embedding
+				fb.BaseURL, fb.APIKey, fb.Command, fb.Deployment, fb.APIVersion = "", "", nil, "", ""
+				fb.APIKeyEnv, fb.APIKeyCmd = "", nil
+				fb.Headers = nil
+				fb.Fallbacks = nil
+				if err := parseProvider(m, fmt.Sprintf("embedding.fallbacks[%d]", i), &fb); err != nil {
+					return nil, err
+				}
+				cfg.Embedding.Fallbacks = append(cfg.Embedding.Fallbacks, fb)
+			}
 		}
 	}
 
@@ -92,9 +294,258 @@ func Load(path string) (*MemoryConfig, error) {
 		}
 	}
 
+	if search, ok := raw["search"].(map[string]any); ok {
+		for key, v := range search {
+			if err := cfg.Search.Set(key, fmt.Sprint(v)); err != nil {
+				return nil, fmt.Errorf("search.%w", err)
+			}
+		}
+	}
+
+	if sc, ok := raw["scoring"].(map[string]any); ok {
+		for _, m := range []struct {
+			key string
+			dst map[string]float64
+		}{
+			{"half_life_days", cfg.Scoring.HalfLifeDays},
+			{"category_boost", cfg.Scoring.CategoryBoost},
+		} {
+			entries, _ := sc[m.key].(map[string]any)
+			for k, v := range entries {
+				f, err := parseNonNegative(fmt.Sprint(v))
+				if err != nil {
+					return nil, fmt.Errorf("scoring.%s.%s: %w", m.key, k, err)
+				}
+				m.dst[strings.ToLower(k)] = f
+			}
+		}
+		if v, ok := sc["project_boost"]; ok {
+			f, err := parseNonNegative(fmt.Sprint(v))
+			if err != nil {
+				return nil, fmt.Errorf("scoring.project_boost: %w", err)
+			}
+			cfg.Scoring.ProjectBoost = f
+		}
+	}
+
+	if rr, ok := raw["rerank"].(map[string]any); ok {
+		if v, ok := rr["provider"].(string); ok && v != "" {
+			cfg.Rerank.Provider = v
+		}
+		if v, ok := rr["model"].(string); ok {
+			cfg.Rerank.Model = v
+		}
+		if v, ok := rr["base_url"].(string); ok {
+			cfg.Rerank.BaseURL = v
+		}
+		if v, ok := rr["api_key"].(string); ok {
+			cfg.Rerank.APIKey = v
+		}
+		if v, ok := commandArgs(rr["command"]); ok {
+			cfg.Rerank.Command = v
+		}
+		if v, ok := rr["top_n"].(int); ok && v > 0 {
+			cfg.Rerank.TopN = v
+		}
+	}
+
 	return cfg, nil
 }
 
+// Set assigns the search setting named key (its YAML name, e.g. "fusion" or
+// "min_score") from its string form. It is used for config.yaml values and
+// for per-query overrides.
+func (s *SearchConfig) Set(key, value string) error {
+	value = strings.TrimSpace(value)
+	var err error
+	switch key {
+	case "mode":
+		if value != "tiered" && value != "hybrid" {
+			return fmt.Errorf("mode: %q is not tiered or hybrid", value)
+		}
+		s.Mode = value
+	case "fusion":
+		if value != "weighted" && value != "rrf" {
+			return fmt.Errorf("fusion: %q is not weighted or rrf", value)
+		}
+		s.Fusion = value
+	case "fts_weight":
+		s.FTSWeight, err = parseNonNegative(value)
+	case "vector_weight":
+		s.VectorWeight, err = parseNonNegative(value)
+	case "min_score":
+		s.MinScore, err = parseNonNegative(value)
+	case "diversity":
+		s.Diversity, err = parseNonNegative(value)
+		if err == nil && s.Diversity > 1 {
+			err = errors.New("must be between 0 and 1")
+		}
+	case "rrf_k":
+		s.RRFK, err = strconv.Atoi(value)
+		if err == nil && s.RRFK < 1 {
+			err = errors.New("must be at least 1")
+		}
+	case "min_fts":
+		s.MinFTS, err = strconv.Atoi(value)
+		if err == nil && s.MinFTS < 1 {
+			err = errors.New("must be at least 1")
+		}
+	default:
+		return fmt.Errorf("%s: unknown setting (want mode, fusion, fts_weight, vector_weight, rrf_k, min_fts, min_score or diversity)", key)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+// parseProvider applies the provider settings of an embedding block, the
+// part a fallback can set, to e; path names the block in errors.
+func parseProvider(emb map[string]any, path string, e *EmbeddingConfig) error {
+	if v, ok := emb["provider"].(string); ok && v != "" {
+		e.Provider = v
+	}
+	if v, ok := emb["model"].(string); ok && v != "" {
+		e.Model = v
+	}
+	if v, ok := emb["base_url"].(string); ok {
+		e.BaseURL = v
+	}
+	if v, ok := emb["api_key"].(string); ok {
+		e.APIKey = v
+	}
+	if v, ok := commandArgs(emb["command"]); ok {
+		e.Command = v
+	}
+	if v, ok := emb["api_key_env"].(string); ok {
+		e.APIKeyEnv = v
+	}
+	if v, ok := commandArgs(emb["api_key_cmd"]); ok {
+		e.APIKeyCmd = v
+	}
+	if v, ok := emb["deployment"].(string); ok {
+		e.Deployment = v
+	}
+	switch v := emb["api_version"].(type) {
+	case string:
+		e.APIVersion = v
+	case time.Time:
+		// YAML reads an unquoted version such as 2024-10-21 as a date.
+		e.APIVersion = v.Format(time.DateOnly)
+	}
+	for _, f := range []struct {
+		key string
+		dst *int
+	}{
+		{"dimensions", &e.Dimensions},
+		{"retries", &e.Retries},
+	} {
+		if v, ok := emb[f.key]; ok {
+			n, err := strconv.Atoi(fmt.Sprint(v))
+			if err != nil || n < 0 {
+				return fmt.Errorf("%s.%s: %v is not a non-negative integer", path, f.key, v)
+			}
+			*f.dst = n
+		}
+	}
+	if e.Retries > MaxRetries {
+		return fmt.Errorf("%s.retries: %d is more than %d", path, e.Retries, MaxRetries)
+	}
+	if v, ok := emb["timeout"]; ok {
+		d, err := parseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s.timeout: %v is not a duration", path, v)
+		}
+		e.Timeout = d
+	}
+	if v, ok := emb["headers"]; ok {
+		m, ok := v.(map[string]any)
+		if !ok && v != nil {
+			return fmt.Errorf("%s.headers: expected header names and values", path)
+		}
+		e.Headers = make(map[string]string, len(m))
+		for name, hv := range m {
+			e.Headers[name] = fmt.Sprint(hv)
+		}
+	}
+	if v, ok := emb["proxy"].(string); ok {
+		if u, err := url.Parse(v); v != "" && (err != nil || u.Scheme == "" || u.Host == "") {
+			return fmt.Errorf("%s.proxy: %q is not a URL", path, v)
+		}
+		e.Proxy = v
+	}
+	if v, ok := emb["ca_cert"].(string); ok {
+		e.CACert = v
+	}
+	if v, ok := emb["prefixes"].(map[string]any); ok {
+		e.Prefixes = make(map[string]PrefixTemplates, len(v))
+		for model, raw := range v {
+			m, _ := raw.(map[string]any)
+			if raw != nil && m == nil {
+				return fmt.Errorf("%s.prefixes.%s: expected query and document templates", path, model)
+			}
+			var t PrefixTemplates
+			for key, dst := range map[string]*string{"query": &t.Query, "document": &t.Document} {
+				if tv, ok := m[key]; ok {
+					if *dst, ok = tv.(string); !ok {
+						return fmt.Errorf("%s.prefixes.%s.%s: %v is not a string", path, model, key, tv)
+					}
+				}
+			}
+			e.Prefixes[model] = t
+		}
+	}
+	return nil
+}
+
+// commandArgs reads a command given as a string split at whitespace or as
+// a list of arguments; ok is false when v is neither.
+func commandArgs(v any) (args []string, ok bool) {
+	switch v := v.(type) {
+	case string:
+		return strings.Fields(v), true
+	case []any:
+		for _, arg := range v {
+			args = append(args, fmt.Sprint(arg))
+		}
+		return args, true
+	}
+	return nil, false
+}
+
+// parseDuration reads a duration such as "90s", or a number of seconds.
+func parseDuration(v any) (time.Duration, error) {
+	var d time.Duration
+	switch v := v.(type) {
+	case int:
+		d = time.Duration(v) * time.Second
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	case string:
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("unexpected %T", v)
+	}
+	if d < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return d, nil
+}
+
+func parseNonNegative(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return f, nil
+}
+
 // ---------------------------------------------------------------------------
 // Memory home resolution
 // ---------------------------------------------------------------------------
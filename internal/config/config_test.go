@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 
@@ -158,3 +159,310 @@ func TestResolveMemoryHome_EnvOverride(t *testing.T) {
 	c.Assert(source, qt.Equals, "env")
 	c.Assert(path, qt.Equals, tmp)
 }
+
+func TestLoad_Search_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "search:\n  mode: hybrid\n  fusion: rrf\n  fts_weight: 1\n  vector_weight: 2.5\n  rrf_k: 20\n  min_score: 0.01\n"
+	c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+
+	cfg, err := config.Load(path)
+	c.Assert(err, qt.IsNil)
+	c.Assert(cfg.Search, qt.DeepEquals, config.SearchConfig{
+		Mode:         "hybrid",
+		Fusion:       "rrf",
+		FTSWeight:    1,
+		VectorWeight: 2.5,
+		RRFK:         20,
+		MinFTS:       3,
+		MinScore:     0.01,
+	})
+}
+
+func TestLoad_Search_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	c.Assert(os.WriteFile(path, []byte("search:\n  fusion: borda\n"), 0o600), qt.IsNil)
+
+	_, err := config.Load(path)
+	c.Assert(err, qt.ErrorMatches, `search.fusion: "borda" is not weighted or rrf`)
+}
+
+func TestSearchConfigSet_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		key, value, want string
+	}{
+		{"mode", "fast", `mode: "fast" is not tiered or hybrid`},
+		{"fts_weight", "-1", "fts_weight: must not be negative"},
+		{"rrf_k", "0", "rrf_k: must be at least 1"},
+		{"diversity", "1.5", "diversity: must be between 0 and 1"},
+		{"min_fts", "x", `min_fts: strconv.Atoi: parsing "x": invalid syntax`},
+		{"limit", "3", "limit: unknown setting .*"},
+	}
+	for _, tt := range tests {
+		c.Run(tt.key, func(c *qt.C) {
+			s := config.Default().Search
+			c.Assert(s.Set(tt.key, tt.value), qt.ErrorMatches, tt.want)
+		})
+	}
+}
+
+func TestLoad_Rerank_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		name string
+		yaml string
+		want config.RerankConfig
+	}{
+		{
+			name: "defaults disable reranking",
+			yaml: "context:\n  semantic: never\n",
+			want: config.RerankConfig{Provider: "none", TopN: 20},
+		},
+		{
+			name: "http provider",
+			yaml: "rerank:\n  provider: jina\n  model: jina-reranker-v2\n  api_key: jk\n  top_n: 50\n",
+			want: config.RerankConfig{Provider: "jina", Model: "jina-reranker-v2", APIKey: "jk", TopN: 50},
+		},
+		{
+			name: "exec command as a list",
+			yaml: "rerank:\n  provider: exec\n  command: [python3, score.py, --fast]\n",
+			want: config.RerankConfig{Provider: "exec", Command: []string{"python3", "score.py", "--fast"}, TopN: 20},
+		},
+		{
+			name: "exec command as a string",
+			yaml: "rerank:\n  provider: exec\n  command: python3 score.py\n",
+			want: config.RerankConfig{Provider: "exec", Command: []string{"python3", "score.py"}, TopN: 20},
+		},
+	}
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			c.Assert(os.WriteFile(path, []byte(tt.yaml), 0o600), qt.IsNil)
+
+			cfg, err := config.Load(path)
+			c.Assert(err, qt.IsNil)
+			c.Assert(cfg.Rerank, qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestLoad_Scoring_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "scoring:\n  half_life_days:\n    Context: 30\n    default: 720\n  category_boost:\n    decision: 1.5\n  project_boost: 1.2\n"
+	c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+
+	c.Run("modifiers are neutral by default", func(c *qt.C) {
+		def := config.Default().Scoring
+		c.Assert(def.HalfLifeDays, qt.HasLen, 0)
+		c.Assert(def.CategoryBoost, qt.HasLen, 0)
+		c.Assert(def.ProjectBoost, qt.Equals, 1.0)
+	})
+
+	c.Run("listed entries are applied with lower-cased categories", func(c *qt.C) {
+		cfg, err := config.Load(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cfg.Scoring.HalfLifeDays, qt.DeepEquals, map[string]float64{"context": 30, "default": 720})
+		c.Assert(cfg.Scoring.CategoryBoost, qt.DeepEquals, map[string]float64{"decision": 1.5})
+		c.Assert(cfg.Scoring.ProjectBoost, qt.Equals, 1.2)
+	})
+}
+
+func TestLoad_Scoring_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	c.Assert(os.WriteFile(path, []byte("scoring:\n  category_boost:\n    bug: -2\n"), 0o600), qt.IsNil)
+
+	_, err := config.Load(path)
+	c.Assert(err, qt.ErrorMatches, "scoring.category_boost.bug: must not be negative")
+}
+
+func TestLoad_EmbeddingChunks_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("defaults", func(c *qt.C) {
+		e := config.Default().Embedding
+		c.Assert(e.ChunkSize, qt.Equals, 1000)
+		c.Assert(e.ChunkOverlap, qt.Equals, 200)
+		c.Assert(e.ChunkAggregate, qt.Equals, "max")
+		c.Assert(e.DistanceMetric, qt.Equals, "cosine")
+		c.Assert(e.Dimensions, qt.Equals, 0)
+		c.Assert(e.Quantization, qt.Equals, "float")
+		c.Assert(e.Rescore, qt.Equals, 0)
+		c.Assert(e.BatchSize, qt.Equals, 32)
+		c.Assert(e.Concurrency, qt.Equals, 4)
+	})
+
+	c.Run("overrides", func(c *qt.C) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		yaml := "embedding:\n  chunk_size: 0\n  chunk_overlap: 50\n  chunk_aggregate: sum\n  distance_metric: dot\n  deployment: embed-prod\n  api_version: 2024-06-01\n  dimensions: 256\n  quantization: int8\n  rescore: 50\n  batch_size: 100\n  concurrency: 1\n  command: [python3, embed.py, --model, \"my model\"]\n  prefixes:\n    my-model:\n      query: \"q: {text}\"\n      document: \"d: \"\n    plain-model:\n"
+		c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+		cfg, err := config.Load(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cfg.Embedding.ChunkSize, qt.Equals, 0)
+		c.Assert(cfg.Embedding.ChunkOverlap, qt.Equals, 50)
+		c.Assert(cfg.Embedding.ChunkAggregate, qt.Equals, "sum")
+		c.Assert(cfg.Embedding.DistanceMetric, qt.Equals, "dot")
+		c.Assert(cfg.Embedding.Deployment, qt.Equals, "embed-prod")
+		c.Assert(cfg.Embedding.APIVersion, qt.Equals, "2024-06-01")
+		c.Assert(cfg.Embedding.Dimensions, qt.Equals, 256)
+		c.Assert(cfg.Embedding.Quantization, qt.Equals, "int8")
+		c.Assert(cfg.Embedding.Rescore, qt.Equals, 50)
+		c.Assert(cfg.Embedding.BatchSize, qt.Equals, 100)
+		c.Assert(cfg.Embedding.Concurrency, qt.Equals, 1)
+		c.Assert(cfg.Embedding.Command, qt.DeepEquals, []string{"python3", "embed.py", "--model", "my model"})
+		c.Assert(cfg.Embedding.Prefixes, qt.DeepEquals, map[string]config.PrefixTemplates{
+			"my-model":    {Query: "q: {text}", Document: "d: "},
+			"plain-model": {},
+		})
+	})
+}
+
+func TestLoad_EmbeddingChunks_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		yaml, want string
+	}{
+		{"embedding:\n  chunk_size: -5\n", "embedding.chunk_size: -5 is not a non-negative integer"},
+		{"embedding:\n  chunk_overlap: lots\n", "embedding.chunk_overlap: lots is not a non-negative integer"},
+		{"embedding:\n  chunk_aggregate: mean\n", `embedding.chunk_aggregate: "mean" is not max or sum`},
+		{"embedding:\n  distance_metric: manhattan\n", `embedding.distance_metric: "manhattan" is not cosine, l2 or dot`},
+		{"embedding:\n  quantization: float16\n", `embedding.quantization: "float16" is not float, int8 or bit`},
+		{"embedding:\n  dimensions: -1\n", `embedding.dimensions: -1 is not a non-negative integer`},
+		{"embedding:\n  batch_size: 0\n", "embedding.batch_size: 0 is not a positive integer"},
+		{"embedding:\n  concurrency: many\n", "embedding.concurrency: many is not a positive integer"},
+		{"embedding:\n  prefixes:\n    m: \"query: \"\n", "embedding.prefixes.m: expected query and document templates"},
+		{"embedding:\n  prefixes:\n    m:\n      query: 3\n", "embedding.prefixes.m.query: 3 is not a string"},
+	}
+	for _, tt := range tests {
+		c.Run(tt.want, func(c *qt.C) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			c.Assert(os.WriteFile(path, []byte(tt.yaml), 0o600), qt.IsNil)
+			_, err := config.Load(path)
+			c.Assert(err, qt.ErrorMatches, tt.want)
+		})
+	}
+}
+
+func TestLoad_EmbeddingHTTP_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("defaults", func(c *qt.C) {
+		e := config.Default().Embedding
+		c.Assert(e.Timeout, qt.Equals, time.Duration(0))
+		c.Assert(e.Retries, qt.Equals, 3)
+		c.Assert(e.Headers, qt.IsNil)
+	})
+
+	c.Run("overrides", func(c *qt.C) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		yaml := "embedding:\n  timeout: 90s\n  retries: 0\n  headers:\n    X-Gateway-Key: abc\n    X-Team: 42\n  proxy: http://proxy.corp:3128\n  ca_cert: corp-ca.pem\n"
+		c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+		cfg, err := config.Load(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cfg.Embedding.Timeout, qt.Equals, 90*time.Second)
+		c.Assert(cfg.Embedding.Retries, qt.Equals, 0)
+		c.Assert(cfg.Embedding.Headers, qt.DeepEquals, map[string]string{"X-Gateway-Key": "abc", "X-Team": "42"})
+		c.Assert(cfg.Embedding.Proxy, qt.Equals, "http://proxy.corp:3128")
+		c.Assert(cfg.Embedding.CACert, qt.Equals, "corp-ca.pem")
+	})
+
+	c.Run("API key sources", func(c *qt.C) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		yaml := "embedding:\n  api_key_env: OPENAI_API_KEY\n  api_key_cmd: pass show openai\n"
+		c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+		cfg, err := config.Load(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cfg.Embedding.APIKeyEnv, qt.Equals, "OPENAI_API_KEY")
+		c.Assert(cfg.Embedding.APIKeyCmd, qt.DeepEquals, []string{"pass", "show", "openai"})
+	})
+
+	c.Run("a bare timeout is in seconds", func(c *qt.C) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		c.Assert(os.WriteFile(path, []byte("embedding:\n  timeout: 45\n"), 0o600), qt.IsNil)
+		cfg, err := config.Load(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(cfg.Embedding.Timeout, qt.Equals, 45*time.Second)
+	})
+}
+
+func TestLoad_EmbeddingHTTP_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		yaml, want string
+	}{
+		{"embedding:\n  timeout: soon\n", "embedding.timeout: soon is not a duration"},
+		{"embedding:\n  timeout: -5s\n", "embedding.timeout: -5s is not a duration"},
+		{"embedding:\n  retries: -1\n", "embedding.retries: -1 is not a non-negative integer"},
+		{"embedding:\n  retries: 40\n", "embedding.retries: 40 is more than 10"},
+		{"embedding:\n  headers: [a, b]\n", "embedding.headers: expected header names and values"},
+		{"embedding:\n  proxy: proxy.corp\n", `embedding.proxy: "proxy.corp" is not a URL`},
+	}
+	for _, tt := range tests {
+		c.Run(tt.want, func(c *qt.C) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			c.Assert(os.WriteFile(path, []byte(tt.yaml), 0o600), qt.IsNil)
+			_, err := config.Load(path)
+			c.Assert(err, qt.ErrorMatches, tt.want)
+		})
+	}
+}
+
+func TestLoad_EmbeddingFallbacks_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "embedding:\n  provider: ollama\n  model: nomic-embed-text\n  base_url: http://gpu-box:11434\n  chunk_size: 500\n  timeout: 2m\n  api_key_env: GPU_BOX_KEY\n  headers:\n    X-Key: k\n  fallbacks:\n    - provider: ollama\n      model: nomic-embed-text\n    - provider: openai\n      model: text-embedding-3-small\n      api_key: sk-test\n      dimensions: 256\n"
+	c.Assert(os.WriteFile(path, []byte(yaml), 0o600), qt.IsNil)
+	cfg, err := config.Load(path)
+	c.Assert(err, qt.IsNil)
+	fbs := cfg.Embedding.Fallbacks
+	c.Assert(fbs, qt.HasLen, 2)
+
+	c.Run("connection settings are not inherited", func(c *qt.C) {
+		c.Assert(fbs[0].Provider, qt.Equals, "ollama")
+		c.Assert(fbs[0].BaseURL, qt.Equals, "")
+		c.Assert(fbs[0].Headers, qt.IsNil)
+		c.Assert(fbs[0].APIKeyEnv, qt.Equals, "")
+		c.Assert(fbs[1].Provider, qt.Equals, "openai")
+		c.Assert(fbs[1].Model, qt.Equals, "text-embedding-3-small")
+		c.Assert(fbs[1].APIKey, qt.Equals, "sk-test")
+		c.Assert(fbs[1].Dimensions, qt.Equals, 256)
+	})
+
+	c.Run("other settings are shared", func(c *qt.C) {
+		c.Assert(fbs[1].ChunkSize, qt.Equals, 500)
+		c.Assert(fbs[1].Timeout, qt.Equals, 2*time.Minute)
+		c.Assert(fbs[1].Fallbacks, qt.IsNil)
+	})
+}
+
+func TestLoad_EmbeddingFallbacks_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		yaml, want string
+	}{
+		{"embedding:\n  fallbacks: openai\n", "embedding.fallbacks: expected a list of providers"},
+		{"embedding:\n  fallbacks:\n    - openai\n", `embedding.fallbacks\[0\]: expected a provider`},
+		{"embedding:\n  fallbacks:\n    - provider: openai\n      dimensions: -3\n", `embedding.fallbacks\[0\].dimensions: -3 is not a non-negative integer`},
+	}
+	for _, tt := range tests {
+		c.Run(tt.want, func(c *qt.C) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			c.Assert(os.WriteFile(path, []byte(tt.yaml), 0o600), qt.IsNil)
+			_, err := config.Load(path)
+			c.Assert(err, qt.ErrorMatches, tt.want)
+		})
+	}
+}
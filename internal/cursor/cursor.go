@@ -0,0 +1,59 @@
+// Package cursor encodes the opaque pagination cursors returned by search,
+// context and listing. A cursor pins the set of visible memories to those
+// that existed when paging began, so saving new memories does not shift
+// later pages.
+package cursor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalid is returned for cursors that cannot be decoded or that belong to
+// a different query.
+var ErrInvalid = errors.New("invalid cursor")
+
+// Cursor is the decoded position of a result page.
+type Cursor struct {
+	// Snapshot is the highest memories.rowid visible when paging began.
+	Snapshot int64 `json:"s"`
+	// Offset is the number of ranked results already returned.
+	Offset int `json:"o,omitempty"`
+	// CreatedAt and ID are the keyset of the last row of a recency listing.
+	CreatedAt string `json:"c,omitempty"`
+	ID        string `json:"i,omitempty"`
+	// Scope fingerprints the query and filters the cursor was issued for.
+	Scope string `json:"k"`
+}
+
+// Scope returns a short fingerprint of parts, used to reject cursors replayed
+// against a different query, project or source.
+func Scope(parts ...string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.Join(parts, "\x00")))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// Encode returns the opaque string form of c.
+func Encode(c Cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Decode parses s and checks that it was issued for scope.
+func Decode(s, scope string) (Cursor, error) {
+	var c Cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.Snapshot < 0 || c.Offset < 0 {
+		return Cursor{}, ErrInvalid
+	}
+	if c.Scope != scope {
+		return Cursor{}, fmt.Errorf("%w: issued for a different query", ErrInvalid)
+	}
+	return c, nil
+}
@@ -0,0 +1,42 @@
+package cursor_test
+
+import (
+	"errors"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/cursor"
+)
+
+func TestDecode_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	scope := cursor.Scope("search", "redis", "proj", "")
+	in := cursor.Cursor{Snapshot: 42, Offset: 10, Scope: scope}
+
+	got, err := cursor.Decode(cursor.Encode(in), scope)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, in)
+}
+
+func TestDecode_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	scope := cursor.Scope("search", "redis", "proj", "")
+
+	c.Run("garbage", func(c *qt.C) {
+		_, err := cursor.Decode("not a cursor", scope)
+		c.Assert(errors.Is(err, cursor.ErrInvalid), qt.IsTrue)
+	})
+
+	c.Run("different scope", func(c *qt.C) {
+		s := cursor.Encode(cursor.Cursor{Snapshot: 1, Scope: scope})
+		_, err := cursor.Decode(s, cursor.Scope("search", "redis", "other", ""))
+		c.Assert(errors.Is(err, cursor.ErrInvalid), qt.IsTrue)
+	})
+
+	c.Run("scope parts are not ambiguous", func(c *qt.C) {
+		c.Assert(cursor.Scope("ab", "c"), qt.Not(qt.Equals), cursor.Scope("a", "bc"))
+	})
+}
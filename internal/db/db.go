@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"log/slog"
 	"math"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -16,7 +19,10 @@ import (
 	vec "github.com/asg017/sqlite-vec-go-bindings/cgo"
 	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver with database/sql
 
+	"github.com/go-ports/echovault/internal/cursor"
+	"github.com/go-ports/echovault/internal/filepaths"
 	"github.com/go-ports/echovault/internal/models"
+	"github.com/go-ports/echovault/internal/query"
 )
 
 func init() { //nolint:gochecknoinits // registers sqlite-vec extension with go-sqlite3 before any DB connection opens
@@ -27,10 +33,117 @@ func init() { //nolint:gochecknoinits // registers sqlite-vec extension with go-
 // ErrDimensionMismatch is returned when a new embedding dimension differs from the one stored.
 var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
 
+// ErrModelMismatch is returned when the embedding model differs from the one
+// the stored vectors were built with.
+var ErrModelMismatch = errors.New("embedding model mismatch")
+
+// Chunk aggregation methods accepted in DB.ChunkAggregate.
+const (
+	AggregateMax = "max"
+	AggregateSum = "sum"
+)
+
+// Distance metrics accepted in DB.DistanceMetric.
+const (
+	// MetricCosine ranks by angle; vectors are normalized on insert.
+	MetricCosine = "cosine"
+	// MetricL2 ranks by Euclidean distance, as tables built before the
+	// metric was configurable do.
+	MetricL2 = "l2"
+	// MetricDot ranks by inner product of unit-length vectors, which makes
+	// it rank like MetricCosine. vec0 has no inner-product metric, so stored
+	// and query vectors are normalized and it is derived from their L2
+	// distance.
+	MetricDot = "dot"
+)
+
+// Vector storage types accepted in DB.Quantization.
+const (
+	// QuantFloat stores float32 components.
+	QuantFloat = "float"
+	// QuantInt8 stores each component of the unit-length vector as a signed
+	// byte, a quarter of the space.
+	QuantInt8 = "int8"
+	// QuantBit stores the sign of each component as a single bit, a 32nd of
+	// the space. Bit vectors are compared by Hamming distance.
+	QuantBit = "bit"
+)
+
+// int8Scale is the factor vec_quantize_int8(..., 'unit') scales components
+// in [-1, 1] by.
+const int8Scale = 127.5
+
+// VectorLayout describes how a generation of vectors is stored.
+type VectorLayout struct {
+	Metric       string
+	Quantization string
+	// Rescorable is set when quantized vectors are stored alongside float
+	// copies, used to re-score the nearest candidates.
+	Rescorable bool
+}
+
+// String describes l as "metric", "metric/quantization" or
+// "metric/quantization+rescore".
+func (l VectorLayout) String() string {
+	out := l.Metric
+	if l.Quantization != QuantFloat {
+		out += "/" + l.Quantization
+	}
+	if l.Rescorable {
+		out += "+rescore"
+	}
+	return out
+}
+
+// bytesPerVector returns the space one dim-dimensional vector takes in l.
+func (l VectorLayout) bytesPerVector(dim int) int {
+	var n int
+	switch l.Quantization {
+	case QuantInt8:
+		n = dim
+	case QuantBit:
+		n = (dim + 7) / 8
+	default:
+		n = 4 * dim
+	}
+	if l.Rescorable {
+		n += 4 * dim
+	}
+	return n
+}
+
+// floats reports whether vectors in l can be read back at full precision.
+func (l VectorLayout) floats() bool {
+	return l.Quantization == QuantFloat || l.Rescorable
+}
+
+// unitLength reports whether l's metric compares vectors normalized to unit
+// length.
+func (l VectorLayout) unitLength() bool {
+	return l.Metric == MetricCosine || l.Metric == MetricDot
+}
+
 // DB wraps a *sql.DB with the path it was opened from.
 type DB struct {
 	db   *sql.DB
 	path string
+
+	// DistanceMetric is the metric vector tables are built with from now on
+	// (MetricL2 when empty). Existing tables keep theirs until a reindex.
+	DistanceMetric string
+
+	// Quantization is how vector tables built from now on store components
+	// (QuantFloat when empty). With Rescore > 0 quantized tables also keep
+	// float copies, and that many of the nearest candidates of a search are
+	// re-scored with them.
+	Quantization string
+	Rescore      int
+
+	// ChunkAggregate combines the vector hits of one memory (its summary and
+	// detail chunks) into a single score: AggregateMax (the default) keeps
+	// the best similarity, AggregateSum adds up the positive ones, favouring
+	// memories that match in several places.
+	ChunkAggregate string
 }
 
 // Open opens (or creates) the SQLite database at path and initialises the schema.
@@ -79,6 +192,38 @@ func (d *DB) createSchema() error {
 			memory_id TEXT PRIMARY KEY REFERENCES memories(id),
 			body      TEXT NOT NULL
 		)`,
+		`CREATE TABLE IF NOT EXISTS memory_files (
+			memory_id  TEXT NOT NULL REFERENCES memories(id),
+			path       TEXT NOT NULL,
+			commit_sha TEXT,
+			line_start INTEGER,
+			line_end   INTEGER,
+			PRIMARY KEY (memory_id, path)
+		)`,
+		`CREATE INDEX IF NOT EXISTS memory_files_path ON memory_files(path)`,
+		// Detail bodies are embedded in overlapping chunks. Each chunk's
+		// vector lives in memory_chunks_vec under the chunk's rowid.
+		`CREATE TABLE IF NOT EXISTS memory_chunks (
+			rowid        INTEGER PRIMARY KEY AUTOINCREMENT,
+			memory_rowid INTEGER NOT NULL,
+			seq          INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS memory_chunks_memory ON memory_chunks(memory_rowid)`,
+		// What each memory's vectors were computed from, so reindexing can
+		// skip memories whose content and embedding model are unchanged.
+		`CREATE TABLE IF NOT EXISTS memory_embeddings (
+			memory_rowid INTEGER PRIMARY KEY,
+			content_hash TEXT NOT NULL,
+			model        TEXT NOT NULL
+		)`,
+		// Memories whose vectors could not be computed when they were saved,
+		// to be embedded once the provider is back.
+		`CREATE TABLE IF NOT EXISTS pending_embeddings (
+			memory_rowid INTEGER PRIMARY KEY,
+			reason       TEXT NOT NULL,
+			attempts     INTEGER NOT NULL DEFAULT 1,
+			queued_at    TEXT NOT NULL
+		)`,
 		`CREATE TABLE IF NOT EXISTS meta (
 			key   TEXT PRIMARY KEY,
 			value TEXT NOT NULL
@@ -102,6 +247,25 @@ func (d *DB) createSchema() error {
 			INSERT INTO memories_fts(memories_fts, rowid, title, what, why, impact, tags, category, project, source)
 			VALUES ('delete', old.rowid, old.title, old.what, old.why, old.impact, old.tags, old.category, old.project, old.source);
 		END`,
+		// Detail bodies are indexed separately, keyed by the memory's rowid.
+		// The table stores its own copy of the body so snippet() works.
+		// INSERT OR REPLACE on memory_details does not fire the delete
+		// trigger, so the insert trigger clears any previous entry first.
+		`CREATE VIRTUAL TABLE IF NOT EXISTS details_fts USING fts5(
+			body,
+			tokenize='porter unicode61'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS memory_details_ai AFTER INSERT ON memory_details BEGIN
+			DELETE FROM details_fts WHERE rowid = (SELECT rowid FROM memories WHERE id = new.memory_id);
+			INSERT INTO details_fts(rowid, body) SELECT rowid, new.body FROM memories WHERE id = new.memory_id;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memory_details_au AFTER UPDATE ON memory_details BEGIN
+			DELETE FROM details_fts WHERE rowid = (SELECT rowid FROM memories WHERE id = old.memory_id);
+			INSERT INTO details_fts(rowid, body) SELECT rowid, new.body FROM memories WHERE id = new.memory_id;
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS memory_details_ad AFTER DELETE ON memory_details BEGIN
+			DELETE FROM details_fts WHERE rowid = (SELECT rowid FROM memories WHERE id = old.memory_id);
+		END`,
 	}
 
 	for _, s := range stmts {
@@ -111,58 +275,351 @@ func (d *DB) createSchema() error {
 	}
 
 	// Migration: add updated_count column if missing.
-	rows, err := d.db.Query("PRAGMA table_info(memories)")
+	cols, err := d.tableColumns("memories")
 	if err != nil {
 		return err
 	}
-	cols := make(map[string]bool)
-	for rows.Next() {
-		var cid int
-		var name, typ string
-		var notNull, pk int
-		var dflt sql.NullString
-		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
-			rows.Close()
-			return err
+	if !cols["updated_count"] {
+		if _, err := d.db.Exec("ALTER TABLE memories ADD COLUMN updated_count INTEGER DEFAULT 0"); err != nil {
+			return fmt.Errorf("migration updated_count: %w", err)
 		}
-		cols[name] = true
 	}
-	rows.Close()
-	if err := rows.Err(); err != nil {
+
+	// Migration: add commit/line-range columns to memory_files if missing.
+	fileCols, err := d.tableColumns("memory_files")
+	if err != nil {
 		return err
 	}
-	if !cols["updated_count"] {
-		if _, err := d.db.Exec("ALTER TABLE memories ADD COLUMN updated_count INTEGER DEFAULT 0"); err != nil {
-			return fmt.Errorf("migration updated_count: %w", err)
+	for _, col := range []string{"commit_sha TEXT", "line_start INTEGER", "line_end INTEGER"} {
+		name, _, _ := strings.Cut(col, " ")
+		if fileCols[name] {
+			continue
 		}
+		if _, err := d.db.Exec("ALTER TABLE memory_files ADD COLUMN " + col); err != nil {
+			return fmt.Errorf("migration memory_files.%s: %w", name, err)
+		}
+	}
+
+	// Migration: populate memory_files from related_files for memories saved
+	// before the table existed.
+	if err := d.backfillMemoryFiles(); err != nil {
+		return fmt.Errorf("migration memory_files: %w", err)
+	}
+	if err := d.relativizeMemoryFiles(); err != nil {
+		return fmt.Errorf("migration memory_files: %w", err)
+	}
+
+	// Migration: index details saved before details_fts existed.
+	if err := d.backfillDetailsFTS(); err != nil {
+		return fmt.Errorf("migration details_fts: %w", err)
 	}
 
 	// Recreate vec table if dimension was previously persisted.
 	if dim, ok, err := d.GetEmbeddingDim(); err == nil && ok {
-		if err := d.createVecTable(dim); err != nil {
+		layout, err := d.StoredLayout()
+		if err != nil {
+			return fmt.Errorf("createSchema: %w", err)
+		}
+		if err := d.createVecTable(dim, layout); err != nil {
 			return fmt.Errorf("createSchema createVecTable: %w", err)
 		}
+		if err := d.migrateVecMetadata(dim); err != nil {
+			return fmt.Errorf("createSchema migrateVecMetadata: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// tableColumns returns the set of column names of table.
+func (d *DB) tableColumns(table string) (map[string]bool, error) {
+	rows, err := d.db.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make(map[string]bool)
+	for rows.Next() {
+		var cid int
+		var name, typ string
+		var notNull, pk int
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		cols[name] = true
+	}
+	return cols, rows.Err()
+}
+
 // ---------------------------------------------------------------------------
 // Vector table helpers
 // ---------------------------------------------------------------------------
 
-// CreateVecTable creates the vec0 virtual table with the given embedding dimension.
-// It is safe to call when the table already exists (uses IF NOT EXISTS).
-func (d *DB) CreateVecTable(dim int) error { return d.createVecTable(dim) }
+// CreateVecTable creates the vec0 virtual tables for memory and detail-chunk
+// vectors with the given embedding dimension, DistanceMetric and
+// Quantization. It is safe to call when the tables already exist (uses IF
+// NOT EXISTS).
+func (d *DB) CreateVecTable(dim int) error { return d.createVecTable(dim, d.Layout()) }
+
+func (d *DB) createVecTable(dim int, l VectorLayout) error {
+	for _, name := range []string{"memories_vec", "memory_chunks_vec"} {
+		if _, err := d.db.Exec(vecTableDDL(name, dim, l)); err != nil {
+			return err
+		}
+	}
+	for k, v := range layoutMeta(l) {
+		if err := d.SetMeta(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-func (d *DB) createVecTable(dim int) error {
-	_, err := d.db.Exec(fmt.Sprintf(
-		`CREATE VIRTUAL TABLE IF NOT EXISTS memories_vec USING vec0(
+// vecTableDDL returns the CREATE statement of a vec0 table. project is a
+// partition key and source/category are metadata columns, so filters on them
+// are applied inside the k-NN scan instead of after it. Float copies of
+// quantized vectors are an auxiliary column, stored but not indexed.
+func vecTableDDL(name string, dim int, l VectorLayout) string {
+	column := fmt.Sprintf("float[%d]", dim)
+	switch l.Quantization {
+	case QuantInt8:
+		column = fmt.Sprintf("int8[%d]", dim)
+	case QuantBit:
+		// Bit vectors only support Hamming distance.
+		column = fmt.Sprintf("bit[%d]", dim)
+	}
+	if l.Metric == MetricCosine && l.Quantization != QuantBit {
+		column += " distance_metric=cosine"
+	}
+	aux := ""
+	if l.Rescorable {
+		aux = ",\n\t\t\t+embedding_float blob"
+	}
+	return fmt.Sprintf(
+		`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING vec0(
 			rowid INTEGER PRIMARY KEY,
-			embedding float[%d]
-		)`, dim,
-	))
-	return err
+			embedding %s,
+			project text partition key,
+			source text,
+			category text%s
+		)`, name, column, aux,
+	)
+}
+
+// layoutMeta returns the meta entries recording l.
+func layoutMeta(l VectorLayout) map[string]string {
+	return map[string]string{
+		"distance_metric":     l.Metric,
+		"vector_quantization": l.Quantization,
+		"vector_rescorable":   strconv.FormatBool(l.Rescorable),
+	}
+}
+
+// metric returns the metric new vector tables are built with.
+func (d *DB) metric() string {
+	if d.DistanceMetric == "" {
+		return MetricL2
+	}
+	return d.DistanceMetric
+}
+
+// Layout returns the layout vector tables are built with from now on.
+func (d *DB) Layout() VectorLayout {
+	l := VectorLayout{Metric: d.metric(), Quantization: d.Quantization}
+	if l.Quantization == "" {
+		l.Quantization = QuantFloat
+	}
+	l.Rescorable = l.Quantization != QuantFloat && d.Rescore > 0
+	return l
+}
+
+// StoredLayout returns the layout of the current vector tables. Tables
+// built before the layout was recorded hold float vectors under MetricL2.
+func (d *DB) StoredLayout() (VectorLayout, error) {
+	l := VectorLayout{Metric: MetricL2, Quantization: QuantFloat}
+	for key, dst := range map[string]*string{
+		"distance_metric":     &l.Metric,
+		"vector_quantization": &l.Quantization,
+	} {
+		v, ok, err := d.GetMeta(key)
+		if err != nil {
+			return l, err
+		}
+		if ok {
+			*dst = v
+		}
+	}
+	v, _, err := d.GetMeta("vector_rescorable")
+	l.Rescorable = v == "true"
+	return l, err
+}
+
+// StoredMetric returns the distance metric of the current vector tables.
+// Tables built before the metric was recorded use MetricL2.
+func (d *DB) StoredMetric() (string, error) {
+	l, err := d.StoredLayout()
+	return l.Metric, err
+}
+
+// similaritySQL converts the distance column of vector hits h under metric
+// into a similarity in [0, 1], 1 being identical.
+func similaritySQL(metric string) string {
+	switch metric {
+	case MetricCosine:
+		// Cosine distance is 1 - cos, in [0, 2].
+		return "(1.0 - h.distance / 2.0)"
+	case MetricDot:
+		// Vectors are unit length, so dot = 1 - l2²/2, in [-1, 1].
+		return "MAX(1.0 - h.distance * h.distance / 4.0, 0.0)"
+	}
+	return "(1.0 / (1.0 + h.distance))"
+}
+
+// hitSQL returns the expression for the distance of a k-NN hit from vec0
+// table v of layout l, and the one converting the distances of hits h into
+// similarities. With rescore the distance is recomputed from the hit's float
+// copy, bound to the query vector; otherwise quantized distances are scaled
+// back to the range of float ones. Hamming distances of dim-bit vectors
+// estimate the angle, giving a similarity calibrated like cosine.
+func hitSQL(l VectorLayout, dim int, rescore bool) (distance, similarity string) {
+	switch {
+	case rescore && l.Metric == MetricCosine:
+		return "vec_distance_cosine(v.embedding_float, ?)", similaritySQL(l.Metric)
+	case rescore:
+		return "vec_distance_l2(v.embedding_float, ?)", similaritySQL(l.Metric)
+	case l.Quantization == QuantBit:
+		return "v.distance", fmt.Sprintf("(1.0 - h.distance / %d.0)", dim)
+	case l.Quantization == QuantInt8 && l.Metric != MetricCosine:
+		return fmt.Sprintf("(v.distance / %g)", int8Scale), similaritySQL(l.Metric)
+	}
+	return "v.distance", similaritySQL(l.Metric)
+}
+
+// quantizeSQL returns the expression converting a bound float32 vector into
+// the representation of l.
+func quantizeSQL(l VectorLayout) string {
+	switch l.Quantization {
+	case QuantInt8:
+		return "vec_quantize_int8(?, 'unit')"
+	case QuantBit:
+		return "vec_quantize_binary(?)"
+	}
+	return "?"
+}
+
+// vecColumns lists the columns of a vec0 table of layout l.
+func vecColumns(l VectorLayout) string {
+	if l.Rescorable {
+		return "rowid, embedding, project, source, category, embedding_float"
+	}
+	return "rowid, embedding, project, source, category"
+}
+
+// copyVecSQL selects the columns of vec0 table v for insertion into another
+// table of the same layout l. vec0 only takes non-float vectors tagged with
+// their type.
+func copyVecSQL(l VectorLayout) string {
+	embedding := "v.embedding"
+	switch l.Quantization {
+	case QuantInt8:
+		embedding = "vec_int8(v.embedding)"
+	case QuantBit:
+		embedding = "vec_bit(v.embedding)"
+	}
+	cols := "v.rowid, " + embedding + ", v.project, v.source, v.category"
+	if l.Rescorable {
+		cols += ", v.embedding_float"
+	}
+	return cols
+}
+
+// floatColumn names the column holding full-precision vectors in l.
+func floatColumn(l VectorLayout) string {
+	if l.Rescorable {
+		return "embedding_float"
+	}
+	return "embedding"
+}
+
+// decodeVector converts a stored vector of type quant back to float32.
+// Quantized vectors only approximate the vector stored.
+func decodeVector(b []byte, quant string) []float32 {
+	switch quant {
+	case QuantInt8:
+		out := make([]float32, len(b))
+		for i, v := range b {
+			out[i] = float32(int8(v)) / int8Scale
+		}
+		return out
+	case QuantBit:
+		out := make([]float32, 8*len(b))
+		for i := range out {
+			out[i] = -1
+			if b[i/8]&(1<<(i%8)) != 0 {
+				out[i] = 1
+			}
+		}
+		return out
+	}
+	return bytesToFloat32s(b)
+}
+
+// prepareVector normalizes embedding to unit length for cosine and dot
+// tables, so the stored vectors compare consistently wherever they are used
+// and L2 distances rank like inner products, and for int8 ones, whose
+// quantization expects components in [-1, 1].
+func prepareVector(embedding []float32, l VectorLayout) []float32 {
+	if !l.unitLength() && l.Quantization != QuantInt8 {
+		return embedding
+	}
+	var sum float64
+	for _, v := range embedding {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return embedding
+	}
+	norm := math.Sqrt(sum)
+	out := make([]float32, len(embedding))
+	for i, v := range embedding {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out
+}
+
+// migrateVecMetadata rebuilds a memories_vec table created before it carried
+// project/source/category columns, copying the stored vectors across.
+func (d *DB) migrateVecMetadata(dim int) error {
+	cols, err := d.tableColumns("memories_vec")
+	if err != nil || cols["project"] {
+		return err
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// vec0 does not rename its shadow tables, so the vectors are staged in a
+	// plain temp table while memories_vec is dropped and recreated.
+	stmts := []string{
+		`DROP TABLE IF EXISTS temp.memories_vec_old`,
+		`CREATE TEMP TABLE memories_vec_old AS SELECT rowid AS id, embedding FROM memories_vec`,
+		`DROP TABLE memories_vec`,
+		vecTableDDL("memories_vec", dim, VectorLayout{Metric: MetricL2, Quantization: QuantFloat}),
+		`INSERT INTO memories_vec (rowid, embedding, project, source, category)
+		 SELECT o.id, o.embedding, m.project, COALESCE(m.source, ''), lower(COALESCE(m.category, ''))
+		 FROM temp.memories_vec_old o
+		 JOIN memories m ON m.rowid = o.id`,
+		`DROP TABLE temp.memories_vec_old`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
 // HasVecTable returns true if the memories_vec table exists.
@@ -177,10 +634,20 @@ func (d *DB) HasVecTable() (bool, error) {
 	return err == nil, err
 }
 
-// DropVecTable drops the memories_vec virtual table if it exists.
+// DropVecTable drops the vector tables, if they exist, and forgets the
+// detail chunks.
 func (d *DB) DropVecTable() error {
-	_, err := d.db.Exec("DROP TABLE IF EXISTS memories_vec")
-	return err
+	for _, stmt := range []string{
+		"DROP TABLE IF EXISTS memories_vec",
+		"DROP TABLE IF EXISTS memory_chunks_vec",
+		"DELETE FROM memory_chunks",
+		"DELETE FROM memory_embeddings",
+	} {
+		if _, err := d.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // GetEmbeddingDim reads the stored embedding dimension from the meta table.
@@ -201,9 +668,22 @@ func (d *DB) SetEmbeddingDim(dim int) error {
 	return d.SetMeta("embedding_dim", strconv.Itoa(dim))
 }
 
-// EnsureVecTable ensures the vector table exists with the given dimension.
-// Returns ErrDimensionMismatch if the stored dimension differs.
-func (d *DB) EnsureVecTable(dim int) error {
+// GetEmbeddingModel reads the embedding model ("provider/model") the stored
+// vectors were built with. Databases indexed before it was recorded have none.
+func (d *DB) GetEmbeddingModel() (string, bool, error) {
+	return d.GetMeta("embedding_model")
+}
+
+// SetEmbeddingModel persists the embedding model in the meta table.
+func (d *DB) SetEmbeddingModel(model string) error {
+	return d.SetMeta("embedding_model", model)
+}
+
+// EnsureVecTable ensures the vector table exists for dim-dimensional vectors
+// from model. Returns ErrDimensionMismatch if the stored dimension differs
+// and ErrModelMismatch if the stored model does. A database without a
+// recorded model adopts model.
+func (d *DB) EnsureVecTable(dim int, model string) error {
 	stored, ok, err := d.GetEmbeddingDim()
 	if err != nil {
 		return err
@@ -212,12 +692,26 @@ func (d *DB) EnsureVecTable(dim int) error {
 		if err := d.SetEmbeddingDim(dim); err != nil {
 			return err
 		}
-		return d.createVecTable(dim)
+		if err := d.SetEmbeddingModel(model); err != nil {
+			return err
+		}
+		return d.createVecTable(dim, d.Layout())
 	}
 	if stored != dim {
 		return fmt.Errorf("%w: database has %d, provider returned %d. Run 'memory reindex' to rebuild",
 			ErrDimensionMismatch, stored, dim)
 	}
+	storedModel, ok, err := d.GetEmbeddingModel()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return d.SetEmbeddingModel(model)
+	}
+	if storedModel != model {
+		return fmt.Errorf("%w: database has %s, provider is %s. Run 'memory reindex' to rebuild",
+			ErrModelMismatch, storedModel, model)
+	}
 	return nil
 }
 
@@ -265,24 +759,157 @@ func (d *DB) InsertMemory(mem *models.Memory, details string) (int64, error) {
 			return rowid, fmt.Errorf("InsertMemory details: %w", err)
 		}
 	}
+	if err := d.setMemoryFiles(mem.ID, mem.RelatedFiles); err != nil {
+		return rowid, fmt.Errorf("InsertMemory files: %w", err)
+	}
 	return rowid, nil
 }
 
-// InsertVector stores an embedding vector for the given memory rowid.
+// InsertVector stores an embedding vector for the given memory rowid, along
+// with the memory's project, source and category used to filter k-NN queries.
 // Silently skips if the vec table does not exist.
 func (d *DB) InsertVector(rowid int64, embedding []float32) error {
 	ok, err := d.HasVecTable()
 	if err != nil || !ok {
 		return err
 	}
-	b := float32sToBytes(embedding)
-	_, err = d.db.Exec(
-		`INSERT OR REPLACE INTO memories_vec (rowid, embedding) VALUES (?, ?)`,
-		rowid, b,
+	layout, err := d.StoredLayout()
+	if err != nil {
+		return err
+	}
+	return insertVector(d.db, liveVecs, rowid, embedding, layout)
+}
+
+// InsertChunkVectors replaces the detail-chunk vectors of the memory with
+// the given rowid, one per chunk in order. An empty embeddings removes them.
+// Silently skips if the vec table does not exist.
+func (d *DB) InsertChunkVectors(rowid int64, embeddings [][]float32) error {
+	ok, err := d.HasVecTable()
+	if err != nil || !ok {
+		return err
+	}
+	layout, err := d.StoredLayout()
+	if err != nil {
+		return err
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := insertChunks(tx, liveVecs, rowid, embeddings, layout); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// vecSet names the tables holding one generation of vectors: the live one
+// searched by queries, or the one a reindex is building.
+type vecSet struct {
+	vec, chunks, chunksVec, states string
+}
+
+var (
+	liveVecs = vecSet{"memories_vec", "memory_chunks", "memory_chunks_vec", "memory_embeddings"}
+	nextVecs = vecSet{"memories_vec_next", "memory_chunks_next", "memory_chunks_vec_next", "memory_embeddings_next"}
+)
+
+// insertVector replaces the memory vector of rowid in set, of layout l.
+// vec0 cannot replace a row in place once it has a partition key.
+func insertVector(ex execer, set vecSet, rowid int64, embedding []float32, l VectorLayout) error {
+	if _, err := ex.Exec(`DELETE FROM `+set.vec+` WHERE rowid = ?`, rowid); err != nil { // #nosec G202 -- table names come from the fixed vecSet values
+		return err
+	}
+	_, err := ex.Exec(`
+		INSERT INTO `+set.vec+` (`+vecColumns(l)+`)
+		SELECT rowid, `+vecValuesSQL(l)+`
+		FROM memories WHERE rowid = ?`, // #nosec G202 -- table names and columns come from fixed values
+		append(vecArgs(embedding, l), rowid)...,
 	)
 	return err
 }
 
+// vecValuesSQL returns the values of a vec0 row of layout l after its rowid,
+// taking the metadata from memories and the vector from vecArgs.
+func vecValuesSQL(l VectorLayout) string {
+	values := quantizeSQL(l) + ", project, COALESCE(source, ''), lower(COALESCE(category, ''))"
+	if l.Rescorable {
+		values += ", ?"
+	}
+	return values
+}
+
+// vecArgs returns the arguments binding embedding to vecValuesSQL(l).
+func vecArgs(embedding []float32, l VectorLayout) []any {
+	b := float32sToBytes(prepareVector(embedding, l))
+	if l.Rescorable {
+		return []any{b, b}
+	}
+	return []any{b}
+}
+
+// insertChunks replaces the detail-chunk vectors of rowid in set, of
+// layout l.
+func insertChunks(ex execer, set vecSet, rowid int64, embeddings [][]float32, l VectorLayout) error {
+	if err := deleteChunks(ex, set, rowid); err != nil {
+		return err
+	}
+	for seq, embedding := range embeddings {
+		res, err := ex.Exec(`INSERT INTO `+set.chunks+` (memory_rowid, seq) VALUES (?, ?)`, rowid, seq) // #nosec G202 -- table names come from the fixed vecSet values
+		if err != nil {
+			return err
+		}
+		chunkID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		args := slices.Concat([]any{chunkID}, vecArgs(embedding, l), []any{rowid})
+		if _, err := ex.Exec(`
+			INSERT INTO `+set.chunksVec+` (`+vecColumns(l)+`)
+			SELECT ?, `+vecValuesSQL(l)+`
+			FROM memories WHERE rowid = ?`, // #nosec G202 -- table names and columns come from fixed values
+			args...,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execer is the part of *sql.DB and *sql.Tx used by deleteChunks.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// deleteChunks removes the detail chunks of a memory in set and their
+// vectors.
+func deleteChunks(ex execer, set vecSet, rowid int64) error {
+	if _, err := ex.Exec(`
+		DELETE FROM `+set.chunksVec+`
+		WHERE rowid IN (SELECT rowid FROM `+set.chunks+` WHERE memory_rowid = ?)`, rowid); err != nil { // #nosec G202 -- table names come from the fixed vecSet values
+		return err
+	}
+	_, err := ex.Exec(`DELETE FROM `+set.chunks+` WHERE memory_rowid = ?`, rowid) // #nosec G202 -- table names come from the fixed vecSet values
+	return err
+}
+
+// deleteVectors removes every vector stored for a memory. Errors are
+// non-fatal: the vec tables may not exist yet.
+func (d *DB) deleteVectors(rowid int64) {
+	if _, err := d.db.Exec(`DELETE FROM memories_vec WHERE rowid = ?`, rowid); err != nil {
+		slog.Debug("vec cleanup skipped", "err", err)
+	}
+	if err := deleteChunks(d.db, liveVecs, rowid); err != nil {
+		slog.Debug("chunk cleanup skipped", "err", err)
+	}
+	if _, err := d.db.Exec(`DELETE FROM memory_embeddings WHERE memory_rowid = ?`, rowid); err != nil {
+		slog.Debug("embedding state cleanup skipped", "err", err)
+	}
+	if err := d.DequeueEmbedding(rowid); err != nil {
+		slog.Debug("pending embedding cleanup skipped", "err", err)
+	}
+}
+
 // GetMemory fetches a single memory by exact ID.
 func (d *DB) GetMemory(id string) (map[string]any, bool, error) {
 	rows, err := d.db.Query(`
@@ -405,11 +1032,11 @@ func (d *DB) DeleteMemory(id string) (bool, error) {
 	if _, err := d.db.Exec(`DELETE FROM memory_details WHERE memory_id = ?`, fullID); err != nil {
 		return false, err
 	}
-	// Clean up vector index before deleting the memory row (rowid is needed).
-	if _, err := d.db.Exec(`DELETE FROM memories_vec WHERE rowid = ?`, rowid); err != nil {
-		// Non-fatal: vec table may not exist yet.
-		slog.Debug("DeleteMemory: vec cleanup skipped", "err", err)
+	if _, err := d.db.Exec(`DELETE FROM memory_files WHERE memory_id = ?`, fullID); err != nil {
+		return false, err
 	}
+	// Clean up vector index before deleting the memory row (rowid is needed).
+	d.deleteVectors(rowid)
 	if _, err := d.db.Exec(`DELETE FROM memories WHERE id = ?`, fullID); err != nil {
 		return false, err
 	}
@@ -461,9 +1088,10 @@ func (d *DB) DeleteByFilter(project, category string, before time.Time) (int, er
 		if _, err := d.db.Exec(`DELETE FROM memory_details WHERE memory_id = ?`, e.id); err != nil {
 			return 0, fmt.Errorf("DeleteByFilter: details: %w", err)
 		}
-		if _, err := d.db.Exec(`DELETE FROM memories_vec WHERE rowid = ?`, e.rowid); err != nil {
-			slog.Debug("DeleteByFilter: vec cleanup skipped", "err", err)
+		if _, err := d.db.Exec(`DELETE FROM memory_files WHERE memory_id = ?`, e.id); err != nil {
+			return 0, fmt.Errorf("DeleteByFilter: files: %w", err)
 		}
+		d.deleteVectors(e.rowid)
 		if _, err := d.db.Exec(`DELETE FROM memories WHERE id = ?`, e.id); err != nil {
 			return 0, fmt.Errorf("DeleteByFilter: memory: %w", err)
 		}
@@ -508,6 +1136,18 @@ func (d *DB) ReplaceMemory(id, title, what, why, impact string, tags, relatedFil
 	if err != nil {
 		return false, fmt.Errorf("ReplaceMemory: update: %w", err)
 	}
+	if ok, vecErr := d.HasVecTable(); vecErr == nil && ok {
+		for _, stmt := range []string{
+			`UPDATE memories_vec SET category = lower(?)
+			WHERE rowid = (SELECT rowid FROM memories WHERE id = ?)`,
+			`UPDATE memory_chunks_vec SET category = lower(?)
+			WHERE rowid IN (SELECT c.rowid FROM memory_chunks c JOIN memories m ON m.rowid = c.memory_rowid WHERE m.id = ?)`,
+		} {
+			if _, err := d.db.Exec(stmt, category, fullID); err != nil {
+				return false, fmt.Errorf("ReplaceMemory: vector metadata: %w", err)
+			}
+		}
+	}
 
 	if details != "" {
 		_, err = d.db.Exec(
@@ -520,6 +1160,9 @@ func (d *DB) ReplaceMemory(id, title, what, why, impact string, tags, relatedFil
 	if err != nil {
 		return false, fmt.Errorf("ReplaceMemory: details: %w", err)
 	}
+	if err := d.setMemoryFiles(fullID, relatedFiles); err != nil {
+		return false, fmt.Errorf("ReplaceMemory: files: %w", err)
+	}
 	return true, nil
 }
 
@@ -527,109 +1170,367 @@ func (d *DB) ReplaceMemory(id, title, what, why, impact string, tags, relatedFil
 // Search
 // ---------------------------------------------------------------------------
 
-// FTSSearch performs a BM25 full-text search over memories.
-func (d *DB) FTSSearch(query string, limit int, project, source string) ([]map[string]any, error) {
-	if query == "" {
+// FTSSearch performs a BM25 full-text search over memory summaries (not
+// details), matching any of the whitespace-separated terms in text as a prefix.
+func (d *DB) FTSSearch(text string, limit int, project, source string) ([]map[string]any, error) {
+	if text == "" {
 		return nil, nil
 	}
 
 	// Build "term1"* OR "term2"* FTS5 query.
-	terms := strings.Fields(query)
+	terms := strings.Fields(text)
 	ftsParts := make([]string, len(terms))
 	for i, t := range terms {
 		ftsParts[i] = `"` + strings.ReplaceAll(t, `"`, `""`) + `"*`
 	}
-	ftsQuery := strings.Join(ftsParts, " OR ")
+	rows, err := d.QuerySearch(query.Compiled{Match: strings.Join(ftsParts, " OR ")}, limit, project, source)
+	if err != nil {
+		return nil, fmt.Errorf("FTSSearch: %w", err)
+	}
+	return rows, nil
+}
 
-	where, params := buildWhere("m", project, source)
-	// The FTS query already has WHERE fts.memories_fts MATCH ?; additional
-	// project/source filters must be AND conditions, not a second WHERE clause.
-	where = strings.Replace(where, " WHERE ", " AND ", 1)
-	params = append([]any{ftsQuery}, params...)
-	params = append(params, limit)
+// detailsWeight scales BM25 scores of detail-body matches so they rank below
+// comparable matches in the title and summary fields.
+const detailsWeight = 0.5
+
+// snippetArgs are the snippet() arguments after the column index: highlight
+// markers, ellipsis and maximum tokens.
+const snippetArgs = `'**', '**', '…', 16`
 
-	ftsQ := `
-		SELECT m.*, -fts.rank AS score,
+// QuerySearch runs a compiled structured query (see package query) against
+// the memories_fts and details_fts indexes. Rows are BM25-ranked, with detail
+// matches weighted by detailsWeight, and carry a "snippet" excerpt with the
+// matched terms wrapped in ** from whichever index scored best. A filter-only
+// query returns the newest matching memories with a score of 0.
+func (d *DB) QuerySearch(c query.Compiled, limit int, project, source string) ([]map[string]any, error) {
+	where, params := buildWhere("m", project, source)
+	clauses := c.Where
+	if where != "" {
+		clauses = append([]string{strings.TrimPrefix(where, " WHERE ")}, clauses...)
+	}
+	params = append(params, c.Args...)
+
+	var q string
+	if c.Match != "" || c.DetailsMatch != "" {
+		var hits []string
+		var matchParams []any
+		if c.Match != "" {
+			hits = append(hits, `SELECT rowid, -rank AS score, snippet(memories_fts, -1, `+snippetArgs+`) AS snippet
+			FROM memories_fts WHERE memories_fts MATCH ?`)
+			matchParams = append(matchParams, c.Match)
+		}
+		if c.DetailsMatch != "" {
+			hits = append(hits, `SELECT rowid, -rank * `+strconv.FormatFloat(detailsWeight, 'f', -1, 64)+` AS score, snippet(details_fts, 0, `+snippetArgs+`) AS snippet
+			FROM details_fts WHERE details_fts MATCH ?`)
+			matchParams = append(matchParams, c.DetailsMatch)
+		}
+		// snippet() is only valid while its FTS table is being scanned, so
+		// the hits are materialized before they are aggregated. MAX(score)
+		// makes SQLite take the bare snippet column from the best-scoring
+		// hit of each memory.
+		q = `
+		WITH hits AS MATERIALIZED (
+			` + strings.Join(hits, "\n\t\t\tUNION ALL\n\t\t\t") + `
+		)
+		SELECT m.*, h.score, h.snippet,
+		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
+		FROM (
+			SELECT rowid, SUM(score) AS score, snippet, MAX(score) AS best
+			FROM hits
+			GROUP BY rowid
+		) h
+		JOIN memories m ON m.rowid = h.rowid` // #nosec G202 -- subqueries are fixed SQL; MATCH expressions flow through ? bound parameters
+		if len(clauses) > 0 {
+			q += "\n\t\tWHERE " + strings.Join(clauses, "\n\t\t  AND ") // #nosec G202 -- predicates are fixed SQL from package query and buildWhere; values flow through ? bound parameters
+		}
+		q += "\n\t\tORDER BY h.score DESC, m.id"
+		params = append(matchParams, params...)
+	} else {
+		if len(clauses) == 0 {
+			return nil, nil
+		}
+		q = `
+		SELECT m.*, 0.0 AS score, '' AS snippet,
 		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
-		FROM memories_fts fts
-		JOIN memories m ON m.rowid = fts.rowid
-		WHERE fts.memories_fts MATCH ?`
-	q := ftsQ + where + "\n\t\tORDER BY fts.rank\n\t\tLIMIT ?" // #nosec G202 -- AND clause uses hardcoded column names only; values flow through ? bound parameters
+		FROM memories m
+		WHERE ` + strings.Join(clauses, "\n\t\t  AND ") + // #nosec G202 -- predicates are fixed SQL from package query and buildWhere; values flow through ? bound parameters
+			"\n\t\tORDER BY m.created_at DESC, m.id DESC"
+	}
+	q += "\n\t\tLIMIT ?"
+	params = append(params, limit)
 
 	rows, err := d.db.Query(q, params...)
 	if err != nil {
-		return nil, fmt.Errorf("FTSSearch: %w", err)
+		return nil, fmt.Errorf("QuerySearch: %w", err)
 	}
 	defer rows.Close()
-	return scanRows(rows)
+	out, err := scanRows(rows)
+	for _, r := range out {
+		delete(r, "best")
+	}
+	return out, err
 }
 
 // VectorSearch performs approximate nearest-neighbour search using sqlite-vec.
 func (d *DB) VectorSearch(queryEmbedding []float32, limit int, project, source string) ([]map[string]any, error) {
+	return d.VectorQuerySearch(queryEmbedding, limit, project, source, query.Compiled{})
+}
+
+// VectorsByID returns the stored embeddings of the given memories, keyed by
+// memory ID. Memories without a vector are omitted. Quantized vectors
+// without float copies are approximations.
+func (d *DB) VectorsByID(ids []string) (map[string][]float32, error) {
 	ok, err := d.HasVecTable()
-	if err != nil || !ok {
+	if err != nil || !ok || len(ids) == 0 {
 		return nil, err
 	}
-
-	vecBytes := float32sToBytes(queryEmbedding)
-
+	layout, err := d.StoredLayout()
+	if err != nil {
+		return nil, fmt.Errorf("VectorsByID: %w", err)
+	}
+	quant := layout.Quantization
+	if layout.Rescorable {
+		quant = QuantFloat
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
 	rows, err := d.db.Query(`
-		SELECT m.*, v.distance,
-		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
-		FROM memories_vec v
-		JOIN memories m ON m.rowid = v.rowid
-		WHERE v.embedding MATCH ? AND k = ?
-		ORDER BY v.distance`,
-		vecBytes, limit,
-	)
+		SELECT m.id, v.`+floatColumn(layout)+`
+		FROM memories m
+		JOIN memories_vec v ON v.rowid = m.rowid
+		WHERE m.id IN (`+placeholders+`)`, args...) // #nosec G202 -- only ? placeholders and a fixed column name are interpolated
 	if err != nil {
-		return nil, fmt.Errorf("VectorSearch: %w", err)
+		return nil, fmt.Errorf("VectorsByID: %w", err)
 	}
 	defer rows.Close()
 
-	all, err := scanRows(rows)
-	if err != nil {
+	out := make(map[string][]float32, len(ids))
+	for rows.Next() {
+		var id string
+		var b []byte
+		if err := rows.Scan(&id, &b); err != nil {
+			return nil, fmt.Errorf("VectorsByID: %w", err)
+		}
+		out[id] = decodeVector(b, quant)
+	}
+	return out, rows.Err()
+}
+
+// maxVecK is the largest k sqlite-vec accepts in a k-NN query.
+const maxVecK = 4096
+
+// VectorQuerySearch is VectorSearch narrowed by the SQL predicates of a
+// compiled structured query. c.Match is ignored.
+//
+// Both the memory vectors and the detail-chunk vectors are searched; the hits
+// of each memory are combined as set by d.ChunkAggregate into its "score",
+// and "distance" is its nearest hit.
+//
+// With quantized vectors stored alongside float copies and d.Rescore set,
+// at least that many nearest candidates are fetched and re-scored with
+// their float copies.
+//
+// Project, source and c.Category are matched inside the k-NN scans, so the k
+// nearest vectors are already in scope. The remaining predicates can only be
+// applied to the k rows returned, and several chunks may belong to the same
+// memory; when fewer than limit memories result, the search is repeated with
+// a larger k until every vector in scope has been considered.
+func (d *DB) VectorQuerySearch(queryEmbedding []float32, limit int, project, source string, c query.Compiled) ([]map[string]any, error) {
+	ok, err := d.HasVecTable()
+	if err != nil || !ok || limit <= 0 {
 		return nil, err
 	}
 
-	// Convert distance → score and post-filter by project/source.
-	results := make([]map[string]any, 0, len(all))
-	for _, r := range all {
-		if project != "" {
-			if p, _ := r["project"].(string); p != project {
-				continue
-			}
+	var scope []string
+	var scopeArgs []any
+	if project != "" {
+		scope = append(scope, "v.project = ?")
+		scopeArgs = append(scopeArgs, project)
+	}
+	if source != "" {
+		scope = append(scope, "v.source = ?")
+		scopeArgs = append(scopeArgs, source)
+	}
+	if c.Category != "" {
+		scope = append(scope, "v.category = ?")
+		scopeArgs = append(scopeArgs, c.Category)
+	}
+	layout, err := d.StoredLayout()
+	if err != nil {
+		return nil, fmt.Errorf("VectorSearch: %w", err)
+	}
+	dim, _, err := d.GetEmbeddingDim()
+	if err != nil {
+		return nil, fmt.Errorf("VectorSearch: %w", err)
+	}
+	rescore := layout.Rescorable && d.Rescore > 0
+	knn := "v.embedding MATCH " + quantizeSQL(layout) + " AND k = ?"
+	for _, cl := range scope {
+		knn += " AND " + cl
+	}
+
+	distance, similarity := hitSQL(layout, dim, rescore)
+	aggregate := "MAX(" + similarity + ")"
+	if d.ChunkAggregate == AggregateSum {
+		aggregate = "SUM(" + similarity + ")"
+	}
+	q := `
+		WITH hits AS (
+			SELECT v.rowid AS memory_rowid, ` + distance + ` AS distance
+			FROM memories_vec v
+			WHERE ` + knn + `
+			UNION ALL
+			SELECT c.memory_rowid, ` + distance + `
+			FROM memory_chunks_vec v
+			JOIN memory_chunks c ON c.rowid = v.rowid
+			WHERE ` + knn + `
+		)
+		SELECT m.*, ` + aggregate + ` AS score, MIN(h.distance) AS distance,
+		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
+		FROM hits h
+		JOIN memories m ON m.rowid = h.memory_rowid` // #nosec G202 -- predicates and the aggregate are fixed SQL; values flow through ? bound parameters
+	if len(c.Where) > 0 {
+		q += "\n\t\tWHERE " + strings.Join(c.Where, "\n\t\t  AND ") // #nosec G202 -- predicates are fixed SQL from package query; values flow through ? bound parameters
+	}
+	q += "\n\t\tGROUP BY m.rowid\n\t\tORDER BY score DESC, distance, m.id"
+
+	vecBytes := float32sToBytes(prepareVector(queryEmbedding, layout))
+	k := min(limit, maxVecK)
+	var rescoreArgs []any
+	if rescore {
+		k = min(max(limit, d.Rescore), maxVecK)
+		rescoreArgs = []any{vecBytes}
+	}
+	inScope := -1
+	for {
+		knnArgs := slices.Concat(rescoreArgs, []any{vecBytes, k}, scopeArgs)
+		params := slices.Concat(knnArgs, knnArgs, c.Args)
+		all, err := d.queryRows(q, params...)
+		if err != nil {
+			return nil, fmt.Errorf("VectorSearch: %w", err)
 		}
-		if source != "" {
-			if s, _ := r["source"].(string); s != source {
-				continue
+		if len(all) >= limit || k >= maxVecK {
+			return truncateRows(all, limit), nil
+		}
+		if inScope < 0 {
+			inScope, err = d.countVectors(scope, scopeArgs)
+			if err != nil {
+				return nil, fmt.Errorf("VectorSearch: %w", err)
 			}
 		}
-		if dist, ok := r["distance"].(float64); ok {
-			r["score"] = 1.0 - dist
-			delete(r, "distance")
+		if k >= inScope {
+			return truncateRows(all, limit), nil
 		}
-		results = append(results, r)
+		k = min(k*4, maxVecK)
 	}
-	return results, nil
 }
 
-// ListRecent returns recently created memories, newest first.
-func (d *DB) ListRecent(limit int, project, source string) ([]map[string]any, error) {
+// queryRows runs q and scans every row.
+func (d *DB) queryRows(q string, args ...any) ([]map[string]any, error) {
+	rows, err := d.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+// countVectors returns the larger of the number of memory and chunk vectors
+// matching the scope predicates built by VectorQuerySearch.
+func (d *DB) countVectors(scope []string, args []any) (int, error) {
+	var most int
+	for _, table := range []string{"memories_vec", "memory_chunks_vec"} {
+		q := "SELECT count(*) FROM " + table + " v"
+		if len(scope) > 0 {
+			q += " WHERE " + strings.Join(scope, " AND ") // #nosec G202 -- predicates are fixed SQL; values flow through ? bound parameters
+		}
+		var n int
+		if err := d.db.QueryRow(q, args...).Scan(&n); err != nil {
+			return 0, err
+		}
+		most = max(most, n)
+	}
+	return most, nil
+}
+
+// truncateRows keeps the first limit rows.
+func truncateRows(rows []map[string]any, limit int) []map[string]any {
+	if len(rows) > limit {
+		return rows[:limit]
+	}
+	return rows
+}
+
+// ListRecent returns recently created memories, newest first. Pass the
+// cursor returned by a previous call to fetch the following page (or "" for
+// the first); the returned cursor is "" when there are no more rows. Memories
+// saved after the first page never appear on later pages.
+func (d *DB) ListRecent(limit int, project, source, cur string) ([]map[string]any, string, error) {
+	if limit <= 0 {
+		return nil, "", nil
+	}
+	scope := cursor.Scope("recent", project, source)
+	pos := cursor.Cursor{Scope: scope}
+	if cur != "" {
+		var err error
+		if pos, err = cursor.Decode(cur, scope); err != nil {
+			return nil, "", err
+		}
+	} else {
+		snapshot, err := d.MaxRowID()
+		if err != nil {
+			return nil, "", fmt.Errorf("ListRecent: %w", err)
+		}
+		pos.Snapshot = snapshot
+	}
+
 	where, params := buildWhere("m", project, source)
-	params = append(params, limit)
+	clauses := []string{"m.rowid <= ?"}
+	params = append(params, pos.Snapshot)
+	if pos.CreatedAt != "" {
+		clauses = append(clauses, "(m.created_at < ? OR (m.created_at = ? AND m.id < ?))")
+		params = append(params, pos.CreatedAt, pos.CreatedAt, pos.ID)
+	}
+	if where == "" {
+		where = " WHERE "
+	} else {
+		where += " AND "
+	}
+	where += strings.Join(clauses, " AND ")
+	params = append(params, limit+1)
 
 	listQ := `
-		SELECT m.id, m.title, m.category, m.tags, m.project, m.source, m.created_at,
+		SELECT m.id, m.title, m.what, m.category, m.tags, m.project, m.source, m.created_at,
 		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
 		FROM memories m`
-	listQ += where + "\n\t\tORDER BY m.created_at DESC\n\t\tLIMIT ?" // #nosec G202 -- WHERE clause uses hardcoded column names only; values flow through ? bound parameters
+	listQ += where + "\n\t\tORDER BY m.created_at DESC, m.id DESC\n\t\tLIMIT ?" // #nosec G202 -- WHERE clause uses hardcoded column names only; values flow through ? bound parameters
 	rows, err := d.db.Query(listQ, params...)
 	if err != nil {
-		return nil, fmt.Errorf("ListRecent: %w", err)
+		return nil, "", fmt.Errorf("ListRecent: %w", err)
 	}
 	defer rows.Close()
-	return scanRows(rows)
+	out, err := scanRows(rows)
+	if err != nil || len(out) <= limit {
+		return out, "", err
+	}
+
+	out = out[:limit]
+	last := out[limit-1]
+	pos.CreatedAt, _ = last["created_at"].(string)
+	pos.ID, _ = last["id"].(string)
+	return out, cursor.Encode(pos), nil
+}
+
+// MaxRowID returns the highest memories rowid, or 0 for an empty database.
+// Rowids only grow (AUTOINCREMENT), so it serves as a snapshot marker.
+func (d *DB) MaxRowID() (int64, error) {
+	var n sql.NullInt64
+	err := d.db.QueryRow(`SELECT MAX(rowid) FROM memories`).Scan(&n)
+	return n.Int64, err
 }
 
 // CountMemories returns the total number of memories matching optional filters.
@@ -641,10 +1542,14 @@ func (d *DB) CountMemories(project, source string) (int, error) {
 	return n, err
 }
 
-// ListAllForReindex returns all memories with fields needed for re-embedding.
+// ListAllForReindex returns all memories with fields needed for re-embedding,
+// including the details body ("" when there is none).
 func (d *DB) ListAllForReindex() ([]map[string]any, error) {
-	rows, err := d.db.Query(
-		`SELECT rowid, title, what, why, impact, tags FROM memories ORDER BY rowid`,
+	rows, err := d.db.Query(`
+		SELECT m.rowid, m.title, m.what, m.why, m.impact, m.tags, COALESCE(d.body, '') AS details
+		FROM memories m
+		LEFT JOIN memory_details d ON d.memory_id = m.id
+		ORDER BY m.rowid`,
 	)
 	if err != nil {
 		return nil, err
@@ -653,6 +1558,756 @@ func (d *DB) ListAllForReindex() ([]map[string]any, error) {
 	return scanRows(rows)
 }
 
+// ---------------------------------------------------------------------------
+// Embedding state and reindexing
+// ---------------------------------------------------------------------------
+
+// EmbeddingState records what a memory's stored vectors were computed from.
+type EmbeddingState struct {
+	Hash  string // hash of the embedded content and chunking settings
+	Model string // embedding provider and model
+}
+
+// SetEmbeddingState records the state of the vectors just stored for rowid.
+func (d *DB) SetEmbeddingState(rowid int64, st EmbeddingState) error {
+	_, err := d.db.Exec(
+		`INSERT OR REPLACE INTO memory_embeddings (memory_rowid, content_hash, model) VALUES (?, ?, ?)`,
+		rowid, st.Hash, st.Model,
+	)
+	return err
+}
+
+// EmbeddingStates returns the recorded state of every memory with vectors,
+// keyed by memory rowid.
+func (d *DB) EmbeddingStates() (map[int64]EmbeddingState, error) {
+	return d.embeddingStates(liveVecs)
+}
+
+func (d *DB) embeddingStates(set vecSet) (map[int64]EmbeddingState, error) {
+	rows, err := d.db.Query(`SELECT memory_rowid, content_hash, model FROM ` + set.states) // #nosec G202 -- table names come from the fixed vecSet values
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64]EmbeddingState)
+	for rows.Next() {
+		var rowid int64
+		var st EmbeddingState
+		if err := rows.Scan(&rowid, &st.Hash, &st.Model); err != nil {
+			return nil, err
+		}
+		out[rowid] = st
+	}
+	return out, rows.Err()
+}
+
+// reindexTargetKey is the meta key naming the model, dimension and layout
+// of the vectors staged by an unfinished reindex.
+const reindexTargetKey = "reindex_target"
+
+// ReindexTarget describes the vectors staged by an unfinished reindex as
+// "model@dim/metric"; ok is false when no reindex was interrupted.
+func (d *DB) ReindexTarget() (target string, ok bool, err error) {
+	return d.GetMeta(reindexTargetKey)
+}
+
+// BeginReindex prepares staging tables for a rebuild of the vector index
+// with dim-dimensional vectors from model; queries keep using the current
+// vectors until FinishReindex. If an interrupted reindex for the same model
+// and dimension left staged vectors behind they are kept, and their states
+// are returned so the caller can skip those memories.
+func (d *DB) BeginReindex(dim int, model string) (map[int64]EmbeddingState, error) {
+	target := fmt.Sprintf("%s@%d/%s", model, dim, d.Layout())
+	prev, ok, err := d.GetMeta(reindexTargetKey)
+	if err != nil {
+		return nil, fmt.Errorf("BeginReindex: %w", err)
+	}
+	if !ok || prev != target {
+		if err := d.dropVecSet(d.db, nextVecs); err != nil {
+			return nil, fmt.Errorf("BeginReindex: %w", err)
+		}
+	}
+	if err := createVecSet(d.db, nextVecs, dim, d.Layout()); err != nil {
+		return nil, fmt.Errorf("BeginReindex: %w", err)
+	}
+	if err := d.SetMeta(reindexTargetKey, target); err != nil {
+		return nil, fmt.Errorf("BeginReindex: %w", err)
+	}
+	staged, err := d.embeddingStates(nextVecs)
+	if err != nil {
+		return nil, fmt.Errorf("BeginReindex: %w", err)
+	}
+	return staged, nil
+}
+
+// StageVectors stores a memory's vectors and their state in the reindex
+// staging tables.
+func (d *DB) StageVectors(rowid int64, st EmbeddingState, embedding []float32, chunks [][]float32) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertVector(tx, nextVecs, rowid, embedding, d.Layout()); err != nil {
+		return fmt.Errorf("StageVectors: %w", err)
+	}
+	if err := insertChunks(tx, nextVecs, rowid, chunks, d.Layout()); err != nil {
+		return fmt.Errorf("StageVectors: %w", err)
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO memory_embeddings_next (memory_rowid, content_hash, model) VALUES (?, ?, ?)`,
+		rowid, st.Hash, st.Model,
+	); err != nil {
+		return fmt.Errorf("StageVectors: %w", err)
+	}
+	return tx.Commit()
+}
+
+// CanStageCurrent reports whether StageCurrent can carry the current vectors
+// over into the layout a reindex builds: they must either be stored the same
+// way or be kept at full precision, and not have lost their length to
+// normalization unless the new metric ignores it.
+func (d *DB) CanStageCurrent() (bool, error) {
+	live, err := d.StoredLayout()
+	if err != nil {
+		return false, err
+	}
+	next := d.Layout()
+	if live == next {
+		return true, nil
+	}
+	return live.floats() && (!live.unitLength() || next.unitLength()), nil
+}
+
+// StageCurrent copies a memory's current vectors and state into the reindex
+// staging tables, for memories that do not need new embeddings. Vectors
+// stored as the reindex stores them are copied as they are; others are
+// converted from their full-precision values.
+func (d *DB) StageCurrent(rowid int64, st EmbeddingState) error {
+	live, err := d.StoredLayout()
+	if err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	if live == d.Layout() {
+		return d.stageCopy(rowid, st, live)
+	}
+	if !live.floats() {
+		return fmt.Errorf("StageCurrent: %s vectors cannot be converted to %s", live, d.Layout())
+	}
+	col := floatColumn(live)
+	var b []byte
+	if err := d.db.QueryRow(`SELECT `+col+` FROM memories_vec WHERE rowid = ?`, rowid).Scan(&b); err != nil { // #nosec G202 -- col is a fixed column name
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	rows, err := d.db.Query(`
+		SELECT v.`+col+`
+		FROM memory_chunks c
+		JOIN memory_chunks_vec v ON v.rowid = c.rowid
+		WHERE c.memory_rowid = ?
+		ORDER BY c.seq`, rowid)
+	if err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	defer rows.Close()
+	var chunks [][]float32
+	for rows.Next() {
+		var cb []byte
+		if err := rows.Scan(&cb); err != nil {
+			return fmt.Errorf("StageCurrent: %w", err)
+		}
+		chunks = append(chunks, bytesToFloat32s(cb))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	return d.StageVectors(rowid, st, bytesToFloat32s(b), chunks)
+}
+
+// stageCopy copies the vectors of rowid, stored in layout l, unchanged into
+// the staging tables, which have the same layout.
+func (d *DB) stageCopy(rowid int64, st EmbeddingState, l VectorLayout) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.Exec(`DELETE FROM memories_vec_next WHERE rowid = ?`, rowid); err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO memories_vec_next (`+vecColumns(l)+`)
+		SELECT `+copyVecSQL(l)+` FROM memories_vec v WHERE v.rowid = ?`, rowid); err != nil { // #nosec G202 -- columns come from fixed values
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	if err := deleteChunks(tx, nextVecs, rowid); err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	var chunkIDs []int64
+	rows, err := tx.Query(`SELECT rowid FROM memory_chunks WHERE memory_rowid = ? ORDER BY seq`, rowid)
+	if err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("StageCurrent: %w", err)
+		}
+		chunkIDs = append(chunkIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	for seq, id := range chunkIDs {
+		res, err := tx.Exec(`INSERT INTO memory_chunks_next (memory_rowid, seq) VALUES (?, ?)`, rowid, seq)
+		if err != nil {
+			return fmt.Errorf("StageCurrent: %w", err)
+		}
+		nextID, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("StageCurrent: %w", err)
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO memory_chunks_vec_next (`+vecColumns(l)+`)
+			SELECT ?, `+strings.TrimPrefix(copyVecSQL(l), "v.rowid, ")+`
+			FROM memory_chunks_vec v WHERE v.rowid = ?`, nextID, id); err != nil { // #nosec G202 -- columns come from fixed values
+			return fmt.Errorf("StageCurrent: %w", err)
+		}
+	}
+	if _, err := tx.Exec(
+		`INSERT OR REPLACE INTO memory_embeddings_next (memory_rowid, content_hash, model) VALUES (?, ?, ?)`,
+		rowid, st.Hash, st.Model,
+	); err != nil {
+		return fmt.Errorf("StageCurrent: %w", err)
+	}
+	return tx.Commit()
+}
+
+// FinishReindex replaces the live vectors with the staged ones, built with
+// dim-dimensional vectors from model, in a single transaction and removes
+// the staging tables. Staged vectors of memories deleted in the meantime
+// are dropped.
+func (d *DB) FinishReindex(dim int, model string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("FinishReindex: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := d.dropVecSet(tx, liveVecs); err != nil {
+		return fmt.Errorf("FinishReindex: %w", err)
+	}
+	layout := d.Layout()
+	if err := createVecSet(tx, liveVecs, dim, layout); err != nil {
+		return fmt.Errorf("FinishReindex: %w", err)
+	}
+	live := `SELECT rowid FROM memories`
+	for _, stmt := range []string{
+		`INSERT INTO memories_vec (` + vecColumns(layout) + `)
+		 SELECT ` + copyVecSQL(layout) + ` FROM memories_vec_next v
+		 WHERE v.rowid IN (` + live + `)`,
+		`INSERT INTO memory_chunks (rowid, memory_rowid, seq)
+		 SELECT rowid, memory_rowid, seq FROM memory_chunks_next
+		 WHERE memory_rowid IN (` + live + `)`,
+		`INSERT INTO memory_chunks_vec (` + vecColumns(layout) + `)
+		 SELECT ` + copyVecSQL(layout) + ` FROM memory_chunks_vec_next v
+		 WHERE v.rowid IN (SELECT rowid FROM memory_chunks)`,
+		`INSERT INTO memory_embeddings (memory_rowid, content_hash, model)
+		 SELECT memory_rowid, content_hash, model FROM memory_embeddings_next
+		 WHERE memory_rowid IN (` + live + `)`,
+		`DELETE FROM pending_embeddings
+		 WHERE memory_rowid IN (SELECT memory_rowid FROM memory_embeddings)`,
+		`DELETE FROM meta WHERE key = '` + reindexTargetKey + `'`,
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("FinishReindex: %w", err)
+		}
+	}
+	meta := layoutMeta(layout)
+	meta["embedding_dim"] = strconv.Itoa(dim)
+	meta["embedding_model"] = model
+	for k, v := range meta {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, k, v); err != nil {
+			return fmt.Errorf("FinishReindex: %w", err)
+		}
+	}
+	if err := d.dropVecSet(tx, nextVecs); err != nil {
+		return fmt.Errorf("FinishReindex: %w", err)
+	}
+	return tx.Commit()
+}
+
+// ---------------------------------------------------------------------------
+// Pending embeddings
+// ---------------------------------------------------------------------------
+
+// QueueEmbedding records that the vectors of the memory with the given rowid
+// could not be computed, and why. Queueing it again counts another attempt.
+func (d *DB) QueueEmbedding(rowid int64, reason string) error {
+	_, err := d.db.Exec(`
+		INSERT INTO pending_embeddings (memory_rowid, reason, queued_at) VALUES (?, ?, ?)
+		ON CONFLICT(memory_rowid) DO UPDATE SET reason = excluded.reason, attempts = attempts + 1`,
+		rowid, reason, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return fmt.Errorf("QueueEmbedding: %w", err)
+	}
+	return nil
+}
+
+// DequeueEmbedding removes the memory with the given rowid from the queue.
+func (d *DB) DequeueEmbedding(rowid int64) error {
+	if _, err := d.db.Exec(`DELETE FROM pending_embeddings WHERE memory_rowid = ?`, rowid); err != nil {
+		return fmt.Errorf("DequeueEmbedding: %w", err)
+	}
+	return nil
+}
+
+// PendingEmbeddings returns up to limit queued memories, oldest first, with
+// the columns of ListAllForReindex. limit <= 0 returns all of them.
+func (d *DB) PendingEmbeddings(limit int) ([]map[string]any, error) {
+	if limit <= 0 {
+		limit = -1
+	}
+	rows, err := d.db.Query(`
+		SELECT m.rowid, m.title, m.what, m.why, m.impact, m.tags, COALESCE(d.body, '') AS details
+		FROM pending_embeddings p
+		JOIN memories m ON m.rowid = p.memory_rowid
+		LEFT JOIN memory_details d ON d.memory_id = m.id
+		ORDER BY p.queued_at, p.memory_rowid
+		LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("PendingEmbeddings: %w", err)
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+// QueueMissingEmbeddings queues every memory that has no vector and is not
+// queued yet, such as memories saved while embedding was disabled, and
+// returns how many it queued.
+func (d *DB) QueueMissingEmbeddings(reason string) (int, error) {
+	ok, err := d.HasVecTable()
+	if err != nil {
+		return 0, fmt.Errorf("QueueMissingEmbeddings: %w", err)
+	}
+	missing := `SELECT rowid FROM memories`
+	if ok {
+		missing += ` WHERE rowid NOT IN (SELECT rowid FROM memories_vec)`
+	}
+	res, err := d.db.Exec(`
+		INSERT INTO pending_embeddings (memory_rowid, reason, queued_at)
+		SELECT rowid, ?, ? FROM (`+missing+`)
+		WHERE true
+		ON CONFLICT(memory_rowid) DO NOTHING`, // #nosec G202 -- missing is built from fixed strings
+		reason, time.Now().UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("QueueMissingEmbeddings: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("QueueMissingEmbeddings: %w", err)
+	}
+	return int(n), nil
+}
+
+// PendingCount returns how many memories are queued for embedding.
+func (d *DB) PendingCount() (int, error) {
+	var n int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM pending_embeddings`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("PendingCount: %w", err)
+	}
+	return n, nil
+}
+
+// createVecSet creates the tables of set, if missing.
+func createVecSet(ex execer, set vecSet, dim int, l VectorLayout) error {
+	for _, stmt := range []string{
+		vecTableDDL(set.vec, dim, l),
+		vecTableDDL(set.chunksVec, dim, l),
+		`CREATE TABLE IF NOT EXISTS ` + set.chunks + ` (
+			rowid        INTEGER PRIMARY KEY AUTOINCREMENT,
+			memory_rowid INTEGER NOT NULL,
+			seq          INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS ` + set.chunks + `_memory ON ` + set.chunks + `(memory_rowid)`,
+		`CREATE TABLE IF NOT EXISTS ` + set.states + ` (
+			memory_rowid INTEGER PRIMARY KEY,
+			content_hash TEXT NOT NULL,
+			model        TEXT NOT NULL
+		)`,
+	} {
+		if _, err := ex.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropVecSet drops the vector tables of set. The live chunk and state
+// tables are part of the schema, so they are emptied instead.
+func (d *DB) dropVecSet(ex execer, set vecSet) error {
+	stmts := []string{"DROP TABLE IF EXISTS " + set.vec, "DROP TABLE IF EXISTS " + set.chunksVec}
+	if set == liveVecs {
+		stmts = append(stmts, "DELETE FROM "+set.chunks, "DELETE FROM "+set.states)
+	} else {
+		stmts = append(stmts, "DROP TABLE IF EXISTS "+set.chunks, "DROP TABLE IF EXISTS "+set.states)
+	}
+	for _, stmt := range stmts {
+		if _, err := ex.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Related files
+// ---------------------------------------------------------------------------
+
+// categoryRankSQL weights categories for file lookups: decisions and bug fixes
+// matter most before touching a file, ambient context the least.
+const categoryRankSQL = `CASE m.category
+			WHEN 'decision' THEN 1.0
+			WHEN 'bug'      THEN 0.9
+			WHEN 'pattern'  THEN 0.8
+			WHEN 'learning' THEN 0.7
+			ELSE 0.6
+		END`
+
+// setMemoryFiles replaces the indexed related-file paths for memoryID.
+// Each entry may carry a line range (see filepaths.ParseRef); paths are
+// otherwise stored as given, so callers are expected to normalize them first.
+// Recorded commit SHAs are cleared; see SetFileCommit.
+func (d *DB) setMemoryFiles(memoryID string, specs []string) error {
+	if _, err := d.db.Exec(`DELETE FROM memory_files WHERE memory_id = ?`, memoryID); err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		p, start, end := filepaths.ParseRef(spec)
+		if p == "" {
+			continue
+		}
+		var lineStart, lineEnd any
+		if start > 0 {
+			lineStart, lineEnd = start, end
+		}
+		if _, err := d.db.Exec(
+			`INSERT OR IGNORE INTO memory_files (memory_id, path, line_start, line_end) VALUES (?, ?, ?, ?)`,
+			memoryID, p, lineStart, lineEnd,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetFileCommit pins every related file of a memory (exact ID or prefix) to commitSHA.
+func (d *DB) SetFileCommit(memoryID, commitSHA string) error {
+	_, err := d.db.Exec(`
+		UPDATE memory_files SET commit_sha = ?
+		WHERE memory_id = (SELECT id FROM memories WHERE id LIKE ? LIMIT 1)`,
+		commitSHA, memoryID+"%",
+	)
+	return err
+}
+
+// FileRefs returns the related files of each memory in ids, keyed by memory ID.
+func (d *DB) FileRefs(ids []string) (map[string][]models.FileRef, error) {
+	out := make(map[string][]models.FileRef, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	params := make([]any, len(ids))
+	for i, id := range ids {
+		params[i] = id
+	}
+	q := `SELECT memory_id, path, COALESCE(commit_sha, ''), COALESCE(line_start, 0), COALESCE(line_end, 0)
+		FROM memory_files WHERE memory_id IN (` + placeholders + `) ORDER BY memory_id, path` // #nosec G202 -- only ? placeholders are interpolated
+	rows, err := d.db.Query(q, params...)
+	if err != nil {
+		return nil, fmt.Errorf("FileRefs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var ref models.FileRef
+		if err := rows.Scan(&id, &ref.Path, &ref.CommitSHA, &ref.LineStart, &ref.LineEnd); err != nil {
+			return nil, fmt.Errorf("FileRefs: scan: %w", err)
+		}
+		out[id] = append(out[id], ref)
+	}
+	return out, rows.Err()
+}
+
+// ListWithFileCommits returns memories (newest first) that have at least one
+// related file pinned to a commit, optionally filtered by project.
+func (d *DB) ListWithFileCommits(project string) ([]map[string]any, error) {
+	where, params := buildWhere("m", project, "")
+	where = strings.Replace(where, " WHERE ", " AND ", 1)
+	q := `
+		SELECT m.id, m.title, m.category, m.project, m.created_at
+		FROM memories m
+		WHERE EXISTS(SELECT 1 FROM memory_files f WHERE f.memory_id = m.id AND f.commit_sha IS NOT NULL)` +
+		where + "\n\t\tORDER BY m.created_at DESC" // #nosec G202 -- AND clause uses hardcoded column names only; values flow through ? bound parameters
+	rows, err := d.db.Query(q, params...)
+	if err != nil {
+		return nil, fmt.Errorf("ListWithFileCommits: %w", err)
+	}
+	defer rows.Close()
+	return scanRows(rows)
+}
+
+// backfillMemoryFiles indexes related_files for memories that have no
+// memory_files rows yet. It runs once; completion is recorded in meta.
+// Absolute paths are made relative to the root of their memory's project
+// (see projectRoots), as Save does for new memories.
+func (d *DB) backfillMemoryFiles() error {
+	if _, done, err := d.GetMeta("memory_files_backfilled"); err != nil || done {
+		return err
+	}
+	rows, err := d.db.Query(`
+		SELECT id, project, related_files FROM memories
+		WHERE related_files IS NOT NULL AND related_files NOT IN ('', 'null', '[]')`)
+	if err != nil {
+		return err
+	}
+	type legacy struct {
+		project string
+		files   []string
+	}
+	pending := make(map[string]legacy)
+	for rows.Next() {
+		var id, project, raw string
+		if err := rows.Scan(&id, &project, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		var files []string
+		if json.Unmarshal([]byte(raw), &files) == nil {
+			pending[id] = legacy{project, files}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	byProject := make(map[string][]string)
+	for _, l := range pending {
+		byProject[l.project] = append(byProject[l.project], l.files...)
+	}
+	roots := projectRoots(byProject)
+	for id, l := range pending {
+		if err := d.setMemoryFiles(id, filepaths.NormalizeAll(l.files, roots[l.project])); err != nil {
+			return err
+		}
+	}
+	if err := d.SetMeta("memory_files_backfilled", "1"); err != nil {
+		return err
+	}
+	// The paths just indexed need no relativizeMemoryFiles.
+	return d.SetMeta("memory_files_relativized", "1")
+}
+
+// relativizeMemoryFiles makes indexed absolute paths inside their memory's
+// project relative to its root, for paths backfilled before that was done.
+// Pinned commits and line ranges are kept. It runs once; completion is
+// recorded in meta.
+func (d *DB) relativizeMemoryFiles() error {
+	if _, done, err := d.GetMeta("memory_files_relativized"); err != nil || done {
+		return err
+	}
+	rows, err := d.db.Query(`
+		SELECT f.memory_id, f.path, m.project FROM memory_files f
+		JOIN memories m ON m.id = f.memory_id`)
+	if err != nil {
+		return err
+	}
+	type indexed struct{ id, path, project string }
+	var abs []indexed
+	byProject := make(map[string][]string)
+	for rows.Next() {
+		var f indexed
+		if err := rows.Scan(&f.id, &f.path, &f.project); err != nil {
+			rows.Close()
+			return err
+		}
+		if filepath.IsAbs(filepath.FromSlash(f.path)) {
+			abs = append(abs, f)
+			byProject[f.project] = append(byProject[f.project], f.path)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	roots := projectRoots(byProject)
+	for _, f := range abs {
+		rel := filepaths.Normalize(f.path, roots[f.project])
+		if rel == "" || rel == f.path {
+			continue
+		}
+		// A memory listing both spellings keeps the relative row.
+		if _, err := d.db.Exec(
+			`UPDATE OR IGNORE memory_files SET path = ? WHERE memory_id = ? AND path = ?`, rel, f.id, f.path,
+		); err != nil {
+			return err
+		}
+		if _, err := d.db.Exec(`DELETE FROM memory_files WHERE memory_id = ? AND path = ?`, f.id, f.path); err != nil {
+			return err
+		}
+	}
+	return d.SetMeta("memory_files_relativized", "1")
+}
+
+// projectRoots guesses the repository root of each project from the
+// related-file paths of its memories, using the first absolute path that
+// reveals one (see filepaths.ProjectRoot). Projects with none are missing.
+func projectRoots(files map[string][]string) map[string]string {
+	roots := make(map[string]string, len(files))
+	for project, paths := range files {
+		for _, p := range paths {
+			if root := filepaths.ProjectRoot(p, project); root != "" {
+				roots[project] = root
+				break
+			}
+		}
+	}
+	return roots
+}
+
+// backfillDetailsFTS indexes existing detail bodies once.
+func (d *DB) backfillDetailsFTS() error {
+	if _, done, err := d.GetMeta("details_fts_backfilled"); err != nil || done {
+		return err
+	}
+	if _, err := d.db.Exec(`
+		INSERT INTO details_fts(rowid, body)
+		SELECT m.rowid, md.body FROM memory_details md
+		JOIN memories m ON m.id = md.memory_id
+		WHERE m.rowid NOT IN (SELECT rowid FROM details_fts)`); err != nil {
+		return err
+	}
+	return d.SetMeta("details_fts_backfilled", "1")
+}
+
+// SearchByFiles returns memories whose related files match any of paths,
+// ranked by category weight and recency (half weight after 30 days without
+// an update). Each path matches exactly, as a glob when it contains glob
+// metacharacters, as a directory prefix of stored paths, or as a file inside a
+// stored directory. Each row carries matched_files ([]string) and score.
+func (d *DB) SearchByFiles(paths []string, limit int, project, source string) ([]map[string]any, error) {
+	var matchClauses []string
+	var params []any
+	for _, p := range paths {
+		if p == "" {
+			continue
+		}
+		if filepaths.IsGlob(p) {
+			matchClauses = append(matchClauses, "f.path GLOB ?")
+			params = append(params, p)
+			continue
+		}
+		matchClauses = append(matchClauses,
+			"(f.path = ? OR substr(f.path, 1, length(?) + 1) = ? || '/' OR substr(?, 1, length(f.path) + 1) = f.path || '/')")
+		params = append(params, p, p, p, p)
+	}
+	if len(matchClauses) == 0 {
+		return nil, nil
+	}
+
+	where, filterParams := buildWhere("m", project, source)
+	where = strings.Replace(where, " WHERE ", " AND ", 1)
+	params = append(params, filterParams...)
+	params = append(params, limit)
+
+	q := `
+		SELECT m.*, group_concat(f.path, char(10)) AS matched_files,
+		       ` + categoryRankSQL + ` / (1.0 + (julianday('now') - julianday(m.updated_at)) / 30.0) AS score,
+		       EXISTS(SELECT 1 FROM memory_details WHERE memory_id = m.id) AS has_details
+		FROM memory_files f
+		JOIN memories m ON m.id = f.memory_id
+		WHERE (` + strings.Join(matchClauses, " OR ") + `)` + where + `
+		GROUP BY m.id
+		ORDER BY score DESC, m.updated_at DESC
+		LIMIT ?` // #nosec G202 -- clauses use hardcoded column names only; values flow through ? bound parameters
+
+	rows, err := d.db.Query(q, params...)
+	if err != nil {
+		return nil, fmt.Errorf("SearchByFiles: %w", err)
+	}
+	defer rows.Close()
+	results, err := scanRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		matched, _ := r["matched_files"].(string)
+		r["matched_files"] = strings.Split(matched, "\n")
+	}
+	return results, nil
+}
+
+// ---------------------------------------------------------------------------
+// Stats
+// ---------------------------------------------------------------------------
+
+// VectorStats counts the live vectors and estimates the space they take.
+type VectorStats struct {
+	Vectors      int // memory vectors
+	ChunkVectors int // detail-chunk vectors
+	Bytes        int64
+}
+
+// VectorStats returns statistics on the live vectors; all zero when there
+// is no vector table.
+func (d *DB) VectorStats() (VectorStats, error) {
+	var st VectorStats
+	ok, err := d.HasVecTable()
+	if err != nil || !ok {
+		return st, err
+	}
+	if err := d.db.QueryRow(`SELECT count(*) FROM memories_vec`).Scan(&st.Vectors); err != nil {
+		return st, fmt.Errorf("VectorStats: %w", err)
+	}
+	if err := d.db.QueryRow(`SELECT count(*) FROM memory_chunks_vec`).Scan(&st.ChunkVectors); err != nil {
+		return st, fmt.Errorf("VectorStats: %w", err)
+	}
+	dim, _, err := d.GetEmbeddingDim()
+	if err != nil {
+		return st, fmt.Errorf("VectorStats: %w", err)
+	}
+	layout, err := d.StoredLayout()
+	if err != nil {
+		return st, fmt.Errorf("VectorStats: %w", err)
+	}
+	st.Bytes = int64(st.Vectors+st.ChunkVectors) * int64(layout.bytesPerVector(dim))
+	return st, nil
+}
+
+// FileSize returns the size in bytes of the database file and its
+// write-ahead log.
+func (d *DB) FileSize() (int64, error) {
+	var total int64
+	for _, path := range []string{d.path, d.path + "-wal"} {
+		fi, err := os.Stat(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		total += fi.Size()
+	}
+	return total, nil
+}
+
 // ---------------------------------------------------------------------------
 // Meta
 // ---------------------------------------------------------------------------
@@ -714,6 +2369,15 @@ func float32sToBytes(floats []float32) []byte {
 	return b
 }
 
+// bytesToFloat32s decodes the little-endian sqlite-vec wire format.
+func bytesToFloat32s(b []byte) []float32 {
+	floats := make([]float32, len(b)/4)
+	for i := range floats {
+		floats[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	return floats
+}
+
 // scanRows reads all rows
 func scanRows(rows *sql.Rows) ([]map[string]any, error) {
 	cols, err := rows.Columns()
@@ -1,14 +1,20 @@
 package db_test
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
 	"path/filepath"
 	"testing"
 	"time"
 
 	qt "github.com/frankban/quicktest"
 
+	"github.com/go-ports/echovault/internal/cursor"
 	"github.com/go-ports/echovault/internal/db"
 	"github.com/go-ports/echovault/internal/models"
+	"github.com/go-ports/echovault/internal/query"
 )
 
 // newMemAt returns a *models.Memory with the given created/updated timestamps.
@@ -303,7 +309,7 @@ func TestEmbeddingDim_HappyPath(t *testing.T) {
 
 	c.Run("EnsureVecTable creates table on first call", func(c *qt.C) {
 		d := openTestDB(t)
-		err := d.EnsureVecTable(384)
+		err := d.EnsureVecTable(384, "test/model")
 		c.Assert(err, qt.IsNil)
 
 		ok, err := d.HasVecTable()
@@ -313,11 +319,34 @@ func TestEmbeddingDim_HappyPath(t *testing.T) {
 
 	c.Run("EnsureVecTable returns ErrDimensionMismatch on mismatch", func(c *qt.C) {
 		d := openTestDB(t)
-		c.Assert(d.EnsureVecTable(384), qt.IsNil)
+		c.Assert(d.EnsureVecTable(384, "test/model"), qt.IsNil)
 
-		err := d.EnsureVecTable(512)
+		err := d.EnsureVecTable(512, "test/model")
 		c.Assert(err, qt.ErrorIs, db.ErrDimensionMismatch)
 	})
+
+	c.Run("EnsureVecTable returns ErrModelMismatch for another model of the same dim", func(c *qt.C) {
+		d := openTestDB(t)
+		c.Assert(d.EnsureVecTable(384, "ollama/nomic-embed-text"), qt.IsNil)
+
+		model, found, err := d.GetEmbeddingModel()
+		c.Assert(err, qt.IsNil)
+		c.Assert(found, qt.IsTrue)
+		c.Assert(model, qt.Equals, "ollama/nomic-embed-text")
+
+		err = d.EnsureVecTable(384, "ollama/mxbai-embed-large")
+		c.Assert(err, qt.ErrorIs, db.ErrModelMismatch)
+	})
+
+	c.Run("a database without a recorded model adopts the first one", func(c *qt.C) {
+		d := openTestDB(t)
+		c.Assert(d.SetEmbeddingDim(384), qt.IsNil)
+		c.Assert(d.EnsureVecTable(384, "openai/text-embedding-3-small"), qt.IsNil)
+
+		model, _, err := d.GetEmbeddingModel()
+		c.Assert(err, qt.IsNil)
+		c.Assert(model, qt.Equals, "openai/text-embedding-3-small")
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -404,6 +433,836 @@ func TestFTSSearch_HappyPath(t *testing.T) {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// QuerySearch
+// ---------------------------------------------------------------------------
+
+func TestQuerySearch_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	seed := func(c *qt.C) *db.DB {
+		d := openTestDB(t)
+		jan := time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC)
+		mar := time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC)
+
+		bug := newMemAt("q-bug", "Token refresh race", "proj", jan)
+		bug.Category = "bug"
+		bug.Tags = []string{"auth", "Concurrency"}
+		dec := newMemAt("q-dec", "Token storage in redis", "proj", mar)
+		dec.Category = "decision"
+		dec.Tags = []string{"auth"}
+		learn := newMemAt("q-learn", "Connection pool sizing", "proj", mar)
+		learn.Category = "learning"
+
+		_, err := d.InsertMemory(bug, "stack trace")
+		c.Assert(err, qt.IsNil)
+		_, err = d.InsertMemory(dec, "")
+		c.Assert(err, qt.IsNil)
+		_, err = d.InsertMemory(learn, "")
+		c.Assert(err, qt.IsNil)
+		return d
+	}
+
+	ids := func(rows []map[string]any) []string {
+		out := make([]string, len(rows))
+		for i, r := range rows {
+			out[i], _ = r["id"].(string)
+		}
+		return out
+	}
+
+	run := func(c *qt.C, d *db.DB, s string) []string {
+		q, err := query.Parse(s)
+		c.Assert(err, qt.IsNil)
+		rows, err := d.QuerySearch(q.Compile(), 10, "", "")
+		c.Assert(err, qt.IsNil)
+		return ids(rows)
+	}
+
+	c.Run("AND requires every term", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "token AND redis"), qt.DeepEquals, []string{"q-dec"})
+	})
+
+	c.Run("excluded term removes matches", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "token -redis"), qt.DeepEquals, []string{"q-bug"})
+	})
+
+	c.Run("phrase matches adjacent words only", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, `"connection pool"`), qt.DeepEquals, []string{"q-learn"})
+		c.Assert(run(c, d, `"pool connection"`), qt.HasLen, 0)
+	})
+
+	c.Run("field scope restricts the column", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "title:race"), qt.DeepEquals, []string{"q-bug"})
+		c.Assert(run(c, d, "why:race"), qt.HasLen, 0)
+	})
+
+	c.Run("category and tag filters", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "token category:bug"), qt.DeepEquals, []string{"q-bug"})
+		c.Assert(run(c, d, "tag:concurrency"), qt.DeepEquals, []string{"q-bug"})
+		c.Assert(run(c, d, "tag:auth -category:bug"), qt.DeepEquals, []string{"q-dec"})
+	})
+
+	c.Run("filter-only query lists newest first", func(c *qt.C) {
+		d := seed(c)
+		got := run(c, d, "tag:auth")
+		c.Assert(got, qt.DeepEquals, []string{"q-dec", "q-bug"})
+	})
+
+	c.Run("date range filters", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "token since:2025-02"), qt.DeepEquals, []string{"q-dec"})
+		c.Assert(run(c, d, "token until:2025-01"), qt.DeepEquals, []string{"q-bug"})
+	})
+
+	c.Run("has:details", func(c *qt.C) {
+		d := seed(c)
+		c.Assert(run(c, d, "has:details"), qt.DeepEquals, []string{"q-bug"})
+	})
+
+	c.Run("empty compiled query returns nil", func(c *qt.C) {
+		d := seed(c)
+		rows, err := d.QuerySearch(query.Compiled{}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.IsNil)
+	})
+}
+
+func TestQuerySearch_Details_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	search := func(c *qt.C, d *db.DB, s string) []map[string]any {
+		q, err := query.Parse(s)
+		c.Assert(err, qt.IsNil)
+		rows, err := d.QuerySearch(q.Compile(), 10, "", "")
+		c.Assert(err, qt.IsNil)
+		return rows
+	}
+
+	c.Run("detail body is searchable with a highlighted snippet", func(c *qt.C) {
+		d := openTestDB(t)
+		_, err := d.InsertMemory(newMem("d-1", "Flaky integration test", "proj"),
+			"The deadlock happens when the goroutine holds the mutex during shutdown.")
+		c.Assert(err, qt.IsNil)
+
+		rows := search(c, d, "deadlock")
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "d-1")
+		c.Assert(rows[0]["snippet"], qt.Contains, "**deadlock**")
+	})
+
+	c.Run("title match outranks details-only match", func(c *qt.C) {
+		d := openTestDB(t)
+		_, err := d.InsertMemory(newMem("d-body", "Unrelated heading", "proj"), "notes about the mutex")
+		c.Assert(err, qt.IsNil)
+		_, err = d.InsertMemory(newMem("d-title", "Mutex ordering", "proj"), "")
+		c.Assert(err, qt.IsNil)
+
+		rows := search(c, d, "mutex")
+		c.Assert(rows, qt.HasLen, 2)
+		c.Assert(rows[0]["id"], qt.Equals, "d-title")
+		c.Assert(rows[0]["snippet"], qt.Contains, "**Mutex**")
+	})
+
+	c.Run("details scope and exclusion", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(newMem("d-a", "Cache eviction", "proj"), "uses redis")
+		_, _ = d.InsertMemory(newMem("d-b", "Cache warmup", "proj"), "uses memcached")
+
+		rows := search(c, d, "details:redis")
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "d-a")
+
+		rows = search(c, d, "cache -redis")
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "d-b")
+	})
+
+	c.Run("index follows replaced, appended and deleted details", func(c *qt.C) {
+		d := openTestDB(t)
+		_, err := d.InsertMemory(newMem("d-r", "Replace me", "proj"), "original body")
+		c.Assert(err, qt.IsNil)
+
+		ok, err := d.ReplaceMemory("d-r", "Replace me", "w", "", "", nil, nil, "", "rewritten body")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(search(c, d, "original"), qt.HasLen, 0)
+		c.Assert(search(c, d, "rewritten"), qt.HasLen, 1)
+
+		ok, err = d.UpdateMemory("d-r", "", "", "", nil, "appended paragraph")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(search(c, d, "appended"), qt.HasLen, 1)
+
+		ok, err = d.DeleteMemory("d-r")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(search(c, d, "rewritten"), qt.HasLen, 0)
+	})
+}
+
+func TestVectorQuerySearch_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	d := openTestDB(t)
+	c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+
+	bug := newMem("v-bug", "Bug", "proj")
+	bug.Category = "bug"
+	dec := newMem("v-dec", "Decision", "proj")
+	dec.Category = "decision"
+	for i, m := range []*models.Memory{bug, dec} {
+		rowid, err := d.InsertMemory(m, "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(rowid, []float32{1, float32(i)}), qt.IsNil)
+	}
+
+	q, err := query.Parse("category:decision")
+	c.Assert(err, qt.IsNil)
+	rows, err := d.VectorQuerySearch([]float32{1, 0}, 10, "", "", q.Compile())
+	c.Assert(err, qt.IsNil)
+	c.Assert(rows, qt.HasLen, 1)
+	c.Assert(rows[0]["id"], qt.Equals, "v-dec")
+}
+
+func TestVectorQuerySearch_Scoped_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("project filter is applied inside the k-NN scan", func(c *qt.C) {
+		d := openTestDB(t)
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		// Twenty closer vectors in another project must not crowd out the
+		// only match in "mine".
+		for i := range 20 {
+			rowid, err := d.InsertMemory(newMem(fmt.Sprintf("o-%02d", i), "Other", "other"), "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(d.InsertVector(rowid, []float32{1, 0}), qt.IsNil)
+		}
+		rowid, err := d.InsertMemory(newMem("mine", "Mine", "mine"), "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(rowid, []float32{0, 1}), qt.IsNil)
+
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "mine", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "mine")
+	})
+
+	c.Run("predicates outside the index expand k until limit is met", func(c *qt.C) {
+		d := openTestDB(t)
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		for i := range 20 {
+			rowid, err := d.InsertMemory(newMem(fmt.Sprintf("u-%02d", i), "Untagged", "p"), "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(d.InsertVector(rowid, []float32{1, 0}), qt.IsNil)
+		}
+		tagged := newMem("tagged", "Tagged", "p")
+		tagged.Tags = []string{"redis"}
+		rowid, err := d.InsertMemory(tagged, "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(rowid, []float32{0, 1}), qt.IsNil)
+
+		q, err := query.Parse("tag:redis")
+		c.Assert(err, qt.IsNil)
+		rows, err := d.VectorQuerySearch([]float32{1, 0}, 2, "p", "", q.Compile())
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "tagged")
+	})
+
+	c.Run("replacing a memory updates its vector category", func(c *qt.C) {
+		d := openTestDB(t)
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		m := newMem("r-1", "Replaced", "p")
+		m.Category = "bug"
+		rowid, err := d.InsertMemory(m, "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(rowid, []float32{1, 0}), qt.IsNil)
+
+		ok, err := d.ReplaceMemory("r-1", "Replaced", "what", "", "", nil, nil, "decision", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+
+		q, err := query.Parse("category:decision")
+		c.Assert(err, qt.IsNil)
+		rows, err := d.VectorQuerySearch([]float32{1, 0}, 5, "", "", q.Compile())
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+	})
+}
+
+func TestInsertChunkVectors_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// setup stores a memory whose summary points away from the query and one
+	// detail chunk that matches it, next to a memory that half matches.
+	setup := func(c *qt.C) (d *db.DB, chunked, other int64) {
+		d = openTestDB(t)
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		chunked, err := d.InsertMemory(newMem("chunked", "Chunked", "p"), "long details")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(chunked, []float32{0, 1}), qt.IsNil)
+		c.Assert(d.InsertChunkVectors(chunked, [][]float32{{0, 1}, {1, 0}}), qt.IsNil)
+		other, err = d.InsertMemory(newMem("other", "Other", "p"), "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.InsertVector(other, []float32{0.8, 0.6}), qt.IsNil)
+		return d, chunked, other
+	}
+
+	c.Run("a matching chunk ranks its memory once, by its best hit", func(c *qt.C) {
+		d, _, _ := setup(c)
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+		c.Assert(rows[0]["id"], qt.Equals, "chunked")
+		c.Assert(rows[0]["distance"], qt.Equals, 0.0)
+		c.Assert(rows[0]["score"], qt.Equals, 1.0)
+	})
+
+	c.Run("sum aggregation adds the hits of a memory", func(c *qt.C) {
+		d, _, other := setup(c)
+		d.ChunkAggregate = db.AggregateSum
+		// Four partial matches (1/(1+0.632) each) now outweigh one exact
+		// one plus two orthogonal ones (1/(1+1.414) each).
+		c.Assert(d.InsertChunkVectors(other, [][]float32{{0.8, 0.6}, {0.8, 0.6}, {0.8, 0.6}}), qt.IsNil)
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "other")
+		c.Assert(rows[1]["id"], qt.Equals, "chunked")
+		c.Assert(rows[1]["score"].(float64) > 1.0, qt.IsTrue)
+	})
+
+	c.Run("replacing with no chunks removes them", func(c *qt.C) {
+		d, chunked, _ := setup(c)
+		c.Assert(d.InsertChunkVectors(chunked, nil), qt.IsNil)
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "other")
+	})
+
+	c.Run("deleting the memory removes its chunks", func(c *qt.C) {
+		d, _, _ := setup(c)
+		ok, err := d.DeleteMemory("chunked")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "other")
+	})
+}
+
+func TestReindex_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// setup stores two memories with 2-dim vectors and recorded states.
+	setup := func(c *qt.C) (d *db.DB, a, b int64) {
+		d = openTestDB(t)
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		a, err := d.InsertMemory(newMem("a", "A", "p"), "")
+		c.Assert(err, qt.IsNil)
+		b, err = d.InsertMemory(newMem("b", "B", "p"), "")
+		c.Assert(err, qt.IsNil)
+		for _, rowid := range []int64{a, b} {
+			c.Assert(d.InsertVector(rowid, []float32{1, 0}), qt.IsNil)
+			c.Assert(d.SetEmbeddingState(rowid, db.EmbeddingState{Hash: "h", Model: "old"}), qt.IsNil)
+		}
+		return d, a, b
+	}
+
+	c.Run("live vectors serve searches until the swap", func(c *qt.C) {
+		d, a, b := setup(c)
+		staged, err := d.BeginReindex(3, "new")
+		c.Assert(err, qt.IsNil)
+		c.Assert(staged, qt.HasLen, 0)
+		st := db.EmbeddingState{Hash: "h", Model: "new"}
+		c.Assert(d.StageVectors(a, st, []float32{0, 1, 0}, [][]float32{{0, 0, 1}}), qt.IsNil)
+
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+
+		c.Assert(d.StageVectors(b, st, []float32{1, 0, 0}, nil), qt.IsNil)
+		c.Assert(d.FinishReindex(3, "new"), qt.IsNil)
+		dim, ok, err := d.GetEmbeddingDim()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(dim, qt.Equals, 3)
+		model, _, err := d.GetEmbeddingModel()
+		c.Assert(err, qt.IsNil)
+		c.Assert(model, qt.Equals, "new")
+		rows, err = d.VectorSearch([]float32{0, 0, 1}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "a")
+		c.Assert(rows[0]["score"], qt.Equals, 1.0)
+		states, err := d.EmbeddingStates()
+		c.Assert(err, qt.IsNil)
+		c.Assert(states, qt.DeepEquals, map[int64]db.EmbeddingState{a: st, b: st})
+	})
+
+	c.Run("an interrupted reindex resumes for the same target", func(c *qt.C) {
+		d, a, _ := setup(c)
+		_, err := d.BeginReindex(3, "new")
+		c.Assert(err, qt.IsNil)
+		st := db.EmbeddingState{Hash: "h", Model: "new"}
+		c.Assert(d.StageVectors(a, st, []float32{0, 1, 0}, nil), qt.IsNil)
+
+		staged, err := d.BeginReindex(3, "new")
+		c.Assert(err, qt.IsNil)
+		c.Assert(staged, qt.DeepEquals, map[int64]db.EmbeddingState{a: st})
+
+		staged, err = d.BeginReindex(4, "new")
+		c.Assert(err, qt.IsNil)
+		c.Assert(staged, qt.HasLen, 0)
+	})
+
+	c.Run("current vectors can be staged as they are", func(c *qt.C) {
+		d, a, b := setup(c)
+		c.Assert(d.InsertChunkVectors(a, [][]float32{{0, 1}}), qt.IsNil)
+		_, err := d.BeginReindex(2, "old")
+		c.Assert(err, qt.IsNil)
+		st := db.EmbeddingState{Hash: "h", Model: "old"}
+		c.Assert(d.StageCurrent(a, st), qt.IsNil)
+		c.Assert(d.StageCurrent(b, st), qt.IsNil)
+		c.Assert(d.FinishReindex(2, "old"), qt.IsNil)
+
+		rows, err := d.VectorSearch([]float32{0, 1}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "a")
+		c.Assert(rows[0]["score"], qt.Equals, 1.0)
+	})
+
+	c.Run("memories deleted during the reindex are not swapped in", func(c *qt.C) {
+		d, a, b := setup(c)
+		_, err := d.BeginReindex(2, "new")
+		c.Assert(err, qt.IsNil)
+		st := db.EmbeddingState{Hash: "h", Model: "new"}
+		c.Assert(d.StageVectors(a, st, []float32{1, 0}, [][]float32{{0, 1}}), qt.IsNil)
+		c.Assert(d.StageVectors(b, st, []float32{1, 0}, nil), qt.IsNil)
+		ok, err := d.DeleteMemory("a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(d.FinishReindex(2, "old"), qt.IsNil)
+
+		rows, err := d.VectorSearch([]float32{0, 1}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "b")
+	})
+}
+
+func TestDistanceMetric_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// setup builds vector tables with metric holding the given vectors, one
+	// memory each, and returns the similarity scores for query by memory id.
+	setup := func(c *qt.C, metric string, vecs ...[]float32) (*db.DB, map[string]float64) {
+		d := openTestDB(t)
+		d.DistanceMetric = metric
+		c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+		for i, v := range vecs {
+			rowid, err := d.InsertMemory(newMem(fmt.Sprintf("m%d", i), "T", "p"), "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(d.InsertVector(rowid, v), qt.IsNil)
+		}
+		rows, err := d.VectorSearch([]float32{1, 0}, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		scores := make(map[string]float64, len(rows))
+		for _, r := range rows {
+			scores[r["id"].(string)] = r["score"].(float64)
+		}
+		return d, scores
+	}
+
+	c.Run("cosine normalizes vectors and scores by angle", func(c *qt.C) {
+		d, scores := setup(c, db.MetricCosine, []float32{3, 0}, []float32{0, 4}, []float32{-2, 0})
+		c.Assert(scores, qt.DeepEquals, map[string]float64{"m0": 1, "m1": 0.5, "m2": 0})
+		metric, err := d.StoredMetric()
+		c.Assert(err, qt.IsNil)
+		c.Assert(metric, qt.Equals, db.MetricCosine)
+		vecs, err := d.VectorsByID([]string{"m0"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(vecs["m0"], qt.DeepEquals, []float32{1, 0})
+	})
+
+	c.Run("l2 scores 1/(1+distance)", func(c *qt.C) {
+		_, scores := setup(c, db.MetricL2, []float32{1, 0}, []float32{2, 0}, []float32{4, 0})
+		c.Assert(scores, qt.DeepEquals, map[string]float64{"m0": 1, "m1": 0.5, "m2": 0.25})
+	})
+
+	c.Run("dot scores unit vectors by (1+dot)/2", func(c *qt.C) {
+		_, scores := setup(c, db.MetricDot, []float32{1, 0}, []float32{0, 1}, []float32{-1, 0})
+		c.Assert(scores["m0"], qt.Equals, 1.0)
+		c.Assert(scores["m1"] > 0.4999 && scores["m1"] < 0.5001, qt.IsTrue)
+		c.Assert(scores["m2"], qt.Equals, 0.0)
+	})
+
+	c.Run("dot normalizes vectors and ranks like cosine", func(c *qt.C) {
+		d, scores := setup(c, db.MetricDot, []float32{0.5, 0.5}, []float32{9, 0}, []float32{-2, 0})
+		c.Assert(scores["m1"], qt.Equals, 1.0)
+		c.Assert(scores["m0"] > 0.85 && scores["m0"] < 0.86, qt.IsTrue)
+		c.Assert(scores["m2"], qt.Equals, 0.0)
+		vecs, err := d.VectorsByID([]string{"m1"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(vecs["m1"], qt.DeepEquals, []float32{1, 0})
+		rows, err := d.VectorSearch([]float32{7, 0}, 1, "p", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "m1")
+		c.Assert(rows[0]["score"], qt.Equals, 1.0)
+	})
+
+	c.Run("a reindex switches the metric", func(c *qt.C) {
+		d, _ := setup(c, db.MetricL2, []float32{0, 2})
+		d.DistanceMetric = db.MetricCosine
+		_, err := d.BeginReindex(2, "test/model")
+		c.Assert(err, qt.IsNil)
+		mem, _, err := d.GetMemory("m0")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.StageCurrent(mem["rowid"].(int64), db.EmbeddingState{Hash: "h", Model: "test/model"}), qt.IsNil)
+		c.Assert(d.FinishReindex(2, "test/model"), qt.IsNil)
+
+		metric, err := d.StoredMetric()
+		c.Assert(err, qt.IsNil)
+		c.Assert(metric, qt.Equals, db.MetricCosine)
+		vecs, err := d.VectorsByID([]string{"m0"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(vecs["m0"], qt.DeepEquals, []float32{0, 1})
+	})
+
+	c.Run("normalized vectors stage into dot tables but not l2 ones", func(c *qt.C) {
+		d, _ := setup(c, db.MetricCosine, []float32{0, 2})
+		d.DistanceMetric = db.MetricDot
+		ok, err := d.CanStageCurrent()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+
+		d, _ = setup(c, db.MetricDot, []float32{0, 2})
+		d.DistanceMetric = db.MetricL2
+		ok, err = d.CanStageCurrent()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsFalse)
+	})
+
+	c.Run("tables built before the metric was recorded use l2", func(c *qt.C) {
+		d := openTestDB(t)
+		metric, err := d.StoredMetric()
+		c.Assert(err, qt.IsNil)
+		c.Assert(metric, qt.Equals, db.MetricL2)
+	})
+}
+
+func TestQuantization_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	unit := func(i int) []float32 {
+		v := make([]float32, 8)
+		v[i] = 1
+		return v
+	}
+	// setup builds cosine vector tables stored as quant holding the given
+	// 8-dimensional vectors, one memory each.
+	setup := func(c *qt.C, quant string, rescore int, vecs ...[]float32) *db.DB {
+		d := openTestDB(t)
+		d.DistanceMetric = db.MetricCosine
+		d.Quantization = quant
+		d.Rescore = rescore
+		c.Assert(d.EnsureVecTable(8, "test/model"), qt.IsNil)
+		for i, v := range vecs {
+			rowid, err := d.InsertMemory(newMem(fmt.Sprintf("m%d", i), "T", "p"), "")
+			c.Assert(err, qt.IsNil)
+			c.Assert(d.InsertVector(rowid, v), qt.IsNil)
+		}
+		return d
+	}
+	search := func(c *qt.C, d *db.DB, q []float32) map[string]float64 {
+		rows, err := d.VectorSearch(q, 5, "p", "")
+		c.Assert(err, qt.IsNil)
+		scores := make(map[string]float64, len(rows))
+		for _, r := range rows {
+			scores[r["id"].(string)] = r["score"].(float64)
+		}
+		return scores
+	}
+	near := func(got, want float64) bool { return math.Abs(got-want) < 0.01 }
+
+	c.Run("int8 vectors score close to float ones in a quarter of the space", func(c *qt.C) {
+		d := setup(c, db.QuantInt8, 0, unit(0), unit(1), []float32{-1, 0, 0, 0, 0, 0, 0, 0})
+		scores := search(c, d, unit(0))
+		c.Assert(near(scores["m0"], 1) && near(scores["m1"], 0.5) && near(scores["m2"], 0), qt.IsTrue, qt.Commentf("%v", scores))
+		st, err := d.VectorStats()
+		c.Assert(err, qt.IsNil)
+		c.Assert(st, qt.Equals, db.VectorStats{Vectors: 3, Bytes: 3 * 8})
+		layout, err := d.StoredLayout()
+		c.Assert(err, qt.IsNil)
+		c.Assert(layout, qt.Equals, db.VectorLayout{Metric: db.MetricCosine, Quantization: db.QuantInt8})
+	})
+
+	c.Run("bit vectors score by the share of matching signs", func(c *qt.C) {
+		ones := []float32{1, 1, 1, 1, 1, 1, 1, 1}
+		d := setup(c, db.QuantBit, 0, ones, []float32{1, 1, 1, 1, -1, -1, -1, -1}, []float32{-1, -1, -1, -1, -1, -1, -1, -1})
+		c.Assert(search(c, d, ones), qt.DeepEquals, map[string]float64{"m0": 1, "m1": 0.5, "m2": 0})
+		st, err := d.VectorStats()
+		c.Assert(err, qt.IsNil)
+		c.Assert(st.Bytes, qt.Equals, int64(3))
+		vecs, err := d.VectorsByID([]string{"m1"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(vecs["m1"], qt.DeepEquals, []float32{1, 1, 1, 1, -1, -1, -1, -1})
+	})
+
+	c.Run("rescoring uses the float copies", func(c *qt.C) {
+		d := setup(c, db.QuantInt8, 10, unit(0), []float32{1, 1, 0, 0, 0, 0, 0, 0})
+		scores := search(c, d, unit(0))
+		c.Assert(scores["m0"], qt.Equals, 1.0)
+		c.Assert(math.Abs(scores["m1"]-(1+math.Sqrt2/2)/2) < 1e-6, qt.IsTrue, qt.Commentf("%v", scores))
+		vecs, err := d.VectorsByID([]string{"m0"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(vecs["m0"], qt.DeepEquals, unit(0))
+		st, err := d.VectorStats()
+		c.Assert(err, qt.IsNil)
+		c.Assert(st.Bytes, qt.Equals, int64(2*(8+32)))
+	})
+
+	c.Run("a reindex to the same layout copies quantized vectors as they are", func(c *qt.C) {
+		d := setup(c, db.QuantInt8, 0, unit(0), unit(1))
+		mem, _, err := d.GetMemory("m0")
+		c.Assert(err, qt.IsNil)
+		rowid := mem["rowid"].(int64)
+		c.Assert(d.InsertChunkVectors(rowid, [][]float32{unit(2)}), qt.IsNil)
+		before := search(c, d, unit(2))
+
+		ok, err := d.CanStageCurrent()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		_, err = d.BeginReindex(8, "test/model")
+		c.Assert(err, qt.IsNil)
+		for _, id := range []string{"m0", "m1"} {
+			mem, _, err := d.GetMemory(id)
+			c.Assert(err, qt.IsNil)
+			c.Assert(d.StageCurrent(mem["rowid"].(int64), db.EmbeddingState{Hash: id, Model: "test/model"}), qt.IsNil)
+		}
+		c.Assert(d.FinishReindex(8, "test/model"), qt.IsNil)
+
+		c.Assert(search(c, d, unit(2)), qt.DeepEquals, before)
+		st, err := d.VectorStats()
+		c.Assert(err, qt.IsNil)
+		c.Assert(st.ChunkVectors, qt.Equals, 1)
+	})
+
+	c.Run("only full-precision vectors convert to another layout", func(c *qt.C) {
+		d := setup(c, db.QuantInt8, 0, unit(0))
+		d.Quantization = db.QuantFloat
+		ok, err := d.CanStageCurrent()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsFalse)
+
+		d = setup(c, db.QuantInt8, 10, unit(0))
+		d.Quantization, d.Rescore = db.QuantFloat, 0
+		ok, err = d.CanStageCurrent()
+		c.Assert(err, qt.IsNil)
+		c.Assert(ok, qt.IsTrue)
+		_, err = d.BeginReindex(8, "test/model")
+		c.Assert(err, qt.IsNil)
+		mem, _, err := d.GetMemory("m0")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.StageCurrent(mem["rowid"].(int64), db.EmbeddingState{Hash: "h", Model: "test/model"}), qt.IsNil)
+		c.Assert(d.FinishReindex(8, "test/model"), qt.IsNil)
+		c.Assert(search(c, d, unit(0)), qt.DeepEquals, map[string]float64{"m0": 1})
+	})
+}
+
+func TestVectorsByID_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	d := openTestDB(t)
+
+	c.Run("no vec table returns nothing", func(c *qt.C) {
+		got, err := d.VectorsByID([]string{"x"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.HasLen, 0)
+	})
+
+	c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+	rowid, err := d.InsertMemory(newMem("with", "With vector", "p"), "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(d.InsertVector(rowid, []float32{0.5, -1}), qt.IsNil)
+	_, err = d.InsertMemory(newMem("without", "Without vector", "p"), "")
+	c.Assert(err, qt.IsNil)
+
+	got, err := d.VectorsByID([]string{"with", "without"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, map[string][]float32{"with": {0.5, -1}})
+}
+
+func TestOpen_MigratesVecMetadata_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	d, err := db.Open(path)
+	c.Assert(err, qt.IsNil)
+	c.Assert(d.EnsureVecTable(2, "test/model"), qt.IsNil)
+	rowid, err := d.InsertMemory(newMem("old", "Old", "proj"), "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(d.Close(), qt.IsNil)
+
+	// Recreate memories_vec the way older releases did, without metadata.
+	raw, err := sql.Open("sqlite3", path)
+	c.Assert(err, qt.IsNil)
+	for _, stmt := range []string{
+		`DROP TABLE memories_vec`,
+		`CREATE VIRTUAL TABLE memories_vec USING vec0(rowid INTEGER PRIMARY KEY, embedding float[2])`,
+		fmt.Sprintf(`INSERT INTO memories_vec (rowid, embedding) VALUES (%d, '[1, 0]')`, rowid),
+	} {
+		_, err = raw.Exec(stmt)
+		c.Assert(err, qt.IsNil)
+	}
+	c.Assert(raw.Close(), qt.IsNil)
+
+	d, err = db.Open(path)
+	c.Assert(err, qt.IsNil)
+	defer d.Close()
+
+	rows, err := d.VectorSearch([]float32{1, 0}, 5, "proj", "")
+	c.Assert(err, qt.IsNil)
+	c.Assert(rows, qt.HasLen, 1)
+	c.Assert(rows[0]["id"], qt.Equals, "old")
+}
+
+func TestOpen_BackfillsMemoryFiles_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// legacyDB stores a memory of project "proj" whose related files were
+	// saved absolute, and runs stmts to make the index look like an older
+	// release left it.
+	legacyDB := func(c *qt.C, stmts ...string) *db.DB {
+		path := filepath.Join(c.TempDir(), "test.db")
+		d, err := db.Open(path)
+		c.Assert(err, qt.IsNil)
+		mem := newMem("old", "Old", "proj")
+		mem.RelatedFiles = []string{"/home/u/src/proj/internal/db/db.go:10-20", "/etc/hosts"}
+		_, err = d.InsertMemory(mem, "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.SetFileCommit("old", "abc123"), qt.IsNil)
+		c.Assert(d.Close(), qt.IsNil)
+
+		raw, err := sql.Open("sqlite3", path)
+		c.Assert(err, qt.IsNil)
+		for _, stmt := range stmts {
+			_, err = raw.Exec(stmt)
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(raw.Close(), qt.IsNil)
+
+		d, err = db.Open(path)
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = d.Close() })
+		return d
+	}
+	// found returns the memories SearchByFiles finds for path.
+	found := func(c *qt.C, d *db.DB, path string) []any {
+		rows, err := d.SearchByFiles([]string{path}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		var ids []any
+		for _, r := range rows {
+			ids = append(ids, r["id"])
+		}
+		return ids
+	}
+
+	c.Run("absolute paths of memories saved before the index are made relative", func(c *qt.C) {
+		d := legacyDB(c,
+			`DELETE FROM memory_files`,
+			`DELETE FROM meta WHERE key IN ('memory_files_backfilled', 'memory_files_relativized')`,
+		)
+		c.Assert(found(c, d, "internal/db/db.go"), qt.DeepEquals, []any{"old"})
+		c.Assert(found(c, d, "/etc/hosts"), qt.DeepEquals, []any{"old"})
+	})
+
+	c.Run("absolute paths indexed by an older backfill are made relative", func(c *qt.C) {
+		d := legacyDB(c, `DELETE FROM meta WHERE key = 'memory_files_relativized'`)
+		c.Assert(found(c, d, "internal/db/db.go"), qt.DeepEquals, []any{"old"})
+		refs, err := d.FileRefs([]string{"old"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(refs["old"], qt.DeepEquals, []models.FileRef{
+			{Path: "/etc/hosts", CommitSHA: "abc123"},
+			{Path: "internal/db/db.go", CommitSHA: "abc123", LineStart: 10, LineEnd: 20},
+		})
+	})
+}
+
+// ---------------------------------------------------------------------------
+// ListRecent
+// ---------------------------------------------------------------------------
+
+func TestListRecent_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	c.Run("pages walk the listing without overlap", func(c *qt.C) {
+		d := openTestDB(t)
+		// r-b and r-c share a timestamp; the id breaks the tie.
+		_, _ = d.InsertMemory(newMemAt("r-a", "A", "p", base), "")
+		_, _ = d.InsertMemory(newMemAt("r-b", "B", "p", base.Add(time.Hour)), "")
+		_, _ = d.InsertMemory(newMemAt("r-c", "C", "p", base.Add(time.Hour)), "")
+
+		var got []string
+		cur := ""
+		for range 3 {
+			rows, next, err := d.ListRecent(2, "", "", cur)
+			c.Assert(err, qt.IsNil)
+			for _, r := range rows {
+				got = append(got, r["id"].(string))
+			}
+			if next == "" {
+				break
+			}
+			cur = next
+		}
+		c.Assert(got, qt.DeepEquals, []string{"r-c", "r-b", "r-a"})
+	})
+
+	c.Run("memories saved after the first page are not listed", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(newMemAt("s-a", "A", "p", base), "")
+		_, _ = d.InsertMemory(newMemAt("s-b", "B", "p", base.Add(time.Hour)), "")
+
+		rows, next, err := d.ListRecent(1, "", "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows[0]["id"], qt.Equals, "s-b")
+
+		// Older timestamp would otherwise sort onto the next page.
+		_, _ = d.InsertMemory(newMemAt("s-new", "New", "p", base.Add(-time.Hour)), "")
+
+		rows, next, err = d.ListRecent(1, "", "", next)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "s-a")
+		c.Assert(next, qt.Equals, "")
+	})
+}
+
+func TestListRecent_FailurePath(t *testing.T) {
+	c := qt.New(t)
+	d := openTestDB(t)
+	_, _ = d.InsertMemory(newMem("f-a", "A", "proj-a"), "")
+	_, _ = d.InsertMemory(newMem("f-b", "B", "proj-a"), "")
+
+	c.Run("undecodable cursor", func(c *qt.C) {
+		_, _, err := d.ListRecent(1, "", "", "%%%")
+		c.Assert(errors.Is(err, cursor.ErrInvalid), qt.IsTrue)
+	})
+
+	c.Run("cursor from another project", func(c *qt.C) {
+		_, next, err := d.ListRecent(1, "proj-a", "", "")
+		c.Assert(err, qt.IsNil)
+		_, _, err = d.ListRecent(1, "proj-b", "", next)
+		c.Assert(errors.Is(err, cursor.ErrInvalid), qt.IsTrue)
+	})
+}
+
 // ---------------------------------------------------------------------------
 // DeleteByFilter
 // ---------------------------------------------------------------------------
@@ -579,3 +1438,281 @@ func TestReplaceMemory_FailurePath(t *testing.T) {
 		c.Assert(ok, qt.IsFalse)
 	})
 }
+
+// ---------------------------------------------------------------------------
+// SearchByFiles
+// ---------------------------------------------------------------------------
+
+func TestSearchByFiles_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	withFiles := func(id, category string, files ...string) *models.Memory {
+		m := newMem(id, "Memory "+id, "proj")
+		m.Category = category
+		m.RelatedFiles = files
+		return m
+	}
+
+	c.Run("exact path matches and reports matched files", func(c *qt.C) {
+		d := openTestDB(t)
+		_, err := d.InsertMemory(withFiles("f1", "bug", "internal/db/db.go", "README.md"), "")
+		c.Assert(err, qt.IsNil)
+
+		rows, err := d.SearchByFiles([]string{"internal/db/db.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "f1")
+		c.Assert(rows[0]["matched_files"], qt.DeepEquals, []string{"internal/db/db.go"})
+	})
+
+	c.Run("directory prefix matches files beneath it", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(withFiles("f1", "bug", "internal/db/db.go"), "")
+		_, _ = d.InsertMemory(withFiles("f2", "bug", "internal/dbx/other.go"), "")
+
+		rows, err := d.SearchByFiles([]string{"internal/db"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "f1")
+	})
+
+	c.Run("file inside a stored directory matches", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(withFiles("dir", "pattern", "internal/search"), "")
+
+		rows, err := d.SearchByFiles([]string{"internal/search/search.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "dir")
+	})
+
+	c.Run("glob pattern matches", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(withFiles("g1", "bug", "internal/db/db.go"), "")
+		_, _ = d.InsertMemory(withFiles("g2", "bug", "internal/db/db_test.txt"), "")
+
+		rows, err := d.SearchByFiles([]string{"internal/*/*.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "g1")
+	})
+
+	c.Run("decisions rank above context at equal recency", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(withFiles("ctx", "context", "a.go"), "")
+		_, _ = d.InsertMemory(withFiles("dec", "decision", "a.go"), "")
+
+		rows, err := d.SearchByFiles([]string{"a.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+		c.Assert(rows[0]["id"], qt.Equals, "dec")
+	})
+
+	c.Run("recent memory ranks above stale one in the same category", func(c *qt.C) {
+		d := openTestDB(t)
+		old := newMemAt("old", "Old", "proj", time.Now().UTC().AddDate(-1, 0, 0))
+		old.Category = "bug"
+		old.RelatedFiles = []string{"a.go"}
+		_, _ = d.InsertMemory(old, "")
+		_, _ = d.InsertMemory(withFiles("new", "bug", "a.go"), "")
+
+		rows, err := d.SearchByFiles([]string{"a.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+		c.Assert(rows[0]["id"], qt.Equals, "new")
+	})
+
+	c.Run("replace updates indexed files and delete removes them", func(c *qt.C) {
+		d := openTestDB(t)
+		_, _ = d.InsertMemory(withFiles("r1", "bug", "old.go"), "")
+
+		_, err := d.ReplaceMemory("r1", "T", "W", "", "", nil, []string{"new.go"}, "bug", "")
+		c.Assert(err, qt.IsNil)
+
+		rows, err := d.SearchByFiles([]string{"old.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 0)
+		rows, err = d.SearchByFiles([]string{"new.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+
+		_, err = d.DeleteMemory("r1")
+		c.Assert(err, qt.IsNil)
+		rows, err = d.SearchByFiles([]string{"new.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 0)
+	})
+}
+
+func TestSearchByFiles_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("no paths returns no rows", func(c *qt.C) {
+		d := openTestDB(t)
+		rows, err := d.SearchByFiles(nil, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 0)
+	})
+
+	c.Run("project filter excludes other projects", func(c *qt.C) {
+		d := openTestDB(t)
+		m := newMem("p1", "T", "proj-a")
+		m.RelatedFiles = []string{"a.go"}
+		_, _ = d.InsertMemory(m, "")
+
+		rows, err := d.SearchByFiles([]string{"a.go"}, 10, "proj-b", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 0)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// FileRefs / SetFileCommit / ListWithFileCommits
+// ---------------------------------------------------------------------------
+
+func TestFileRefs_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("line ranges are parsed and commits are pinned by prefix", func(c *qt.C) {
+		d := openTestDB(t)
+		m := newMem("ref-1234", "T", "proj")
+		m.RelatedFiles = []string{"a.go:10-20", "b.go"}
+		_, err := d.InsertMemory(m, "")
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(d.SetFileCommit("ref-", "abc123"), qt.IsNil)
+
+		refs, err := d.FileRefs([]string{"ref-1234"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(refs["ref-1234"], qt.DeepEquals, []models.FileRef{
+			{Path: "a.go", CommitSHA: "abc123", LineStart: 10, LineEnd: 20},
+			{Path: "b.go", CommitSHA: "abc123"},
+		})
+
+		// The range suffix is not part of the indexed path.
+		rows, err := d.SearchByFiles([]string{"a.go"}, 10, "", "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+	})
+
+	c.Run("only memories with pinned files are listed", func(c *qt.C) {
+		d := openTestDB(t)
+		pinned := newMem("pinned", "Pinned", "proj")
+		pinned.RelatedFiles = []string{"a.go"}
+		unpinned := newMem("unpinned", "Unpinned", "proj")
+		unpinned.RelatedFiles = []string{"b.go"}
+		_, _ = d.InsertMemory(pinned, "")
+		_, _ = d.InsertMemory(unpinned, "")
+		c.Assert(d.SetFileCommit("pinned", "abc123"), qt.IsNil)
+
+		rows, err := d.ListWithFileCommits("")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["id"], qt.Equals, "pinned")
+
+		rows, err = d.ListWithFileCommits("other")
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 0)
+	})
+}
+
+func TestFileRefs_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("no ids returns empty map", func(c *qt.C) {
+		d := openTestDB(t)
+		refs, err := d.FileRefs(nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(refs, qt.HasLen, 0)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Pending embeddings
+// ---------------------------------------------------------------------------
+
+func TestPendingEmbeddings_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// setup stores two memories and queues both.
+	setup := func(c *qt.C) (d *db.DB, a, b int64) {
+		d = openTestDB(t)
+		a, err := d.InsertMemory(newMem("a", "A", "p"), "details of A")
+		c.Assert(err, qt.IsNil)
+		b, err = d.InsertMemory(newMem("b", "B", "p"), "")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.QueueEmbedding(a, "connection refused"), qt.IsNil)
+		c.Assert(d.QueueEmbedding(b, "connection refused"), qt.IsNil)
+		return d, a, b
+	}
+
+	c.Run("queued memories are listed oldest first", func(c *qt.C) {
+		d, a, b := setup(c)
+		c.Assert(d.QueueEmbedding(a, "timeout"), qt.IsNil)
+		n, err := d.PendingCount()
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 2)
+
+		rows, err := d.PendingEmbeddings(0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+		c.Assert(rows[0]["rowid"], qt.Equals, a)
+		c.Assert(rows[0]["details"], qt.Equals, "details of A")
+		c.Assert(rows[1]["rowid"], qt.Equals, b)
+
+		rows, err = d.PendingEmbeddings(1)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+	})
+
+	c.Run("dequeued and deleted memories leave the queue", func(c *qt.C) {
+		d, a, _ := setup(c)
+		c.Assert(d.DequeueEmbedding(a), qt.IsNil)
+		deleted, err := d.DeleteMemory("b")
+		c.Assert(err, qt.IsNil)
+		c.Assert(deleted, qt.IsTrue)
+		n, err := d.PendingCount()
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 0)
+	})
+
+	c.Run("a reindex embedding a memory dequeues it", func(c *qt.C) {
+		d, a, b := setup(c)
+		_, err := d.BeginReindex(2, "new")
+		c.Assert(err, qt.IsNil)
+		c.Assert(d.StageVectors(a, db.EmbeddingState{Hash: "h", Model: "new"}, []float32{1, 0}, nil), qt.IsNil)
+		c.Assert(d.FinishReindex(2, "new"), qt.IsNil)
+		rows, err := d.PendingEmbeddings(0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 1)
+		c.Assert(rows[0]["rowid"], qt.Equals, b)
+	})
+
+	c.Run("memories without a vector are queued once", func(c *qt.C) {
+		d := openTestDB(t)
+		a, err := d.InsertMemory(newMem("a", "A", "p"), "")
+		c.Assert(err, qt.IsNil)
+		b, err := d.InsertMemory(newMem("b", "B", "p"), "")
+		c.Assert(err, qt.IsNil)
+		_, err = d.InsertMemory(newMem("c", "C", "p"), "")
+		c.Assert(err, qt.IsNil)
+
+		n, err := d.QueueMissingEmbeddings("no vector")
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 3)
+
+		c.Assert(d.DequeueEmbedding(a), qt.IsNil)
+		c.Assert(d.DequeueEmbedding(b), qt.IsNil)
+		c.Assert(d.EnsureVecTable(2, "m"), qt.IsNil)
+		c.Assert(d.InsertVector(a, []float32{1, 0}), qt.IsNil)
+		n, err = d.QueueMissingEmbeddings("no vector")
+		c.Assert(err, qt.IsNil)
+		c.Assert(n, qt.Equals, 1)
+		rows, err := d.PendingEmbeddings(0)
+		c.Assert(err, qt.IsNil)
+		c.Assert(rows, qt.HasLen, 2)
+		for _, row := range rows {
+			c.Assert(row["rowid"], qt.Not(qt.Equals), a)
+		}
+	})
+}
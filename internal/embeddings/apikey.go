@@ -0,0 +1,49 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/go-ports/echovault/internal/config"
+)
+
+// apiKeyCmdTimeout bounds how long an api_key_cmd may take, e.g. to unlock
+// a password store.
+const apiKeyCmdTimeout = 30 * time.Second
+
+// ResolveAPIKey returns the API key of ec: the output of api_key_cmd, else
+// the value of the api_key_env variable, else the literal api_key.
+func ResolveAPIKey(ec config.EmbeddingConfig) (string, error) {
+	switch {
+	case len(ec.APIKeyCmd) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), apiKeyCmdTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, ec.APIKeyCmd[0], ec.APIKeyCmd[1:]...) // #nosec G204 -- the command is configured by the user in config.yaml
+		stderr := &tailBuffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("api_key_cmd: %w%s", err, stderr.suffix())
+		}
+		key := strings.TrimSpace(string(out))
+		if key == "" {
+			return "", errors.New("api_key_cmd: printed no key")
+		}
+		return key, nil
+
+	case ec.APIKeyEnv != "":
+		key := strings.TrimSpace(os.Getenv(ec.APIKeyEnv))
+		if key == "" {
+			return "", fmt.Errorf("api_key_env: %s is not set", ec.APIKeyEnv)
+		}
+		return key, nil
+
+	default:
+		return ec.APIKey, nil
+	}
+}
@@ -0,0 +1,95 @@
+package embeddings_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// ResolveAPIKey
+// ---------------------------------------------------------------------------
+
+func TestResolveAPIKey_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("a literal key", func(c *qt.C) {
+		key, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{APIKey: "sk-literal"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(key, qt.Equals, "sk-literal")
+	})
+
+	c.Run("an environment variable wins over the literal key", func(c *qt.C) {
+		c.Setenv("ECHOVAULT_TEST_KEY", "sk-env\n")
+		key, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{APIKey: "sk-literal", APIKeyEnv: "ECHOVAULT_TEST_KEY"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(key, qt.Equals, "sk-env")
+	})
+
+	c.Run("a command wins over the environment", func(c *qt.C) {
+		c.Setenv("ECHOVAULT_TEST_KEY", "sk-env")
+		key, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{
+			APIKeyEnv: "ECHOVAULT_TEST_KEY",
+			APIKeyCmd: []string{"echo", "sk-cmd"},
+		})
+		c.Assert(err, qt.IsNil)
+		c.Assert(key, qt.Equals, "sk-cmd")
+	})
+
+	c.Run("NewProvider sends the resolved key", func(c *qt.C) {
+		var auth string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1]}]}`))
+		}))
+		defer srv.Close()
+		c.Setenv("ECHOVAULT_TEST_KEY", "sk-env")
+
+		conf := cfg("openai", "text-embedding-3-small", "", srv.URL)
+		conf.Embedding.APIKeyEnv = "ECHOVAULT_TEST_KEY"
+		ep, err := embeddings.NewProvider(conf)
+		c.Assert(err, qt.IsNil)
+		_, err = ep.Embed(context.Background(), "hello")
+		c.Assert(err, qt.IsNil)
+		c.Assert(auth, qt.Equals, "Bearer sk-env")
+		c.Assert(conf.Embedding.APIKey, qt.Equals, "")
+	})
+}
+
+func TestResolveAPIKey_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("an unset environment variable", func(c *qt.C) {
+		c.Setenv("ECHOVAULT_TEST_KEY", "")
+		_, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{APIKeyEnv: "ECHOVAULT_TEST_KEY"})
+		c.Assert(err, qt.ErrorMatches, "api_key_env: ECHOVAULT_TEST_KEY is not set")
+	})
+
+	c.Run("a failing command reports its stderr", func(c *qt.C) {
+		_, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{
+			APIKeyCmd: []string{"sh", "-c", "echo vault is locked >&2; exit 2"},
+		})
+		c.Assert(err, qt.ErrorMatches, "api_key_cmd: exit status 2: vault is locked")
+	})
+
+	c.Run("a command printing nothing", func(c *qt.C) {
+		_, err := embeddings.ResolveAPIKey(config.EmbeddingConfig{APIKeyCmd: []string{"true"}})
+		c.Assert(err, qt.ErrorMatches, "api_key_cmd: printed no key")
+	})
+
+	c.Run("NewProvider reports the provider", func(c *qt.C) {
+		c.Setenv("ECHOVAULT_TEST_KEY", "")
+		conf := cfg("cohere", "embed-english-v3.0", "", "")
+		conf.Embedding.APIKeyEnv = "ECHOVAULT_TEST_KEY"
+		ep, err := embeddings.NewProvider(conf)
+		c.Assert(err, qt.ErrorMatches, "embedding provider cohere: api_key_env: ECHOVAULT_TEST_KEY is not set")
+		c.Assert(ep, qt.IsNil)
+	})
+}
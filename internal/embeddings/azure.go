@@ -0,0 +1,87 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+const (
+	// defaultAzureAPIVersion is the Azure OpenAI REST API version used when
+	// none is configured.
+	defaultAzureAPIVersion = "2024-10-21"
+	// azureMaxBatch is the most inputs Azure OpenAI embeds in one request.
+	azureMaxBatch = 2048
+)
+
+// AzureOpenAI calls the embeddings API of an Azure OpenAI deployment.
+type AzureOpenAI struct {
+	// BaseURL is the resource endpoint, e.g. https://NAME.openai.azure.com.
+	BaseURL    string
+	Deployment string
+	APIKey     string // #nosec G117 -- APIKey is an intentional field name for the Azure OpenAI authentication token
+	APIVersion string
+	// Dimensions, when positive, asks models that support it for vectors
+	// shortened to this length.
+	Dimensions int
+	client     *httpjson.Client
+}
+
+// NewAzureOpenAI returns an Azure OpenAI provider for the given deployment.
+// apiVersion defaults to a current general-availability version.
+func NewAzureOpenAI(baseURL, deployment, apiKey, apiVersion string) *AzureOpenAI {
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+	}
+	return &AzureOpenAI{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Deployment: deployment,
+		APIKey:     apiKey,
+		APIVersion: apiVersion,
+		client:     httpjson.Default(),
+	}
+}
+
+// Embed embeds a single text string.
+func (a *AzureOpenAI) Embed(ctx context.Context, text string) ([]float32, error) {
+	results, err := a.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// EmbedQuery embeds a search query; the model embeds queries like
+// documents.
+func (a *AzureOpenAI) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return a.Embed(ctx, text)
+}
+
+// EmbedBatch embeds multiple texts, in requests of up to 2048 texts.
+func (a *AzureOpenAI) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(ctx, texts, azureMaxBatch, a.embed)
+}
+
+func (a *AzureOpenAI) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody := map[string]any{"input": texts}
+	if a.Dimensions > 0 {
+		reqBody["dimensions"] = a.Dimensions
+	}
+	headers := map[string]string{"api-key": a.APIKey}
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/embeddings?api-version=%s",
+		a.BaseURL, url.PathEscape(a.Deployment), url.QueryEscape(a.APIVersion))
+
+	var resp openAIResponse
+	if err := a.client.Do(ctx, http.MethodPost, endpoint, headers, reqBody, &resp); err != nil {
+		return nil, fmt.Errorf("azure-openai embed: %w", err)
+	}
+	results, err := resp.vectors(len(texts))
+	if err != nil {
+		return nil, fmt.Errorf("azure-openai embed: %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,115 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// AzureOpenAI.EmbedBatch
+// ---------------------------------------------------------------------------
+
+func TestAzureOpenAIEmbedBatch_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("request addresses the deployment with api-key and api-version", func(c *qt.C) {
+		var gotPath, gotVersion, gotKey string
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			gotVersion = r.URL.Query().Get("api-version")
+			gotKey = r.Header.Get("api-key")
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0.2]},{"index":0,"embedding":[0.1]}]}`))
+		}))
+		defer srv.Close()
+
+		a := embeddings.NewAzureOpenAI(srv.URL+"/", "embed-prod", "az-key", "")
+		got, err := a.EmbedBatch(context.Background(), []string{"a", "b"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{0.1}, {0.2}})
+		c.Assert(gotPath, qt.Equals, "/openai/deployments/embed-prod/embeddings")
+		c.Assert(gotVersion, qt.Equals, "2024-10-21")
+		c.Assert(gotKey, qt.Equals, "az-key")
+		c.Assert(body, qt.DeepEquals, map[string]any{"input": []any{"a", "b"}})
+	})
+
+	c.Run("dimensions is forwarded", func(c *qt.C) {
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[1.0]}]}`))
+		}))
+		defer srv.Close()
+
+		a := embeddings.NewAzureOpenAI(srv.URL, "embed", "k", "2024-06-01")
+		a.Dimensions = 256
+		_, err := a.Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(body["dimensions"], qt.Equals, float64(256))
+	})
+
+	c.Run("more than 2048 texts are split across requests", func(c *qt.C) {
+		var sizes []int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				Input []string `json:"input"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			sizes = append(sizes, len(body.Input))
+			type item struct {
+				Index     int       `json:"index"`
+				Embedding []float32 `json:"embedding"`
+			}
+			data := make([]item, len(body.Input))
+			for i := range data {
+				data[i] = item{Index: i, Embedding: []float32{1}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+		}))
+		defer srv.Close()
+
+		a := embeddings.NewAzureOpenAI(srv.URL, "embed", "k", "")
+		got, err := a.EmbedBatch(context.Background(), make([]string, 2050))
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.HasLen, 2050)
+		c.Assert(sizes, qt.DeepEquals, []int{2048, 2})
+	})
+}
+
+func TestAzureOpenAIEmbedBatch_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("non-2xx response returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, `{"error":{"code":"DeploymentNotFound"}}`, http.StatusNotFound)
+		}))
+		defer srv.Close()
+
+		a := embeddings.NewAzureOpenAI(srv.URL, "missing", "k", "")
+		_, err := a.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, `azure-openai embed: HTTP 404: .*DeploymentNotFound.*`)
+	})
+
+	c.Run("result count mismatch returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1]}]}`))
+		}))
+		defer srv.Close()
+
+		a := embeddings.NewAzureOpenAI(srv.URL, "embed", "k", "")
+		_, err := a.EmbedBatch(context.Background(), []string{"a", "b"})
+		c.Assert(err, qt.ErrorMatches, "azure-openai embed: expected 2 results, got 1")
+	})
+}
@@ -0,0 +1,132 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+// maxRateLimitRetries bounds how often a request sharing a Throttle is
+// retried after a rate limit. The HTTP client leaves those retries to the
+// Throttle, so its own retries setting does not add to them.
+const maxRateLimitRetries = 5
+
+// Throttle coordinates callers sharing a rate-limited provider: when one of
+// them is told to slow down (HTTP 429), all of them pause before sending
+// their next request. The zero value is ready to use.
+type Throttle struct {
+	mu      sync.Mutex
+	until   time.Time
+	backoff time.Duration
+}
+
+// wait blocks until the throttle allows another request or ctx is done.
+func (t *Throttle) wait(ctx context.Context) error {
+	t.mu.Lock()
+	d := time.Until(t.until)
+	t.mu.Unlock()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// limited reports whether err is a rate-limit response and, if so, pauses
+// all callers for the server's Retry-After delay, or else for a backoff
+// doubling from one second up to a minute.
+func (t *Throttle) limited(err error) bool {
+	var se *httpjson.StatusError
+	if !errors.As(err, &se) || se.Code != http.StatusTooManyRequests {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d := se.RetryAfter
+	if d <= 0 {
+		t.backoff = min(max(2*t.backoff, time.Second), time.Minute)
+		d = t.backoff
+	}
+	if until := time.Now().Add(d); until.After(t.until) {
+		t.until = until
+	}
+	return true
+}
+
+// succeeded resets the backoff after a request went through.
+func (t *Throttle) succeeded() {
+	t.mu.Lock()
+	t.backoff = 0
+	t.mu.Unlock()
+}
+
+// EmbedBatches embeds texts in requests of at most size texts each (all at
+// once when size <= 0). Requests answered with HTTP 429 are retried, up to
+// maxRateLimitRetries times, after the pause t imposes on everyone sharing
+// it; with a nil t they are left to the provider's HTTP client.
+func EmbedBatches(ctx context.Context, p Provider, texts []string, size int, t *Throttle) ([][]float32, error) {
+	if size <= 0 {
+		size = max(len(texts), 1)
+	}
+	out := make([][]float32, 0, len(texts))
+	for batch := range slices.Chunk(texts, size) {
+		vecs, err := embedThrottled(ctx, p, batch, t)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(batch) {
+			return nil, fmt.Errorf("embed batch: expected %d results, got %d", len(batch), len(vecs))
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
+
+func embedThrottled(ctx context.Context, p Provider, texts []string, t *Throttle) ([][]float32, error) {
+	if t != nil {
+		ctx = httpjson.WithRateLimitsHandled(ctx)
+	}
+	for attempt := 0; ; attempt++ {
+		if t != nil {
+			if err := t.wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		vecs, err := p.EmbedBatch(ctx, texts)
+		if err == nil {
+			if t != nil {
+				t.succeeded()
+			}
+			return vecs, nil
+		}
+		if t == nil || attempt == maxRateLimitRetries || !t.limited(err) {
+			return nil, err
+		}
+	}
+}
+
+// inBatches embeds texts with embed in requests of at most limit texts, the
+// most the provider accepts in one request.
+func inBatches(ctx context.Context, texts []string, limit int, embed func(context.Context, []string) ([][]float32, error)) ([][]float32, error) {
+	out := make([][]float32, 0, len(texts))
+	for batch := range slices.Chunk(texts, limit) {
+		vecs, err := embed(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, vecs...)
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package embeddings_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+// fakeProvider embeds each text as its length, failing the first failures
+// requests with err. handled records for each request whether its context
+// leaves rate limits to the caller.
+type fakeProvider struct {
+	failures int
+	err      error
+	batches  [][]string
+	handled  []bool
+}
+
+func (f *fakeProvider) Embed(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := f.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (f *fakeProvider) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return f.Embed(ctx, text)
+}
+
+func (f *fakeProvider) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	f.handled = append(f.handled, httpjson.RateLimitsHandled(ctx))
+	if f.failures > 0 {
+		f.failures--
+		return nil, f.err
+	}
+	f.batches = append(f.batches, texts)
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = []float32{float32(len(t))}
+	}
+	return out, nil
+}
+
+func TestEmbedBatches_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("texts are split into requests of at most size", func(c *qt.C) {
+		p := &fakeProvider{}
+		got, err := embeddings.EmbedBatches(context.Background(), p, []string{"a", "bb", "ccc"}, 2, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{1}, {2}, {3}})
+		c.Assert(p.batches, qt.DeepEquals, [][]string{{"a", "bb"}, {"ccc"}})
+	})
+
+	c.Run("rate-limited requests are retried after Retry-After", func(c *qt.C) {
+		p := &fakeProvider{
+			failures: 2,
+			err:      &httpjson.StatusError{Code: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+		}
+		got, err := embeddings.EmbedBatches(context.Background(), p, []string{"a"}, 0, &embeddings.Throttle{})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{1}})
+		c.Assert(p.handled, qt.DeepEquals, []bool{true, true, true})
+	})
+
+	c.Run("without a throttle rate limits are left to the HTTP client", func(c *qt.C) {
+		p := &fakeProvider{}
+		_, err := embeddings.EmbedBatches(context.Background(), p, []string{"a"}, 0, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(p.handled, qt.DeepEquals, []bool{false})
+	})
+}
+
+func TestEmbedBatches_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("other errors are not retried", func(c *qt.C) {
+		boom := errors.New("boom")
+		p := &fakeProvider{failures: 1, err: boom}
+		_, err := embeddings.EmbedBatches(context.Background(), p, []string{"a"}, 0, &embeddings.Throttle{})
+		c.Assert(err, qt.Equals, boom)
+	})
+
+	c.Run("rate limiting gives up after the retries", func(c *qt.C) {
+		p := &fakeProvider{
+			failures: 100,
+			err:      &httpjson.StatusError{Code: http.StatusTooManyRequests, RetryAfter: time.Millisecond},
+		}
+		_, err := embeddings.EmbedBatches(context.Background(), p, []string{"a"}, 0, &embeddings.Throttle{})
+		c.Assert(err, qt.ErrorMatches, "HTTP 429: ")
+		c.Assert(p.failures, qt.Equals, 94)
+	})
+
+	c.Run("without a throttle a rate limit fails at once", func(c *qt.C) {
+		p := &fakeProvider{failures: 1, err: &httpjson.StatusError{Code: http.StatusTooManyRequests}}
+		_, err := embeddings.EmbedBatches(context.Background(), p, []string{"a"}, 0, nil)
+		c.Assert(err, qt.IsNotNil)
+		c.Assert(p.failures, qt.Equals, 0)
+	})
+}
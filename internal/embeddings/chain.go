@@ -0,0 +1,357 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+const (
+	// breakerThreshold consecutive failures open a provider's circuit: it is
+	// skipped for breakerCooldown, then given one more try.
+	breakerThreshold = 3
+	breakerCooldown  = time.Minute
+	// healthTTL is how long a readiness check of a provider is trusted.
+	healthTTL = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned when every provider of a model failed too
+// often lately to be tried again yet.
+var ErrCircuitOpen = errors.New("circuit open after repeated failures")
+
+// ModelID identifies the embedding model of cfg as "provider/model".
+func ModelID(cfg *config.MemoryConfig) string {
+	return cfg.Embedding.Provider + "/" + cfg.Embedding.Model
+}
+
+// ChainConfigs returns the settings of each provider in cfg's chain: the
+// primary, then embedding.fallbacks in order. It is empty when embedding is
+// disabled; fallbacks set to "none" are left out.
+func ChainConfigs(cfg *config.MemoryConfig) []*config.MemoryConfig {
+	if p := cfg.Embedding.Provider; p == "" || p == "none" {
+		return nil
+	}
+	primary := *cfg
+	primary.Embedding.Fallbacks = nil
+	out := []*config.MemoryConfig{&primary}
+	for _, fb := range cfg.Embedding.Fallbacks {
+		if fb.Provider == "" || fb.Provider == "none" {
+			continue
+		}
+		c := *cfg
+		c.Embedding = fb
+		out = append(out, &c)
+	}
+	return out
+}
+
+// Chain is an ordered list of embedding providers. Vectors of different
+// models cannot be compared, so callers embed through the Route of one
+// model, which falls back only between the providers serving that model.
+type Chain struct {
+	members []*member
+}
+
+// member is one provider of a Chain with its circuit breaker and cached
+// readiness. A provider whose API key comes from api_key_env or api_key_cmd
+// is constructed when first used, so that a key that cannot be resolved only
+// takes that provider out of the chain.
+type member struct {
+	id       string
+	cfg      *config.MemoryConfig
+	fallback bool
+
+	pmu    sync.Mutex // guards p, pErr and pRetry; held while the key resolves
+	p      Provider
+	pErr   error     // why p could not be constructed
+	pRetry time.Time // when to try constructing p again after pErr
+
+	mu        sync.Mutex
+	failures  int       // consecutive failures
+	openUntil time.Time // circuit open until then
+	ready     bool
+	checkedAt time.Time
+}
+
+// NewChain constructs the providers of cfg's chain. Providers whose API key
+// has to be resolved are only checked for other configuration errors; their
+// key is resolved when they are first used. Returns (nil, nil) when
+// embedding is disabled.
+func NewChain(cfg *config.MemoryConfig) (*Chain, error) {
+	c := &Chain{}
+	for i, mc := range ChainConfigs(cfg) {
+		m := &member{id: ModelID(mc), cfg: mc, fallback: i > 0}
+		ec := mc.Embedding
+		lazy := ec.APIKeyEnv != "" || len(ec.APIKeyCmd) > 0
+		check := mc
+		if lazy {
+			unresolved := *mc
+			unresolved.Embedding.APIKeyEnv, unresolved.Embedding.APIKeyCmd = "", nil
+			check = &unresolved
+		}
+		p, err := NewProvider(check)
+		if err != nil {
+			_ = c.Close()
+			return nil, m.wrap(err)
+		}
+		if lazy {
+			_ = Close(p)
+		} else {
+			m.p = p
+		}
+		c.members = append(c.members, m)
+	}
+	if len(c.members) == 0 {
+		return nil, nil
+	}
+	return c, nil
+}
+
+// Models returns the models of the chain, in order of preference.
+func (c *Chain) Models() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range c.members {
+		if !seen[m.id] {
+			seen[m.id] = true
+			out = append(out, m.id)
+		}
+	}
+	return out
+}
+
+// Route returns the Provider embedding with model through the providers of
+// the chain serving it, or nil when none does.
+func (c *Chain) Route(model string) *Route {
+	r := &Route{Model: model}
+	for _, m := range c.members {
+		if m.id == model {
+			r.members = append(r.members, m)
+		}
+	}
+	if len(r.members) == 0 {
+		return nil
+	}
+	r.Config = r.members[0].cfg
+	return r
+}
+
+// Probe embeds a test text with every provider of the chain, in order,
+// regardless of their circuits, and records the outcomes in them.
+func (c *Chain) Probe(ctx context.Context) []ProbeResult {
+	out := make([]ProbeResult, len(c.members))
+	for i, m := range c.members {
+		p, err := m.provider()
+		if err != nil {
+			out[i] = ProbeResult{Model: m.id, Err: err}
+			continue
+		}
+		vec, err := p.Embed(ctx, "echovault doctor")
+		m.record(ctx, err)
+		out[i] = ProbeResult{Model: m.id, Dim: len(vec), Err: err}
+	}
+	return out
+}
+
+// ProbeResult is the outcome of probing one provider of a Chain.
+type ProbeResult struct {
+	Model string
+	Dim   int
+	Err   error
+}
+
+// Close closes every provider of the chain.
+func (c *Chain) Close() error {
+	var errs []error
+	for _, m := range c.members {
+		m.pmu.Lock()
+		errs = append(errs, Close(m.p))
+		m.p = nil
+		m.pmu.Unlock()
+	}
+	return errors.Join(errs...)
+}
+
+// Route embeds with one model, trying the providers that serve it in chain
+// order and skipping those whose circuit is open.
+type Route struct {
+	Model  string               // "provider/model"
+	Config *config.MemoryConfig // settings of the first provider serving Model
+
+	members []*member
+}
+
+// Embed embeds a single document.
+func (r *Route) Embed(ctx context.Context, text string) (vec []float32, err error) {
+	err = r.try(ctx, func(p Provider) error {
+		vec, err = p.Embed(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+// EmbedBatch embeds multiple documents.
+func (r *Route) EmbedBatch(ctx context.Context, texts []string) (vecs [][]float32, err error) {
+	err = r.try(ctx, func(p Provider) error {
+		vecs, err = p.EmbedBatch(ctx, texts)
+		return err
+	})
+	return vecs, err
+}
+
+// EmbedQuery embeds a search query.
+func (r *Route) EmbedQuery(ctx context.Context, text string) (vec []float32, err error) {
+	err = r.try(ctx, func(p Provider) error {
+		vec, err = p.EmbedQuery(ctx, text)
+		return err
+	})
+	return vec, err
+}
+
+// Ready reports whether a provider of the route is expected to answer
+// promptly: its circuit is closed and, for Ollama, the model is loaded.
+// Readiness checks are cached for healthTTL.
+func (r *Route) Ready() bool {
+	for _, m := range r.members {
+		if m.isReady() {
+			return true
+		}
+	}
+	return false
+}
+
+// try calls embed with each provider in turn until one succeeds. A single
+// provider's error is returned as it is; the errors of several are joined.
+func (r *Route) try(ctx context.Context, embed func(Provider) error) error {
+	var errs []error
+	for _, m := range r.members {
+		if !m.allowed() {
+			continue
+		}
+		p, err := m.provider()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = embed(p)
+		m.record(ctx, err)
+		if err == nil || ctx.Err() != nil || isRateLimit(err) {
+			return err
+		}
+		errs = append(errs, err)
+	}
+	switch len(errs) {
+	case 0:
+		return fmt.Errorf("embedding %s: %w", r.Model, ErrCircuitOpen)
+	case 1:
+		return errs[0]
+	default:
+		return errors.Join(errs...)
+	}
+}
+
+// isRateLimit reports whether err is an HTTP 429 answer. Rate limits are
+// retried by the caller rather than counted against the provider.
+func isRateLimit(err error) bool {
+	var se *httpjson.StatusError
+	return errors.As(err, &se) && se.Code == http.StatusTooManyRequests
+}
+
+// provider returns the member's Provider, constructing it on first use. A
+// provider that cannot be constructed, because its API key cannot be
+// resolved, opens the circuit, and the error is returned without trying
+// again until the circuit's cooldown has passed.
+func (m *member) provider() (Provider, error) {
+	m.pmu.Lock()
+	defer m.pmu.Unlock()
+	if m.p != nil {
+		return m.p, nil
+	}
+	if m.pErr != nil && time.Now().Before(m.pRetry) {
+		return nil, m.pErr
+	}
+	p, err := NewProvider(m.cfg)
+	if err != nil {
+		m.pErr, m.pRetry = m.wrap(err), time.Now().Add(breakerCooldown)
+		m.mu.Lock()
+		m.failures, m.openUntil = breakerThreshold, m.pRetry
+		m.ready, m.checkedAt = false, time.Now()
+		m.mu.Unlock()
+		return nil, m.pErr
+	}
+	m.p, m.pErr = p, nil
+	return p, nil
+}
+
+// wrap names the setting err comes from when the member is a fallback.
+func (m *member) wrap(err error) error {
+	if m.fallback {
+		return fmt.Errorf("embedding.fallbacks: %w", err)
+	}
+	return err
+}
+
+// allowed reports whether the member's circuit lets a request through.
+func (m *member) allowed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failures < breakerThreshold || !time.Now().Before(m.openUntil)
+}
+
+// record updates the circuit with the outcome of a request. Cancelled
+// requests and rate limits do not count.
+func (m *member) record(ctx context.Context, err error) {
+	if err != nil && (ctx.Err() != nil || isRateLimit(err)) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		m.failures = 0
+		m.ready, m.checkedAt = true, time.Now()
+		return
+	}
+	m.failures++
+	m.ready, m.checkedAt = false, time.Now()
+	if m.failures >= breakerThreshold {
+		m.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// isReady reports the member's readiness, checking it again once the
+// cached answer is older than healthTTL. The check runs without holding mu,
+// so requests recording their outcome meanwhile are not held up; their
+// outcome is newer and wins over the check's.
+func (m *member) isReady() bool {
+	if !m.allowed() {
+		return false
+	}
+	m.mu.Lock()
+	checkedAt, ready := m.checkedAt, m.ready
+	m.mu.Unlock()
+	if !checkedAt.IsZero() && time.Since(checkedAt) < healthTTL {
+		return ready
+	}
+
+	ready = true
+	if ec := m.cfg.Embedding; ec.Provider == "ollama" {
+		baseURL := ec.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		ready = IsOllamaModelLoaded(ec.Model, baseURL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkedAt.Equal(checkedAt) {
+		m.ready, m.checkedAt = ready, time.Now()
+	}
+	return m.ready
+}
@@ -0,0 +1,280 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// Chain
+// ---------------------------------------------------------------------------
+
+// newChainServer answers TEI /embed requests with the given status, and with
+// the vector [value] per text when it is 200. It counts the requests.
+func newChainServer(c *qt.C, status int, value float32, calls *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if status != http.StatusOK {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		var body struct {
+			Inputs []string `json:"inputs"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		vecs := make([][]float32, len(body.Inputs))
+		for i := range body.Inputs {
+			vecs[i] = []float32{value}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(vecs)
+	}))
+	c.Cleanup(srv.Close)
+	return srv
+}
+
+// chainConfig returns a config embedding with a TEI server at each URL,
+// all serving model "m" unless models gives another name. Requests are not
+// retried.
+func chainConfig(urls []string, models ...string) *config.MemoryConfig {
+	mc := cfg("tei", "m", "", urls[0])
+	mc.Embedding.Retries = 0
+	for i, u := range urls[1:] {
+		fb := mc.Embedding
+		fb.BaseURL = u
+		if i < len(models) {
+			fb.Model = models[i]
+		}
+		mc.Embedding.Fallbacks = append(mc.Embedding.Fallbacks, fb)
+	}
+	return mc
+}
+
+func TestChain_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("a failing provider falls back to the next serving the model", func(c *qt.C) {
+		var down, up atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusServiceUnavailable, 0, &down).URL,
+			newChainServer(c, http.StatusOK, 7, &up).URL,
+		}))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+		c.Assert(chain.Models(), qt.DeepEquals, []string{"tei/m"})
+
+		vec, err := chain.Route("tei/m").Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(vec, qt.DeepEquals, []float32{7})
+		c.Assert(down.Load(), qt.Equals, int32(1))
+		c.Assert(up.Load(), qt.Equals, int32(1))
+	})
+
+	c.Run("a provider failing repeatedly is skipped", func(c *qt.C) {
+		var down, up atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusServiceUnavailable, 0, &down).URL,
+			newChainServer(c, http.StatusOK, 7, &up).URL,
+		}))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		r := chain.Route("tei/m")
+		for range 5 {
+			_, err := r.Embed(context.Background(), "a")
+			c.Assert(err, qt.IsNil)
+		}
+		c.Assert(down.Load(), qt.Equals, int32(3))
+		c.Assert(up.Load(), qt.Equals, int32(5))
+	})
+
+	c.Run("routes only use providers of their model", func(c *qt.C) {
+		var first, second atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusServiceUnavailable, 0, &first).URL,
+			newChainServer(c, http.StatusOK, 7, &second).URL,
+		}, "other"))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+		c.Assert(chain.Models(), qt.DeepEquals, []string{"tei/m", "tei/other"})
+
+		_, err = chain.Route("tei/m").Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "tei embed: .*503.*")
+		c.Assert(second.Load(), qt.Equals, int32(0))
+
+		vec, err := chain.Route("tei/other").Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(vec, qt.DeepEquals, []float32{7})
+		c.Assert(chain.Route("openai/m"), qt.IsNil)
+	})
+
+	c.Run("probe reports every provider", func(c *qt.C) {
+		var down, up atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusServiceUnavailable, 0, &down).URL,
+			newChainServer(c, http.StatusOK, 7, &up).URL,
+		}, "other"))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		got := chain.Probe(context.Background())
+		c.Assert(got, qt.HasLen, 2)
+		c.Assert(got[0].Model, qt.Equals, "tei/m")
+		c.Assert(got[0].Err, qt.IsNotNil)
+		c.Assert(got[1].Model, qt.Equals, "tei/other")
+		c.Assert(got[1].Err, qt.IsNil)
+		c.Assert(got[1].Dim, qt.Equals, 1)
+	})
+
+	c.Run("requests are not held up by a readiness check", func(c *qt.C) {
+		checking, release := make(chan struct{}), make(chan struct{})
+		var checked atomic.Bool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/ps" {
+				close(checking)
+				select {
+				case <-release:
+				case <-r.Context().Done():
+				}
+				checked.Store(true)
+				_, _ = w.Write([]byte(`{"models":[{"name":"m:latest"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"embeddings":[[7]]}`))
+		}))
+		c.Cleanup(srv.Close)
+		chain, err := embeddings.NewChain(cfg("ollama", "m", "", srv.URL))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+		r := chain.Route("ollama/m")
+
+		ready := make(chan bool)
+		go func() { ready <- r.Ready() }()
+		<-checking
+		vec, err := r.Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(vec, qt.DeepEquals, []float32{7})
+		c.Assert(checked.Load(), qt.IsFalse)
+		close(release)
+		c.Assert(<-ready, qt.IsTrue)
+	})
+
+	c.Run("a provider whose key cannot be resolved is skipped", func(c *qt.C) {
+		var up atomic.Int32
+		runs := filepath.Join(c.TempDir(), "runs")
+		mc := chainConfig([]string{"http://localhost:1", newChainServer(c, http.StatusOK, 7, &up).URL})
+		mc.Embedding.APIKeyCmd = []string{"sh", "-c", "echo run >> " + runs + "; echo vault is locked >&2; exit 2"}
+		chain, err := embeddings.NewChain(mc)
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		r := chain.Route("tei/m")
+		for range 3 {
+			vec, err := r.Embed(context.Background(), "a")
+			c.Assert(err, qt.IsNil)
+			c.Assert(vec, qt.DeepEquals, []float32{7})
+		}
+		data, err := os.ReadFile(runs)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(data), qt.Equals, "run\n")
+
+		got := chain.Probe(context.Background())
+		c.Assert(got[0].Err, qt.ErrorMatches, "embedding provider tei: api_key_cmd: exit status 2: vault is locked")
+		c.Assert(got[1].Err, qt.IsNil)
+	})
+
+	c.Run("a fallback's key is resolved when the fallback is first used", func(c *qt.C) {
+		var up, fb atomic.Int32
+		mc := chainConfig([]string{
+			newChainServer(c, http.StatusOK, 7, &up).URL,
+			newChainServer(c, http.StatusOK, 9, &fb).URL,
+		})
+		mc.Embedding.Fallbacks[0].APIKeyEnv = "ECHOVAULT_TEST_FALLBACK_KEY"
+		chain, err := embeddings.NewChain(mc)
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		vec, err := chain.Route("tei/m").Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(vec, qt.DeepEquals, []float32{7})
+	})
+
+	c.Run("embedding disabled yields no chain", func(c *qt.C) {
+		chain, err := embeddings.NewChain(cfg("none", "", "", ""))
+		c.Assert(err, qt.IsNil)
+		c.Assert(chain, qt.IsNil)
+	})
+}
+
+func TestChain_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("once every circuit is open requests are not sent", func(c *qt.C) {
+		var down atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusServiceUnavailable, 0, &down).URL,
+		}))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		r := chain.Route("tei/m")
+		for range 3 {
+			_, err := r.Embed(context.Background(), "a")
+			c.Assert(err, qt.ErrorMatches, "tei embed: .*503.*")
+		}
+		_, err = r.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorIs, embeddings.ErrCircuitOpen)
+		c.Assert(down.Load(), qt.Equals, int32(3))
+		c.Assert(r.Ready(), qt.IsFalse)
+	})
+
+	c.Run("rate limits do not open the circuit or fall back", func(c *qt.C) {
+		var limited, up atomic.Int32
+		chain, err := embeddings.NewChain(chainConfig([]string{
+			newChainServer(c, http.StatusTooManyRequests, 0, &limited).URL,
+			newChainServer(c, http.StatusOK, 7, &up).URL,
+		}))
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		r := chain.Route("tei/m")
+		for range 4 {
+			_, err := r.Embed(context.Background(), "a")
+			c.Assert(err, qt.ErrorMatches, "tei embed: .*429.*")
+		}
+		c.Assert(limited.Load(), qt.Equals, int32(4))
+		c.Assert(up.Load(), qt.Equals, int32(0))
+	})
+
+	c.Run("a fallback whose key cannot be resolved fails when it is needed", func(c *qt.C) {
+		var down atomic.Int32
+		mc := chainConfig([]string{newChainServer(c, http.StatusServiceUnavailable, 0, &down).URL, "http://localhost:1"})
+		mc.Embedding.Fallbacks[0].APIKeyEnv = "ECHOVAULT_TEST_FALLBACK_KEY"
+		chain, err := embeddings.NewChain(mc)
+		c.Assert(err, qt.IsNil)
+		c.Cleanup(func() { _ = chain.Close() })
+
+		r := chain.Route("tei/m")
+		_, err = r.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "(?s)tei embed: .*503.*\nembedding.fallbacks: embedding provider tei: api_key_env: ECHOVAULT_TEST_FALLBACK_KEY is not set")
+		c.Assert(r.Ready(), qt.IsFalse)
+	})
+
+	c.Run("an invalid fallback is reported", func(c *qt.C) {
+		mc := cfg("tei", "m", "", "http://localhost:1")
+		mc.Embedding.Fallbacks = []config.EmbeddingConfig{{Provider: "bogus", Model: "m"}}
+		_, err := embeddings.NewChain(mc)
+		c.Assert(err, qt.ErrorMatches, "embedding.fallbacks: .*bogus.*")
+	})
+}
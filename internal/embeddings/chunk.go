@@ -0,0 +1,40 @@
+package embeddings
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunks splits text into pieces of at most size characters, breaking
+// between words, so each can be embedded separately. Consecutive chunks share
+// about overlap characters (capped at half the size) so that a passage cut at
+// a boundary still appears whole in one of them. A single word longer than
+// size becomes its own chunk. It returns nil for blank text or size <= 0.
+func Chunks(text string, size, overlap int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 || size <= 0 {
+		return nil
+	}
+	overlap = min(max(overlap, 0), size/2)
+
+	var chunks []string
+	for start := 0; ; {
+		end, n := start+1, utf8.RuneCountInString(words[start])
+		for end < len(words) && n+1+utf8.RuneCountInString(words[end]) <= size {
+			n += 1 + utf8.RuneCountInString(words[end])
+			end++
+		}
+		chunks = append(chunks, strings.Join(words[start:end], " "))
+		if end == len(words) {
+			return chunks
+		}
+
+		// Step back over up to overlap characters, always moving forward.
+		next, back := end, 0
+		for next-1 > start && back+utf8.RuneCountInString(words[next-1])+1 <= overlap {
+			next--
+			back += utf8.RuneCountInString(words[next]) + 1
+		}
+		start = next
+	}
+}
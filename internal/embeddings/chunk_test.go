@@ -0,0 +1,43 @@
+package embeddings_test
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+func TestChunks_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("short text is a single chunk", func(c *qt.C) {
+		got := embeddings.Chunks("  one   two\nthree ", 100, 20)
+		c.Assert(got, qt.DeepEquals, []string{"one two three"})
+	})
+
+	c.Run("chunks break between words and overlap", func(c *qt.C) {
+		got := embeddings.Chunks("aa bb cc dd ee ff gg", 8, 3)
+		c.Assert(got, qt.DeepEquals, []string{"aa bb cc", "cc dd ee", "ee ff gg"})
+	})
+
+	c.Run("without overlap every word appears once", func(c *qt.C) {
+		text := strings.Repeat("word ", 50)
+		got := embeddings.Chunks(text, 20, 0)
+		c.Assert(strings.Fields(strings.Join(got, " ")), qt.HasLen, 50)
+		for _, ch := range got {
+			c.Assert(len(ch) <= 20, qt.IsTrue)
+		}
+	})
+
+	c.Run("an overlong word is its own chunk", func(c *qt.C) {
+		got := embeddings.Chunks("a verylongidentifier b", 5, 2)
+		c.Assert(got, qt.DeepEquals, []string{"a", "verylongidentifier", "b"})
+	})
+
+	c.Run("blank text or disabled size yields nothing", func(c *qt.C) {
+		c.Assert(embeddings.Chunks("   ", 10, 0), qt.IsNil)
+		c.Assert(embeddings.Chunks("some text", 0, 0), qt.IsNil)
+	})
+}
@@ -0,0 +1,91 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+const (
+	defaultCohereBase = "https://api.cohere.com"
+	// cohereMaxBatch is the most texts Cohere embeds in one request.
+	cohereMaxBatch = 96
+)
+
+// Cohere calls the Cohere v2 embed API.
+type Cohere struct {
+	Model   string
+	APIKey  string // #nosec G117 -- APIKey is an intentional field name for the Cohere authentication token
+	BaseURL string
+	client  *httpjson.Client
+}
+
+// NewCohere returns a Cohere provider. baseURL defaults to the Cohere API.
+func NewCohere(model, apiKey, baseURL string) *Cohere {
+	if baseURL == "" {
+		baseURL = defaultCohereBase
+	}
+	return &Cohere{
+		Model:   model,
+		APIKey:  apiKey,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client:  httpjson.Default(),
+	}
+}
+
+// Embed embeds a single text string.
+func (c *Cohere) Embed(ctx context.Context, text string) ([]float32, error) {
+	results, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// EmbedBatch embeds multiple texts as documents to be searched, in
+// requests of up to 96 texts.
+func (c *Cohere) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(ctx, texts, cohereMaxBatch, func(ctx context.Context, batch []string) ([][]float32, error) {
+		return c.embed(ctx, batch, "search_document")
+	})
+}
+
+// EmbedQuery embeds a search query with input_type "search_query".
+func (c *Cohere) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	results, err := c.embed(ctx, []string{text}, "search_query")
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+func (c *Cohere) embed(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	reqBody := map[string]any{
+		"model":           c.Model,
+		"texts":           texts,
+		"input_type":      inputType,
+		"embedding_types": []string{"float"},
+	}
+	headers := map[string]string{"Authorization": "Bearer " + c.APIKey}
+
+	var resp struct {
+		Embeddings struct {
+			Float [][]float32 `json:"float"`
+		} `json:"embeddings"`
+	}
+	if err := c.client.Do(ctx, http.MethodPost, c.BaseURL+"/v2/embed", headers, reqBody, &resp); err != nil {
+		return nil, fmt.Errorf("cohere embed: %w", err)
+	}
+	if len(resp.Embeddings.Float) != len(texts) {
+		return nil, fmt.Errorf("cohere embed: expected %d results, got %d", len(texts), len(resp.Embeddings.Float))
+	}
+	for _, e := range resp.Embeddings.Float {
+		if len(e) == 0 {
+			return nil, fmt.Errorf("cohere embed: empty embedding returned")
+		}
+	}
+	return resp.Embeddings.Float, nil
+}
@@ -0,0 +1,115 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// Cohere.EmbedBatch
+// ---------------------------------------------------------------------------
+
+// newCohereServer answers /v2/embed with a one-dimensional vector per text,
+// recording the request bodies.
+func newCohereServer(c *qt.C, bodies *[]map[string]any) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/embed" || r.Header.Get("Authorization") != "Bearer co-key" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*bodies = append(*bodies, body)
+		texts, _ := body["texts"].([]any)
+		vecs := make([][]float32, len(texts))
+		for i := range vecs {
+			vecs[i] = []float32{float32(i)}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": map[string]any{"float": vecs}})
+	}))
+	c.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCohereEmbedBatch_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("texts are embedded as search documents", func(c *qt.C) {
+		var bodies []map[string]any
+		srv := newCohereServer(c, &bodies)
+
+		co := embeddings.NewCohere("embed-english-v3.0", "co-key", srv.URL)
+		got, err := co.EmbedBatch(context.Background(), []string{"a", "b"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{0}, {1}})
+		c.Assert(bodies, qt.DeepEquals, []map[string]any{{
+			"model":           "embed-english-v3.0",
+			"texts":           []any{"a", "b"},
+			"input_type":      "search_document",
+			"embedding_types": []any{"float"},
+		}})
+	})
+
+	c.Run("more than 96 texts are split across requests", func(c *qt.C) {
+		var bodies []map[string]any
+		srv := newCohereServer(c, &bodies)
+
+		co := embeddings.NewCohere("embed-english-v3.0", "co-key", srv.URL)
+		got, err := co.EmbedBatch(context.Background(), make([]string, 100))
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.HasLen, 100)
+		c.Assert(bodies, qt.HasLen, 2)
+		c.Assert(bodies[0]["texts"], qt.HasLen, 96)
+		c.Assert(bodies[1]["texts"], qt.HasLen, 4)
+		c.Assert(got[99], qt.DeepEquals, []float32{3})
+	})
+}
+
+func TestCohereEmbedQuery_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	var bodies []map[string]any
+	srv := newCohereServer(c, &bodies)
+
+	co := embeddings.NewCohere("embed-english-v3.0", "co-key", srv.URL)
+	got, err := co.EmbedQuery(context.Background(), "q")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, []float32{0})
+	c.Assert(bodies, qt.HasLen, 1)
+	c.Assert(bodies[0]["input_type"], qt.Equals, "search_query")
+}
+
+func TestCohereEmbedBatch_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("non-2xx response returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, `{"message":"invalid api token"}`, http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		co := embeddings.NewCohere("embed-english-v3.0", "bad", srv.URL)
+		_, err := co.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, `cohere embed: HTTP 401: .*invalid api token.*`)
+	})
+
+	c.Run("missing float embeddings return error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"embeddings":{}}`))
+		}))
+		defer srv.Close()
+
+		co := embeddings.NewCohere("embed-english-v3.0", "co-key", srv.URL)
+		_, err := co.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "cohere embed: expected 1 results, got 0")
+	})
+}
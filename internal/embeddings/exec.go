@@ -0,0 +1,232 @@
+package embeddings
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exec embeds texts with a local command kept running across calls. For
+// each request the command reads one line of JSON from stdin,
+//
+//	{"texts": ["...", ...], "input_type": "document" | "query"}
+//
+// and must print one line {"vectors": [[...], ...]} with a vector per
+// text, in order, or {"error": "..."}. A command that exits is started
+// again on the next request; one that does not answer within Timeout is
+// killed.
+type Exec struct {
+	Command []string
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	proc *execProcess
+}
+
+// execProcess is a running Exec command.
+type execProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *tailBuffer
+}
+
+// NewExec returns an Exec provider running command (program and arguments)
+// with a 30s timeout per request.
+func NewExec(command []string) *Exec {
+	return &Exec{Command: command, Timeout: 30 * time.Second}
+}
+
+// Embed embeds a single document.
+func (e *Exec) Embed(ctx context.Context, text string) ([]float32, error) {
+	results, err := e.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// EmbedBatch embeds multiple documents in one request.
+func (e *Exec) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return e.request(ctx, texts, "document")
+}
+
+// EmbedQuery embeds a search query.
+func (e *Exec) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	results, err := e.request(ctx, []string{text}, "query")
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// Close stops the command.
+func (e *Exec) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+	return nil
+}
+
+// errExecCrashed marks a request that failed because the command exited.
+var errExecCrashed = errors.New("command exited")
+
+// request sends texts to the command, starting it when it is not running.
+// When a command that served earlier requests has exited in the meantime,
+// it is restarted and the request retried once.
+func (e *Exec) request(ctx context.Context, texts []string, inputType string) ([][]float32, error) {
+	line, err := json.Marshal(map[string]any{"texts": texts, "input_type": inputType})
+	if err != nil {
+		return nil, fmt.Errorf("exec embed: %w", err)
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	warm := e.proc != nil
+	vecs, err := e.roundTrip(ctx, line, len(texts))
+	if errors.Is(err, errExecCrashed) && warm {
+		vecs, err = e.roundTrip(ctx, line, len(texts))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("exec embed: %w", err)
+	}
+	for _, v := range vecs {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("exec embed: empty vector returned")
+		}
+	}
+	return vecs, nil
+}
+
+// roundTrip writes one request line for n texts and reads the answer. An
+// answer that is not one also stops the command, as the lines it prints
+// next could answer this request rather than the next one. Callers hold mu.
+func (e *Exec) roundTrip(ctx context.Context, line []byte, n int) ([][]float32, error) {
+	if e.proc == nil {
+		if err := e.start(); err != nil {
+			return nil, err
+		}
+	}
+	p := e.proc
+
+	type answer struct {
+		line []byte
+		err  error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		if _, err := p.stdin.Write(line); err != nil {
+			answers <- answer{err: err}
+			return
+		}
+		out, err := p.stdout.ReadBytes('\n')
+		answers <- answer{out, err}
+	}()
+
+	timer := time.NewTimer(e.Timeout)
+	defer timer.Stop()
+	var a answer
+	select {
+	case a = <-answers:
+	case <-ctx.Done():
+		e.stop()
+		return nil, ctx.Err()
+	case <-timer.C:
+		e.stop()
+		return nil, fmt.Errorf("no answer within %s", e.Timeout)
+	}
+	if a.err != nil {
+		e.stop()
+		return nil, fmt.Errorf("%w: %s%s", errExecCrashed, p.cmd.ProcessState, p.stderr.suffix())
+	}
+
+	var resp struct {
+		Vectors [][]float32 `json:"vectors"`
+		Error   string      `json:"error"`
+	}
+	if err := json.Unmarshal(a.line, &resp); err != nil {
+		e.stop()
+		return nil, fmt.Errorf("decode output: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	if len(resp.Vectors) != n {
+		e.stop()
+		return nil, fmt.Errorf("expected %d vectors, got %d", n, len(resp.Vectors))
+	}
+	return resp.Vectors, nil
+}
+
+// start runs the command. Callers hold mu.
+func (e *Exec) start() error {
+	if len(e.Command) == 0 {
+		return errors.New("no command configured")
+	}
+	cmd := exec.Command(e.Command[0], e.Command[1:]...) // #nosec G204 -- the command is configured by the user in config.yaml
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	e.proc = &execProcess{cmd: cmd, stdin: stdin, stdout: bufio.NewReaderSize(stdout, 1<<20), stderr: stderr}
+	return nil
+}
+
+// stop kills the command and waits for it to exit. Callers hold mu.
+func (e *Exec) stop() {
+	if e.proc == nil {
+		return
+	}
+	_ = e.proc.stdin.Close()
+	_ = e.proc.cmd.Process.Kill()
+	_ = e.proc.cmd.Wait()
+	e.proc = nil
+}
+
+// tailBuffer keeps the last few KiB written to it, to report what a failed
+// command printed on stderr.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailBufferSize = 4096
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > tailBufferSize {
+		b.buf = b.buf[len(b.buf)-tailBufferSize:]
+	}
+	return len(p), nil
+}
+
+// suffix returns ": <stderr>" for the output so far, or "" when there is
+// none.
+func (b *tailBuffer) suffix() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := strings.TrimSpace(string(b.buf))
+	if s == "" {
+		return ""
+	}
+	return ": " + s
+}
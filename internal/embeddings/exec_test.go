@@ -0,0 +1,153 @@
+package embeddings_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// Exec
+// ---------------------------------------------------------------------------
+
+// TestHelperProcess is not a real test: it is the embedding command that the
+// Exec tests run, re-executing the test binary. Each text is embedded as
+// [its length, the process ID, 1 for queries]. The mode argument makes it
+// misbehave; "junk-once" prints a stray line before its first answer unless
+// the file named by ECHOVAULT_EMBED_MARKER exists, and creates it.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("ECHOVAULT_EMBED_HELPER") != "1" {
+		t.Skip("helper process for the Exec tests")
+	}
+	mode := os.Args[len(os.Args)-1]
+	in := bufio.NewScanner(os.Stdin)
+	for n := 1; in.Scan(); n++ {
+		var req struct {
+			Texts     []string `json:"texts"`
+			InputType string   `json:"input_type"`
+		}
+		_ = json.Unmarshal(in.Bytes(), &req)
+		switch {
+		case mode == "error":
+			fmt.Println(`{"error":"model not loaded"}`)
+			continue
+		case mode == "slow":
+			time.Sleep(time.Minute)
+		case mode == "crash" || (mode == "crash-after-1" && n == 2):
+			fmt.Fprintln(os.Stderr, "out of memory")
+			os.Exit(3)
+		case mode == "junk-once":
+			marker := os.Getenv("ECHOVAULT_EMBED_MARKER")
+			if _, err := os.Stat(marker); err != nil {
+				_ = os.WriteFile(marker, nil, 0o600)
+				fmt.Println("loading model...")
+			}
+		case mode == "short":
+			req.Texts = req.Texts[1:]
+		}
+		query := float32(0)
+		if req.InputType == "query" {
+			query = 1
+		}
+		vecs := make([][]float32, len(req.Texts))
+		for i, text := range req.Texts {
+			vecs[i] = []float32{float32(len(text)), float32(os.Getpid()), query}
+		}
+		out, _ := json.Marshal(map[string]any{"vectors": vecs})
+		fmt.Println(string(out))
+	}
+	os.Exit(0)
+}
+
+// helperCommand returns an Exec running TestHelperProcess in mode.
+func helperCommand(c *qt.C, mode string) *embeddings.Exec {
+	c.Setenv("ECHOVAULT_EMBED_HELPER", "1")
+	e := embeddings.NewExec([]string{os.Args[0], "-test.run=^TestHelperProcess$", "--", mode})
+	c.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+func TestExec_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("the process stays up across requests", func(c *qt.C) {
+		e := helperCommand(c, "ok")
+		docs, err := e.EmbedBatch(context.Background(), []string{"a", "bb"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(docs, qt.HasLen, 2)
+		c.Assert(docs[0][0], qt.Equals, float32(1))
+		c.Assert(docs[1][0], qt.Equals, float32(2))
+		c.Assert(docs[0][2], qt.Equals, float32(0))
+
+		query, err := e.EmbedQuery(context.Background(), "ccc")
+		c.Assert(err, qt.IsNil)
+		c.Assert(query[0], qt.Equals, float32(3))
+		c.Assert(query[1], qt.Equals, docs[0][1])
+		c.Assert(query[2], qt.Equals, float32(1))
+	})
+
+	c.Run("a process that crashed is restarted", func(c *qt.C) {
+		e := helperCommand(c, "crash-after-1")
+		first, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		second, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(second[1], qt.Not(qt.Equals), first[1])
+	})
+
+	c.Run("a process that printed a junk line is restarted", func(c *qt.C) {
+		c.Setenv("ECHOVAULT_EMBED_MARKER", filepath.Join(c.TempDir(), "started"))
+		e := helperCommand(c, "junk-once")
+		_, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: decode output: .*")
+		got, err := e.Embed(context.Background(), "bbb")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got[0], qt.Equals, float32(3))
+	})
+
+	c.Run("a request without an answer times out", func(c *qt.C) {
+		e := helperCommand(c, "slow")
+		e.Timeout = 100 * time.Millisecond
+		_, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: no answer within 100ms")
+		_, err = e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: no answer within 100ms")
+	})
+}
+
+func TestExec_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("errors reported by the command are returned", func(c *qt.C) {
+		e := helperCommand(c, "error")
+		_, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: model not loaded")
+	})
+
+	c.Run("an answer with too few vectors is rejected", func(c *qt.C) {
+		e := helperCommand(c, "short")
+		_, err := e.EmbedBatch(context.Background(), []string{"a", "bb"})
+		c.Assert(err, qt.ErrorMatches, "exec embed: expected 2 vectors, got 1")
+	})
+
+	c.Run("a command that exits reports its stderr", func(c *qt.C) {
+		e := helperCommand(c, "crash")
+		_, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: command exited: exit status 3: out of memory")
+	})
+
+	c.Run("a command that cannot start", func(c *qt.C) {
+		e := embeddings.NewExec([]string{"/nonexistent/embedder"})
+		_, err := e.Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, "exec embed: .*no such file or directory")
+	})
+}
@@ -0,0 +1,441 @@
+package embeddings
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Local embeds texts offline from a static table of token vectors: each
+// text is split into tokens and their vectors are averaged. The table is
+// either a GloVe or word2vec text file, or a model2vec model directory.
+type Local struct {
+	Path string
+
+	dim     int
+	vectors []float32      // row-major, dim components per token
+	vocab   map[string]int // token → row
+	// wordPiece is the tokenizer of a model2vec model; nil for word tables.
+	wordPiece *wordPiece
+}
+
+// NewLocal loads the embedding table at path. A directory is read as a
+// model2vec model (model.safetensors and tokenizer.json); a file is read as
+// "token v1 v2 ..." lines, with an optional word2vec "count dim" header.
+func NewLocal(path string) (*Local, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("local embed: %w", err)
+	}
+	l := &Local{Path: path}
+	if info.IsDir() {
+		err = l.loadModel2Vec(path)
+	} else {
+		err = l.loadWordVectors(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("local embed: %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Embed embeds a single text string.
+func (l *Local) Embed(_ context.Context, text string) ([]float32, error) {
+	return l.embed(text), nil
+}
+
+// EmbedBatch embeds multiple texts.
+func (l *Local) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = l.embed(text)
+	}
+	return out, nil
+}
+
+// EmbedQuery embeds a search query like a document. A query without known
+// tokens is an error, so that search falls back to keywords instead of
+// ranking by a zero vector.
+func (l *Local) EmbedQuery(_ context.Context, text string) ([]float32, error) {
+	vec := l.embed(text)
+	for _, v := range vec {
+		if v != 0 {
+			return vec, nil
+		}
+	}
+	return nil, fmt.Errorf("local embed: no known tokens in query %q", text)
+}
+
+// embed returns the unit-length mean of the vectors of the known tokens of
+// text, or a zero vector when it has none.
+func (l *Local) embed(text string) []float32 {
+	var tokens []string
+	if l.wordPiece != nil {
+		tokens = l.wordPiece.tokenize(text)
+	} else {
+		tokens = strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+	}
+	sum := make([]float64, l.dim)
+	n := 0
+	for _, tok := range tokens {
+		row, ok := l.vocab[tok]
+		if !ok {
+			continue
+		}
+		for i, v := range l.vectors[row*l.dim : (row+1)*l.dim] {
+			sum[i] += float64(v)
+		}
+		n++
+	}
+	out := make([]float32, l.dim)
+	if n == 0 {
+		return out
+	}
+	var norm float64
+	for _, v := range sum {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return out
+	}
+	for i, v := range sum {
+		out[i] = float32(v / norm)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Word vector files
+// ---------------------------------------------------------------------------
+
+// loadWordVectors reads a GloVe or word2vec text file.
+func (l *Local) loadWordVectors(path string) error {
+	f, err := os.Open(path) // #nosec G304 -- path is the configured embedding table
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	l.vocab = make(map[string]int)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if line == 1 && len(fields) == 2 {
+			if _, err := strconv.Atoi(fields[0]); err == nil {
+				continue // word2vec "count dim" header
+			}
+		}
+		if l.dim == 0 {
+			l.dim = len(fields) - 1
+		}
+		if len(fields)-1 != l.dim || l.dim == 0 {
+			return fmt.Errorf("line %d: expected a token and %d components, got %d fields", line, l.dim, len(fields))
+		}
+		if _, ok := l.vocab[fields[0]]; ok {
+			continue
+		}
+		for _, s := range fields[1:] {
+			v, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			l.vectors = append(l.vectors, float32(v))
+		}
+		l.vocab[fields[0]] = len(l.vocab)
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if len(l.vocab) == 0 {
+		return fmt.Errorf("no token vectors found")
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// model2vec models
+// ---------------------------------------------------------------------------
+
+// loadModel2Vec reads a model2vec model directory: the token vectors in
+// model.safetensors and the WordPiece vocabulary in tokenizer.json.
+func (l *Local) loadModel2Vec(dir string) error {
+	wp, vocab, err := loadWordPiece(filepath.Join(dir, "tokenizer.json"))
+	if err != nil {
+		return err
+	}
+	tensors, err := readSafetensors(filepath.Join(dir, "model.safetensors"))
+	if err != nil {
+		return err
+	}
+	if _, ok := tensors["mapping"]; ok {
+		return fmt.Errorf("model.safetensors: vocabulary-quantized models are not supported")
+	}
+	emb, ok := tensors["embeddings"]
+	if !ok || len(emb.shape) != 2 {
+		return fmt.Errorf("model.safetensors: no 2-dimensional embeddings tensor")
+	}
+	rows, dim := emb.shape[0], emb.shape[1]
+	if len(emb.data) < rows*dim {
+		return fmt.Errorf("model.safetensors: embeddings tensor is truncated")
+	}
+	for tok, id := range vocab {
+		if id < 0 || id >= rows {
+			return fmt.Errorf("tokenizer.json: token %q has id %d, but there are %d token vectors", tok, id, rows)
+		}
+	}
+	if w, ok := tensors["weights"]; ok {
+		if len(w.data) < rows {
+			return fmt.Errorf("model.safetensors: %d token weights for %d token vectors", len(w.data), rows)
+		}
+		for r := range rows {
+			for i := range dim {
+				emb.data[r*dim+i] *= w.data[r]
+			}
+		}
+	}
+	// model2vec leaves the unknown token out of the mean.
+	delete(vocab, wp.unk)
+	l.dim, l.vectors, l.vocab, l.wordPiece = dim, emb.data, vocab, wp
+	return nil
+}
+
+// wordPiece is a BERT-style tokenizer: text is lowercased when the model
+// asks for it, split at whitespace and punctuation, and each word is split
+// into the longest vocabulary pieces, continuations marked by prefix.
+type wordPiece struct {
+	vocab     map[string]int
+	unk       string
+	prefix    string
+	maxChars  int
+	lowercase bool
+}
+
+// loadWordPiece reads a Hugging Face tokenizer.json with a WordPiece model.
+func loadWordPiece(path string) (*wordPiece, map[string]int, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is inside the configured model directory
+	if err != nil {
+		return nil, nil, err
+	}
+	var tj struct {
+		Normalizer *struct {
+			Lowercase *bool `json:"lowercase"`
+		} `json:"normalizer"`
+		Model struct {
+			Type     string         `json:"type"`
+			Vocab    map[string]int `json:"vocab"`
+			Unk      string         `json:"unk_token"`
+			Prefix   *string        `json:"continuing_subword_prefix"`
+			MaxChars int            `json:"max_input_chars_per_word"`
+		} `json:"model"`
+	}
+	if err := json.Unmarshal(data, &tj); err != nil {
+		return nil, nil, fmt.Errorf("tokenizer.json: %w", err)
+	}
+	if tj.Model.Type != "WordPiece" {
+		return nil, nil, fmt.Errorf("tokenizer.json: %q tokenizers are not supported, only WordPiece", tj.Model.Type)
+	}
+	wp := &wordPiece{
+		vocab:     tj.Model.Vocab,
+		unk:       tj.Model.Unk,
+		prefix:    "##",
+		maxChars:  tj.Model.MaxChars,
+		lowercase: true,
+	}
+	if tj.Model.Prefix != nil {
+		wp.prefix = *tj.Model.Prefix
+	}
+	if wp.maxChars <= 0 {
+		wp.maxChars = 100
+	}
+	if tj.Normalizer != nil && tj.Normalizer.Lowercase != nil {
+		wp.lowercase = *tj.Normalizer.Lowercase
+	}
+	vocab := make(map[string]int, len(wp.vocab))
+	for tok, id := range wp.vocab {
+		vocab[tok] = id
+	}
+	return wp, vocab, nil
+}
+
+// tokenize returns the vocabulary pieces of text.
+func (wp *wordPiece) tokenize(text string) []string {
+	if wp.lowercase {
+		text = strings.ToLower(text)
+	}
+	var pieces []string
+	for _, word := range splitWords(text) {
+		pieces = append(pieces, wp.split(word)...)
+	}
+	return pieces
+}
+
+// split splits word greedily into the longest vocabulary pieces; a word
+// that cannot be split is the unknown token.
+func (wp *wordPiece) split(word string) []string {
+	runes := []rune(word)
+	if len(runes) > wp.maxChars {
+		return []string{wp.unk}
+	}
+	var pieces []string
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		var piece string
+		for ; end > start; end-- {
+			piece = string(runes[start:end])
+			if start > 0 {
+				piece = wp.prefix + piece
+			}
+			if _, ok := wp.vocab[piece]; ok {
+				break
+			}
+		}
+		if end == start {
+			return []string{wp.unk}
+		}
+		pieces = append(pieces, piece)
+		start = end
+	}
+	return pieces
+}
+
+// splitWords splits text at whitespace, making each punctuation character a
+// word of its own, as BERT's pre-tokenizer does.
+func splitWords(text string) []string {
+	var words []string
+	var cur []rune
+	flush := func() {
+		if len(cur) > 0 {
+			words = append(words, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r) || unicode.IsControl(r):
+			flush()
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			flush()
+			words = append(words, string(r))
+		default:
+			cur = append(cur, r)
+		}
+	}
+	flush()
+	return words
+}
+
+// tensor is a safetensors tensor converted to float32.
+type tensor struct {
+	shape []int
+	data  []float32
+}
+
+// readSafetensors reads the float tensors of a safetensors file: an 8-byte
+// little-endian header length, a JSON header locating each tensor, then the
+// tensor data.
+func readSafetensors(path string) (map[string]tensor, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- path is inside the configured model directory
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("model.safetensors: truncated header")
+	}
+	n := binary.LittleEndian.Uint64(data)
+	if n > uint64(len(data)-8) {
+		return nil, fmt.Errorf("model.safetensors: truncated header")
+	}
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(data[8:8+n], &header); err != nil {
+		return nil, fmt.Errorf("model.safetensors: %w", err)
+	}
+	body := data[8+n:]
+	out := make(map[string]tensor)
+	for name, raw := range header {
+		if name == "__metadata__" {
+			continue
+		}
+		var h struct {
+			Dtype   string `json:"dtype"`
+			Shape   []int  `json:"shape"`
+			Offsets [2]int `json:"data_offsets"`
+		}
+		if err := json.Unmarshal(raw, &h); err != nil {
+			return nil, fmt.Errorf("model.safetensors: %s: %w", name, err)
+		}
+		if h.Offsets[0] < 0 || h.Offsets[0] > h.Offsets[1] || h.Offsets[1] > len(body) {
+			return nil, fmt.Errorf("model.safetensors: %s: data out of range", name)
+		}
+		t := tensor{shape: h.Shape}
+		if t.data, err = decodeFloats(h.Dtype, body[h.Offsets[0]:h.Offsets[1]]); err != nil {
+			if name == "embeddings" || name == "weights" {
+				return nil, fmt.Errorf("model.safetensors: %s: %w", name, err)
+			}
+			t.data = nil
+		}
+		out[name] = t
+	}
+	return out, nil
+}
+
+// decodeFloats converts little-endian F32, F16 or BF16 values to float32.
+func decodeFloats(dtype string, b []byte) ([]float32, error) {
+	switch dtype {
+	case "F32":
+		out := make([]float32, len(b)/4)
+		for i := range out {
+			out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+		}
+		return out, nil
+	case "F16":
+		out := make([]float32, len(b)/2)
+		for i := range out {
+			out[i] = halfToFloat(binary.LittleEndian.Uint16(b[i*2:]))
+		}
+		return out, nil
+	case "BF16":
+		out := make([]float32, len(b)/2)
+		for i := range out {
+			out[i] = math.Float32frombits(uint32(binary.LittleEndian.Uint16(b[i*2:])) << 16)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported dtype %s", dtype)
+	}
+}
+
+// halfToFloat converts an IEEE 754 half-precision value to float32.
+func halfToFloat(h uint16) float32 {
+	sign := uint32(h>>15) << 31
+	exp := uint32(h>>10) & 0x1f
+	frac := uint32(h) & 0x3ff
+	switch {
+	case exp == 0 && frac == 0:
+		return math.Float32frombits(sign)
+	case exp == 0: // subnormal
+		v := float32(frac) / (1 << 24)
+		if sign != 0 {
+			v = -v
+		}
+		return v
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0xff<<23 | frac<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | frac<<13)
+	}
+}
@@ -0,0 +1,135 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// Local
+// ---------------------------------------------------------------------------
+
+// writeModel2Vec writes a model2vec model directory with a WordPiece
+// tokenizer over vocab and one F32 embedding row per token.
+func writeModel2Vec(c *qt.C, vocab []string, rows [][]float32) string {
+	dir := c.TempDir()
+	ids := make(map[string]int, len(vocab))
+	for i, tok := range vocab {
+		ids[tok] = i
+	}
+	tokenizer, err := json.Marshal(map[string]any{
+		"normalizer": map[string]any{"type": "BertNormalizer", "lowercase": true},
+		"model":      map[string]any{"type": "WordPiece", "unk_token": "[UNK]", "continuing_subword_prefix": "##", "vocab": ids},
+	})
+	c.Assert(err, qt.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dir, "tokenizer.json"), tokenizer, 0o600), qt.IsNil)
+
+	var data []byte
+	for _, row := range rows {
+		for _, v := range row {
+			data = binary.LittleEndian.AppendUint32(data, math.Float32bits(v))
+		}
+	}
+	header, err := json.Marshal(map[string]any{
+		"__metadata__": map[string]string{"format": "pt"},
+		"embeddings":   map[string]any{"dtype": "F32", "shape": []int{len(rows), len(rows[0])}, "data_offsets": []int{0, len(data)}},
+	})
+	c.Assert(err, qt.IsNil)
+	file := binary.LittleEndian.AppendUint64(nil, uint64(len(header)))
+	file = append(append(file, header...), data...)
+	c.Assert(os.WriteFile(filepath.Join(dir, "model.safetensors"), file, 0o600), qt.IsNil)
+	return dir
+}
+
+func TestLocal_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("GloVe vectors are mean pooled and normalized", func(c *qt.C) {
+		path := filepath.Join(c.TempDir(), "glove.txt")
+		c.Assert(os.WriteFile(path, []byte("database 1 0\npooler 0 1\n"), 0o600), qt.IsNil)
+
+		l, err := embeddings.NewLocal(path)
+		c.Assert(err, qt.IsNil)
+		got, err := l.EmbedBatch(context.Background(), []string{"Database, POOLER!", "database", "nothing known"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got[0], qt.DeepEquals, []float32{float32(1 / math.Sqrt2), float32(1 / math.Sqrt2)})
+		c.Assert(got[1], qt.DeepEquals, []float32{1, 0})
+		c.Assert(got[2], qt.DeepEquals, []float32{0, 0})
+	})
+
+	c.Run("word2vec header line is skipped", func(c *qt.C) {
+		path := filepath.Join(c.TempDir(), "w2v.txt")
+		c.Assert(os.WriteFile(path, []byte("2 3\nred 0 0 2\nblue 0 3 0\n"), 0o600), qt.IsNil)
+
+		l, err := embeddings.NewLocal(path)
+		c.Assert(err, qt.IsNil)
+		got, err := l.EmbedQuery(context.Background(), "red")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{0, 0, 1})
+	})
+
+	c.Run("model2vec models split words into WordPiece tokens", func(c *qt.C) {
+		dir := writeModel2Vec(c,
+			[]string{"[UNK]", "pool", "##er", "db", "."},
+			[][]float32{{5, 5}, {1, 0}, {0, 1}, {0, 0}, {0, 0}},
+		)
+		l, err := embeddings.NewLocal(dir)
+		c.Assert(err, qt.IsNil)
+
+		got, err := l.Embed(context.Background(), "Pooler.")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{float32(1 / math.Sqrt2), float32(1 / math.Sqrt2)})
+
+		// Unknown words map to [UNK], which is left out of the mean.
+		got, err = l.Embed(context.Background(), "pool xyz")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{1, 0})
+	})
+}
+
+func TestLocal_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("missing table", func(c *qt.C) {
+		_, err := embeddings.NewLocal(filepath.Join(c.TempDir(), "missing.txt"))
+		c.Assert(err, qt.ErrorMatches, "local embed: .*no such file or directory")
+	})
+
+	c.Run("rows of different lengths", func(c *qt.C) {
+		path := filepath.Join(c.TempDir(), "glove.txt")
+		c.Assert(os.WriteFile(path, []byte("a 1 0\nb 1\n"), 0o600), qt.IsNil)
+		_, err := embeddings.NewLocal(path)
+		c.Assert(err, qt.ErrorMatches, "local embed: .*: line 2: expected a token and 2 components, got 2 fields")
+	})
+
+	c.Run("non-WordPiece tokenizer", func(c *qt.C) {
+		dir := c.TempDir()
+		c.Assert(os.WriteFile(filepath.Join(dir, "tokenizer.json"), []byte(`{"model":{"type":"Unigram"}}`), 0o600), qt.IsNil)
+		_, err := embeddings.NewLocal(dir)
+		c.Assert(err, qt.ErrorMatches, `local embed: .*: tokenizer.json: "Unigram" tokenizers are not supported, only WordPiece`)
+	})
+
+	c.Run("query without known tokens", func(c *qt.C) {
+		path := filepath.Join(c.TempDir(), "glove.txt")
+		c.Assert(os.WriteFile(path, []byte("a 1 0\n"), 0o600), qt.IsNil)
+		l, err := embeddings.NewLocal(path)
+		c.Assert(err, qt.IsNil)
+		_, err = l.EmbedQuery(context.Background(), "zzz")
+		c.Assert(err, qt.ErrorMatches, `local embed: no known tokens in query "zzz"`)
+	})
+
+	c.Run("token ids beyond the embeddings", func(c *qt.C) {
+		dir := writeModel2Vec(c, []string{"[UNK]", "a", "b"}, [][]float32{{1}, {2}})
+		_, err := embeddings.NewLocal(dir)
+		c.Assert(err, qt.ErrorMatches, `local embed: .*: tokenizer.json: token "b" has id 2, but there are 2 token vectors`)
+	})
+}
@@ -2,17 +2,20 @@ package embeddings
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/go-ports/echovault/internal/httpjson"
 )
 
 // Ollama calls a local Ollama server for embeddings.
 type Ollama struct {
 	Model   string
 	BaseURL string
-	client  *http.Client
+	client  *httpjson.Client
 }
 
 // NewOllama returns an Ollama provider with a 30s timeout.
@@ -20,37 +23,72 @@ func NewOllama(model, baseURL string) *Ollama {
 	return &Ollama{
 		Model:   model,
 		BaseURL: strings.TrimRight(baseURL, "/"),
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  httpjson.Default(),
 	}
 }
 
-// Embed calls POST /api/embeddings and returns the embedding vector.
+// Embed embeds a single text string.
 func (o *Ollama) Embed(ctx context.Context, text string) ([]float32, error) {
+	results, err := o.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// EmbedQuery embeds a search query; the model embeds queries like
+// documents.
+func (o *Ollama) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return o.Embed(ctx, text)
+}
+
+// EmbedBatch embeds multiple texts in a single POST /api/embed call. Servers
+// too old to have that endpoint get one POST /api/embeddings call per text.
+func (o *Ollama) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	reqBody := map[string]any{
-		"model":  o.Model,
-		"prompt": text,
+		"model": o.Model,
+		"input": texts,
 	}
 	var resp struct {
-		Embedding []float32 `json:"embedding"`
+		Embeddings [][]float32 `json:"embeddings"`
 	}
-	if err := doJSON(ctx, o.client, http.MethodPost, o.BaseURL+"/api/embeddings", nil, reqBody, &resp); err != nil {
+	err := o.client.Do(ctx, http.MethodPost, o.BaseURL+"/api/embed", nil, reqBody, &resp)
+	var se *httpjson.StatusError
+	if errors.As(err, &se) && se.Code == http.StatusNotFound {
+		return o.embedLegacy(ctx, texts)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("ollama embed: %w", err)
 	}
-	if len(resp.Embedding) == 0 {
-		return nil, fmt.Errorf("ollama embed: empty embedding returned")
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama embed: expected %d results, got %d", len(texts), len(resp.Embeddings))
+	}
+	for _, e := range resp.Embeddings {
+		if len(e) == 0 {
+			return nil, fmt.Errorf("ollama embed: empty embedding returned")
+		}
 	}
-	return resp.Embedding, nil
+	return resp.Embeddings, nil
 }
 
-// EmbedBatch embeds each text sequentially.
-func (o *Ollama) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+// embedLegacy embeds each text with the pre-0.3 POST /api/embeddings.
+func (o *Ollama) embedLegacy(ctx context.Context, texts []string) ([][]float32, error) {
 	results := make([][]float32, len(texts))
-	for i, t := range texts {
-		v, err := o.Embed(ctx, t)
-		if err != nil {
-			return nil, err
+	for i, text := range texts {
+		reqBody := map[string]any{
+			"model":  o.Model,
+			"prompt": text,
+		}
+		var resp struct {
+			Embedding []float32 `json:"embedding"`
+		}
+		if err := o.client.Do(ctx, http.MethodPost, o.BaseURL+"/api/embeddings", nil, reqBody, &resp); err != nil {
+			return nil, fmt.Errorf("ollama embed: %w", err)
+		}
+		if len(resp.Embedding) == 0 {
+			return nil, fmt.Errorf("ollama embed: empty embedding returned")
 		}
-		results[i] = v
+		results[i] = resp.Embedding
 	}
 	return results, nil
 }
@@ -68,7 +106,7 @@ func IsOllamaModelLoaded(model, baseURL string) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
-	if err := doJSON(ctx, client, http.MethodGet,
+	if err := httpjson.Do(ctx, client, http.MethodGet,
 		strings.TrimRight(baseURL, "/")+"/api/ps",
 		nil, nil, &resp,
 	); err != nil {
@@ -12,14 +12,39 @@ import (
 	"github.com/go-ports/echovault/internal/embeddings"
 )
 
-// newOllamaEmbedServer starts a test HTTP server that responds to every request
-// with an Ollama-style embedding response containing vec.
-func newOllamaEmbedServer(t *testing.T, vec []float32) *httptest.Server {
+// newOllamaEmbedServer starts a test HTTP server that answers POST /api/embed
+// with vec for every input text, counting the requests in *calls.
+func newOllamaEmbedServer(t *testing.T, vec []float32, calls *int) *httptest.Server {
 	t.Helper()
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/embed", func(w http.ResponseWriter, r *http.Request) {
+		if calls != nil {
+			*calls++
+		}
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := make([][]float32, len(req.Input))
+		for i := range out {
+			out[i] = vec
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": out})
+	})
+	return httptest.NewServer(mux)
+}
+
+// newOllamaLegacyServer starts a test HTTP server that only has the pre-0.3
+// POST /api/embeddings endpoint, answering with vec.
+func newOllamaLegacyServer(t *testing.T, vec []float32) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/embeddings", func(w http.ResponseWriter, _ *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		_ = json.NewEncoder(w).Encode(map[string]any{"embedding": vec})
-	}))
+	})
+	return httptest.NewServer(mux)
 }
 
 // newOllamaErrorServer starts a test HTTP server that always returns 500.
@@ -47,7 +72,7 @@ func TestOllamaEmbed_HappyPath(t *testing.T) {
 
 	for _, tc := range cases {
 		c.Run(tc.name, func(c *qt.C) {
-			srv := newOllamaEmbedServer(t, tc.vec)
+			srv := newOllamaEmbedServer(t, tc.vec, nil)
 			defer srv.Close()
 
 			o := embeddings.NewOllama("test-model", srv.URL)
@@ -74,7 +99,7 @@ func TestOllamaEmbed_FailurePath(t *testing.T) {
 	c.Run("empty embedding in response returns error", func(c *qt.C) {
 		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 			w.Header().Set("Content-Type", "application/json")
-			_ = json.NewEncoder(w).Encode(map[string]any{"embedding": make([]float32, 0)})
+			_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": [][]float32{{}}})
 		}))
 		defer srv.Close()
 
@@ -93,15 +118,28 @@ func TestOllamaEmbed_FailurePath(t *testing.T) {
 func TestOllamaEmbedBatch_HappyPath(t *testing.T) {
 	c := qt.New(t)
 
-	c.Run("each text receives the same fixed vector", func(c *qt.C) {
+	c.Run("all texts are embedded in one request", func(c *qt.C) {
 		fixedVec := []float32{1.0, 2.0}
-		srv := newOllamaEmbedServer(t, fixedVec)
+		calls := 0
+		srv := newOllamaEmbedServer(t, fixedVec, &calls)
 		defer srv.Close()
 
 		o := embeddings.NewOllama("test-model", srv.URL)
 		got, err := o.EmbedBatch(context.Background(), []string{"alpha", "beta", "gamma"})
 		c.Assert(err, qt.IsNil)
 		c.Assert(got, qt.DeepEquals, [][]float32{fixedVec, fixedVec, fixedVec})
+		c.Assert(calls, qt.Equals, 1)
+	})
+
+	c.Run("servers without /api/embed fall back to /api/embeddings", func(c *qt.C) {
+		fixedVec := []float32{0.5, 0.5}
+		srv := newOllamaLegacyServer(t, fixedVec)
+		defer srv.Close()
+
+		o := embeddings.NewOllama("test-model", srv.URL)
+		got, err := o.EmbedBatch(context.Background(), []string{"alpha", "beta"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{fixedVec, fixedVec})
 	})
 }
 
@@ -5,7 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
+
+	"github.com/go-ports/echovault/internal/httpjson"
 )
 
 const defaultOpenAIBase = "https://api.openai.com/v1"
@@ -15,7 +16,10 @@ type OpenAI struct {
 	Model   string
 	APIKey  string // #nosec G117 -- APIKey is an intentional field name for the OpenAI authentication token
 	BaseURL string
-	client  *http.Client
+	// Dimensions, when positive, asks models that support it (the
+	// text-embedding-3 family) for vectors shortened to this length.
+	Dimensions int
+	client     *httpjson.Client
 }
 
 // NewOpenAI returns an OpenAI provider. baseURL defaults to the OpenAI endpoint.
@@ -27,7 +31,7 @@ func NewOpenAI(model, apiKey, baseURL string) *OpenAI {
 		Model:   model,
 		APIKey:  apiKey,
 		BaseURL: strings.TrimRight(baseURL, "/"),
-		client:  &http.Client{Timeout: 30 * time.Second},
+		client:  httpjson.Default(),
 	}
 }
 
@@ -43,37 +47,59 @@ func (o *OpenAI) Embed(ctx context.Context, text string) ([]float32, error) {
 	return results[0], nil
 }
 
+// EmbedQuery embeds a search query; the model embeds queries like
+// documents.
+func (o *OpenAI) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return o.Embed(ctx, text)
+}
+
 // EmbedBatch embeds multiple texts in a single API call.
 func (o *OpenAI) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
 	reqBody := map[string]any{
 		"model": o.Model,
 		"input": texts,
 	}
+	if o.Dimensions > 0 {
+		reqBody["dimensions"] = o.Dimensions
+	}
 	headers := map[string]string{
 		"Authorization": "Bearer " + o.APIKey,
 	}
 
-	var resp struct {
-		Data []struct {
-			Index     int       `json:"index"`
-			Embedding []float32 `json:"embedding"`
-		} `json:"data"`
+	var resp openAIResponse
+	if err := o.client.Do(ctx, http.MethodPost, o.BaseURL+"/embeddings", headers, reqBody, &resp); err != nil {
+		return nil, fmt.Errorf("openai embed: %w", err)
 	}
-	if err := doJSON(ctx, o.client, http.MethodPost, o.BaseURL+"/embeddings", headers, reqBody, &resp); err != nil {
+	results, err := resp.vectors(len(texts))
+	if err != nil {
 		return nil, fmt.Errorf("openai embed: %w", err)
 	}
-	if len(resp.Data) == 0 {
-		return nil, fmt.Errorf("openai embed: empty data in response")
+	return results, nil
+}
+
+// openAIResponse is the body of an OpenAI embeddings response, which Azure
+// OpenAI shares.
+type openAIResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// vectors returns the embeddings of the n texts requested in input order.
+func (r *openAIResponse) vectors(n int) ([][]float32, error) {
+	if len(r.Data) == 0 {
+		return nil, fmt.Errorf("empty data in response")
 	}
-	if len(resp.Data) != len(texts) {
-		return nil, fmt.Errorf("openai embed: expected %d results, got %d", len(texts), len(resp.Data))
+	if len(r.Data) != n {
+		return nil, fmt.Errorf("expected %d results, got %d", n, len(r.Data))
 	}
 
 	// Fill results by index to handle out-of-order responses and detect gaps.
-	results := make([][]float32, len(texts))
-	for _, d := range resp.Data {
-		if d.Index < 0 || d.Index >= len(texts) {
-			return nil, fmt.Errorf("openai embed: result index %d out of range [0, %d)", d.Index, len(texts))
+	results := make([][]float32, n)
+	for _, d := range r.Data {
+		if d.Index < 0 || d.Index >= n {
+			return nil, fmt.Errorf("result index %d out of range [0, %d)", d.Index, n)
 		}
 		results[d.Index] = d.Embedding
 	}
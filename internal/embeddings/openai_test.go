@@ -2,6 +2,7 @@ package embeddings_test
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -47,6 +48,34 @@ func TestOpenAIEmbed_HappyPath(t *testing.T) {
 	})
 }
 
+func TestOpenAIDimensions_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("dimensions is sent only when set", func(c *qt.C) {
+		var bodies []map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			bodies = append(bodies, body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[1.0]}]}`))
+		}))
+		defer srv.Close()
+
+		o := embeddings.NewOpenAI("text-embedding-3-large", "sk-test", srv.URL)
+		_, err := o.Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		o.Dimensions = 256
+		_, err = o.Embed(context.Background(), "b")
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(bodies, qt.HasLen, 2)
+		_, sent := bodies[0]["dimensions"]
+		c.Assert(sent, qt.IsFalse)
+		c.Assert(bodies[1]["dimensions"], qt.Equals, float64(256))
+	})
+}
+
 func TestOpenAIEmbed_FailurePath(t *testing.T) {
 	c := qt.New(t)
 
@@ -0,0 +1,99 @@
+package embeddings
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-ports/echovault/internal/config"
+)
+
+// bgeQueryInstruction is the query instruction of BGE-style English models.
+const bgeQueryInstruction = "Represent this sentence for searching relevant passages: {text}"
+
+// knownPrefixes are the templates of asymmetric models whose model cards ask
+// for them, matched by the start of the model name.
+var knownPrefixes = []struct {
+	model     string
+	templates config.PrefixTemplates
+}{
+	{"nomic-embed-text", config.PrefixTemplates{Query: "search_query: {text}", Document: "search_document: {text}"}},
+	{"multilingual-e5-", config.PrefixTemplates{Query: "query: {text}", Document: "passage: {text}"}},
+	{"e5-", config.PrefixTemplates{Query: "query: {text}", Document: "passage: {text}"}},
+	{"bge-small-en", config.PrefixTemplates{Query: bgeQueryInstruction}},
+	{"bge-base-en", config.PrefixTemplates{Query: bgeQueryInstruction}},
+	{"bge-large-en", config.PrefixTemplates{Query: bgeQueryInstruction}},
+	{"mxbai-embed-large", config.PrefixTemplates{Query: bgeQueryInstruction}},
+	{"snowflake-arctic-embed", config.PrefixTemplates{Query: bgeQueryInstruction}},
+}
+
+// PrefixesFor returns the templates texts for the configured model are
+// embedded with: embedding.prefixes when it lists the model, else the
+// built-in ones for known asymmetric models, else none. Model names are
+// compared without an Ollama ":tag" or a Hugging Face "org/" part.
+func PrefixesFor(cfg *config.MemoryConfig) config.PrefixTemplates {
+	model := cfg.Embedding.Model
+	if t, ok := cfg.Embedding.Prefixes[model]; ok {
+		return t
+	}
+	name := strings.ToLower(normalizeModelName(model))
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if t, ok := cfg.Embedding.Prefixes[name]; ok {
+		return t
+	}
+	for _, k := range knownPrefixes {
+		if strings.HasPrefix(name, k.model) {
+			return k.templates
+		}
+	}
+	return config.PrefixTemplates{}
+}
+
+// ApplyTemplate presents text as tmpl asks: "{text}" in tmpl is replaced by
+// text, and a template without it is a prefix.
+func ApplyTemplate(tmpl, text string) string {
+	if tmpl == "" {
+		return text
+	}
+	if strings.Contains(tmpl, "{text}") {
+		return strings.Replace(tmpl, "{text}", text, 1)
+	}
+	return tmpl + text
+}
+
+// prefixed applies prefix templates to the texts of an asymmetric model.
+type prefixed struct {
+	p Provider
+	t config.PrefixTemplates
+}
+
+// WithPrefixes returns a Provider embedding documents and queries with p
+// after presenting them as t asks.
+func WithPrefixes(p Provider, t config.PrefixTemplates) Provider {
+	return &prefixed{p: p, t: t}
+}
+
+// Embed embeds a single document.
+func (x *prefixed) Embed(ctx context.Context, text string) ([]float32, error) {
+	return x.p.Embed(ctx, ApplyTemplate(x.t.Document, text))
+}
+
+// EmbedBatch embeds multiple documents.
+func (x *prefixed) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	docs := make([]string, len(texts))
+	for i, text := range texts {
+		docs[i] = ApplyTemplate(x.t.Document, text)
+	}
+	return x.p.EmbedBatch(ctx, docs)
+}
+
+// EmbedQuery embeds a search query.
+func (x *prefixed) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return x.p.EmbedQuery(ctx, ApplyTemplate(x.t.Query, text))
+}
+
+// Close closes the wrapped provider.
+func (x *prefixed) Close() error {
+	return Close(x.p)
+}
@@ -0,0 +1,95 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// PrefixesFor / ApplyTemplate
+// ---------------------------------------------------------------------------
+
+func TestPrefixesFor_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		model string
+		want  config.PrefixTemplates
+	}{
+		{"nomic-embed-text", config.PrefixTemplates{Query: "search_query: {text}", Document: "search_document: {text}"}},
+		{"nomic-embed-text:v1.5", config.PrefixTemplates{Query: "search_query: {text}", Document: "search_document: {text}"}},
+		{"nomic-ai/nomic-embed-text-v1.5", config.PrefixTemplates{Query: "search_query: {text}", Document: "search_document: {text}"}},
+		{"intfloat/multilingual-e5-large", config.PrefixTemplates{Query: "query: {text}", Document: "passage: {text}"}},
+		{"BAAI/bge-small-en-v1.5", config.PrefixTemplates{Query: "Represent this sentence for searching relevant passages: {text}"}},
+		{"mxbai-embed-large", config.PrefixTemplates{Query: "Represent this sentence for searching relevant passages: {text}"}},
+		{"bge-m3", config.PrefixTemplates{}},
+		{"text-embedding-3-small", config.PrefixTemplates{}},
+	}
+	for _, tt := range tests {
+		c.Run(tt.model, func(c *qt.C) {
+			conf := cfg("ollama", tt.model, "", "")
+			c.Assert(embeddings.PrefixesFor(conf), qt.Equals, tt.want)
+		})
+	}
+
+	c.Run("configured templates override the built-in ones", func(c *qt.C) {
+		conf := cfg("ollama", "nomic-embed-text:latest", "", "")
+		conf.Embedding.Prefixes = map[string]config.PrefixTemplates{
+			"nomic-embed-text": {Query: "q: "},
+		}
+		c.Assert(embeddings.PrefixesFor(conf), qt.Equals, config.PrefixTemplates{Query: "q: "})
+	})
+}
+
+func TestApplyTemplate_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(embeddings.ApplyTemplate("", "text"), qt.Equals, "text")
+	c.Assert(embeddings.ApplyTemplate("query: ", "text"), qt.Equals, "query: text")
+	c.Assert(embeddings.ApplyTemplate("<q>{text}</q>", "text"), qt.Equals, "<q>text</q>")
+}
+
+// ---------------------------------------------------------------------------
+// NewProvider prefixes
+// ---------------------------------------------------------------------------
+
+func TestNewProvider_Prefixes_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	var prompts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		prompts = append(prompts, body.Input...)
+		vecs := make([][]float32, len(body.Input))
+		for i := range vecs {
+			vecs[i] = []float32{1}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": vecs})
+	}))
+	defer srv.Close()
+
+	ep, err := embeddings.NewProvider(cfg("ollama", "nomic-embed-text", "", srv.URL))
+	c.Assert(err, qt.IsNil)
+
+	_, err = ep.EmbedBatch(context.Background(), []string{"a", "b"})
+	c.Assert(err, qt.IsNil)
+	_, err = ep.Embed(context.Background(), "c")
+	c.Assert(err, qt.IsNil)
+	_, err = ep.EmbedQuery(context.Background(), "d")
+	c.Assert(err, qt.IsNil)
+	c.Assert(prompts, qt.DeepEquals, []string{
+		"search_document: a", "search_document: b", "search_document: c", "search_query: d",
+	})
+}
@@ -4,31 +4,79 @@ package embeddings
 import (
 	"context"
 	"fmt"
+	"io"
+	"path/filepath"
+	"time"
 
 	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/httpjson"
 )
 
-// Provider is the interface for embedding models.
+// Provider is the interface for embedding models. Asymmetric models embed
+// a search query differently from the documents it should find, so the
+// intent of a text decides the method used.
 type Provider interface {
-	// Embed returns a float32 vector for the given text.
+	// Embed returns a float32 vector for the given document text.
 	Embed(ctx context.Context, text string) ([]float32, error)
-	// EmbedBatch returns vectors for multiple texts.
+	// EmbedBatch returns vectors for multiple document texts.
 	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+	// EmbedQuery returns a float32 vector for a search query.
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
 }
 
 // NewProvider constructs a Provider from the given config.
-// Returns (nil, nil) when the provider is "" or "none".
+// Returns (nil, nil) when the provider is "" or "none". The API key is
+// resolved from api_key_cmd or api_key_env when set. Texts are presented
+// as PrefixesFor the model asks, and with embedding.dimensions set, vectors
+// are truncated to that length.
 func NewProvider(cfg *config.MemoryConfig) (Provider, error) {
+	if ec := cfg.Embedding; ec.Provider != "" && ec.Provider != "none" && (ec.APIKeyEnv != "" || len(ec.APIKeyCmd) > 0) {
+		key, err := ResolveAPIKey(ec)
+		if err != nil {
+			return nil, fmt.Errorf("embedding provider %s: %w", ec.Provider, err)
+		}
+		resolved := *cfg
+		resolved.Embedding.APIKey = key
+		cfg = &resolved
+	}
+	p, err := newProvider(cfg)
+	if p == nil || err != nil {
+		return p, err
+	}
+	if t := PrefixesFor(cfg); t != (config.PrefixTemplates{}) {
+		p = WithPrefixes(p, t)
+	}
+	if cfg.Embedding.Dimensions > 0 {
+		p = Truncate(p, cfg.Embedding.Dimensions)
+	}
+	return p, nil
+}
+
+func newProvider(cfg *config.MemoryConfig) (Provider, error) {
 	switch cfg.Embedding.Provider {
 	case "ollama":
 		baseURL := cfg.Embedding.BaseURL
 		if baseURL == "" {
 			baseURL = "http://localhost:11434"
 		}
-		return NewOllama(cfg.Embedding.Model, baseURL), nil
+		// The first request after Ollama starts waits for the model to load.
+		client, err := httpClient(cfg, 2*time.Minute)
+		if err != nil {
+			return nil, err
+		}
+		o := NewOllama(cfg.Embedding.Model, baseURL)
+		o.client = client
+		return o, nil
 
 	case "openai":
-		return NewOpenAI(cfg.Embedding.Model, cfg.Embedding.APIKey, cfg.Embedding.BaseURL), nil
+		client, err := httpClient(cfg, 0)
+		if err != nil {
+			return nil, err
+		}
+		o := NewOpenAI(cfg.Embedding.Model, cfg.Embedding.APIKey, cfg.Embedding.BaseURL)
+		o.Dimensions = cfg.Embedding.Dimensions
+		o.client = client
+		return o, nil
 
 	case "openrouter":
 		const openRouterBase = "https://openrouter.ai/api/v1"
@@ -36,7 +84,67 @@ func NewProvider(cfg *config.MemoryConfig) (Provider, error) {
 		if baseURL == "" {
 			baseURL = openRouterBase
 		}
-		return NewOpenAI(cfg.Embedding.Model, cfg.Embedding.APIKey, baseURL), nil
+		client, err := httpClient(cfg, 0)
+		if err != nil {
+			return nil, err
+		}
+		o := NewOpenAI(cfg.Embedding.Model, cfg.Embedding.APIKey, baseURL)
+		o.client = client
+		return o, nil
+
+	case "azure-openai":
+		ec := cfg.Embedding
+		baseURL := providerBaseURL(cfg, "")
+		if baseURL == "" {
+			return nil, fmt.Errorf("embedding provider azure-openai: base_url is required")
+		}
+		deployment := ec.Deployment
+		if deployment == "" {
+			deployment = ec.Model
+		}
+		client, err := httpClient(cfg, 0)
+		if err != nil {
+			return nil, err
+		}
+		a := NewAzureOpenAI(baseURL, deployment, ec.APIKey, ec.APIVersion)
+		a.Dimensions = ec.Dimensions
+		a.client = client
+		return a, nil
+
+	case "cohere":
+		client, err := httpClient(cfg, 0)
+		if err != nil {
+			return nil, err
+		}
+		c := NewCohere(cfg.Embedding.Model, cfg.Embedding.APIKey, providerBaseURL(cfg, defaultCohereBase))
+		c.client = client
+		return c, nil
+
+	case "tei":
+		client, err := httpClient(cfg, 0)
+		if err != nil {
+			return nil, err
+		}
+		t := NewTEI(providerBaseURL(cfg, "http://localhost:8080"), cfg.Embedding.APIKey)
+		t.client = client
+		return t, nil
+
+	case "local":
+		path := cfg.Embedding.Model
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.Home, path)
+		}
+		return NewLocal(path)
+
+	case "exec":
+		if len(cfg.Embedding.Command) == 0 {
+			return nil, fmt.Errorf("embedding provider exec: command is required")
+		}
+		e := NewExec(cfg.Embedding.Command)
+		if cfg.Embedding.Timeout > 0 {
+			e.Timeout = cfg.Embedding.Timeout
+		}
+		return e, nil
 
 	case "", "none":
 		return nil, nil
@@ -45,3 +153,47 @@ func NewProvider(cfg *config.MemoryConfig) (Provider, error) {
 		return nil, fmt.Errorf("unknown embedding provider: %s", cfg.Embedding.Provider)
 	}
 }
+
+// Close releases what p holds, such as the process of an exec provider.
+func Close(p Provider) error {
+	if c, ok := p.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// httpClient returns the HTTP client of cfg's provider, with the configured
+// timeout, retries, headers, proxy and CA bundle. def is the timeout when
+// none is configured; 0 leaves the client's default.
+func httpClient(cfg *config.MemoryConfig, def time.Duration) (*httpjson.Client, error) {
+	ec := cfg.Embedding
+	timeout := ec.Timeout
+	if timeout <= 0 {
+		timeout = def
+	}
+	caCert := ec.CACert
+	if caCert != "" && !filepath.IsAbs(caCert) {
+		caCert = filepath.Join(cfg.Home, caCert)
+	}
+	client, err := httpjson.NewClient(httpjson.Options{
+		Timeout: timeout,
+		Retries: ec.Retries,
+		Headers: ec.Headers,
+		Proxy:   ec.Proxy,
+		CACert:  caCert,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding provider %s: %w", ec.Provider, err)
+	}
+	return client, nil
+}
+
+// providerBaseURL returns the configured base URL, or def when none is set.
+// The default config carries Ollama's base URL, which does not count as set
+// for the providers that do not share Ollama's API.
+func providerBaseURL(cfg *config.MemoryConfig, def string) string {
+	if u := cfg.Embedding.BaseURL; u != "" && u != config.Default().Embedding.BaseURL {
+		return u
+	}
+	return def
+}
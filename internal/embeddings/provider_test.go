@@ -1,6 +1,10 @@
 package embeddings_test
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	qt "github.com/frankban/quicktest"
@@ -57,11 +61,107 @@ func TestNewProvider_HappyPath(t *testing.T) {
 		c.Assert(err, qt.IsNil)
 		c.Assert(ep, qt.IsNotNil)
 	})
+
+	c.Run("azure-openai provider returns non-nil Provider", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("azure-openai", "text-embedding-3-small", "az-key", "https://example.openai.azure.com"))
+		c.Assert(err, qt.IsNil)
+		c.Assert(ep, qt.IsNotNil)
+	})
+
+	c.Run("cohere provider returns non-nil Provider", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("cohere", "embed-english-v3.0", "co-key", ""))
+		c.Assert(err, qt.IsNil)
+		c.Assert(ep, qt.IsNotNil)
+	})
+
+	c.Run("tei provider returns non-nil Provider", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("tei", "", "", ""))
+		c.Assert(err, qt.IsNil)
+		c.Assert(ep, qt.IsNotNil)
+	})
+
+	c.Run("tei provider ignores the default ollama base URL", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("tei", "", "", config.Default().Embedding.BaseURL))
+		c.Assert(err, qt.IsNil)
+		c.Assert(ep.(*embeddings.TEI).BaseURL, qt.Equals, "http://localhost:8080")
+	})
+}
+
+func TestNewProvider_Dimensions_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("vectors are truncated to embedding.dimensions", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"embeddings":[[0.3,0.4,0.5]]}`))
+		}))
+		defer srv.Close()
+
+		conf := cfg("ollama", "nomic-embed-text", "", srv.URL)
+		conf.Embedding.Dimensions = 2
+		ep, err := embeddings.NewProvider(conf)
+		c.Assert(err, qt.IsNil)
+		got, err := ep.Embed(context.Background(), "hello")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{0.6, 0.8})
+	})
+}
+
+func TestNewProvider_HTTPOptions_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("requests are retried and carry the configured headers", func(c *qt.C) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "rate limited", http.StatusTooManyRequests)
+				return
+			}
+			if r.Header.Get("X-Gateway-Key") != "g" {
+				http.Error(w, "missing gateway key", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"data":[{"index":0,"embedding":[0.1,0.2]}]}`))
+		}))
+		defer srv.Close()
+
+		conf := cfg("openai", "text-embedding-3-small", "sk-test", srv.URL)
+		conf.Embedding.Headers = map[string]string{"X-Gateway-Key": "g"}
+		ep, err := embeddings.NewProvider(conf)
+		c.Assert(err, qt.IsNil)
+		got, err := ep.Embed(context.Background(), "hello")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{0.1, 0.2})
+		c.Assert(calls.Load(), qt.Equals, int32(2))
+	})
 }
 
 func TestNewProvider_FailurePath(t *testing.T) {
 	c := qt.New(t)
 
+	c.Run("azure-openai without base URL returns error", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("azure-openai", "text-embedding-3-small", "az-key", ""))
+		c.Assert(err, qt.ErrorMatches, "embedding provider azure-openai: base_url is required")
+		c.Assert(ep, qt.IsNil)
+	})
+
+	c.Run("exec without command returns error", func(c *qt.C) {
+		ep, err := embeddings.NewProvider(cfg("exec", "my-model", "", ""))
+		c.Assert(err, qt.ErrorMatches, "embedding provider exec: command is required")
+		c.Assert(ep, qt.IsNil)
+	})
+
+	c.Run("a missing CA bundle returns error", func(c *qt.C) {
+		conf := cfg("tei", "", "", "")
+		conf.Home = c.TempDir()
+		conf.Embedding.CACert = "corp-ca.pem"
+		ep, err := embeddings.NewProvider(conf)
+		c.Assert(err, qt.ErrorMatches, "embedding provider tei: ca_cert: open .*corp-ca.pem: no such file or directory")
+		c.Assert(ep, qt.IsNil)
+	})
+
 	c.Run("unknown provider returns error", func(c *qt.C) {
 		ep, err := embeddings.NewProvider(cfg("unsupported-provider", "", "", ""))
 		c.Assert(err, qt.IsNotNil)
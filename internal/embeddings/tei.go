@@ -0,0 +1,85 @@
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+// teiMaxBatch is the default --max-client-batch-size of a
+// text-embeddings-inference server.
+const teiMaxBatch = 32
+
+// TEI calls the /embed endpoint of a Hugging Face text-embeddings-inference
+// server. The server decides the model.
+type TEI struct {
+	BaseURL string
+	APIKey  string // #nosec G117 -- APIKey is an intentional field name for the server's --api-key
+	client  *httpjson.Client
+}
+
+// NewTEI returns a TEI provider with a 30s timeout.
+func NewTEI(baseURL, apiKey string) *TEI {
+	return &TEI{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		client:  httpjson.Default(),
+	}
+}
+
+// Embed embeds a single text string.
+func (t *TEI) Embed(ctx context.Context, text string) ([]float32, error) {
+	results, err := t.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return results[0], nil
+}
+
+// EmbedQuery embeds a search query; the model embeds queries like
+// documents.
+func (t *TEI) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return t.Embed(ctx, text)
+}
+
+// EmbedBatch embeds multiple texts in requests of up to 32 texts. A server
+// configured with a smaller batch size answers 413, and the batch is split
+// in half until it fits.
+func (t *TEI) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	return inBatches(ctx, texts, teiMaxBatch, t.embed)
+}
+
+func (t *TEI) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	// Texts longer than the model's input are cut rather than rejected.
+	reqBody := map[string]any{
+		"inputs":   texts,
+		"truncate": true,
+	}
+	var headers map[string]string
+	if t.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + t.APIKey}
+	}
+
+	var resp [][]float32
+	err := t.client.Do(ctx, http.MethodPost, t.BaseURL+"/embed", headers, reqBody, &resp)
+	var se *httpjson.StatusError
+	if errors.As(err, &se) && se.Code == http.StatusRequestEntityTooLarge && len(texts) > 1 {
+		return inBatches(ctx, texts, (len(texts)+1)/2, t.embed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tei embed: %w", err)
+	}
+	if len(resp) != len(texts) {
+		return nil, fmt.Errorf("tei embed: expected %d results, got %d", len(texts), len(resp))
+	}
+	for _, e := range resp {
+		if len(e) == 0 {
+			return nil, fmt.Errorf("tei embed: empty embedding returned")
+		}
+	}
+	return resp, nil
+}
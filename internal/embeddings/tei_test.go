@@ -0,0 +1,119 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+// ---------------------------------------------------------------------------
+// TEI.EmbedBatch
+// ---------------------------------------------------------------------------
+
+// newTEIServer answers /embed with a vector holding each text's length,
+// rejecting batches larger than maxBatch with 413 as TEI does, and records
+// the batch sizes requested.
+func newTEIServer(c *qt.C, maxBatch int, sizes *[]int) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Inputs   []string `json:"inputs"`
+			Truncate bool     `json:"truncate"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		*sizes = append(*sizes, len(body.Inputs))
+		if r.URL.Path != "/embed" || !body.Truncate {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if len(body.Inputs) > maxBatch {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			_, _ = w.Write([]byte(`{"error":"batch size too large","error_type":"Validation"}`))
+			return
+		}
+		vecs := make([][]float32, len(body.Inputs))
+		for i, in := range body.Inputs {
+			vecs[i] = []float32{float32(len(in))}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(vecs)
+	}))
+	c.Cleanup(srv.Close)
+	return srv
+}
+
+func TestTEIEmbedBatch_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("texts are embedded in one request", func(c *qt.C) {
+		var sizes []int
+		srv := newTEIServer(c, 32, &sizes)
+
+		got, err := embeddings.NewTEI(srv.URL, "").EmbedBatch(context.Background(), []string{"a", "bb"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{1}, {2}})
+		c.Assert(sizes, qt.DeepEquals, []int{2})
+	})
+
+	c.Run("batches are split to the default client batch size", func(c *qt.C) {
+		var sizes []int
+		srv := newTEIServer(c, 32, &sizes)
+
+		got, err := embeddings.NewTEI(srv.URL, "").EmbedBatch(context.Background(), make([]string, 40))
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.HasLen, 40)
+		c.Assert(sizes, qt.DeepEquals, []int{32, 8})
+	})
+
+	c.Run("a 413 halves the batch until the server accepts it", func(c *qt.C) {
+		var sizes []int
+		srv := newTEIServer(c, 5, &sizes)
+
+		got, err := embeddings.NewTEI(srv.URL, "").EmbedBatch(context.Background(), []string{"a", "bb", "ccc", "d", "ee", "f", "g", "h"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{1}, {2}, {3}, {1}, {2}, {1}, {1}, {1}})
+		c.Assert(sizes, qt.DeepEquals, []int{8, 4, 4})
+	})
+
+	c.Run("api key is sent as a bearer token", func(c *qt.C) {
+		var auth string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[[0.5]]`))
+		}))
+		defer srv.Close()
+
+		_, err := embeddings.NewTEI(srv.URL, "tei-key").Embed(context.Background(), "a")
+		c.Assert(err, qt.IsNil)
+		c.Assert(auth, qt.Equals, "Bearer tei-key")
+	})
+}
+
+func TestTEIEmbedBatch_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("a single text too large for the server returns error", func(c *qt.C) {
+		var sizes []int
+		srv := newTEIServer(c, 0, &sizes)
+
+		_, err := embeddings.NewTEI(srv.URL, "").Embed(context.Background(), "a")
+		c.Assert(err, qt.ErrorMatches, `tei embed: HTTP 413: .*batch size too large.*`)
+	})
+
+	c.Run("result count mismatch returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[[0.5]]`))
+		}))
+		defer srv.Close()
+
+		_, err := embeddings.NewTEI(srv.URL, "").EmbedBatch(context.Background(), []string{"a", "b"})
+		c.Assert(err, qt.ErrorMatches, "tei embed: expected 2 results, got 1")
+	})
+}
@@ -0,0 +1,78 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// truncated shortens the vectors of a provider whose model was trained for
+// it (Matryoshka representation learning), keeping the leading dimensions.
+type truncated struct {
+	p   Provider
+	dim int
+}
+
+// Truncate returns a Provider whose vectors are the first dim components of
+// p's, renormalized to unit length. Vectors shorter than dim are an error.
+func Truncate(p Provider, dim int) Provider {
+	return &truncated{p: p, dim: dim}
+}
+
+// Embed embeds a single text string.
+func (t *truncated) Embed(ctx context.Context, text string) ([]float32, error) {
+	vec, err := t.p.Embed(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return truncateVector(vec, t.dim)
+}
+
+// EmbedBatch embeds multiple texts.
+func (t *truncated) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs, err := t.p.EmbedBatch(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(vecs))
+	for i, vec := range vecs {
+		if out[i], err = truncateVector(vec, t.dim); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// EmbedQuery embeds a search query.
+func (t *truncated) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vec, err := t.p.EmbedQuery(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+	return truncateVector(vec, t.dim)
+}
+
+// Close closes the wrapped provider.
+func (t *truncated) Close() error {
+	return Close(t.p)
+}
+
+func truncateVector(vec []float32, dim int) ([]float32, error) {
+	if len(vec) < dim {
+		return nil, fmt.Errorf("truncate embedding: model returned %d dimensions, fewer than the %d configured", len(vec), dim)
+	}
+	out := make([]float32, dim)
+	copy(out, vec)
+	var sum float64
+	for _, v := range out {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return out, nil
+	}
+	norm := math.Sqrt(sum)
+	for i, v := range out {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out, nil
+}
@@ -0,0 +1,53 @@
+package embeddings_test
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/embeddings"
+)
+
+func TestTruncate_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("vectors keep their leading dimensions at unit length", func(c *qt.C) {
+		p := embeddings.Truncate(&fakeProvider{}, 1)
+		got, err := p.EmbedBatch(context.Background(), []string{"abc", "a"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, [][]float32{{1}, {1}})
+	})
+
+	c.Run("Embed truncates and renormalizes", func(c *qt.C) {
+		p := embeddings.Truncate(vectorProvider{3, 4, 12}, 2)
+		got, err := p.Embed(context.Background(), "x")
+		c.Assert(err, qt.IsNil)
+		c.Assert(got, qt.DeepEquals, []float32{0.6, 0.8})
+	})
+}
+
+func TestTruncate_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("vectors shorter than the dimension are an error", func(c *qt.C) {
+		p := embeddings.Truncate(vectorProvider{3, 4}, 3)
+		_, err := p.Embed(context.Background(), "x")
+		c.Assert(err, qt.ErrorMatches, "truncate embedding: model returned 2 dimensions, fewer than the 3 configured")
+	})
+}
+
+// vectorProvider embeds every text as itself.
+type vectorProvider []float32
+
+func (v vectorProvider) Embed(context.Context, string) ([]float32, error) { return v, nil }
+
+func (v vectorProvider) EmbedQuery(context.Context, string) ([]float32, error) { return v, nil }
+
+func (v vectorProvider) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range out {
+		out[i] = v
+	}
+	return out, nil
+}
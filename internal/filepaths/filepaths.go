@@ -0,0 +1,174 @@
+// Package filepaths normalizes the related-file paths stored with memories so
+// that repo-relative and absolute spellings of the same file compare equal.
+package filepaths
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lineRangeRe matches an optional trailing line range on a related-file spec:
+// "path:12", "path:12-40", "path#L12" or "path#L12-L40".
+var lineRangeRe = regexp.MustCompile(`(?::(\d+)(?:-(\d+))?|#L(\d+)(?:-L?(\d+))?)$`)
+
+// RepoRoot walks up from dir looking for a .git entry (directory or file, so
+// worktrees and submodules are recognised). Returns "" when dir is not inside
+// a repository.
+func RepoRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// CurrentRepoRoot returns RepoRoot of the working directory, or "" on error.
+func CurrentRepoRoot() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return RepoRoot(cwd)
+}
+
+// ProjectRoot guesses the repository root of project from an absolute path
+// inside it, for paths stored before they were made repo-relative: the
+// repository enclosing path when it is named after project (as the default
+// project name is the working directory's), else the path up to its last
+// directory named project. Returns "" when neither is found.
+func ProjectRoot(path, project string) string {
+	p, _, _ := ParseRef(path)
+	p = filepath.Clean(filepath.FromSlash(p))
+	if project == "" || !filepath.IsAbs(p) {
+		return ""
+	}
+	if root := RepoRoot(filepath.Dir(p)); root != "" && filepath.Base(root) == project {
+		return root
+	}
+	for dir := filepath.Dir(p); ; {
+		if filepath.Base(dir) == project {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ParseRef splits a related-file spec into its path and optional line range.
+// start and end are 0 when no range is given; a single line yields start == end.
+func ParseRef(spec string) (path string, start, end int) {
+	spec = strings.TrimSpace(spec)
+	m := lineRangeRe.FindStringSubmatchIndex(spec)
+	if m == nil {
+		return spec, 0, 0
+	}
+	group := func(i int) string {
+		if m[2*i] < 0 {
+			return ""
+		}
+		return spec[m[2*i]:m[2*i+1]]
+	}
+	from, to := group(1), group(2)
+	if from == "" {
+		from, to = group(3), group(4)
+	}
+	start, _ = strconv.Atoi(from)
+	end = start
+	if to != "" {
+		end, _ = strconv.Atoi(to)
+	}
+	if start <= 0 || end < start {
+		return spec, 0, 0
+	}
+	return spec[:m[0]], start, end
+}
+
+// FormatRef is the inverse of ParseRef, producing the canonical "path:start-end"
+// spelling (or just "path" when there is no range).
+func FormatRef(path string, start, end int) string {
+	switch {
+	case start <= 0:
+		return path
+	case end <= start:
+		return path + ":" + strconv.Itoa(start)
+	default:
+		return path + ":" + strconv.Itoa(start) + "-" + strconv.Itoa(end)
+	}
+}
+
+// Normalize returns the canonical stored form of path: cleaned, slash-separated
+// and, when path is absolute and lies inside root, relative to root.
+// Absolute paths outside root are kept absolute. A trailing line range (see
+// ParseRef) is preserved in canonical form. Returns "" for blank input or a
+// path that refers to root itself.
+func Normalize(path, root string) string {
+	p, start, end := ParseRef(path)
+	if p == "" {
+		return ""
+	}
+	p = filepath.Clean(filepath.FromSlash(p))
+	if filepath.IsAbs(p) && root != "" {
+		abs := p
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			abs = resolved
+		}
+		if rel, ok := relativeTo(root, abs); ok {
+			p = rel
+		} else if rel, ok := relativeTo(root, p); ok {
+			p = rel
+		}
+	}
+	p = filepath.ToSlash(p)
+	if p == "." {
+		return ""
+	}
+	return FormatRef(p, start, end)
+}
+
+// NormalizeAll normalizes every entry in paths, dropping blanks and duplicates
+// while preserving order.
+func NormalizeAll(paths []string, root string) []string {
+	out := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		n := Normalize(p, root)
+		if n == "" || seen[n] {
+			continue
+		}
+		seen[n] = true
+		out = append(out, n)
+	}
+	return out
+}
+
+// IsGlob reports whether pattern contains glob metacharacters.
+func IsGlob(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// relativeTo returns p relative to root when p is root or lies beneath it.
+func relativeTo(root, p string) (string, bool) {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
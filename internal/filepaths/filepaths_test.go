@@ -0,0 +1,212 @@
+package filepaths_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/filepaths"
+)
+
+// ---------------------------------------------------------------------------
+// Normalize
+// ---------------------------------------------------------------------------
+
+func TestNormalize_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	root := filepath.FromSlash("/work/repo")
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"repo-relative path is kept", "internal/db/db.go", "internal/db/db.go"},
+		{"leading ./ is stripped", "./internal/db/db.go", "internal/db/db.go"},
+		{"redundant segments are cleaned", "internal//db/../db/db.go", "internal/db/db.go"},
+		{"trailing slash is dropped", "internal/db/", "internal/db"},
+		{"absolute path inside root becomes relative", "/work/repo/internal/db/db.go", "internal/db/db.go"},
+		{"absolute path outside root stays absolute", "/etc/hosts", "/etc/hosts"},
+		{"sibling with shared prefix stays absolute", "/work/repository/x.go", "/work/repository/x.go"},
+		{"glob pattern is preserved", "internal/*/db.go", "internal/*/db.go"},
+		{"surrounding whitespace is trimmed", "  cmd/main.go ", "cmd/main.go"},
+	}
+
+	for _, tc := range cases {
+		c.Run(tc.name, func(c *qt.C) {
+			c.Assert(filepaths.Normalize(tc.in, root), qt.Equals, tc.want)
+		})
+	}
+
+	c.Run("empty root leaves absolute paths untouched", func(c *qt.C) {
+		c.Assert(filepaths.Normalize("/work/repo/a.go", ""), qt.Equals, "/work/repo/a.go")
+	})
+}
+
+func TestNormalize_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("blank input returns empty string", func(c *qt.C) {
+		c.Assert(filepaths.Normalize("   ", "/work/repo"), qt.Equals, "")
+	})
+
+	c.Run("root itself returns empty string", func(c *qt.C) {
+		c.Assert(filepaths.Normalize("/work/repo", "/work/repo"), qt.Equals, "")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// NormalizeAll
+// ---------------------------------------------------------------------------
+
+func TestNormalizeAll_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	got := filepaths.NormalizeAll([]string{"a.go", "./a.go", "", "/r/b.go", "b.go"}, "/r")
+	c.Assert(got, qt.DeepEquals, []string{"a.go", "b.go"})
+}
+
+// ---------------------------------------------------------------------------
+// RepoRoot
+// ---------------------------------------------------------------------------
+
+func TestRepoRoot_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	c.Assert(err, qt.IsNil)
+	c.Assert(os.Mkdir(filepath.Join(root, ".git"), 0o755), qt.IsNil)
+	sub := filepath.Join(root, "internal", "db")
+	c.Assert(os.MkdirAll(sub, 0o755), qt.IsNil)
+
+	c.Run("root is found from a nested directory", func(c *qt.C) {
+		c.Assert(filepaths.RepoRoot(sub), qt.Equals, root)
+	})
+
+	c.Run("root is found from the root itself", func(c *qt.C) {
+		c.Assert(filepaths.RepoRoot(root), qt.Equals, root)
+	})
+
+	c.Run(".git file (worktree) is recognised", func(c *qt.C) {
+		wt, err := filepath.EvalSymlinks(t.TempDir())
+		c.Assert(err, qt.IsNil)
+		c.Assert(os.WriteFile(filepath.Join(wt, ".git"), []byte("gitdir: elsewhere\n"), 0o600), qt.IsNil)
+		c.Assert(filepaths.RepoRoot(wt), qt.Equals, wt)
+	})
+}
+
+func TestRepoRoot_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("directory outside any repository returns empty string", func(c *qt.C) {
+		dir := t.TempDir()
+		if filepaths.RepoRoot(dir) != "" {
+			c.Skip("temp dir is nested inside a git repository")
+		}
+		c.Assert(filepaths.RepoRoot(dir), qt.Equals, "")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// ProjectRoot
+// ---------------------------------------------------------------------------
+
+func TestProjectRoot_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("the repository named after the project is found on disk", func(c *qt.C) {
+		parent, err := filepath.EvalSymlinks(t.TempDir())
+		c.Assert(err, qt.IsNil)
+		root := filepath.Join(parent, "proj")
+		c.Assert(os.MkdirAll(filepath.Join(root, ".git"), 0o755), qt.IsNil)
+		got := filepaths.ProjectRoot(filepath.Join(root, "internal", "gone.go"), "proj")
+		c.Assert(got, qt.Equals, root)
+	})
+
+	c.Run("else the last directory named after the project", func(c *qt.C) {
+		got := filepaths.ProjectRoot("/home/u/proj/src/proj/internal/db/db.go:12", "proj")
+		c.Assert(got, qt.Equals, filepath.FromSlash("/home/u/proj/src/proj"))
+	})
+}
+
+func TestProjectRoot_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("relative paths have no root", func(c *qt.C) {
+		c.Assert(filepaths.ProjectRoot("internal/proj/db.go", "proj"), qt.Equals, "")
+	})
+
+	c.Run("paths outside the project have no root", func(c *qt.C) {
+		c.Assert(filepaths.ProjectRoot("/etc/hosts", "proj"), qt.Equals, "")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// IsGlob
+// ---------------------------------------------------------------------------
+
+func TestIsGlob_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Assert(filepaths.IsGlob("internal/**/*.go"), qt.IsTrue)
+	c.Assert(filepaths.IsGlob("file?.go"), qt.IsTrue)
+	c.Assert(filepaths.IsGlob("[ab].go"), qt.IsTrue)
+	c.Assert(filepaths.IsGlob("internal/db/db.go"), qt.IsFalse)
+}
+
+// ---------------------------------------------------------------------------
+// ParseRef / FormatRef
+// ---------------------------------------------------------------------------
+
+func TestParseRef_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	cases := []struct {
+		name      string
+		spec      string
+		wantPath  string
+		wantStart int
+		wantEnd   int
+	}{
+		{"plain path", "internal/db/db.go", "internal/db/db.go", 0, 0},
+		{"colon single line", "internal/db/db.go:42", "internal/db/db.go", 42, 42},
+		{"colon range", "internal/db/db.go:10-20", "internal/db/db.go", 10, 20},
+		{"github anchor single line", "a.go#L7", "a.go", 7, 7},
+		{"github anchor range", "a.go#L7-L9", "a.go", 7, 9},
+		{"github anchor range without second L", "a.go#L7-9", "a.go", 7, 9},
+	}
+
+	for _, tc := range cases {
+		c.Run(tc.name, func(c *qt.C) {
+			path, start, end := filepaths.ParseRef(tc.spec)
+			c.Assert(path, qt.Equals, tc.wantPath)
+			c.Assert(start, qt.Equals, tc.wantStart)
+			c.Assert(end, qt.Equals, tc.wantEnd)
+		})
+	}
+
+	c.Run("Normalize keeps the range in canonical form", func(c *qt.C) {
+		c.Assert(filepaths.Normalize("/r/a.go#L3-L5", "/r"), qt.Equals, "a.go:3-5")
+		c.Assert(filepaths.Normalize("./a.go:8", "/r"), qt.Equals, "a.go:8")
+	})
+}
+
+func TestParseRef_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("inverted range is treated as part of the path", func(c *qt.C) {
+		path, start, end := filepaths.ParseRef("a.go:20-10")
+		c.Assert(path, qt.Equals, "a.go:20-10")
+		c.Assert(start, qt.Equals, 0)
+		c.Assert(end, qt.Equals, 0)
+	})
+
+	c.Run("zero line is treated as part of the path", func(c *qt.C) {
+		path, start, _ := filepaths.ParseRef("a.go:0")
+		c.Assert(path, qt.Equals, "a.go:0")
+		c.Assert(start, qt.Equals, 0)
+	})
+}
@@ -0,0 +1,193 @@
+package httpjson
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	// Retry backoff doubles from baseBackoff up to maxBackoff.
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 10 * time.Second
+	// maxDoublings doublings of baseBackoff reach maxBackoff.
+	maxDoublings = 5
+	// maxRetryAfter is the longest Retry-After delay waited for; a server
+	// asking for more gets its error returned instead.
+	maxRetryAfter = time.Minute
+)
+
+// Options configure a Client.
+type Options struct {
+	Timeout time.Duration     // per attempt; 0 means 30s
+	Retries int               // retries after the first attempt
+	Headers map[string]string // added to every request
+	Proxy   string            // proxy URL; "" uses HTTPS_PROXY/HTTP_PROXY
+	CACert  string            // PEM file of extra CAs to trust
+}
+
+// Client sends JSON requests, retrying those that time out, lose their
+// connection or are answered with HTTP 429 or 502-504, with exponential
+// backoff and jitter or after the delay the server asks for with
+// Retry-After. Under a context from WithRateLimitsHandled, HTTP 429 answers
+// are returned at once.
+type Client struct {
+	http    *http.Client
+	retries int
+	headers map[string]string
+	// sleep waits for d or until ctx is done; replaced in tests.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient returns a Client configured by o.
+func NewClient(o Options) (*Client, error) {
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if o.Proxy != "" {
+		u, err := url.Parse(o.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if o.CACert != "" {
+		pem, err := os.ReadFile(o.CACert) // #nosec G304 -- the CA bundle path is configured by the user
+		if err != nil {
+			return nil, fmt.Errorf("ca_cert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert: no certificates found in %s", o.CACert)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return &Client{
+		http:    &http.Client{Timeout: timeout, Transport: transport},
+		retries: o.Retries,
+		headers: o.Headers,
+		sleep:   sleep,
+	}, nil
+}
+
+// Default returns a Client with a 30s timeout that does not retry.
+func Default() *Client {
+	c, _ := NewClient(Options{})
+	return c
+}
+
+// Do sends a request like the package-level Do, with the client's headers
+// added after headers, retrying as described on Client.
+func (c *Client) Do(ctx context.Context, method, url string, headers map[string]string, body, out any) error {
+	if len(c.headers) > 0 {
+		merged := make(map[string]string, len(headers)+len(c.headers))
+		for k, v := range headers {
+			merged[k] = v
+		}
+		for k, v := range c.headers {
+			merged[k] = v
+		}
+		headers = merged
+	}
+	for attempt := 0; ; attempt++ {
+		err := Do(ctx, c.http, method, url, headers, body, out)
+		if err == nil || attempt >= c.retries || ctx.Err() != nil || (RateLimitsHandled(ctx) && isRateLimit(err)) {
+			return err
+		}
+		d, ok := retryDelay(err, attempt)
+		if !ok {
+			return err
+		}
+		if err := c.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// rateLimitsHandledKey marks a context whose caller retries rate limits.
+type rateLimitsHandledKey struct{}
+
+// WithRateLimitsHandled returns a copy of ctx under which Client.Do does not
+// retry HTTP 429 answers, for callers that retry them themselves, so that
+// the two do not multiply.
+func WithRateLimitsHandled(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rateLimitsHandledKey{}, true)
+}
+
+// RateLimitsHandled reports whether ctx comes from WithRateLimitsHandled.
+func RateLimitsHandled(ctx context.Context) bool {
+	handled, _ := ctx.Value(rateLimitsHandledKey{}).(bool)
+	return handled
+}
+
+// isRateLimit reports whether err is an HTTP 429 answer.
+func isRateLimit(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && se.Code == http.StatusTooManyRequests
+}
+
+// retryDelay returns how long to wait before retrying a request that failed
+// with err on the given attempt (from 0), and whether to retry at all.
+func retryDelay(err error, attempt int) (time.Duration, bool) {
+	var se *StatusError
+	if errors.As(err, &se) {
+		switch se.Code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			return 0, false
+		}
+		if se.RetryAfter > 0 {
+			return se.RetryAfter, se.RetryAfter <= maxRetryAfter
+		}
+	} else if !retryableNetError(err) {
+		return 0, false
+	}
+	// Jitter over the upper half of the backoff keeps clients that failed
+	// together from retrying together. Doubling stops at maxBackoff, before
+	// the shift could overflow.
+	d := maxBackoff
+	if attempt < maxDoublings {
+		d = min(baseBackoff<<attempt, maxBackoff)
+	}
+	return d/2 + rand.N(d/2+1), true // #nosec G404 -- jitter needs no cryptographic randomness
+}
+
+// retryableNetError reports whether err is a network error worth retrying,
+// such as a timeout or a dropped connection. Errors meaning that nothing
+// listens at the address fail at once, as do failures to marshal or decode.
+func retryableNetError(err error) bool {
+	var ue *url.Error
+	if !errors.As(err, &ue) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.Is(err, syscall.ECONNREFUSED) || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return false
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,241 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// ---------------------------------------------------------------------------
+// Client
+// ---------------------------------------------------------------------------
+
+// newSequenceServer answers requests with the given statuses in turn, then
+// with 200 and {"ok": true}. headers are set on every error response.
+func newSequenceServer(c *qt.C, headers map[string]string, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			for k, v := range headers {
+				w.Header().Set(k, v)
+			}
+			http.Error(w, "busy", statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	c.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newTestClient returns a Client for o that records the delays it waits
+// instead of sleeping.
+func newTestClient(c *qt.C, o Options) (*Client, *[]time.Duration) {
+	client, err := NewClient(o)
+	c.Assert(err, qt.IsNil)
+	var delays []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return client, &delays
+}
+
+func TestClientDo_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("5xx answers are retried with growing jittered backoff", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, nil, http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusServiceUnavailable)
+		client, delays := newTestClient(c, Options{Retries: 3})
+
+		var out struct{ OK bool }
+		err := client.Do(context.Background(), http.MethodPost, srv.URL, nil, map[string]any{"a": 1}, &out)
+		c.Assert(err, qt.IsNil)
+		c.Assert(out.OK, qt.IsTrue)
+		c.Assert(calls.Load(), qt.Equals, int32(4))
+		c.Assert(*delays, qt.HasLen, 3)
+		for i, d := range *delays {
+			backoff := baseBackoff << i
+			c.Assert(d >= backoff/2 && d <= backoff, qt.IsTrue, qt.Commentf("delay %d: %s", i, d))
+		}
+	})
+
+	c.Run("429 answers wait for Retry-After", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, map[string]string{"Retry-After": "7"}, http.StatusTooManyRequests, http.StatusTooManyRequests)
+		client, delays := newTestClient(c, Options{Retries: 3})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(calls.Load(), qt.Equals, int32(3))
+		c.Assert(*delays, qt.DeepEquals, []time.Duration{7 * time.Second, 7 * time.Second})
+	})
+
+	c.Run("the backoff stops growing at the maximum", func(c *qt.C) {
+		for _, attempt := range []int{4, 5, 35, 64, 1000} {
+			d, ok := retryDelay(&StatusError{Code: http.StatusServiceUnavailable}, attempt)
+			c.Assert(ok, qt.IsTrue)
+			c.Assert(d >= maxBackoff/2 && d <= maxBackoff, qt.IsTrue, qt.Commentf("attempt %d: %s", attempt, d))
+		}
+	})
+
+	c.Run("configured headers are sent and win over the provider's", func(c *qt.C) {
+		var got http.Header
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r.Header
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		c.Cleanup(srv.Close)
+		client, _ := newTestClient(c, Options{Headers: map[string]string{"X-Gateway-Key": "g", "Authorization": "Gateway g"}})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, map[string]string{"Authorization": "Bearer k", "X-Other": "o"}, nil, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.Get("X-Gateway-Key"), qt.Equals, "g")
+		c.Assert(got.Get("Authorization"), qt.Equals, "Gateway g")
+		c.Assert(got.Get("X-Other"), qt.Equals, "o")
+	})
+
+	c.Run("requests go through the configured proxy", func(c *qt.C) {
+		var target string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target = r.URL.String()
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		}))
+		c.Cleanup(proxy.Close)
+		client, _ := newTestClient(c, Options{Proxy: proxy.URL})
+
+		var out struct{ OK bool }
+		err := client.Do(context.Background(), http.MethodGet, "http://embeddings.internal/v1/embed", nil, nil, &out)
+		c.Assert(err, qt.IsNil)
+		c.Assert(out.OK, qt.IsTrue)
+		c.Assert(target, qt.Equals, "http://embeddings.internal/v1/embed")
+	})
+
+	c.Run("a CA bundle makes a private certificate trusted", func(c *qt.C) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		c.Cleanup(srv.Close)
+		caFile := filepath.Join(c.TempDir(), "ca.pem")
+		block := &pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}
+		c.Assert(os.WriteFile(caFile, pem.EncodeToMemory(block), 0o600), qt.IsNil)
+
+		err := Default().Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.ErrorMatches, ".*certificate.*")
+
+		client, _ := newTestClient(c, Options{CACert: caFile})
+		err = client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.IsNil)
+	})
+}
+
+func TestClientDo_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("the last error is returned once retries run out", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, nil, 503, 503, 503, 503, 503)
+		client, delays := newTestClient(c, Options{Retries: 2})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		var se *StatusError
+		c.Assert(errors.As(err, &se), qt.IsTrue)
+		c.Assert(se.Code, qt.Equals, http.StatusServiceUnavailable)
+		c.Assert(calls.Load(), qt.Equals, int32(3))
+		c.Assert(*delays, qt.HasLen, 2)
+	})
+
+	c.Run("other statuses are not retried", func(c *qt.C) {
+		for _, status := range []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError} {
+			srv, calls := newSequenceServer(c, nil, status)
+			client, _ := newTestClient(c, Options{Retries: 3})
+			err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+			c.Assert(err, qt.ErrorMatches, "HTTP .*")
+			c.Assert(calls.Load(), qt.Equals, int32(1))
+		}
+	})
+
+	c.Run("429 answers are not retried when the caller handles them", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, nil, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+		client, delays := newTestClient(c, Options{Retries: 3})
+		ctx := WithRateLimitsHandled(context.Background())
+
+		err := client.Do(ctx, http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.ErrorMatches, "HTTP 429: busy")
+		c.Assert(calls.Load(), qt.Equals, int32(1))
+		c.Assert(*delays, qt.HasLen, 0)
+
+		err = client.Do(ctx, http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(calls.Load(), qt.Equals, int32(3))
+	})
+
+	c.Run("a Retry-After beyond a minute is not waited for", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, map[string]string{"Retry-After": "3600"}, http.StatusTooManyRequests)
+		client, delays := newTestClient(c, Options{Retries: 3})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.ErrorMatches, "HTTP 429: busy")
+		c.Assert(calls.Load(), qt.Equals, int32(1))
+		c.Assert(*delays, qt.HasLen, 0)
+	})
+
+	c.Run("nothing listening fails at once", func(c *qt.C) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		srv.Close()
+		client, delays := newTestClient(c, Options{Retries: 3})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.ErrorMatches, "request: .*connection refused")
+		c.Assert(*delays, qt.HasLen, 0)
+	})
+
+	c.Run("a timed out request is retried", func(c *qt.C) {
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) == 1 {
+				<-r.Context().Done()
+				return
+			}
+			_, _ = w.Write([]byte(`{}`))
+		}))
+		c.Cleanup(srv.Close)
+		client, delays := newTestClient(c, Options{Timeout: 50 * time.Millisecond, Retries: 1})
+
+		err := client.Do(context.Background(), http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.IsNil)
+		c.Assert(*delays, qt.HasLen, 1)
+	})
+
+	c.Run("cancelling stops the retries", func(c *qt.C) {
+		srv, calls := newSequenceServer(c, nil, 503, 503, 503)
+		client, _ := newTestClient(c, Options{Retries: 3})
+		ctx, cancel := context.WithCancel(context.Background())
+		client.sleep = func(context.Context, time.Duration) error {
+			cancel()
+			return ctx.Err()
+		}
+
+		err := client.Do(ctx, http.MethodGet, srv.URL, nil, nil, nil)
+		c.Assert(err, qt.ErrorIs, context.Canceled)
+		c.Assert(calls.Load(), qt.Equals, int32(1))
+	})
+
+	c.Run("invalid client options", func(c *qt.C) {
+		empty := filepath.Join(c.TempDir(), "empty.pem")
+		c.Assert(os.WriteFile(empty, []byte("not a certificate"), 0o600), qt.IsNil)
+		_, err := NewClient(Options{CACert: empty})
+		c.Assert(err, qt.ErrorMatches, "ca_cert: no certificates found in .*empty.pem")
+		_, err = NewClient(Options{CACert: filepath.Join(c.TempDir(), "missing.pem")})
+		c.Assert(err, qt.ErrorMatches, "ca_cert: .*no such file or directory")
+	})
+}
@@ -0,0 +1,89 @@
+// Package httpjson sends JSON requests to the HTTP APIs of embedding and
+// rerank providers.
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned by Do when the server answers with a non-2xx
+// status.
+type StatusError struct {
+	Code int
+	Body string // start of the response body
+	// RetryAfter is the delay requested by a Retry-After header, 0 if none.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Code, e.Body)
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(v); err == nil && n > 0 {
+		return time.Duration(n) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// Do executes an HTTP request, marshalling body as JSON and unmarshalling
+// the response into out. Pass nil body for GET requests. Pass nil out to discard
+// the response body. Returns a *StatusError on non-2xx status codes.
+func Do(ctx context.Context, client *http.Client, method, url string, headers map[string]string, body, out any) error {
+	var bodyReader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("marshal: %w", err)
+		}
+		bodyReader = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req) // #nosec G704 -- SSRF risk accepted; URL is the user-configured provider endpoint
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return &StatusError{
+			Code:       resp.StatusCode,
+			Body:       string(bytes.TrimSpace(snippet)),
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("decode: %w", err)
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strconv"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/go-ports/echovault/internal/buildinfo"
 	"github.com/go-ports/echovault/internal/models"
+	"github.com/go-ports/echovault/internal/search"
 	"github.com/go-ports/echovault/internal/service"
 )
 
@@ -59,7 +61,13 @@ When filling ` + "`details`" + `, prefer this structure:
 - Tradeoffs
 - Follow-up`
 
-const searchDescription = `Search memories using keyword and semantic search. Returns matching memories ranked by relevance. You MUST call this at session start before doing any work, and whenever the user's request relates to a topic that may have prior context.` //nolint:lll
+const searchDescription = `Search memories using keyword and semantic search. Returns matching memories ranked by relevance. You MUST call this at session start before doing any work, and whenever the user's request relates to a topic that may have prior context. Each result's snippet shows the matching text (from the summary fields or the memory's details) with matched terms in **bold**. Results are returned under "results"; when has_more is true, pass next_cursor back as cursor to get the next page. Results flagged possibly_stale describe files that have since changed substantially, moved, or been deleted — verify them against the code before relying on them.` //nolint:lll
+
+const queryDescription = `Search terms. Bare terms match any word. Also supports "exact phrases", AND / OR / (grouping), -term to exclude memories containing the word (-term* for words starting with it), field scopes title:, what:, why:, impact:, details:, and filters category:bug, tag:auth, source:, project:, since:2025-01, until:2025-03-15 (or relative 30d, 2w, 6m, 1y), has:details. Filters narrow the whole query and cannot be combined with OR; comma-separate filter values to match any of them (category:bug,decision).` //nolint:lll
+
+const cursorDescription = `Pass the next_cursor from a previous response (with the same other arguments) to fetch the next page. Memories saved after the first page are not included in later pages.` //nolint:lll
+
+const forFilesDescription = `Get memories related to the files you are about to read or edit. Call this before modifying a file to learn about prior decisions, bugs, and gotchas that touched it. Accepts repo-relative or absolute paths, glob patterns (e.g. internal/db/*.go), and directories (which match every file beneath them). Results are ranked by category and recency.` //nolint:lll
 
 const contextDescription = `Get memory context for the current project. You MUST call this at session start to load prior decisions, bugs, and context. Do not skip this step — prior sessions contain decisions and context that directly affect your current task. Use memory_search for specific topics.` //nolint:lll
 
@@ -81,6 +89,8 @@ func Serve(_ context.Context, disabledTools []string) error {
 		return fmt.Errorf("mcp: init service: %w", err)
 	}
 	defer svc.Close()
+	svc.StartBackgroundReindex()
+	svc.StartBackgroundEmbed()
 
 	return mcpserver.ServeStdio(NewServer(svc, disabledTools))
 }
@@ -123,7 +133,7 @@ func registerTools(s *mcpserver.MCPServer, svc *service.Service, disabledTools [
 				mcp.Enum(validCategories...),
 			),
 			mcp.WithArray("related_files",
-				mcp.Description("File paths involved."),
+				mcp.Description("File paths involved, repo-relative. Append :start-end to pin a line range (e.g. internal/db/db.go:120-180)."),
 				mcp.WithStringItems(),
 			),
 			mcp.WithString("details",
@@ -142,7 +152,7 @@ func registerTools(s *mcpserver.MCPServer, svc *service.Service, disabledTools [
 		s.AddTool(mcp.NewTool("memory_search",
 			mcp.WithDescription(searchDescription),
 			mcp.WithString("query",
-				mcp.Description("Search terms"),
+				mcp.Description(queryDescription),
 				mcp.Required(),
 			),
 			mcp.WithNumber("limit",
@@ -151,6 +161,15 @@ func registerTools(s *mcpserver.MCPServer, svc *service.Service, disabledTools [
 			mcp.WithString("project",
 				mcp.Description("Filter to project."),
 			),
+			mcp.WithString("cursor",
+				mcp.Description(cursorDescription),
+			),
+			mcp.WithBoolean("diverse",
+				mcp.Description("Prefer varied results over several near-identical memories on the same topic. Defaults to the search.diversity config setting."),
+			),
+			mcp.WithBoolean("explain",
+				mcp.Description("Add an explain object to each result showing how its score was computed: keyword (BM25) and vector (distance) rank and raw score, fusion weights, reranker score, recency/category/project multipliers and matched terms."),
+			),
 		), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			return handleSearch(ctx, svc, req)
 		})
@@ -166,11 +185,33 @@ func registerTools(s *mcpserver.MCPServer, svc *service.Service, disabledTools [
 			mcp.WithNumber("limit",
 				mcp.Description("Max memories (default 10)"),
 			),
+			mcp.WithString("cursor",
+				mcp.Description(cursorDescription),
+			),
 		), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			return handleContext(ctx, svc, req)
 		})
 	}
 
+	if !isDisabled("memory_for_files", disabledTools) {
+		s.AddTool(mcp.NewTool("memory_for_files",
+			mcp.WithDescription(forFilesDescription),
+			mcp.WithArray("files",
+				mcp.Description("File paths, globs, or directories you are about to touch."),
+				mcp.WithStringItems(),
+				mcp.Required(),
+			),
+			mcp.WithString("project",
+				mcp.Description("Filter to project."),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Max results (default 10)"),
+			),
+		), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return handleForFiles(ctx, svc, req)
+		})
+	}
+
 	if !isDisabled("memory_delete", disabledTools) {
 		s.AddTool(mcp.NewTool("memory_delete",
 			mcp.WithDescription(deleteDescription),
@@ -223,7 +264,7 @@ func registerTools(s *mcpserver.MCPServer, svc *service.Service, disabledTools [
 				mcp.Enum(validCategories...),
 			),
 			mcp.WithArray("related_files",
-				mcp.Description("File paths involved."),
+				mcp.Description("File paths involved, repo-relative. Append :start-end to pin a line range (e.g. internal/db/db.go:120-180)."),
 				mcp.WithStringItems(),
 			),
 			mcp.WithString("details",
@@ -284,29 +325,47 @@ func handleSearch(ctx context.Context, svc *service.Service, req mcp.CallToolReq
 		limit = 5
 	}
 	project := req.GetString("project", "")
+	cur := req.GetString("cursor", "")
+
+	sc := svc.Config.Search
+	if diverse, ok := req.GetArguments()["diverse"].(bool); ok {
+		switch {
+		case !diverse:
+			sc.Diversity = 0
+		case sc.Diversity == 0:
+			sc.Diversity = search.DefaultDiversity
+		}
+	}
 
-	results, err := svc.Search(ctx, query, limit, project, "", true)
+	results, next, err := svc.SearchWith(ctx, sc, query, limit, project, "", cur, true)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
+	explain := req.GetBool("explain", false)
 	clean := make([]map[string]any, 0, len(results))
 	for _, r := range results {
-		clean = append(clean, map[string]any{
-			"id":          r.ID,
-			"title":       r.Title,
-			"what":        r.What,
-			"why":         r.Why,
-			"impact":      r.Impact,
-			"category":    r.Category,
-			"tags":        parseTags(r.Tags),
-			"project":     r.Project,
-			"created_at":  truncate(r.CreatedAt, 10),
-			"score":       roundTwo(r.Score),
-			"has_details": r.HasDetails,
-		})
+		item := map[string]any{
+			"id":             r.ID,
+			"title":          r.Title,
+			"what":           r.What,
+			"why":            r.Why,
+			"impact":         r.Impact,
+			"category":       r.Category,
+			"tags":           parseTags(r.Tags),
+			"project":        r.Project,
+			"created_at":     truncate(r.CreatedAt, 10),
+			"score":          roundTwo(r.Score),
+			"has_details":    r.HasDetails,
+			"possibly_stale": r.PossiblyStale,
+			"snippet":        r.Snippet,
+		}
+		if explain {
+			item["explain"] = explainJSON(r.Explain)
+		}
+		clean = append(clean, item)
 	}
-	return jsonResult(clean)
+	return jsonResult(withVectorWarning(svc, withPage(map[string]any{"results": clean}, next)))
 }
 
 func handleContext(ctx context.Context, svc *service.Service, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -319,7 +378,9 @@ func handleContext(ctx context.Context, svc *service.Service, req mcp.CallToolRe
 		limit = 10
 	}
 
-	results, total, err := svc.GetContext(ctx, limit, project, "", "", "never", false)
+	cur := req.GetString("cursor", "")
+
+	results, total, next, err := svc.GetContext(ctx, limit, project, "", "", "never", cur, false)
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
@@ -328,12 +389,14 @@ func handleContext(ctx context.Context, svc *service.Service, req mcp.CallToolRe
 	for _, r := range results {
 		tagsRaw, _ := r["tags"].(string)
 		dateStr, _ := r["created_at"].(string)
+		stale, _ := r["possibly_stale"].(bool)
 		memories = append(memories, map[string]any{
-			"id":       r["id"],
-			"title":    r["title"],
-			"category": r["category"],
-			"tags":     parseTags(tagsRaw),
-			"date":     formatDate(dateStr),
+			"id":             r["id"],
+			"title":          r["title"],
+			"category":       r["category"],
+			"tags":           parseTags(tagsRaw),
+			"date":           formatDate(dateStr),
+			"possibly_stale": stale,
 		})
 	}
 
@@ -342,12 +405,51 @@ func handleContext(ctx context.Context, svc *service.Service, req mcp.CallToolRe
 		message += " No memories found for project \"" + project + "\"."
 	}
 
-	return jsonResult(map[string]any{
+	return jsonResult(withVectorWarning(svc, withPage(map[string]any{
 		"total":    total,
 		"showing":  len(memories),
 		"memories": memories,
 		"message":  message,
-	})
+	}, next)))
+}
+
+func handleForFiles(_ context.Context, svc *service.Service, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	files := req.GetStringSlice("files", make([]string, 0))
+	if len(files) == 0 {
+		return mcp.NewToolResultError("'files' must contain at least one path"), nil
+	}
+	limit := req.GetInt("limit", 10)
+	if limit <= 0 {
+		limit = 10
+	}
+	project := req.GetString("project", "")
+
+	results, err := svc.ForFiles(files, limit, project, "")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	clean := make([]map[string]any, 0, len(results))
+	for _, r := range results {
+		tagsRaw, _ := r["tags"].(string)
+		createdAt, _ := r["created_at"].(string)
+		score, _ := r["score"].(float64)
+		hasDetails, _ := r["has_details"].(int64)
+		clean = append(clean, map[string]any{
+			"id":            r["id"],
+			"title":         r["title"],
+			"what":          r["what"],
+			"why":           r["why"],
+			"category":      r["category"],
+			"tags":          parseTags(tagsRaw),
+			"project":       r["project"],
+			"matched_files": r["matched_files"],
+			"created_at":    truncate(createdAt, 10),
+			"score":         roundTwo(score),
+			"has_details":   hasDetails != 0,
+		})
+	}
+	return jsonResult(clean)
 }
 
 func handleDelete(_ context.Context, svc *service.Service, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -420,10 +522,10 @@ func handleReplace(ctx context.Context, svc *service.Service, req mcp.CallToolRe
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	return jsonResult(map[string]any{
+	return jsonResult(withVectorWarning(svc, map[string]any{
 		"id":     result.ID,
 		"action": result.Action,
-	})
+	}))
 }
 
 // ---------------------------------------------------------------------------
@@ -439,6 +541,26 @@ func isValidCategory(c string) bool {
 	return false
 }
 
+// withPage adds the pagination fields to a tool response: has_more, and
+// next_cursor when there is another page.
+func withPage(m map[string]any, next string) map[string]any {
+	m["has_more"] = next != ""
+	if next != "" {
+		m["next_cursor"] = next
+	}
+	return m
+}
+
+// withVectorWarning adds a vector_warning to a tool response when the stored
+// vectors do not fit the configured embedding model, so the agent knows that
+// search is keyword-only.
+func withVectorWarning(svc *service.Service, m map[string]any) map[string]any {
+	if st := svc.VectorStatus(); st.Stale {
+		m["vector_warning"] = "WARNING: " + st.Warning + "."
+	}
+	return m
+}
+
 func jsonResult(v any) (*mcp.CallToolResult, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
@@ -480,6 +602,54 @@ func formatDate(dateStr string) string {
 	return t.Format("Jan 02")
 }
 
+// explainJSON renders a score breakdown for memory_search. Sources and
+// multipliers that did not apply are omitted.
+func explainJSON(e search.Explanation) map[string]any {
+	out := map[string]any{
+		"fusion":          e.Fusion,
+		"fused":           roundSix(e.Fused),
+		"keyword_hits":    e.FTSHits,
+		"vector_searched": e.VectorSearched,
+		"matched_terms":   e.Terms,
+	}
+	if e.Terms == nil {
+		out["matched_terms"] = []string{}
+	}
+	for key, src := range map[string]*search.SourceScore{"fts": e.FTS, "vector": e.Vector} {
+		if src == nil {
+			continue
+		}
+		raw := "bm25"
+		if key == "vector" {
+			raw = "distance"
+		}
+		out[key] = map[string]any{
+			"rank":         src.Rank,
+			raw:            roundSix(src.Raw),
+			"normalized":   roundSix(src.Normalized),
+			"weight":       src.Weight,
+			"contribution": roundSix(src.Contribution()),
+		}
+	}
+	if e.Reranked {
+		out["rerank"] = roundSix(e.Rerank)
+	}
+	if e.Decay != 0 {
+		out["base"] = roundSix(e.Base)
+		out["decay"] = roundSix(e.Decay)
+		out["category_boost"] = e.CategoryBoost
+		out["project_boost"] = e.ProjectBoost
+	}
+	return out
+}
+
+// roundSix rounds f to 6 significant digits, enough to compare the small
+// scores of RRF and BM25.
+func roundSix(f float64) float64 {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(f, 'g', 6, 64), 64)
+	return v
+}
+
 // roundTwo rounds f to 2 decimal places.
 func roundTwo(f float64) float64 {
 	return math.Round(f*100) / 100
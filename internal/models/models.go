@@ -80,6 +80,33 @@ type MemoryDetail struct {
 	Body     string
 }
 
+// FileRef is a related file recorded with a memory, pinned to the git commit
+// that was checked out when the memory was written.
+type FileRef struct {
+	Path      string
+	CommitSHA string // empty when the file was not inside a git repository
+	LineStart int    // 0 when the memory covers the whole file
+	LineEnd   int
+}
+
+// StaleFile describes how a related file has drifted since its memory was written.
+type StaleFile struct {
+	FileRef
+	State       string  // "changed", "deleted" or "renamed"
+	RenamedTo   string  // set when State is "renamed"
+	ChangeRatio float64 // fraction of the file (or line range) that changed
+}
+
+// StaleMemory is a memory whose related files have drifted.
+type StaleMemory struct {
+	ID        string
+	Title     string
+	Category  string
+	Project   string
+	CreatedAt string
+	Files     []StaleFile
+}
+
 // SearchResult is a single hit returned from hybrid search.
 type SearchResult struct {
 	ID         string
@@ -105,11 +132,52 @@ type SaveResult struct {
 	Warnings []string
 }
 
+// VectorStatus is returned from Service.VectorStatus.
+type VectorStatus struct {
+	Model      string // embedding model the stored vectors were built with, "" if unknown
+	Configured string // embedding model in the config
+	// Stale is set when the stored vectors cannot be searched with the
+	// configured model; search then uses keywords only. Warning says why.
+	Stale      bool
+	Warning    string
+	Reindexing bool // a background reindex is rebuilding the vectors
+}
+
+// IndexStats is returned from Service.Stats.
+type IndexStats struct {
+	Memories     int
+	Vectors      int // memory vectors
+	Pending      int // memories queued for embedding
+	ChunkVectors int // detail-chunk vectors
+	Dim          int
+	Model        string // embedding model of the stored vectors
+	Metric       string
+	Storage      string // how vector components are stored
+	VectorBytes  int64  // estimated space taken by the vectors
+	IndexBytes   int64  // size of the index database
+}
+
+// DoctorCheck is one finding of Service.Doctor.
+type DoctorCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
 // ReindexResult is returned from Service.Reindex.
 type ReindexResult struct {
-	Count int
-	Dim   int
-	Model string
+	Count    int // memories in the index
+	Embedded int // memories embedded by this run
+	Reused   int // memories whose current vectors were kept
+	Dim      int
+	Model    string
+}
+
+// EmbedPendingResult is returned from Service.EmbedPending.
+type EmbedPendingResult struct {
+	Queued   int // memories without a vector found and queued by this run
+	Embedded int // queued memories embedded by this run
+	Pending  int // memories still queued
 }
 
 // ---------------------------------------------------------------------------
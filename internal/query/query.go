@@ -0,0 +1,700 @@
+// Package query parses the structured search syntax accepted by `memory search`
+// and the memory_search MCP tool, and compiles it into an FTS5 MATCH
+// expression plus SQL predicates over the memories table.
+//
+// Syntax:
+//
+//	redis timeout          either term (bare terms are prefix-matched and OR'd)
+//	redis AND timeout      both terms
+//	"connection pool"      exact phrase
+//	-redis                 exclude memories containing the word (-redis* for words starting with it)
+//	(a OR b) AND c         grouping
+//	title:pool why:"race"  restrict a term to the title, what, why, impact or details field
+//	category:bug           filters: category, tag, source, project (comma = any of)
+//	since:2025-01          created on or after a date (YYYY, YYYY-MM, YYYY-MM-DD or 30d, 2w, 6m, 1y ago)
+//	until:2025-03-15       created before the end of a date
+//	has:details            memories with details (or has:files for related files)
+//
+// Filters and excluded terms always narrow the whole query, wherever they
+// appear, so a filter cannot be an alternative to other terms: it is an error
+// as an operand of OR (category:bug OR tag:auth), or of AND inside a negated
+// group. Alternative filter values are written category:bug,decision.
+// Memory summaries and detail bodies are separate FTS tables, so a group of
+// AND'd terms matches only when all of them occur in the same one.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// scopedFields are the FTS columns a term can be restricted to with "field:".
+// "details" targets the detail body, which lives in its own FTS table.
+var scopedFields = map[string]bool{"title": true, "what": true, "why": true, "impact": true, "details": true}
+
+// filterFields are the "field:value" prefixes compiled into SQL predicates.
+var filterFields = map[string]bool{
+	"category": true, "tag": true, "source": true, "project": true,
+	"since": true, "until": true, "has": true,
+}
+
+// ErrSyntax wraps every error returned by Parse.
+var ErrSyntax = errors.New("invalid query")
+
+var relativeDateRe = regexp.MustCompile(`^(\d+)([dwmy])$`)
+
+// Filter is a structured "field:value" constraint.
+type Filter struct {
+	Field  string
+	Values []string // comma-separated alternatives; any may match
+	Negate bool
+}
+
+// Query is a parsed search expression.
+type Query struct {
+	match   node
+	exclude []node
+	Filters []Filter
+	text    []string
+	now     time.Time
+}
+
+// Compiled is a Query translated to SQL. Where predicates reference the
+// memories table through alias "m"; Args bind their placeholders in order.
+type Compiled struct {
+	Match        string // MATCH expression for memories_fts; "" when nothing can match there
+	DetailsMatch string // MATCH expression for details_fts; "" when nothing can match there
+	Where        []string
+	Args         []any
+	// Category is set (lowercased) when the query requires exactly one
+	// category, so vector search can apply it inside the k-NN scan. The
+	// equivalent predicate is still part of Where.
+	Category string
+}
+
+// Parse parses s. Relative dates are resolved against the current time.
+func Parse(s string) (*Query, error) {
+	return ParseAt(s, time.Now().UTC())
+}
+
+// ParseAt parses s, resolving relative dates (since:30d) against now.
+func ParseAt(s string, now time.Time) (*Query, error) {
+	q, err := parse(s, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrSyntax, err)
+	}
+	return q, nil
+}
+
+func parse(s string, now time.Time) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	var root node
+	if len(toks) > 0 {
+		root, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.toks) {
+			return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+		}
+	}
+
+	if err := checkFilters(root, false); err != nil {
+		return nil, err
+	}
+	q := &Query{now: now}
+	q.match = q.extract(root)
+	for _, f := range q.Filters {
+		if err := validateFilter(f); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// Empty reports whether the query has no terms and no filters.
+func (q *Query) Empty() bool {
+	return q.match == nil && len(q.exclude) == 0 && len(q.Filters) == 0
+}
+
+// Text returns the positive search terms joined by spaces, without operators,
+// field scopes or filters — suitable for embedding.
+func (q *Query) Text() string {
+	return strings.Join(q.text, " ")
+}
+
+// Terms returns the positive search terms and phrases, in query order.
+func (q *Query) Terms() []string {
+	return slices.Clone(q.text)
+}
+
+// Compile translates q into an FTS5 expression and SQL predicates.
+func (q *Query) Compile() Compiled {
+	var c Compiled
+	if q.match != nil {
+		c.Match, _ = q.match.fts(false)
+		c.DetailsMatch, _ = q.match.fts(true)
+	}
+	// An excluded term removes the memory when it matches either table.
+	for _, table := range []struct {
+		name    string
+		details bool
+	}{{"memories_fts", false}, {"details_fts", true}} {
+		var parts []string
+		for _, n := range q.exclude {
+			if s, ok := n.fts(table.details); ok {
+				parts = append(parts, s)
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		c.Where = append(c.Where, "m.rowid NOT IN (SELECT rowid FROM "+table.name+" WHERE "+table.name+" MATCH ?)")
+		c.Args = append(c.Args, strings.Join(parts, " OR "))
+	}
+	var categories []Filter
+	for _, f := range q.Filters {
+		if f.Field == "category" {
+			categories = append(categories, f)
+		}
+	}
+	if len(categories) == 1 && !categories[0].Negate && len(categories[0].Values) == 1 {
+		c.Category = strings.ToLower(categories[0].Values[0])
+	}
+	for _, f := range q.Filters {
+		clause, args := q.filterSQL(f)
+		if f.Negate {
+			clause = "NOT (" + clause + ")"
+		}
+		c.Where = append(c.Where, clause)
+		c.Args = append(c.Args, args...)
+	}
+	return c
+}
+
+// extract walks the parse tree, moving filters into q.Filters and negated
+// terms into q.exclude, and returns what remains of the positive expression.
+func (q *Query) extract(n node) node {
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *filterNode:
+		q.Filters = append(q.Filters, Filter{Field: n.field, Values: splitValues(n.value), Negate: n.neg})
+		return nil
+	case *termNode:
+		if n.neg {
+			q.exclude = append(q.exclude, &termNode{field: n.field, text: n.text, phrase: n.phrase, star: n.star, exact: true})
+			return nil
+		}
+		q.text = append(q.text, n.text)
+		return n
+	case *groupNode:
+		if n.neg {
+			// A negated group excludes everything its positive terms match;
+			// filters inside it are negated individually.
+			inner := &Query{now: q.now}
+			kept := inner.extract(&groupNode{op: n.op, children: n.children})
+			if kept != nil {
+				q.exclude = append(q.exclude, exactly(kept))
+			}
+			for _, f := range inner.Filters {
+				f.Negate = !f.Negate
+				q.Filters = append(q.Filters, f)
+			}
+			return nil
+		}
+		var kept []node
+		for _, child := range n.children {
+			if k := q.extract(child); k != nil {
+				kept = append(kept, k)
+			}
+		}
+		switch len(kept) {
+		case 0:
+			return nil
+		case 1:
+			return kept[0]
+		}
+		return &groupNode{op: n.op, children: kept}
+	}
+	return nil
+}
+
+// exactly returns a copy of n whose terms match whole words only, as
+// excluded terms do unless written with a trailing "*".
+func exactly(n node) node {
+	switch n := n.(type) {
+	case *termNode:
+		t := *n
+		t.exact = true
+		return &t
+	case *groupNode:
+		g := *n
+		g.children = make([]node, len(n.children))
+		for i, child := range n.children {
+			g.children[i] = exactly(child)
+		}
+		return &g
+	}
+	return n
+}
+
+// checkFilters rejects filters that are alternatives to other terms, whose
+// meaning would change when extract lifts them out of the expression: an
+// operand of an explicit OR, or of AND inside a negated group (an OR of the
+// negations). neg reports whether n is negated.
+func checkFilters(n node, neg bool) error {
+	g, ok := n.(*groupNode)
+	if !ok {
+		return nil
+	}
+	neg = neg != g.neg
+	for i, child := range g.children {
+		if g.disjunct(i, neg) {
+			if f := firstFilter(child); f != nil {
+				return fmt.Errorf("%s:%s: a filter narrows the whole query and cannot be an alternative to other terms; list alternative values as %s:a,b",
+					f.field, f.value, f.field)
+			}
+		}
+		if err := checkFilters(child, neg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstFilter returns the first filter in n, or nil.
+func firstFilter(n node) *filterNode {
+	switch n := n.(type) {
+	case *filterNode:
+		return n
+	case *groupNode:
+		for _, child := range n.children {
+			if f := firstFilter(child); f != nil {
+				return f
+			}
+		}
+	}
+	return nil
+}
+
+// filterSQL returns the predicate for f (ignoring Negate) and its arguments.
+func (q *Query) filterSQL(f Filter) (string, []any) {
+	var clauses []string
+	var args []any
+	for _, v := range f.Values {
+		switch f.Field {
+		case "category", "source", "project":
+			clauses = append(clauses, "lower(m."+f.Field+") = lower(?)")
+			args = append(args, v)
+		case "tag":
+			clauses = append(clauses, "(json_valid(m.tags) AND EXISTS (SELECT 1 FROM json_each(m.tags) WHERE lower(json_each.value) = lower(?)))")
+			args = append(args, v)
+		case "since":
+			start, _, _ := resolveDate(v, q.now)
+			clauses = append(clauses, "m.created_at >= ?")
+			args = append(args, start.Format(time.RFC3339))
+		case "until":
+			_, end, _ := resolveDate(v, q.now)
+			clauses = append(clauses, "m.created_at < ?")
+			args = append(args, end.Format(time.RFC3339))
+		case "has":
+			switch strings.ToLower(v) {
+			case "details":
+				clauses = append(clauses, "EXISTS (SELECT 1 FROM memory_details WHERE memory_id = m.id)")
+			case "files":
+				clauses = append(clauses, "EXISTS (SELECT 1 FROM memory_files WHERE memory_id = m.id)")
+			}
+		}
+	}
+	if len(clauses) == 1 {
+		return clauses[0], args
+	}
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+func validateFilter(f Filter) error {
+	if len(f.Values) == 0 {
+		return fmt.Errorf("%s: needs a value", f.Field)
+	}
+	for _, v := range f.Values {
+		switch f.Field {
+		case "since", "until":
+			if _, _, err := resolveDate(v, time.Time{}); err != nil {
+				return fmt.Errorf("%s:%s: %w", f.Field, v, err)
+			}
+		case "has":
+			if lv := strings.ToLower(v); lv != "details" && lv != "files" {
+				return fmt.Errorf("has:%s: expected has:details or has:files", v)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDate returns the half-open interval [start, end) covered by v.
+// Absolute dates cover their whole year, month or day; relative dates
+// ("30d", "2w", "6m", "1y") are the single instant that long before now.
+func resolveDate(v string, now time.Time) (start, end time.Time, err error) {
+	if m := relativeDateRe.FindStringSubmatch(v); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		var t time.Time
+		switch m[2] {
+		case "d":
+			t = now.AddDate(0, 0, -n)
+		case "w":
+			t = now.AddDate(0, 0, -7*n)
+		case "m":
+			t = now.AddDate(0, -n, 0)
+		case "y":
+			t = now.AddDate(-n, 0, 0)
+		}
+		return t, t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, t.AddDate(0, 0, 1), nil
+	}
+	if t, err := time.Parse("2006-01", v); err == nil {
+		return t, t.AddDate(0, 1, 0), nil
+	}
+	if t, err := time.Parse("2006", v); err == nil {
+		return t, t.AddDate(1, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, errors.New("expected YYYY, YYYY-MM, YYYY-MM-DD or a relative age like 30d")
+}
+
+func splitValues(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Parse tree
+// ---------------------------------------------------------------------------
+
+// node is a parse tree element. fts renders it as an FTS5 expression for
+// memories_fts (details false) or details_fts (details true); ok is false when
+// the node can never match that table.
+type node interface {
+	fts(details bool) (expr string, ok bool)
+}
+
+type termNode struct {
+	field  string // "" for all columns
+	text   string
+	phrase bool
+	star   bool // written with a trailing "*"
+	exact  bool // match whole words only, unless star
+	neg    bool
+}
+
+func (n *termNode) fts(details bool) (string, bool) {
+	s := `"` + strings.ReplaceAll(n.text, `"`, `""`) + `"`
+	if n.star || (!n.phrase && !n.exact) {
+		s += "*"
+	}
+	switch {
+	case n.field == "":
+		return s, true
+	case details:
+		// details_fts has a single column.
+		return s, n.field == "details"
+	case n.field == "details":
+		return "", false
+	}
+	return n.field + " : " + s, true
+}
+
+type filterNode struct {
+	field, value string
+	neg          bool
+}
+
+// fts is never called: filters are extracted before compilation.
+func (n *filterNode) fts(bool) (string, bool) { return "", false }
+
+type groupNode struct {
+	op       string // "AND" or "OR"
+	children []node
+	joined   []bool // joined[i]: children[i] follows an explicit operator
+	neg      bool
+}
+
+// disjunct reports whether children[i] is an alternative to a sibling when
+// the group is negated as neg says: joined to one by an explicit OR, or by
+// AND inside a negated group. Terms merely placed side by side are OR'd too,
+// but filters among them narrow the whole query by design.
+func (n *groupNode) disjunct(i int, neg bool) bool {
+	if len(n.children) < 2 || (n.op == "OR") == neg {
+		return false
+	}
+	if n.op == "AND" {
+		return true
+	}
+	return n.joined[i] || (i+1 < len(n.joined) && n.joined[i+1])
+}
+
+func (n *groupNode) fts(details bool) (string, bool) {
+	parts := make([]string, 0, len(n.children))
+	for _, c := range n.children {
+		s, ok := c.fts(details)
+		if !ok {
+			if n.op == "AND" {
+				return "", false
+			}
+			continue
+		}
+		parts = append(parts, s)
+	}
+	switch len(parts) {
+	case 0:
+		return "", false
+	case 1:
+		return parts[0], true
+	}
+	return "(" + strings.Join(parts, " "+n.op+" ") + ")", true
+}
+
+// ---------------------------------------------------------------------------
+// Lexer
+// ---------------------------------------------------------------------------
+
+type tokenKind int
+
+const (
+	tokTerm tokenKind = iota
+	tokAnd
+	tokOr
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // raw text, for error messages
+	field  string
+	value  string
+	phrase bool
+	star   bool
+	neg    bool
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	r := []rune(s)
+	for i := 0; i < len(r); {
+		switch c := r[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "("})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")"})
+			i++
+		case c == '-' && i+1 < len(r) && r[i+1] == '(':
+			toks = append(toks, token{kind: tokLParen, text: "-(", neg: true})
+			i += 2
+		default:
+			tok, next, err := lexTerm(r, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, tok)
+			i = next
+		}
+	}
+	return toks, nil
+}
+
+// lexTerm reads one term starting at r[i]: an optional "-", an optional
+// "field:" prefix and a bare word or quoted phrase.
+func lexTerm(r []rune, i int) (token, int, error) {
+	start := i
+	tok := token{kind: tokTerm}
+	if r[i] == '-' && i+1 < len(r) && !isBreak(r[i+1]) {
+		tok.neg = true
+		i++
+	}
+	// Optional field prefix: letters followed by ':' and a non-break rune.
+	j := i
+	for j < len(r) && r[j] >= 'a' && r[j] <= 'z' {
+		j++
+	}
+	if j > i && j < len(r) && r[j] == ':' {
+		name := string(r[i:j])
+		if scopedFields[name] || filterFields[name] {
+			tok.field = name
+			i = j + 1
+		}
+	}
+
+	if i < len(r) && r[i] == '"' {
+		end := i + 1
+		for end < len(r) && r[end] != '"' {
+			end++
+		}
+		if end >= len(r) {
+			return tok, 0, errors.New("unterminated quoted phrase")
+		}
+		tok.value = string(r[i+1 : end])
+		tok.phrase = true
+		i = end + 1
+		if i < len(r) && r[i] == '*' {
+			tok.star = true
+			i++
+		}
+	} else {
+		end := i
+		for end < len(r) && !isBreak(r[end]) {
+			end++
+		}
+		tok.value = string(r[i:end])
+		i = end
+		if v, ok := strings.CutSuffix(tok.value, "*"); ok && !filterFields[tok.field] {
+			tok.value, tok.star = v, true
+		}
+	}
+	tok.text = string(r[start:i])
+
+	if tok.field == "" && !tok.neg && !tok.phrase {
+		switch tok.value {
+		case "AND":
+			tok.kind = tokAnd
+		case "OR":
+			tok.kind = tokOr
+		}
+	}
+	if tok.kind == tokTerm && strings.TrimSpace(tok.value) == "" {
+		if tok.field != "" {
+			return tok, 0, fmt.Errorf("%s: needs a value", tok.field)
+		}
+		return tok, 0, fmt.Errorf("empty term %q", tok.text)
+	}
+	return tok, i, nil
+}
+
+func isBreak(c rune) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' || c == '"'
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+// ---------------------------------------------------------------------------
+
+// parser implements:
+//
+//	or    = and { ["OR"] and }
+//	and   = unary { "AND" unary }
+//	unary = term | "(" or ")" | "-(" or ")"
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.toks) {
+		return nil
+	}
+	return &p.toks[p.pos]
+}
+
+func (p *parser) parseOr() (node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []node{first}
+	joined := []bool{false}
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokRParen {
+			break
+		}
+		explicit := t.kind == tokOr
+		if explicit {
+			p.pos++
+		}
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+		joined = append(joined, explicit)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &groupNode{op: "OR", children: children, joined: joined}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []node{first}
+	for {
+		t := p.peek()
+		if t == nil || t.kind != tokAnd {
+			break
+		}
+		p.pos++
+		next, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &groupNode{op: "AND", children: children}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, errors.New("unexpected end of query")
+	}
+	p.pos++
+	switch t.kind {
+	case tokTerm:
+		if filterFields[t.field] {
+			return &filterNode{field: t.field, value: t.value, neg: t.neg}, nil
+		}
+		return &termNode{field: t.field, text: t.value, phrase: t.phrase, star: t.star, neg: t.neg}, nil
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.peek(); c == nil || c.kind != tokRParen {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		if !t.neg {
+			return inner, nil
+		}
+		if g, ok := inner.(*groupNode); ok {
+			g.neg = true
+			return g, nil
+		}
+		return &groupNode{op: "AND", children: []node{inner}, neg: true}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
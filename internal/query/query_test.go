@@ -0,0 +1,196 @@
+package query_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/query"
+)
+
+var now = time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+
+func compile(c *qt.C, s string) query.Compiled {
+	c.TB.Helper()
+	q, err := query.ParseAt(s, now)
+	c.Assert(err, qt.IsNil)
+	return q.Compile()
+}
+
+// ---------------------------------------------------------------------------
+// Parse / Compile
+// ---------------------------------------------------------------------------
+
+func TestCompile_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	matches := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"bare terms are prefix-matched and OR'd", "redis timeout", `("redis"* OR "timeout"*)`},
+		{"AND binds tighter than OR", "a b AND c", `("a"* OR ("b"* AND "c"*))`},
+		{"quoted phrase is exact", `"connection pool"`, `"connection pool"`},
+		{"field scope", `title:pool why:"data race"`, `(title : "pool"* OR why : "data race")`},
+		{"grouping", "(a OR b) AND c", `(("a"* OR "b"*) AND "c"*)`},
+		{"lowercase and is a term", "salt and pepper", `("salt"* OR "and"* OR "pepper"*)`},
+		{"unknown field prefix stays part of the term", "http://x", `"http://x"*`},
+		{"phrase directly after a word starts a new term", `a"b c"`, `("a"* OR "b c")`},
+		{"trailing star on a phrase prefix-matches its last word", `"connection po"*`, `"connection po"*`},
+		{"trailing star on a word is not doubled", "redis*", `"redis"*`},
+	}
+	for _, tc := range matches {
+		c.Run(tc.name, func(c *qt.C) {
+			got := compile(c, tc.in)
+			c.Assert(got.Match, qt.Equals, tc.want)
+			c.Assert(got.Where, qt.HasLen, 0)
+		})
+	}
+
+	c.Run("excluded term becomes a NOT IN predicate on both tables", func(c *qt.C) {
+		got := compile(c, "cache -redis")
+		c.Assert(got.Match, qt.Equals, `"cache"*`)
+		c.Assert(got.Where, qt.DeepEquals, []string{
+			"m.rowid NOT IN (SELECT rowid FROM memories_fts WHERE memories_fts MATCH ?)",
+			"m.rowid NOT IN (SELECT rowid FROM details_fts WHERE details_fts MATCH ?)",
+		})
+		c.Assert(got.Args, qt.DeepEquals, []any{`"redis"`, `"redis"`})
+	})
+
+	c.Run("excluded terms match whole words unless starred", func(c *qt.C) {
+		c.Assert(compile(c, "-redis*").Args[0], qt.Equals, `"redis"*`)
+		c.Assert(compile(c, "-title:redis").Args[0], qt.Equals, `title : "redis"`)
+	})
+
+	c.Run("negated group is excluded as a whole", func(c *qt.C) {
+		got := compile(c, "cache -(redis OR memcached*)")
+		c.Assert(got.Args[0], qt.Equals, `("redis" OR "memcached"*)`)
+	})
+
+	c.Run("filters in a negated group of alternatives are negated one by one", func(c *qt.C) {
+		got := compile(c, "cache -(redis category:bug)")
+		c.Assert(got.Where, qt.DeepEquals, []string{
+			"m.rowid NOT IN (SELECT rowid FROM memories_fts WHERE memories_fts MATCH ?)",
+			"m.rowid NOT IN (SELECT rowid FROM details_fts WHERE details_fts MATCH ?)",
+			"NOT (lower(m.category) = lower(?))",
+		})
+	})
+
+	c.Run("unscoped terms also search details", func(c *qt.C) {
+		got := compile(c, `timeout "stack trace"`)
+		c.Assert(got.DetailsMatch, qt.Equals, `("timeout"* OR "stack trace")`)
+	})
+
+	c.Run("summary field scopes never match details", func(c *qt.C) {
+		got := compile(c, "title:pool OR race")
+		c.Assert(got.Match, qt.Equals, `(title : "pool"* OR "race"*)`)
+		c.Assert(got.DetailsMatch, qt.Equals, `"race"*`)
+
+		got = compile(c, "title:pool AND race")
+		c.Assert(got.DetailsMatch, qt.Equals, "")
+	})
+
+	c.Run("details scope only matches details", func(c *qt.C) {
+		got := compile(c, "details:goroutine")
+		c.Assert(got.Match, qt.Equals, "")
+		c.Assert(got.DetailsMatch, qt.Equals, `"goroutine"*`)
+	})
+
+	c.Run("filters compile to predicates and leave the match untouched", func(c *qt.C) {
+		got := compile(c, "category:bug tag:auth token")
+		c.Assert(got.Match, qt.Equals, `"token"*`)
+		c.Assert(got.Where, qt.HasLen, 2)
+		c.Assert(got.Args, qt.DeepEquals, []any{"bug", "auth"})
+	})
+
+	c.Run("comma-separated values match any", func(c *qt.C) {
+		got := compile(c, "category:bug,decision")
+		c.Assert(got.Where, qt.DeepEquals, []string{"(lower(m.category) = lower(?) OR lower(m.category) = lower(?))"})
+		c.Assert(got.Args, qt.DeepEquals, []any{"bug", "decision"})
+	})
+
+	c.Run("a single required category is exposed for vector search", func(c *qt.C) {
+		c.Assert(compile(c, "category:Bug token").Category, qt.Equals, "bug")
+		c.Assert(compile(c, "category:bug,decision").Category, qt.Equals, "")
+		c.Assert(compile(c, "-category:bug").Category, qt.Equals, "")
+		c.Assert(compile(c, "category:bug category:decision").Category, qt.Equals, "")
+	})
+
+	c.Run("negated filter is wrapped in NOT", func(c *qt.C) {
+		got := compile(c, "-category:learning")
+		c.Assert(got.Match, qt.Equals, "")
+		c.Assert(got.Where, qt.DeepEquals, []string{"NOT (lower(m.category) = lower(?))"})
+	})
+
+	dates := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"since month is its first instant", "since:2025-01", "2025-01-01T00:00:00Z"},
+		{"since year", "since:2024", "2024-01-01T00:00:00Z"},
+		{"until day is the next midnight", "until:2025-03-15", "2025-03-16T00:00:00Z"},
+		{"until month is the next month", "until:2025-12", "2026-01-01T00:00:00Z"},
+		{"relative days", "since:30d", "2025-05-16T12:00:00Z"},
+		{"relative weeks", "since:2w", "2025-06-01T12:00:00Z"},
+		{"relative months", "since:6m", "2024-12-15T12:00:00Z"},
+	}
+	for _, tc := range dates {
+		c.Run(tc.name, func(c *qt.C) {
+			got := compile(c, tc.in)
+			c.Assert(got.Args, qt.DeepEquals, []any{tc.want})
+		})
+	}
+
+	c.Run("has:details", func(c *qt.C) {
+		got := compile(c, "has:details")
+		c.Assert(got.Where, qt.DeepEquals, []string{"EXISTS (SELECT 1 FROM memory_details WHERE memory_id = m.id)"})
+	})
+
+	c.Run("Text drops operators, scopes, filters and exclusions", func(c *qt.C) {
+		q, err := query.ParseAt(`title:pool AND "data race" -redis category:bug`, now)
+		c.Assert(err, qt.IsNil)
+		c.Assert(q.Text(), qt.Equals, "pool data race")
+		c.Assert(q.Terms(), qt.DeepEquals, []string{"pool", "data race"})
+	})
+
+	c.Run("blank query is empty", func(c *qt.C) {
+		q, err := query.ParseAt("   ", now)
+		c.Assert(err, qt.IsNil)
+		c.Assert(q.Empty(), qt.IsTrue)
+	})
+}
+
+func TestParse_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{"unterminated phrase", `"connection pool`},
+		{"missing closing parenthesis", "(a OR b"},
+		{"stray closing parenthesis", "a)"},
+		{"dangling AND", "a AND"},
+		{"leading OR", "OR a"},
+		{"filter without value", "category:"},
+		{"empty phrase", `""`},
+		{"invalid date", "since:last-tuesday"},
+		{"unknown has value", "has:cats"},
+		{"filter as an operand of OR", "category:bug OR tag:auth"},
+		{"filter as an operand of OR after a group", "(redis -cache) OR category:decision"},
+		{"group with a filter as an operand of OR", "(redis category:bug) OR cache"},
+		{"filter under AND in a negated group", "-(redis AND category:bug)"},
+		{"lone star", "redis *"},
+	}
+	for _, tc := range cases {
+		c.Run(tc.name, func(c *qt.C) {
+			_, err := query.ParseAt(tc.in, now)
+			c.Assert(err, qt.IsNotNil)
+			c.Assert(errors.Is(err, query.ErrSyntax), qt.IsTrue)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// Exec scores documents by running a local command. The command receives
+// {"query": "...", "documents": ["...", ...]} on stdin and must print
+// {"scores": [...]} with one score per document, in document order.
+type Exec struct {
+	Command []string
+	Timeout time.Duration
+}
+
+// NewExec returns an Exec reranker running command (program and arguments)
+// with a 30s timeout.
+func NewExec(command []string) *Exec {
+	return &Exec{Command: command, Timeout: 30 * time.Second}
+}
+
+// Rerank runs the command once for all documents.
+func (e *Exec) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	in, err := json.Marshal(map[string]any{"query": query, "documents": documents})
+	if err != nil {
+		return nil, fmt.Errorf("exec rerank: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.Command[0], e.Command[1:]...) // #nosec G204 -- the command is configured by the user in config.yaml
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("exec rerank: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	var resp struct {
+		Scores []float64 `json:"scores"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("exec rerank: decode output: %w", err)
+	}
+	if len(resp.Scores) != len(documents) {
+		return nil, fmt.Errorf("exec rerank: expected %d scores, got %d", len(documents), len(resp.Scores))
+	}
+	return resp.Scores, nil
+}
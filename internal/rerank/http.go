@@ -0,0 +1,103 @@
+package rerank
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-ports/echovault/internal/httpjson"
+)
+
+// TEI calls the /rerank endpoint of a Hugging Face text-embeddings-inference
+// server running a reranker model.
+type TEI struct {
+	BaseURL string
+	client  *http.Client
+}
+
+// NewTEI returns a TEI reranker with a 30s timeout.
+func NewTEI(baseURL string) *TEI {
+	return &TEI{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Rerank calls POST /rerank.
+func (t *TEI) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	reqBody := map[string]any{
+		"query": query,
+		"texts": documents,
+	}
+	var resp []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := httpjson.Do(ctx, t.client, http.MethodPost, t.BaseURL+"/rerank", nil, reqBody, &resp); err != nil {
+		return nil, fmt.Errorf("tei rerank: %w", err)
+	}
+	indexes := make([]int, len(resp))
+	scores := make([]float64, len(resp))
+	for i, r := range resp {
+		indexes[i], scores[i] = r.Index, r.Score
+	}
+	out, err := byIndex(len(documents), indexes, scores)
+	if err != nil {
+		return nil, fmt.Errorf("tei rerank: %w", err)
+	}
+	return out, nil
+}
+
+// Cohere calls a Cohere-compatible /v1/rerank endpoint, as served by Cohere,
+// Jina and local servers such as Infinity.
+type Cohere struct {
+	Model   string
+	APIKey  string // #nosec G117 -- APIKey is an intentional field name for the rerank API's authentication token
+	BaseURL string
+	client  *http.Client
+}
+
+// NewCohere returns a Cohere-compatible reranker with a 30s timeout.
+func NewCohere(model, apiKey, baseURL string) *Cohere {
+	return &Cohere{
+		Model:   model,
+		APIKey:  apiKey,
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Rerank calls POST /v1/rerank.
+func (c *Cohere) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	reqBody := map[string]any{
+		"model":     c.Model,
+		"query":     query,
+		"documents": documents,
+		"top_n":     len(documents),
+	}
+	var headers map[string]string
+	if c.APIKey != "" {
+		headers = map[string]string{"Authorization": "Bearer " + c.APIKey}
+	}
+	var resp struct {
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+		} `json:"results"`
+	}
+	if err := httpjson.Do(ctx, c.client, http.MethodPost, c.BaseURL+"/v1/rerank", headers, reqBody, &resp); err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	indexes := make([]int, len(resp.Results))
+	scores := make([]float64, len(resp.Results))
+	for i, r := range resp.Results {
+		indexes[i], scores[i] = r.Index, r.RelevanceScore
+	}
+	out, err := byIndex(len(documents), indexes, scores)
+	if err != nil {
+		return nil, fmt.Errorf("rerank: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,77 @@
+// Package rerank provides an interface and implementations for rerankers,
+// cross-encoder models that rescore search candidates against the query.
+package rerank
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-ports/echovault/internal/config"
+)
+
+// Reranker scores documents by their relevance to a query.
+type Reranker interface {
+	// Rerank returns one relevance score per document, in document order.
+	// Higher is more relevant.
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// New constructs a Reranker from the given config.
+// Returns (nil, nil) when the provider is "" or "none".
+func New(cfg *config.MemoryConfig) (Reranker, error) {
+	rc := cfg.Rerank
+	switch rc.Provider {
+	case "tei":
+		baseURL := rc.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:8080"
+		}
+		return NewTEI(baseURL), nil
+
+	case "jina":
+		baseURL := rc.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.jina.ai"
+		}
+		return NewCohere(rc.Model, rc.APIKey, baseURL), nil
+
+	case "cohere":
+		baseURL := rc.BaseURL
+		if baseURL == "" {
+			baseURL = "https://api.cohere.com"
+		}
+		return NewCohere(rc.Model, rc.APIKey, baseURL), nil
+
+	case "exec":
+		if len(rc.Command) == 0 {
+			return nil, fmt.Errorf("rerank provider exec: command is required")
+		}
+		return NewExec(rc.Command), nil
+
+	case "", "none":
+		return nil, nil
+
+	default:
+		return nil, fmt.Errorf("unknown rerank provider: %s", rc.Provider)
+	}
+}
+
+// byIndex fills a score slice of length n from (index, score) pairs returned
+// by an API, rejecting out-of-range indexes and missing documents.
+func byIndex(n int, indexes []int, scores []float64) ([]float64, error) {
+	out := make([]float64, n)
+	seen := make([]bool, n)
+	for i, idx := range indexes {
+		if idx < 0 || idx >= n {
+			return nil, fmt.Errorf("result index %d out of range [0, %d)", idx, n)
+		}
+		out[idx] = scores[i]
+		seen[idx] = true
+	}
+	for i, ok := range seen {
+		if !ok {
+			return nil, fmt.Errorf("no score for document %d", i)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package rerank_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/rerank"
+)
+
+// ---------------------------------------------------------------------------
+// New
+// ---------------------------------------------------------------------------
+
+func TestNew_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	tests := []struct {
+		provider string
+		command  []string
+		want     any
+	}{
+		{"tei", nil, &rerank.TEI{}},
+		{"jina", nil, &rerank.Cohere{}},
+		{"cohere", nil, &rerank.Cohere{}},
+		{"exec", []string{"scorer"}, &rerank.Exec{}},
+	}
+	for _, tt := range tests {
+		c.Run(tt.provider, func(c *qt.C) {
+			cfg := config.Default()
+			cfg.Rerank.Provider = tt.provider
+			cfg.Rerank.Command = tt.command
+			r, err := rerank.New(cfg)
+			c.Assert(err, qt.IsNil)
+			c.Assert(reflect.TypeOf(r), qt.Equals, reflect.TypeOf(tt.want))
+		})
+	}
+
+	c.Run("none disables reranking", func(c *qt.C) {
+		r, err := rerank.New(config.Default())
+		c.Assert(err, qt.IsNil)
+		c.Assert(r, qt.IsNil)
+	})
+}
+
+func TestNew_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("unknown provider", func(c *qt.C) {
+		cfg := config.Default()
+		cfg.Rerank.Provider = "magic"
+		_, err := rerank.New(cfg)
+		c.Assert(err, qt.ErrorMatches, "unknown rerank provider: magic")
+	})
+
+	c.Run("exec without command", func(c *qt.C) {
+		cfg := config.Default()
+		cfg.Rerank.Provider = "exec"
+		_, err := rerank.New(cfg)
+		c.Assert(err, qt.ErrorMatches, "rerank provider exec: command is required")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// TEI.Rerank
+// ---------------------------------------------------------------------------
+
+func TestTEIRerank_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	var got struct {
+		Query string   `json:"query"`
+		Texts []string `json:"texts"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/rerank")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		// TEI returns results sorted by score, not by input order.
+		_, _ = w.Write([]byte(`[{"index":1,"score":0.9},{"index":0,"score":0.2}]`))
+	}))
+	defer srv.Close()
+
+	scores, err := rerank.NewTEI(srv.URL+"/").Rerank(context.Background(), "why drop redis", []string{"a", "b"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(scores, qt.DeepEquals, []float64{0.2, 0.9})
+	c.Assert(got.Query, qt.Equals, "why drop redis")
+	c.Assert(got.Texts, qt.DeepEquals, []string{"a", "b"})
+}
+
+func TestTEIRerank_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("non-2xx response returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+		}))
+		defer srv.Close()
+
+		_, err := rerank.NewTEI(srv.URL).Rerank(context.Background(), "q", []string{"a"})
+		c.Assert(err, qt.ErrorMatches, "tei rerank: HTTP 503: model not loaded")
+	})
+
+	c.Run("missing document score returns error", func(c *qt.C) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`[{"index":0,"score":0.5}]`))
+		}))
+		defer srv.Close()
+
+		_, err := rerank.NewTEI(srv.URL).Rerank(context.Background(), "q", []string{"a", "b"})
+		c.Assert(err, qt.ErrorMatches, "tei rerank: no score for document 1")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Cohere.Rerank
+// ---------------------------------------------------------------------------
+
+func TestCohereRerank_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	var auth string
+	var got struct {
+		Model     string   `json:"model"`
+		Documents []string `json:"documents"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, qt.Equals, "/v1/rerank")
+		auth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_, _ = w.Write([]byte(`{"results":[{"index":2,"relevance_score":0.8},{"index":0,"relevance_score":0.5},{"index":1,"relevance_score":0.1}]}`))
+	}))
+	defer srv.Close()
+
+	scores, err := rerank.NewCohere("rerank-v3", "key", srv.URL).Rerank(context.Background(), "q", []string{"a", "b", "c"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(scores, qt.DeepEquals, []float64{0.5, 0.1, 0.8})
+	c.Assert(auth, qt.Equals, "Bearer key")
+	c.Assert(got.Model, qt.Equals, "rerank-v3")
+	c.Assert(got.Documents, qt.DeepEquals, []string{"a", "b", "c"})
+}
+
+func TestCohereRerank_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"results":[{"index":5,"relevance_score":0.8}]}`))
+	}))
+	defer srv.Close()
+
+	_, err := rerank.NewCohere("m", "", srv.URL).Rerank(context.Background(), "q", []string{"a"})
+	c.Assert(err, qt.ErrorMatches, `rerank: result index 5 out of range \[0, 1\)`)
+}
+
+// ---------------------------------------------------------------------------
+// Exec.Rerank
+// ---------------------------------------------------------------------------
+
+func TestExecRerank_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// The script scores documents by their position, ignoring the input.
+	r := rerank.NewExec([]string{"sh", "-c", `cat >/dev/null; echo '{"scores":[0.1,0.7]}'`})
+	scores, err := r.Rerank(context.Background(), "q", []string{"a", "b"})
+	c.Assert(err, qt.IsNil)
+	c.Assert(scores, qt.DeepEquals, []float64{0.1, 0.7})
+}
+
+func TestExecRerank_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("non-zero exit returns stderr", func(c *qt.C) {
+		r := rerank.NewExec([]string{"sh", "-c", "echo boom >&2; exit 3"})
+		_, err := r.Rerank(context.Background(), "q", []string{"a"})
+		c.Assert(err, qt.ErrorMatches, "exec rerank: exit status 3: boom")
+	})
+
+	c.Run("wrong number of scores", func(c *qt.C) {
+		r := rerank.NewExec([]string{"sh", "-c", `cat >/dev/null; echo '{"scores":[1]}'`})
+		_, err := r.Rerank(context.Background(), "q", []string{"a", "b"})
+		c.Assert(err, qt.ErrorMatches, "exec rerank: expected 2 scores, got 1")
+	})
+}
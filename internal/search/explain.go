@@ -0,0 +1,81 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Explanation records how a result's score was computed.
+type Explanation struct {
+	// Fusion is the method that combined the keyword and vector lists.
+	Fusion string
+	// FTS and Vector describe the result's entry in each list; nil when it
+	// was not in that list.
+	FTS, Vector *SourceScore
+	// FTSHits is the number of keyword results considered and
+	// VectorSearched whether the query was embedded; TieredSearch only
+	// embeds when FTSHits is below Options.MinFTS.
+	FTSHits        int
+	VectorSearched bool
+	// Fused is the score after fusion: the sum of each source's
+	// Weight*Normalized.
+	Fused float64
+	// Reranked is set when the reranker scored the result; Rerank is that
+	// score, which replaces Fused.
+	Reranked bool
+	Rerank   float64
+	// Base is the score before modifiers: the fused (or reranked) relevance.
+	Base float64
+	// Decay, CategoryBoost and ProjectBoost are the multipliers applied to
+	// Base (see Modifiers). Zero when modifiers were not applied.
+	Decay         float64
+	CategoryBoost float64
+	ProjectBoost  float64
+	// Terms are the query terms found in the memory's summary fields, tags
+	// or snippet. Stemmed matches (retry for retries) are not listed.
+	Terms []string
+}
+
+// SourceScore is a result's standing in the keyword or vector list.
+type SourceScore struct {
+	// Rank is the 1-based position in the list.
+	Rank int
+	// Raw is the keyword BM25 score (SQLite's rank negated, so higher is
+	// better, with detail matches weighted down) or the vector distance to
+	// the query (lower is better).
+	Raw float64
+	// Normalized is the value fusion weighs: the max-normalized score for
+	// weighted fusion, 1/(k+Rank) for RRF.
+	Normalized float64
+	Weight     float64
+}
+
+// Contribution is the source's share of the fused score.
+func (s SourceScore) Contribution() float64 { return s.Weight * s.Normalized }
+
+// matchedTerms returns the terms of q found in r: a bare term as a word
+// prefix, as FTS matches it, and a phrase as consecutive words.
+func matchedTerms(terms []string, r Result) []string {
+	text := strings.Join([]string{r.Title, r.What, r.Why, r.Impact, r.Tags, r.Snippet}, " ")
+	joined := " " + strings.Join(words(text), " ") + " "
+
+	var out []string
+	for _, t := range terms {
+		tw := words(t)
+		switch {
+		case len(tw) == 0:
+		case len(tw) == 1 && strings.Contains(joined, " "+tw[0]):
+			out = append(out, t)
+		case len(tw) > 1 && strings.Contains(joined, " "+strings.Join(tw, " ")+" "):
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// words splits s into lowercased letter and digit runs.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
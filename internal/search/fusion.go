@@ -0,0 +1,112 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Fusion combines ranked FTS and vector rows into a single ranking of at
+// most limit results. Both inputs are ordered best first; either may be
+// empty when that search did not run or found nothing.
+type Fusion interface {
+	Fuse(fts, vec []map[string]any, limit int) []Result
+}
+
+// Fusion methods accepted by NewFusion.
+const (
+	FusionWeighted = "weighted"
+	FusionRRF      = "rrf"
+)
+
+// NewFusion returns the fusion strategy named by method. The weights scale
+// the contribution of each list; k is the RRF rank constant (ignored for
+// weighted fusion, <= 0 means 60).
+func NewFusion(method string, ftsWeight, vecWeight float64, k int) (Fusion, error) {
+	switch method {
+	case "", FusionWeighted:
+		return Weighted{FTS: ftsWeight, Vector: vecWeight}, nil
+	case FusionRRF:
+		if k <= 0 {
+			k = 60
+		}
+		return RRF{K: k, FTS: ftsWeight, Vector: vecWeight}, nil
+	}
+	return nil, fmt.Errorf("unknown fusion method %q (want %s or %s)", method, FusionWeighted, FusionRRF)
+}
+
+// Weighted fuses by a weighted sum of max-normalized scores (see
+// MergeResults). When only one list has results it keeps its normalized
+// scores unweighted, so keyword-only searches still score up to 1.
+type Weighted struct {
+	FTS, Vector float64
+}
+
+// Fuse implements Fusion.
+func (w Weighted) Fuse(fts, vec []map[string]any, limit int) []Result {
+	switch {
+	case len(vec) == 0:
+		return MergeResults(fts, nil, 1, 0, limit)
+	case len(fts) == 0:
+		return MergeResults(nil, vec, 0, 1, limit)
+	}
+	return MergeResults(fts, vec, w.FTS, w.Vector, limit)
+}
+
+// RRF is Reciprocal Rank Fusion: each list contributes weight/(K+rank) for
+// every result it ranks (rank starting at 1). Only positions matter, so a
+// lone weak keyword hit no longer outranks strong semantic matches, and
+// scores are comparable across queries.
+type RRF struct {
+	K           int
+	FTS, Vector float64
+}
+
+// Fuse implements Fusion.
+func (r RRF) Fuse(fts, vec []map[string]any, limit int) []Result {
+	combined := make(map[string]*Result, len(fts)+len(vec))
+	add := func(rows []map[string]any, weight float64, vector bool) {
+		for i, row := range rows {
+			src := &SourceScore{Rank: i + 1, Raw: asFloat(row["score"]), Normalized: 1 / float64(r.K+i+1), Weight: weight}
+			if vector {
+				src.Raw = asFloat(row["distance"])
+			}
+			res, ok := combined[asString(row["id"])]
+			if !ok {
+				r := rowToResult(row)
+				r.Score = 0
+				res = &r
+				combined[r.ID] = res
+			}
+			res.Score += src.Contribution()
+			if vector {
+				res.Explain.Vector = src
+			} else {
+				res.Explain.FTS = src
+			}
+		}
+	}
+	add(fts, r.FTS, false)
+	add(vec, r.Vector, true)
+
+	results := make([]Result, 0, len(combined))
+	for _, res := range combined {
+		res.Explain.Fusion = FusionRRF
+		results = append(results, *res)
+	}
+	return rankResults(results, limit)
+}
+
+// rankResults sorts results by descending score and keeps the first limit.
+// Ties break on ID so that pages cut from the ranking are deterministic.
+func rankResults(results []Result, limit int) []Result {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if limit > 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
@@ -0,0 +1,152 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/go-ports/echovault/internal/db"
+)
+
+// DefaultDiversity is the MMR trade-off used when diversity is requested
+// without a configured value.
+const DefaultDiversity = 0.3
+
+// mmrPool is the number of top candidates re-selected for diversity. It does
+// not grow with the page window, so successive pages see the same selection.
+const mmrPool = 50
+
+// Candidate is an item considered for diversity-aware selection.
+type Candidate struct {
+	ID        string
+	Relevance float64
+	// Text is compared by term overlap when either candidate has no Vector.
+	Text   string
+	Vector []float32
+}
+
+// MMR orders candidates by Maximal Marginal Relevance: each step picks the
+// candidate maximising
+//
+//	(1-diversity)*relevance - diversity*max(similarity to those already picked)
+//
+// with relevance scaled to [0, 1]. The first fixed candidates count as
+// already picked and keep their positions. It returns candidate indexes in
+// their new order.
+func MMR(cands []Candidate, fixed int, diversity float64) []int {
+	fixed = min(fixed, len(cands))
+	order := make([]int, 0, len(cands))
+	for i := range fixed {
+		order = append(order, i)
+	}
+	var maxRel float64
+	for _, c := range cands[fixed:] {
+		maxRel = math.Max(maxRel, c.Relevance)
+	}
+	if maxRel <= 0 {
+		maxRel = 1
+	}
+
+	terms := make([]map[string]bool, len(cands))
+	for i, c := range cands {
+		terms[i] = termSet(c.Text)
+	}
+	// maxSim[i] is candidate i's highest similarity to any picked candidate.
+	maxSim := make([]float64, len(cands))
+	picked := make([]bool, len(cands))
+	pick := func(j int) {
+		picked[j] = true
+		for i := range cands {
+			if !picked[i] {
+				maxSim[i] = math.Max(maxSim[i], similarity(cands[i], cands[j], terms[i], terms[j]))
+			}
+		}
+	}
+	for i := range fixed {
+		pick(i)
+	}
+	for len(order) < len(cands) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, c := range cands {
+			if picked[i] {
+				continue
+			}
+			score := (1-diversity)*c.Relevance/maxRel - diversity*maxSim[i]
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		order = append(order, best)
+		pick(best)
+	}
+	return order
+}
+
+// similarity is the cosine similarity of two candidates' vectors, or the
+// Jaccard overlap of their terms when either lacks one.
+func similarity(a, b Candidate, ta, tb map[string]bool) float64 {
+	if len(a.Vector) > 0 && len(a.Vector) == len(b.Vector) {
+		return cosine(a.Vector, b.Vector)
+	}
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	var shared int
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// termSet returns the lowercased words of s, ignoring one-letter words.
+func termSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) > 1 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+// diversify re-selects the top mmrPool results by MMR, using stored vectors
+// where available. Results beyond the pool keep their order after it.
+func (o Options) diversify(database *db.DB, results []Result) []Result {
+	if o.Diversity <= 0 || len(results) < 2 {
+		return results
+	}
+	pool := results[:min(mmrPool, len(results))]
+	ids := make([]string, len(pool))
+	for i, r := range pool {
+		ids[i] = r.ID
+	}
+	// Missing vectors only weaken the similarity to term overlap.
+	vectors, _ := database.VectorsByID(ids)
+
+	cands := make([]Candidate, len(pool))
+	for i, r := range pool {
+		cands[i] = Candidate{ID: r.ID, Relevance: r.Score, Text: r.Title + " " + r.What, Vector: vectors[r.ID]}
+	}
+	out := make([]Result, 0, len(results))
+	for _, i := range MMR(cands, 0, o.Diversity) {
+		out = append(out, pool[i])
+	}
+	return append(out, results[len(pool):]...)
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Modifiers scale relevance scores by a memory's age, category and project,
+// so that, for example, yesterday's decision outranks a two-year-old context
+// note on the same subject.
+type Modifiers struct {
+	// HalfLifeDays maps a category to the age in days at which its scores
+	// are halved. The "default" entry applies to other categories; a missing
+	// or zero half-life disables decay.
+	HalfLifeDays map[string]float64
+	// CategoryBoost multiplies the scores of a category (1 when missing).
+	CategoryBoost map[string]float64
+	// Project is the current project; its memories are multiplied by
+	// ProjectBoost.
+	Project      string
+	ProjectBoost float64
+	// Now is the reference time for ages. Zero means time.Now().
+	Now time.Time
+}
+
+// Factors returns the multipliers applied to a memory of the given category
+// and project created at createdAt (RFC 3339). Each is 1 when it has no
+// effect.
+func (m *Modifiers) Factors(category, project, createdAt string) (decay, categoryBoost, projectBoost float64) {
+	decay, categoryBoost, projectBoost = 1, 1, 1
+	if m == nil {
+		return decay, categoryBoost, projectBoost
+	}
+	category = strings.ToLower(category)
+
+	halfLife, ok := m.HalfLifeDays[category]
+	if !ok {
+		halfLife = m.HalfLifeDays["default"]
+	}
+	if created, err := time.Parse(time.RFC3339, createdAt); err == nil && halfLife > 0 {
+		now := m.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		if age := now.Sub(created).Hours() / 24; age > 0 {
+			decay = math.Exp2(-age / halfLife)
+		}
+	}
+	if b, ok := m.CategoryBoost[category]; ok {
+		categoryBoost = b
+	}
+	if m.Project != "" && project == m.Project && m.ProjectBoost > 0 {
+		projectBoost = m.ProjectBoost
+	}
+	return decay, categoryBoost, projectBoost
+}
+
+// apply multiplies each result's score by its modifiers, records them in its
+// Explanation and re-sorts the results.
+func (m *Modifiers) apply(results []Result) []Result {
+	if m == nil || len(results) == 0 {
+		return results
+	}
+	for i := range results {
+		r := &results[i]
+		decay, cat, proj := m.Factors(r.Category, r.Project, r.CreatedAt)
+		r.Explain.Base = r.Score
+		r.Explain.Decay, r.Explain.CategoryBoost, r.Explain.ProjectBoost = decay, cat, proj
+		r.Score *= decay * cat * proj
+	}
+	return rankResults(results, 0)
+}
@@ -3,10 +3,15 @@ package search
 
 import (
 	"context"
-	"sort"
+	"log/slog"
+	"slices"
+	"strings"
 
+	"github.com/go-ports/echovault/internal/cursor"
 	"github.com/go-ports/echovault/internal/db"
 	"github.com/go-ports/echovault/internal/embeddings"
+	"github.com/go-ports/echovault/internal/query"
+	"github.com/go-ports/echovault/internal/rerank"
 )
 
 // Result is a single search hit with a combined relevance score.
@@ -24,134 +29,332 @@ type Result struct {
 	CreatedAt  string
 	HasDetails bool
 	FilePath   string
+	// Snippet is a short excerpt around the full-text match, with matched
+	// terms wrapped in **. Empty for vector-only and filter-only hits.
+	Snippet string
+	// PossiblyStale is set by the service layer when related files changed
+	// substantially, were deleted or were renamed since the memory was written.
+	PossiblyStale bool
+	// Explain breaks Score down into its components.
+	Explain Explanation
 }
 
 // MergeResults combines FTS5 and vector search results with weighted scoring.
-// ftsWeight defaults to 0.3, vecWeight to 0.7 when called from Tiered/HybridSearch.
+// It is the implementation of Weighted fusion.
 func MergeResults(fts, vec []map[string]any, ftsWeight, vecWeight float64, limit int) []Result {
+	ftsRaw := make([]float64, len(fts))
+	for i, row := range fts {
+		ftsRaw[i] = asFloat(row["score"])
+	}
 	normalizeRows(fts)
 	normalizeRows(vec)
 
 	// Combined map keyed by memory ID.
 	combined := make(map[string]*Result, len(fts)+len(vec))
 
-	for _, row := range fts {
+	for i, row := range fts {
 		r := rowToResult(row)
+		r.Explain.FTS = &SourceScore{Rank: i + 1, Raw: ftsRaw[i], Normalized: r.Score, Weight: ftsWeight}
 		r.Score = ftsWeight * r.Score
 		existing := r // copy
 		combined[r.ID] = &existing
 	}
-	for _, row := range vec {
+	for i, row := range vec {
 		r := rowToResult(row)
+		src := &SourceScore{Rank: i + 1, Raw: asFloat(row["distance"]), Normalized: r.Score, Weight: vecWeight}
 		if existing, ok := combined[r.ID]; ok {
 			existing.Score += vecWeight * r.Score
+			existing.Explain.Vector = src
 		} else {
 			r.Score = vecWeight * r.Score
+			r.Explain.Vector = src
 			cp := r
 			combined[r.ID] = &cp
 		}
 	}
 
-	// Collect and sort descending by score.
 	results := make([]Result, 0, len(combined))
 	for _, r := range combined {
+		r.Explain.Fusion = FusionWeighted
 		results = append(results, *r)
 	}
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	if limit > 0 && len(results) > limit {
-		return results[:limit]
-	}
-	return results
+	return rankResults(results, limit)
 }
 
 // defaultSearchLimit is the fallback result count when callers pass limit <= 0.
 const defaultSearchLimit = 20
 
-// TieredSearch runs FTS first and only embeds when results are sparse.
-// minFTS is the minimum number of FTS hits before skipping the embed call.
-// Pass minFTS=0 to use the default of 3.
-func TieredSearch(
-	ctx context.Context,
-	database *db.DB,
-	ep embeddings.Provider,
-	query string,
-	limit, minFTS int,
-	project, source string,
-) ([]Result, error) {
-	if limit <= 0 {
-		limit = defaultSearchLimit
-	}
-	if minFTS <= 0 {
-		minFTS = 3
-	}
+// Options tunes how TieredSearch and HybridSearch rank results.
+type Options struct {
+	// Fusion combines FTS and vector results. nil means 0.3/0.7 Weighted.
+	Fusion Fusion
+	// MinFTS is the number of FTS hits at which TieredSearch skips the
+	// embedding call. <= 0 means 3.
+	MinFTS int
+	// MinScore drops results whose final score is below it. With a
+	// reranker it applies to the reranker's scores: the results past
+	// RerankTopN have none and are kept only when every reranked result is.
+	MinScore float64
+	// Reranker, when set, rescores the top RerankTopN fused candidates
+	// against the query; their score becomes the reranker's. Rerank errors
+	// are logged and the fused order is kept.
+	Reranker   rerank.Reranker
+	RerankTopN int
+	// Diversity, between 0 and 1, re-selects the top candidates by Maximal
+	// Marginal Relevance so near-duplicate memories don't crowd out the rest
+	// (see MMR). 0 keeps the relevance order.
+	Diversity float64
+	// Modifiers, when set, scale scores by age, category and project after
+	// fusion and reranking. The reranked results stay ahead of the rest, as
+	// their scores are on another scale.
+	Modifiers *Modifiers
+	// Scope identifies the settings the options were built from. Paging
+	// cursors are only accepted under the Scope they were issued with, as
+	// other settings rank results differently.
+	Scope string
+}
 
-	ftsRows, err := database.FTSSearch(query, limit*2, project, source)
-	if err != nil {
-		return nil, err
+func (o Options) fusion() Fusion {
+	if o.Fusion == nil {
+		return Weighted{FTS: 0.3, Vector: 0.7}
 	}
+	return o.Fusion
+}
 
-	// Normalize FTS scores in-place.
-	normalizeRows(ftsRows)
-
-	// Enough FTS results — return without calling the embedding provider.
-	if len(ftsRows) >= minFTS {
-		return toResults(ftsRows[:clamp(limit, len(ftsRows))]), nil
+// candidates returns how many fused results to produce for a page window,
+// so that the reranker and MMR see their full candidate sets.
+func (o Options) candidates(window int) int {
+	n := window
+	if o.Reranker != nil {
+		n = max(n, o.RerankTopN)
 	}
+	if o.Diversity > 0 {
+		n = max(n, mmrPool)
+	}
+	return n
+}
 
-	// No embedding provider — FTS-only fallback.
-	if ep == nil {
-		return toResults(ftsRows[:clamp(limit, len(ftsRows))]), nil
+// finish reranks, applies score modifiers to and diversifies the fused
+// results and returns the first window of the final ranking. Reranker scores
+// are on another scale than fused ones, so the reranked head and the rest
+// are modified and diversified apart, the head first. ftsHits and
+// vectorSearched are recorded in each result's Explanation.
+func (o Options) finish(ctx context.Context, database *db.DB, text string, results []Result, window, ftsHits int, vectorSearched bool) []Result {
+	for i := range results {
+		e := &results[i].Explain
+		e.FTSHits, e.VectorSearched = ftsHits, vectorSearched
+		e.Fused = results[i].Score
+	}
+	n := o.rerank(ctx, text, results)
+	head := o.diversify(database, o.Modifiers.apply(results[:n]))
+	tail := o.diversify(database, o.Modifiers.apply(results[n:]))
+	results = append(append(make([]Result, 0, len(results)), head...), tail...)
+	if len(results) > window {
+		return results[:window]
 	}
+	return results
+}
 
-	// Sparse FTS — fall back to hybrid search, embedding errors are non-fatal.
-	vec, err := ep.Embed(ctx, query)
-	if err != nil {
-		return toResults(ftsRows[:clamp(limit, len(ftsRows))]), nil //nolint:nilerr // embedding errors are non-fatal; FTS results are returned as a fallback
+// rerank passes the top RerankTopN results through the reranker, reordering
+// them in place, and returns how many it rescored.
+func (o Options) rerank(ctx context.Context, text string, results []Result) int {
+	if o.Reranker == nil || o.RerankTopN <= 0 || text == "" || len(results) == 0 {
+		return 0
 	}
-	vecRows, err := database.VectorSearch(vec, limit*2, project, source)
+	head := results[:min(o.RerankTopN, len(results))]
+	docs := make([]string, len(head))
+	for i, r := range head {
+		docs[i] = rerankDocument(r)
+	}
+	scores, err := o.Reranker.Rerank(ctx, text, docs)
 	if err != nil {
-		return toResults(ftsRows[:clamp(limit, len(ftsRows))]), nil //nolint:nilerr // vector search errors are non-fatal; FTS results are returned as a fallback
+		slog.Warn("search: rerank failed, keeping fused order", "err", err)
+		return 0
+	}
+	for i := range head {
+		head[i].Score = scores[i]
+		head[i].Explain.Reranked, head[i].Explain.Rerank = true, scores[i]
 	}
+	rankResults(head, 0)
+	return len(head)
+}
 
-	return MergeResults(ftsRows, vecRows, 0.3, 0.7, limit), nil
+// rerankDocument is the text of r scored by the reranker.
+func rerankDocument(r Result) string {
+	parts := []string{r.Title, r.What}
+	for _, p := range []string{r.Why, r.Impact} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "\n")
 }
 
-// HybridSearch always runs both FTS and vector search (when ep != nil).
+// TieredSearch runs FTS first and only embeds when results are sparse, i.e.
+// fewer than opts.MinFTS. q uses the structured syntax of package query; its
+// filters also narrow the vector results.
+//
+// cur is "" for the first page or the cursor returned with the previous page;
+// the returned cursor is "" when there are no more results.
+func TieredSearch(
+	ctx context.Context,
+	database *db.DB,
+	ep embeddings.Provider,
+	q string,
+	limit int,
+	project, source, cur string,
+	opts Options,
+) ([]Result, string, error) {
+	minFTS := opts.MinFTS
+	if minFTS <= 0 {
+		minFTS = 3
+	}
+	fusion := opts.fusion()
+	return paginate(database, q, limit, project, source, cur, opts, func(parsed *query.Query, compiled query.Compiled, window int) ([]Result, error) {
+		n := opts.candidates(window)
+		ftsRows, err := database.QuerySearch(compiled, n*2, project, source)
+		if err != nil {
+			return nil, err
+		}
+		fuse := func(vecRows []map[string]any, vectorSearched bool) []Result {
+			fused := fusion.Fuse(ftsRows, vecRows, n)
+			return opts.finish(ctx, database, parsed.Text(), fused, window, len(ftsRows), vectorSearched)
+		}
+
+		// Enough FTS results, no embedding provider, or nothing to embed
+		// (filter-only query) — return without calling the provider.
+		if len(ftsRows) >= minFTS || ep == nil || parsed.Text() == "" {
+			return fuse(nil, false), nil
+		}
+
+		// Sparse FTS — fall back to hybrid search, embedding errors are non-fatal.
+		vec, err := ep.EmbedQuery(ctx, parsed.Text())
+		if err != nil {
+			return fuse(nil, false), nil //nolint:nilerr // embedding errors are non-fatal; FTS results are returned as a fallback
+		}
+		vecRows, err := database.VectorQuerySearch(vec, n*2, project, source, compiled)
+		if err != nil {
+			return fuse(nil, false), nil //nolint:nilerr // vector search errors are non-fatal; FTS results are returned as a fallback
+		}
+
+		return fuse(vecRows, true), nil
+	})
+}
+
+// HybridSearch always runs both FTS and vector search (when ep != nil and
+// the query has terms to embed). opts.MinFTS is ignored. Paging works as for
+// TieredSearch.
 func HybridSearch(
 	ctx context.Context,
 	database *db.DB,
 	ep embeddings.Provider,
-	query string,
+	q string,
 	limit int,
-	project, source string,
-) ([]Result, error) {
+	project, source, cur string,
+	opts Options,
+) ([]Result, string, error) {
+	fusion := opts.fusion()
+	return paginate(database, q, limit, project, source, cur, opts, func(parsed *query.Query, compiled query.Compiled, window int) ([]Result, error) {
+		n := opts.candidates(window)
+		ftsRows, err := database.QuerySearch(compiled, n*2, project, source)
+		if err != nil {
+			return nil, err
+		}
+
+		// FTS-only mode when no embedding provider or nothing to embed.
+		if ep == nil || parsed.Text() == "" {
+			return opts.finish(ctx, database, parsed.Text(), fusion.Fuse(ftsRows, nil, n), window, len(ftsRows), false), nil
+		}
+
+		vec, err := ep.EmbedQuery(ctx, parsed.Text())
+		if err != nil {
+			return nil, err
+		}
+		vecRows, err := database.VectorQuerySearch(vec, n*2, project, source, compiled)
+		if err != nil {
+			return nil, err
+		}
+
+		return opts.finish(ctx, database, parsed.Text(), fusion.Fuse(ftsRows, vecRows, n), window, len(ftsRows), true), nil
+	})
+}
+
+// rankFunc returns the top window results for a parsed query.
+type rankFunc func(parsed *query.Query, compiled query.Compiled, window int) ([]Result, error)
+
+// paginate parses q, restricts it to the memories visible when paging began
+// and cuts the page selected by cur out of the ranking produced by rank.
+// Each page re-ranks the first offset+limit+1 results, so scores and order
+// match those of earlier pages. Results scoring below opts.MinScore are
+// dropped, and those returned get the matched query terms in their
+// Explanation. Cursors are scoped to the query, filters and opts.Scope.
+func paginate(database *db.DB, q string, limit int, project, source, cur string, opts Options, rank rankFunc) ([]Result, string, error) {
 	if limit <= 0 {
 		limit = defaultSearchLimit
 	}
-	ftsRows, err := database.FTSSearch(query, limit*2, project, source)
-	if err != nil {
-		return nil, err
+	parsed, err := query.Parse(q)
+	if err != nil || parsed.Empty() {
+		return nil, "", err
 	}
 
-	// FTS-only mode when no embedding provider.
-	if ep == nil {
-		normalizeRows(ftsRows)
-		return toResults(ftsRows[:clamp(limit, len(ftsRows))]), nil
+	scope := cursor.Scope("search", q, project, source, opts.Scope)
+	pos := cursor.Cursor{Scope: scope}
+	if cur != "" {
+		if pos, err = cursor.Decode(cur, scope); err != nil {
+			return nil, "", err
+		}
+	} else if pos.Snapshot, err = database.MaxRowID(); err != nil {
+		return nil, "", err
 	}
 
-	vec, err := ep.Embed(ctx, query)
+	compiled := parsed.Compile()
+	compiled.Where = append(compiled.Where, "m.rowid <= ?")
+	compiled.Args = append(compiled.Args, pos.Snapshot)
+
+	results, err := rank(parsed, compiled, pos.Offset+limit+1)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
-	vecRows, err := database.VectorSearch(vec, limit*2, project, source)
-	if err != nil {
-		return nil, err
+	if opts.MinScore > 0 {
+		results = aboveMinScore(results, opts.MinScore)
+	}
+	if len(results) <= pos.Offset {
+		return nil, "", nil
 	}
+	results = results[pos.Offset:]
+	next := ""
+	if len(results) > limit {
+		results = results[:limit]
+		pos.Offset += limit
+		next = cursor.Encode(pos)
+	}
+	terms := parsed.Terms()
+	for i := range results {
+		results[i].Explain.Terms = matchedTerms(terms, results[i])
+	}
+	return results, next, nil
+}
 
-	return MergeResults(ftsRows, vecRows, 0.3, 0.7, limit), nil
+// aboveMinScore drops the results scoring below minScore. Results the
+// reranker did not score while it scored others rank below those and have no
+// score on its scale; they are kept only when every reranked result is.
+func aboveMinScore(results []Result, minScore float64) []Result {
+	reranked := slices.ContainsFunc(results, func(r Result) bool { return r.Explain.Reranked })
+	headPassed := true
+	kept := results[:0]
+	for _, r := range results {
+		switch {
+		case reranked && !r.Explain.Reranked:
+			if headPassed {
+				kept = append(kept, r)
+			}
+		case r.Score >= minScore:
+			kept = append(kept, r)
+		default:
+			headPassed = false
+		}
+	}
+	return kept
 }
 
 // ---------------------------------------------------------------------------
@@ -193,28 +396,10 @@ func rowToResult(row map[string]any) Result {
 		CreatedAt:  asString(row["created_at"]),
 		HasDetails: asBool(row["has_details"]),
 		FilePath:   asString(row["file_path"]),
+		Snippet:    asString(row["snippet"]),
 	}
 }
 
-// toResults converts a slice of db rows to []Result.
-func toResults(rows []map[string]any) []Result {
-	out := make([]Result, len(rows))
-	for i, r := range rows {
-		out[i] = rowToResult(r)
-	}
-	return out
-}
-
-func clamp(limit, n int) int {
-	if limit <= 0 {
-		return n
-	}
-	if limit < n {
-		return limit
-	}
-	return n
-}
-
 func asString(v any) string {
 	if v == nil {
 		return ""
@@ -1,12 +1,15 @@
 package search
 
 // White-box testing required: the score-normalization and type-coercion helpers
-// (normalizeRows, asString, asFloat, asBool, clamp) are unexported and drive
+// (normalizeRows, asString, asFloat, asBool) are unexported and drive
 // the correctness of merged search results. Their behaviour cannot be observed
 // through the public MergeResults API because that API returns only the final
-// ranked list, hiding intermediate score values and conversion details.
+// ranked list, hiding intermediate score values and conversion details. The
+// same holds for the rerank and MinScore steps of Options.finish and paginate.
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	qt "github.com/frankban/quicktest"
@@ -128,28 +131,84 @@ func TestAsBool_HappyPath(t *testing.T) {
 }
 
 // ---------------------------------------------------------------------------
-// clamp
+// matchedTerms
 // ---------------------------------------------------------------------------
 
-func TestClamp_HappyPath(t *testing.T) {
+func TestMatchedTerms_HappyPath(t *testing.T) {
 	c := qt.New(t)
 
-	cases := []struct {
-		name  string
-		limit int
-		n     int
-		want  int
-	}{
-		{"limit less than n", 3, 5, 3},
-		{"limit equal to n", 5, 5, 5},
-		{"limit greater than n", 7, 5, 5},
-		{"limit zero (no limit)", 0, 5, 5},
-		{"limit negative (no limit)", -1, 5, 5},
+	r := Result{
+		Title:   "Connection pool exhaustion",
+		What:    "Workers leaked pooled connections",
+		Tags:    `["postgres"]`,
+		Snippet: "raised **max_conns** to 50",
 	}
+	got := matchedTerms([]string{"pool", "conn", "postgres", "max_conns", "connection pool", "pool exhaustion leak", "redis"}, r)
+	c.Assert(got, qt.DeepEquals, []string{"pool", "conn", "postgres", "max_conns", "connection pool"})
+}
 
-	for _, tc := range cases {
-		c.Run(tc.name, func(c *qt.C) {
-			c.Assert(clamp(tc.limit, tc.n), qt.Equals, tc.want)
-		})
+// ---------------------------------------------------------------------------
+// Options.finish / aboveMinScore
+// ---------------------------------------------------------------------------
+
+// logitReranker scores documents by their title line on a scale unlike fused
+// scores: unbounded and possibly negative.
+type logitReranker map[string]float64
+
+func (r logitReranker) Rerank(_ context.Context, _ string, docs []string) ([]float64, error) {
+	scores := make([]float64, len(docs))
+	for i, d := range docs {
+		title, _, _ := strings.Cut(d, "\n")
+		scores[i] = r[title]
 	}
+	return scores, nil
+}
+
+func TestFinishRerankScale_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	fused := func() []Result {
+		return []Result{
+			{ID: "a", Title: "a", Score: 0.9},
+			{ID: "b", Title: "b", Score: 0.8},
+			{ID: "c", Title: "c", Score: 0.7},
+			{ID: "d", Title: "d", Score: 0.6, Project: "here"},
+			{ID: "e", Title: "e", Score: 0.5},
+		}
+	}
+	opts := Options{
+		Reranker:   logitReranker{"a": -3, "b": 5},
+		RerankTopN: 2,
+		Modifiers:  &Modifiers{Project: "here", ProjectBoost: 2},
+	}
+	ids := func(results []Result) []string {
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.ID
+		}
+		return out
+	}
+
+	c.Run("modifiers keep the reranked head ahead of the fused tail", func(c *qt.C) {
+		got := opts.finish(context.Background(), nil, "q", fused(), 10, 0, false)
+		c.Assert(ids(got), qt.DeepEquals, []string{"b", "a", "d", "c", "e"})
+		c.Assert(got[1].Score, qt.Equals, -3.0)
+		c.Assert(got[2].Score, qt.Equals, 1.2)
+	})
+
+	c.Run("MinScore keeps the tail when every reranked result passes", func(c *qt.C) {
+		got := opts.finish(context.Background(), nil, "q", fused(), 10, 0, false)
+		got[1].Score = 4
+		c.Assert(ids(aboveMinScore(got, 3)), qt.DeepEquals, []string{"b", "a", "d", "c", "e"})
+	})
+
+	c.Run("MinScore drops the tail below a reranked result that fails", func(c *qt.C) {
+		got := opts.finish(context.Background(), nil, "q", fused(), 10, 0, false)
+		c.Assert(ids(aboveMinScore(got, 1)), qt.DeepEquals, []string{"b"})
+	})
+
+	c.Run("without a reranker MinScore compares fused scores", func(c *qt.C) {
+		got := Options{}.finish(context.Background(), nil, "q", fused(), 10, 0, false)
+		c.Assert(ids(aboveMinScore(got, 0.65)), qt.DeepEquals, []string{"a", "b", "c"})
+	})
 }
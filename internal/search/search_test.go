@@ -2,6 +2,7 @@ package search_test
 
 import (
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 
@@ -102,3 +103,157 @@ func TestMergeResults_HappyPath(t *testing.T) {
 		c.Assert(r.HasDetails, qt.IsTrue)
 	})
 }
+
+func TestNewFusion_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("weighted keeps a lone list's normalized scores", func(c *qt.C) {
+		f, err := search.NewFusion(search.FusionWeighted, 0.3, 0.7, 0)
+		c.Assert(err, qt.IsNil)
+		got := f.Fuse([]map[string]any{row("a", 4.0), row("b", 2.0)}, nil, 10)
+		c.Assert(got, qt.HasLen, 2)
+		c.Assert(got[0].Score, qt.Equals, 1.0)
+		c.Assert(got[1].Score, qt.Equals, 0.5)
+	})
+
+	c.Run("rrf scores by rank, not magnitude", func(c *qt.C) {
+		f, err := search.NewFusion(search.FusionRRF, 1, 1, 60)
+		c.Assert(err, qt.IsNil)
+		fts := []map[string]any{row("weak", 0.001)}
+		vec := []map[string]any{row("strong", 0.9), row("weak", 0.1)}
+		got := f.Fuse(fts, vec, 10)
+		c.Assert(got, qt.HasLen, 2)
+		c.Assert(got[0].ID, qt.Equals, "weak")
+		r1, r2 := 61.0, 62.0 // k + rank
+		c.Assert(got[0].Score, qt.Equals, 1/r1+1/r2)
+		c.Assert(got[1].ID, qt.Equals, "strong")
+		c.Assert(got[1].Score, qt.Equals, 1/r1)
+	})
+
+	c.Run("rrf weights scale each list", func(c *qt.C) {
+		f, err := search.NewFusion(search.FusionRRF, 0, 1, 10)
+		c.Assert(err, qt.IsNil)
+		got := f.Fuse([]map[string]any{row("kw", 1)}, []map[string]any{row("sem", 1)}, 1)
+		c.Assert(got, qt.HasLen, 1)
+		c.Assert(got[0].ID, qt.Equals, "sem")
+	})
+}
+
+func TestFuse_Explain_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	vecRow := func(id string, distance float64) map[string]any {
+		r := row(id, 1-distance)
+		r["distance"] = distance
+		return r
+	}
+	fts := []map[string]any{row("a", 4.0), row("b", 2.0)}
+	vec := []map[string]any{vecRow("b", 0.2)}
+
+	c.Run("weighted records raw and normalized scores per source", func(c *qt.C) {
+		got := search.MergeResults(fts, vec, 0.3, 0.7, 10)
+		c.Assert(got[0].ID, qt.Equals, "b")
+		e := got[0].Explain
+		c.Assert(e.Fusion, qt.Equals, search.FusionWeighted)
+		c.Assert(*e.FTS, qt.DeepEquals, search.SourceScore{Rank: 2, Raw: 2.0, Normalized: 0.5, Weight: 0.3})
+		c.Assert(*e.Vector, qt.DeepEquals, search.SourceScore{Rank: 1, Raw: 0.2, Normalized: 1, Weight: 0.7})
+		c.Assert(e.FTS.Contribution()+e.Vector.Contribution(), qt.Equals, got[0].Score)
+		c.Assert(got[1].Explain.Vector, qt.IsNil)
+	})
+
+	c.Run("rrf records rank contributions", func(c *qt.C) {
+		f, err := search.NewFusion(search.FusionRRF, 1, 2, 10)
+		c.Assert(err, qt.IsNil)
+		got := f.Fuse([]map[string]any{row("a", 4.0)}, []map[string]any{vecRow("a", 0.25)}, 10)
+		e := got[0].Explain
+		c.Assert(e.Fusion, qt.Equals, search.FusionRRF)
+		c.Assert(e.FTS.Raw, qt.Equals, 4.0)
+		c.Assert(e.Vector.Raw, qt.Equals, 0.25)
+		k1 := 11.0
+		c.Assert(e.Vector.Normalized, qt.Equals, 1/k1)
+		c.Assert(e.Vector.Contribution(), qt.Equals, 2/k1)
+	})
+}
+
+func TestNewFusion_FailurePath(t *testing.T) {
+	c := qt.New(t)
+	_, err := search.NewFusion("borda", 1, 1, 0)
+	c.Assert(err, qt.ErrorMatches, `unknown fusion method "borda".*`)
+}
+
+func TestMMR_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("zero diversity keeps relevance order", func(c *qt.C) {
+		cands := []search.Candidate{
+			{ID: "a", Relevance: 0.5, Text: "x"},
+			{ID: "b", Relevance: 0.9, Text: "x"},
+		}
+		c.Assert(search.MMR(cands, 0, 0), qt.DeepEquals, []int{1, 0})
+	})
+
+	c.Run("vector near-duplicates are pushed down", func(c *qt.C) {
+		cands := []search.Candidate{
+			{ID: "a", Relevance: 1.0, Vector: []float32{1, 0}},
+			{ID: "a2", Relevance: 0.95, Vector: []float32{1, 0.01}},
+			{ID: "b", Relevance: 0.6, Vector: []float32{0, 1}},
+		}
+		c.Assert(search.MMR(cands, 0, 0.5), qt.DeepEquals, []int{0, 2, 1})
+	})
+
+	c.Run("term overlap is used without vectors", func(c *qt.C) {
+		cands := []search.Candidate{
+			{ID: "a", Relevance: 1.0, Text: "postgres migration batch one"},
+			{ID: "a2", Relevance: 0.9, Text: "postgres migration batch two"},
+			{ID: "b", Relevance: 0.5, Text: "redis eviction policy"},
+		}
+		c.Assert(search.MMR(cands, 0, 0.5), qt.DeepEquals, []int{0, 2, 1})
+	})
+
+	c.Run("fixed candidates stay first and count as picked", func(c *qt.C) {
+		cands := []search.Candidate{
+			{ID: "shown", Text: "postgres migration batch one"},
+			{ID: "dup", Relevance: 1.0, Text: "postgres migration batch two"},
+			{ID: "other", Relevance: 0.5, Text: "redis eviction policy"},
+		}
+		c.Assert(search.MMR(cands, 1, 0.5), qt.DeepEquals, []int{0, 2, 1})
+	})
+}
+
+func TestModifiersFactors_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	m := &search.Modifiers{
+		HalfLifeDays:  map[string]float64{"context": 100, "default": 0},
+		CategoryBoost: map[string]float64{"decision": 1.2, "context": 0.9},
+		Project:       "echovault",
+		ProjectBoost:  1.5,
+		Now:           now,
+	}
+	ago := func(days int) string { return now.AddDate(0, 0, -days).Format(time.RFC3339) }
+
+	c.Run("decay halves scores every half-life", func(c *qt.C) {
+		decay, cat, proj := m.Factors("context", "other", ago(200))
+		c.Assert(decay, qt.Equals, 0.25)
+		c.Assert(cat, qt.Equals, 0.9)
+		c.Assert(proj, qt.Equals, 1.0)
+	})
+
+	c.Run("categories without a half-life do not decay", func(c *qt.C) {
+		decay, cat, _ := m.Factors("Decision", "other", ago(700))
+		c.Assert(decay, qt.Equals, 1.0)
+		c.Assert(cat, qt.Equals, 1.2)
+	})
+
+	c.Run("current project is boosted", func(c *qt.C) {
+		_, _, proj := m.Factors("bug", "echovault", ago(1))
+		c.Assert(proj, qt.Equals, 1.5)
+	})
+
+	c.Run("nil modifiers have no effect", func(c *qt.C) {
+		var none *search.Modifiers
+		decay, cat, proj := none.Factors("context", "echovault", ago(700))
+		c.Assert([]float64{decay, cat, proj}, qt.DeepEquals, []float64{1, 1, 1})
+	})
+}
@@ -4,6 +4,8 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,17 +13,24 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-ports/echovault/internal/config"
+	"github.com/go-ports/echovault/internal/cursor"
 	"github.com/go-ports/echovault/internal/db"
 	"github.com/go-ports/echovault/internal/embeddings"
+	"github.com/go-ports/echovault/internal/filepaths"
 	"github.com/go-ports/echovault/internal/markdown"
 	"github.com/go-ports/echovault/internal/models"
+	queryparse "github.com/go-ports/echovault/internal/query"
 	"github.com/go-ports/echovault/internal/redaction"
+	"github.com/go-ports/echovault/internal/rerank"
 	"github.com/go-ports/echovault/internal/search"
+	"github.com/go-ports/echovault/internal/staleness"
 )
 
 // Service orchestrates all memory operations.
@@ -31,10 +40,21 @@ type Service struct {
 	Config     *config.MemoryConfig
 
 	database       *db.DB
-	embProvider    embeddings.Provider
+	chain          *embeddings.Chain
+	reranker       rerank.Reranker
 	ignorePatterns []*regexp.Regexp
 	vectorsOK      *bool
+	dimMismatch    bool                  // a dimension mismatch was seen since the last reindex
+	staleHead      string                // repository and commit staleFlags hold
+	staleFlags     map[string]staleEntry // possiblyStale results by memory ID
 	mu             sync.Mutex
+
+	// Background work started by StartBackgroundReindex and
+	// StartBackgroundEmbed.
+	reindexing atomic.Bool
+	draining   atomic.Bool // queued memories are being embedded
+	stops      []context.CancelFunc
+	background sync.WaitGroup
 }
 
 // New initialises a Service rooted at memoryHome.
@@ -58,6 +78,10 @@ func New(memoryHome string) (*Service, error) {
 	if err != nil {
 		return nil, fmt.Errorf("service.New: open db: %w", err)
 	}
+	database.ChunkAggregate = cfg.Embedding.ChunkAggregate
+	database.DistanceMetric = cfg.Embedding.DistanceMetric
+	database.Quantization = cfg.Embedding.Quantization
+	database.Rescore = cfg.Embedding.Rescore
 
 	return &Service{
 		MemoryHome: memoryHome,
@@ -67,8 +91,24 @@ func New(memoryHome string) (*Service, error) {
 	}, nil
 }
 
-// Close releases all resources held by the service.
+// Close releases all resources held by the service, stopping background
+// work first: a reindex resumes on the next reindex, and memories not yet
+// embedded stay queued.
 func (s *Service) Close() error {
+	s.mu.Lock()
+	stops := s.stops
+	s.mu.Unlock()
+	for _, stop := range stops {
+		stop()
+	}
+	s.background.Wait()
+	s.mu.Lock()
+	if s.chain != nil {
+		if err := s.chain.Close(); err != nil {
+			slog.Warn("Close: embedding provider", "err", err)
+		}
+	}
+	s.mu.Unlock()
 	return s.database.Close()
 }
 
@@ -76,19 +116,46 @@ func (s *Service) Close() error {
 // Lazy helpers
 // ---------------------------------------------------------------------------
 
-// embeddingProvider returns the Provider, lazily initialising it (thread-safe).
-func (s *Service) embeddingProvider(_ context.Context) (embeddings.Provider, error) {
+// embeddingChain returns the provider chain, lazily initialising it
+// (thread-safe). It is nil when embedding is disabled.
+func (s *Service) embeddingChain() (*embeddings.Chain, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.chain != nil {
+		return s.chain, nil
+	}
+	chain, err := embeddings.NewChain(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	s.chain = chain
+	return chain, nil
+}
+
+// embeddingProvider returns the Route embedding with the model of the
+// stored vectors, or nil when embedding is disabled.
+func (s *Service) embeddingProvider(_ context.Context) (*embeddings.Route, error) {
+	chain, err := s.embeddingChain()
+	if err != nil || chain == nil {
+		return nil, err
+	}
+	return chain.Route(s.modelID()), nil
+}
+
+// rerankerFor returns the Reranker, lazily initialising it (thread-safe).
+// It returns nil when reranking is disabled.
+func (s *Service) rerankerFor() (rerank.Reranker, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.embProvider != nil {
-		return s.embProvider, nil
+	if s.reranker != nil {
+		return s.reranker, nil
 	}
-	ep, err := embeddings.NewProvider(s.Config)
+	r, err := rerank.New(s.Config)
 	if err != nil {
 		return nil, err
 	}
-	s.embProvider = ep
-	return ep, nil
+	s.reranker = r
+	return r, nil
 }
 
 // getIgnorePatterns returns redaction patterns, lazily loaded from .memoryignore.
@@ -109,7 +176,8 @@ func (s *Service) getIgnorePatterns() []*regexp.Regexp {
 	return patterns
 }
 
-// vectorsAvailable checks whether the vec table exists, caching the result.
+// vectorsAvailable checks whether the vec table exists and was built with
+// the configured embedding model, caching the result.
 func (s *Service) vectorsAvailable() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -120,6 +188,10 @@ func (s *Service) vectorsAvailable() bool {
 	if err != nil {
 		ok = false
 	}
+	if ok {
+		stored, found, err := s.database.GetEmbeddingModel()
+		ok = err == nil && (!found || stored == s.modelID())
+	}
 	s.vectorsOK = &ok
 	return ok
 }
@@ -129,6 +201,240 @@ func (s *Service) setVectorsOK(ok bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.vectorsOK = &ok
+	if ok {
+		s.dimMismatch = false
+	}
+}
+
+// vectorsMismatched records that the stored vectors do not fit the
+// configured embedding model, as reported by err.
+func (s *Service) vectorsMismatched(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := false
+	s.vectorsOK = &ok
+	if errors.Is(err, db.ErrDimensionMismatch) {
+		s.dimMismatch = true
+	}
+}
+
+// modelID identifies the model new vectors are computed with as
+// "provider/model": the model of the stored vectors when a provider of the
+// chain serves it, else the primary provider's.
+func (s *Service) modelID() string {
+	primary := embeddings.ModelID(s.Config)
+	stored, found, err := s.database.GetEmbeddingModel()
+	if err != nil || !found || stored == primary {
+		return primary
+	}
+	for _, c := range embeddings.ChainConfigs(s.Config) {
+		if embeddings.ModelID(c) == stored {
+			return stored
+		}
+	}
+	return primary
+}
+
+// VectorStatus reports whether the stored vectors were built with the
+// configured embedding model. When they were not, search falls back to
+// keywords and new memories are saved without vectors until a reindex.
+func (s *Service) VectorStatus() models.VectorStatus {
+	st := models.VectorStatus{Configured: s.modelID(), Reindexing: s.reindexing.Load()}
+	stored, _, err := s.database.GetEmbeddingModel()
+	if err != nil {
+		slog.Warn("VectorStatus", "err", err)
+	}
+	st.Model = stored
+	switch provider := s.Config.Embedding.Provider; {
+	case provider == "" || provider == "none":
+		return st
+	case stored != "" && stored != st.Configured:
+		st.Stale = true
+		st.Warning = fmt.Sprintf("stored vectors were built with %s but the configured embedding model is %s", stored, st.Configured)
+	default:
+		s.mu.Lock()
+		mismatch := s.dimMismatch
+		s.mu.Unlock()
+		if !mismatch {
+			return st
+		}
+		st.Stale = true
+		st.Warning = "stored vectors have a different dimension than " + st.Configured + " returns"
+	}
+	if st.Reindexing {
+		st.Warning += "; semantic search is off until the background reindex finishes"
+	} else {
+		st.Warning += "; semantic search is off until 'memory reindex' rebuilds them"
+	}
+	return st
+}
+
+// StartBackgroundReindex rebuilds stale vectors in the background when
+// embedding.auto_reindex is set, reporting whether it started one. Close
+// stops it; being resumable, the next reindex continues where it stopped.
+func (s *Service) StartBackgroundReindex() bool {
+	if !s.Config.Embedding.AutoReindex || !s.VectorStatus().Stale || !s.reindexing.CompareAndSwap(false, true) {
+		return false
+	}
+	s.goBackground(func(ctx context.Context) {
+		defer s.reindexing.Store(false)
+		slog.Info("background reindex started", "model", s.modelID())
+		res, err := s.Reindex(ctx, false, nil)
+		if err != nil {
+			slog.Warn("background reindex failed", "err", err)
+			return
+		}
+		slog.Info("background reindex finished", "memories", res.Count, "embedded", res.Embedded)
+	})
+	return true
+}
+
+// StartBackgroundEmbed embeds the memories queued while the embedding
+// provider was unavailable in the background, reporting whether it started.
+// Stale vectors are left to a reindex. Close stops it; the memories not yet
+// embedded stay queued.
+func (s *Service) StartBackgroundEmbed() bool {
+	if s.reindexing.Load() || s.VectorStatus().Stale {
+		return false
+	}
+	if n, err := s.database.PendingCount(); err != nil || n == 0 {
+		return false
+	}
+	s.goBackground(func(ctx context.Context) {
+		r, err := s.embeddingProvider(ctx)
+		if err != nil || r == nil {
+			return
+		}
+		n, err := s.drainPending(ctx, r, 0)
+		if err != nil {
+			slog.Warn("background embed stopped", "embedded", n, "err", err)
+			return
+		}
+		slog.Info("background embed finished", "embedded", n)
+	})
+	return true
+}
+
+// goBackground runs fn in a goroutine whose context Close cancels before
+// waiting for it to return.
+func (s *Service) goBackground(fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.stops = append(s.stops, cancel)
+	s.mu.Unlock()
+	s.background.Go(func() {
+		defer cancel()
+		fn(ctx)
+	})
+}
+
+// Doctor checks the embedding setup: whether the provider answers, and
+// whether the vector index fits the configured model and distance metric.
+func (s *Service) Doctor(ctx context.Context) []models.DoctorCheck {
+	var checks []models.DoctorCheck
+	add := func(name string, ok bool, format string, args ...any) {
+		checks = append(checks, models.DoctorCheck{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	provider := s.Config.Embedding.Provider
+	if provider == "" || provider == "none" {
+		add("embedding provider", true, "none configured; search uses keywords only")
+	} else if chain, err := s.embeddingChain(); err != nil {
+		add("embedding provider", false, "%s: %v", embeddings.ModelID(s.Config), err)
+	} else {
+		for i, res := range chain.Probe(ctx) {
+			name := "embedding provider"
+			if i > 0 {
+				name = fmt.Sprintf("fallback provider %d", i)
+			}
+			if res.Err != nil {
+				add(name, false, "%s: %v", res.Model, res.Err)
+			} else {
+				add(name, true, "%s returns %d dimensions", res.Model, res.Dim)
+			}
+		}
+	}
+
+	dim, ok, err := s.database.GetEmbeddingDim()
+	switch st := s.VectorStatus(); {
+	case err != nil:
+		add("vector index", false, "%v", err)
+	case !ok:
+		add("vector index", true, "not built yet")
+	case st.Stale:
+		add("vector index", false, "%s", st.Warning)
+	default:
+		add("vector index", true, "%d dimensions, built with %s", dim, st.Model)
+	}
+
+	if layout, err := s.database.StoredLayout(); err != nil {
+		add("distance metric", false, "%v", err)
+	} else if ok {
+		configured := s.database.Layout()
+		if layout.Metric != configured.Metric {
+			add("distance metric", false, "%s in use, %s configured; run 'memory reindex' to switch", layout.Metric, configured.Metric)
+		} else {
+			add("distance metric", true, "%s", layout.Metric)
+		}
+		if in, want := describeStorage(layout), describeStorage(configured); in != want {
+			add("vector storage", false, "%s in use, %s configured; run 'memory reindex' to switch", in, want)
+		} else {
+			add("vector storage", true, "%s", in)
+		}
+	}
+
+	if target, ok, err := s.database.ReindexTarget(); err != nil {
+		add("reindex", false, "%v", err)
+	} else if ok && !s.reindexing.Load() {
+		add("reindex", false, "a reindex to %s was interrupted; run 'memory reindex' to finish it", target)
+	}
+
+	if n, err := s.database.PendingCount(); err != nil {
+		add("pending embeddings", false, "%v", err)
+	} else if n > 0 {
+		add("pending embeddings", false, "%d memories are waiting for vectors; run 'memory embed --pending'", n)
+	}
+	return checks
+}
+
+// Stats reports the size of the index and of the vectors in it.
+func (s *Service) Stats() (*models.IndexStats, error) {
+	st := &models.IndexStats{}
+	var err error
+	if st.Memories, err = s.database.CountMemories("", ""); err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	if st.Pending, err = s.database.PendingCount(); err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	vs, err := s.database.VectorStats()
+	if err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	st.Vectors, st.ChunkVectors, st.VectorBytes = vs.Vectors, vs.ChunkVectors, vs.Bytes
+	if st.Dim, _, err = s.database.GetEmbeddingDim(); err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	if st.Model, _, err = s.database.GetEmbeddingModel(); err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	layout, err := s.database.StoredLayout()
+	if err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	st.Metric, st.Storage = layout.Metric, describeStorage(layout)
+	if st.IndexBytes, err = s.database.FileSize(); err != nil {
+		return nil, fmt.Errorf("Stats: %w", err)
+	}
+	return st, nil
+}
+
+// describeStorage describes how vectors of layout l are stored.
+func describeStorage(l db.VectorLayout) string {
+	if l.Rescorable {
+		return l.Quantization + " with float copies for re-scoring"
+	}
+	return l.Quantization
 }
 
 // ---------------------------------------------------------------------------
@@ -153,11 +459,11 @@ func mergeTags(existing, extra []string) []string {
 }
 
 // ensureVectors sets up the vec table for the given embedding dimension.
-// Returns false when there is a dimension mismatch.
+// Returns false when the stored vectors have another dimension or model.
 func (s *Service) ensureVectors(embedding []float32) bool {
-	if err := s.database.EnsureVecTable(len(embedding)); err != nil {
-		if errors.Is(err, db.ErrDimensionMismatch) {
-			s.setVectorsOK(false)
+	if err := s.database.EnsureVecTable(len(embedding), s.modelID()); err != nil {
+		if errors.Is(err, db.ErrDimensionMismatch) || errors.Is(err, db.ErrModelMismatch) {
+			s.vectorsMismatched(err)
 		} else {
 			slog.Warn("ensureVectors", "err", err)
 		}
@@ -217,15 +523,13 @@ func (s *Service) shouldUseSemantic(mode string) bool {
 	case "always":
 		return true
 	}
-	// "auto": for Ollama, only use if the model is currently loaded.
-	if s.Config.Embedding.Provider == "ollama" {
-		baseURL := s.Config.Embedding.BaseURL
-		if baseURL == "" {
-			baseURL = "http://localhost:11434"
-		}
-		return embeddings.IsOllamaModelLoaded(s.Config.Embedding.Model, baseURL)
+	// "auto": only when a provider of the stored vectors' model is ready,
+	// which for Ollama means the model is loaded. The chain caches it.
+	r, err := s.embeddingProvider(context.Background())
+	if err != nil || r == nil {
+		return true
 	}
-	return true
+	return r.Ready()
 }
 
 // resultsToMaps converts search.Result values into the map format used by
@@ -236,6 +540,7 @@ func resultsToMaps(results []search.Result) []map[string]any {
 		out[i] = map[string]any{
 			"id":          r.ID,
 			"title":       r.Title,
+			"what":        r.What,
 			"category":    r.Category,
 			"tags":        r.Tags,
 			"project":     r.Project,
@@ -266,6 +571,10 @@ func (s *Service) Save(ctx context.Context, raw *models.RawMemoryInput, project
 	}
 
 	warnings := detailsWarnings(raw)
+	vectors := s.VectorStatus()
+	if vectors.Stale {
+		warnings = append(warnings, "Saved without a vector: "+vectors.Warning+".")
+	}
 
 	// Redact all text fields.
 	patterns := s.getIgnorePatterns()
@@ -280,6 +589,7 @@ func (s *Service) Save(ctx context.Context, raw *models.RawMemoryInput, project
 	if raw.Details != "" {
 		raw.Details = redaction.Redact(raw.Details, patterns)
 	}
+	raw.RelatedFiles = filepaths.NormalizeAll(raw.RelatedFiles, filepaths.CurrentRepoRoot())
 
 	// Dedup check via FTS.
 	dedupQuery := raw.Title + " " + raw.What
@@ -335,24 +645,20 @@ func (s *Service) Save(ctx context.Context, raw *models.RawMemoryInput, project
 			}
 
 			// Re-embed the updated memory (non-fatal).
-			if ep, err := s.embeddingProvider(ctx); err == nil && ep != nil {
-				tagsStr := strings.Join(mergedTags, " ")
-				embedText := fmt.Sprintf("%s %s %s %s %s", topTitle, raw.What, raw.Why, raw.Impact, tagsStr)
-				if embedding, embedErr := ep.Embed(ctx, embedText); embedErr == nil {
-					if s.ensureVectors(embedding) {
-						if mem, found, dbErr := s.database.GetMemory(existingID); dbErr == nil && found {
-							if rowid, ok := mem["rowid"].(int64); ok {
-								if err := s.database.InsertVector(rowid, embedding); err != nil {
-									slog.Warn("Save: re-embed insert vector", "err", err)
-								}
-							}
-						}
+			tagsStr := strings.Join(mergedTags, " ")
+			embedText := fmt.Sprintf("%s %s %s %s %s", topTitle, raw.What, raw.Why, raw.Impact, tagsStr)
+			if mem, found, dbErr := s.database.GetMemory(existingID); dbErr == nil && found {
+				if rowid, ok := mem["rowid"].(int64); ok {
+					var details string
+					if d, err := s.database.GetDetails(existingID); err == nil && d != nil {
+						details = d.Body
 					}
-				} else {
-					slog.Warn("Save: re-embed failed", "err", embedErr)
+					s.saveVectors(ctx, "Save: re-embed", vectors.Stale, rowid, embedText, details)
 				}
 			}
 
+			s.pinFileCommits(ctx, existingID)
+
 			return &models.SaveResult{
 				ID:       existingID,
 				FilePath: existingFilePath,
@@ -374,21 +680,12 @@ func (s *Service) Save(ctx context.Context, raw *models.RawMemoryInput, project
 	if err != nil {
 		return nil, fmt.Errorf("Save: insert memory: %w", err)
 	}
+	s.pinFileCommits(ctx, mem.ID)
 
 	// Embed (non-fatal).
-	if ep, epErr := s.embeddingProvider(ctx); epErr == nil && ep != nil {
-		tagsStr := strings.Join(mem.Tags, " ")
-		embedText := fmt.Sprintf("%s %s %s %s %s", mem.Title, mem.What, mem.Why, mem.Impact, tagsStr)
-		if embedding, embedErr := ep.Embed(ctx, embedText); embedErr == nil {
-			if !s.ensureVectors(embedding) {
-				slog.Warn("Save: vector dimension mismatch — run 'memory reindex' to rebuild")
-			} else if err := s.database.InsertVector(rowid, embedding); err != nil {
-				slog.Warn("Save: insert vector", "err", err)
-			}
-		} else {
-			slog.Warn("Save: embedding failed", "err", embedErr)
-		}
-	}
+	tagsStr := strings.Join(mem.Tags, " ")
+	embedText := fmt.Sprintf("%s %s %s %s %s", mem.Title, mem.What, mem.Why, mem.Impact, tagsStr)
+	s.saveVectors(ctx, "Save", vectors.Stale, rowid, embedText, raw.Details)
 
 	return &models.SaveResult{
 		ID:       mem.ID,
@@ -403,33 +700,92 @@ func (s *Service) Save(ctx context.Context, raw *models.RawMemoryInput, project
 // ---------------------------------------------------------------------------
 
 // Search runs tiered FTS + vector search, falling back to FTS-only when vectors
-// are unavailable or when useVectors is false.
+// are unavailable or when useVectors is false. cur is "" for the first page or
+// the cursor returned with the previous page; the returned cursor is "" when
+// there are no more results.
 //
 //revive:disable:flag-parameter
-func (s *Service) Search(ctx context.Context, query string, limit int, project, source string, useVectors bool) ([]search.Result, error) {
+func (s *Service) Search(ctx context.Context, query string, limit int, project, source, cur string, useVectors bool) ([]search.Result, string, error) {
+	return s.SearchWith(ctx, s.Config.Search, query, limit, project, source, cur, useVectors)
+}
+
+// SearchWith is Search ranked by sc instead of Config.Search, for callers
+// that override search settings per query.
+func (s *Service) SearchWith(ctx context.Context, sc config.SearchConfig, query string, limit int, project, source, cur string, useVectors bool) ([]search.Result, string, error) {
+	results, next, err := s.search(ctx, sc, query, limit, project, source, cur, useVectors)
+	if err != nil {
+		return nil, "", err
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	stale := s.possiblyStale(ctx, ids)
+	for i := range results {
+		results[i].PossiblyStale = stale[results[i].ID]
+	}
+	return results, next, nil
+}
+
+// search is Search without the staleness annotation.
+func (s *Service) search(ctx context.Context, sc config.SearchConfig, query string, limit int, project, source, cur string, useVectors bool) ([]search.Result, string, error) {
+	opts, err := s.searchOptions(sc)
+	if err != nil {
+		return nil, "", err
+	}
+	run := search.TieredSearch
+	if sc.Mode == "hybrid" {
+		run = search.HybridSearch
+	}
+
 	if !useVectors {
-		return search.HybridSearch(ctx, s.database, nil, query, limit, project, source)
+		return run(ctx, s.database, nil, query, limit, project, source, cur, opts)
 	}
 
 	if s.vectorsAvailable() {
-		ep, err := s.embeddingProvider(ctx)
-		if err != nil {
+		var ep embeddings.Provider
+		if r, err := s.embeddingProvider(ctx); err != nil {
 			slog.Warn("Search: embedding provider error", "err", err)
-			ep = nil
+		} else if r != nil {
+			ep = r
 		}
-		results, err := search.TieredSearch(ctx, s.database, ep, query, limit, 0, project, source)
-		if err == nil {
-			return results, nil
+		results, next, err := run(ctx, s.database, ep, query, limit, project, source, cur, opts)
+		if err == nil || errors.Is(err, queryparse.ErrSyntax) || errors.Is(err, cursor.ErrInvalid) {
+			return results, next, err
 		}
 		if errors.Is(err, db.ErrDimensionMismatch) {
-			s.setVectorsOK(false)
+			s.vectorsMismatched(err)
 		} else {
-			slog.Warn("Search: tiered search error", "err", err)
+			slog.Warn("Search: vector search error", "err", err)
 		}
 	}
 
 	// FTS-only fallback.
-	return search.TieredSearch(ctx, s.database, nil, query, limit, 0, project, source)
+	return run(ctx, s.database, nil, query, limit, project, source, cur, opts)
+}
+
+// searchOptions translates search settings into search.Options.
+func (s *Service) searchOptions(cfg config.SearchConfig) (search.Options, error) {
+	fusion, err := search.NewFusion(cfg.Fusion, cfg.FTSWeight, cfg.VectorWeight, cfg.RRFK)
+	if err != nil {
+		return search.Options{}, fmt.Errorf("search config: %w", err)
+	}
+	opts := search.Options{
+		Fusion:    fusion,
+		MinFTS:    cfg.MinFTS,
+		MinScore:  cfg.MinScore,
+		Diversity: cfg.Diversity,
+		Modifiers: s.modifiers(),
+		Scope:     s.rankingScope(cfg),
+	}
+	// Like embedding errors, a misconfigured reranker degrades to the fused
+	// ranking rather than failing the search.
+	if r, err := s.rerankerFor(); err != nil {
+		slog.Warn("Search: rerank provider error", "err", err)
+	} else if r != nil {
+		opts.Reranker, opts.RerankTopN = r, s.Config.Rerank.TopN
+	}
+	return opts, nil
 }
 
 //revive:enable:flag-parameter
@@ -440,18 +796,20 @@ func (s *Service) Search(ctx context.Context, query string, limit int, project,
 
 // GetContext returns memory summaries for context injection along with the
 // total count. semanticMode is one of "auto", "always", "never" (defaults to
-// the value in Config when empty).
+// the value in Config when empty). cur and the returned cursor page through
+// the results as for Search; recent memories only top up a query's results
+// on a single, final first page.
 //
 //revive:disable:flag-parameter
 func (s *Service) GetContext( //nolint:gocognit // complexity from multiple semantic modes
 	ctx context.Context,
 	limit int,
-	project, source, query, semanticMode string,
+	project, source, query, semanticMode, cur string,
 	topupRecent bool,
-) ([]map[string]any, int, error) {
+) ([]map[string]any, int, string, error) {
 	total, err := s.database.CountMemories(project, source)
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, "", err
 	}
 
 	// Normalise semantic mode.
@@ -466,44 +824,335 @@ func (s *Service) GetContext( //nolint:gocognit // complexity from multiple sema
 
 	if query != "" { //nolint:nestif // top-up logic requires checking seen IDs across both search and recent results
 		useVectors := s.shouldUseSemantic(semanticMode)
-		results, err := s.Search(ctx, query, limit, project, source, useVectors)
+		results, next, err := s.search(ctx, s.Config.Search, query, limit, project, source, cur, useVectors)
 		if err != nil {
-			return nil, total, err
+			return nil, total, "", err
 		}
 		out := resultsToMaps(results)
 
-		if topupRecent && len(out) < limit {
-			recent, err := s.database.ListRecent(limit, project, source)
-			if err == nil {
-				seen := make(map[string]bool, len(out))
-				for _, r := range out {
-					if id, ok := r["id"].(string); ok {
-						seen[id] = true
-					}
-				}
-				for _, r := range recent {
-					if id, ok := r["id"].(string); ok && seen[id] {
-						continue
-					}
-					out = append(out, r)
-					if len(out) >= limit {
-						break
-					}
-				}
+		if topupRecent && cur == "" && next == "" && len(out) < limit {
+			pool := limit
+			if s.Config.Search.Diversity > 0 {
+				pool = limit * 3
+			}
+			if recent, _, err := s.database.ListRecent(pool, project, source, ""); err == nil {
+				out = s.topUp(out, recent, limit)
 			}
 		}
-		return out, total, nil
+		s.annotateStale(ctx, out)
+		return out, total, next, nil
 	}
 
-	recent, err := s.database.ListRecent(limit, project, source)
+	recent, next, err := s.database.ListRecent(limit, project, source, cur)
 	if err != nil {
-		return nil, total, err
+		return nil, total, "", err
 	}
-	return recent, total, nil
+	s.annotateStale(ctx, recent)
+	return recent, total, next, nil
 }
 
 //revive:enable:flag-parameter
 
+// rankingScope fingerprints the settings that rank searches under cfg: cfg
+// itself, as amended per query, and the scoring and rerank settings.
+func (s *Service) rankingScope(cfg config.SearchConfig) string {
+	rr := s.Config.Rerank
+	return fmt.Sprintf("%+v %+v %s/%s/%d", cfg, s.Config.Scoring, rr.Provider, rr.Model, rr.TopN)
+}
+
+// modifiers returns the score modifiers of Config.Scoring. The current
+// project is the working directory's name, as for the CLI's --project flag.
+func (s *Service) modifiers() *search.Modifiers {
+	m := &search.Modifiers{
+		HalfLifeDays:  s.Config.Scoring.HalfLifeDays,
+		CategoryBoost: s.Config.Scoring.CategoryBoost,
+		ProjectBoost:  s.Config.Scoring.ProjectBoost,
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		m.Project = filepath.Base(cwd)
+	}
+	return m
+}
+
+// topUp appends recent memories not already in out until it holds limit.
+// They are ranked by the score modifiers (age, category, project), newest
+// first among equals. With search diversity enabled, they are then picked by
+// MMR against the memories already in out.
+func (s *Service) topUp(out, recent []map[string]any, limit int) []map[string]any {
+	seen := make(map[string]bool, len(out))
+	for _, r := range out {
+		if id, ok := r["id"].(string); ok {
+			seen[id] = true
+		}
+	}
+	fresh := make([]map[string]any, 0, len(recent))
+	for _, r := range recent {
+		if id, ok := r["id"].(string); ok && !seen[id] {
+			fresh = append(fresh, r)
+		}
+	}
+	mods := s.modifiers()
+	weight := make(map[string]float64, len(fresh))
+	for _, r := range fresh {
+		id, _ := r["id"].(string)
+		category, _ := r["category"].(string)
+		project, _ := r["project"].(string)
+		createdAt, _ := r["created_at"].(string)
+		decay, cat, proj := mods.Factors(category, project, createdAt)
+		weight[id] = decay * cat * proj
+	}
+	sort.SliceStable(fresh, func(i, j int) bool {
+		wi, _ := fresh[i]["id"].(string)
+		wj, _ := fresh[j]["id"].(string)
+		return weight[wi] > weight[wj]
+	})
+
+	if d := s.Config.Search.Diversity; d > 0 && len(fresh) > 1 {
+		rows := append(append(make([]map[string]any, 0, len(out)+len(fresh)), out...), fresh...)
+		ids := make([]string, len(rows))
+		for i, r := range rows {
+			ids[i], _ = r["id"].(string)
+		}
+		vectors, _ := s.database.VectorsByID(ids)
+		cands := make([]search.Candidate, len(rows))
+		for i, r := range rows {
+			title, _ := r["title"].(string)
+			what, _ := r["what"].(string)
+			// Newer memories are more relevant; out's entries are fixed.
+			rel := 1 - float64(i-len(out))/float64(len(fresh))
+			cands[i] = search.Candidate{ID: ids[i], Relevance: rel, Text: title + " " + what, Vector: vectors[ids[i]]}
+		}
+		order := search.MMR(cands, len(out), d)
+		diverse := make([]map[string]any, 0, len(fresh))
+		for _, i := range order[len(out):] {
+			diverse = append(diverse, rows[i])
+		}
+		fresh = diverse
+	}
+
+	for _, r := range fresh {
+		if len(out) >= limit {
+			break
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// ForFiles
+// ---------------------------------------------------------------------------
+
+// ForFiles returns memories whose related files match any of files, ranked by
+// category and recency. Absolute paths inside the current repository are
+// matched against their repo-relative form; globs and directory prefixes are
+// supported (see db.SearchByFiles).
+func (s *Service) ForFiles(files []string, limit int, project, source string) ([]map[string]any, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	paths := filepaths.NormalizeAll(files, filepaths.CurrentRepoRoot())
+	for i, p := range paths {
+		paths[i], _, _ = filepaths.ParseRef(p)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("ForFiles: at least one file path is required")
+	}
+	return s.database.SearchByFiles(paths, limit, project, source)
+}
+
+// ---------------------------------------------------------------------------
+// Staleness
+// ---------------------------------------------------------------------------
+
+// staleChecker returns a checker for the git repository containing the working
+// directory, or nil when there is no repository or git is unavailable.
+func staleChecker() *staleness.Checker {
+	root := filepaths.CurrentRepoRoot()
+	if root == "" {
+		return nil
+	}
+	chk, err := staleness.NewChecker(root)
+	if err != nil {
+		return nil
+	}
+	return chk
+}
+
+// pinFileCommits records the current HEAD commit against the related files of
+// memoryID (exact ID or prefix). Non-fatal: outside a git repository the files
+// simply stay unpinned and are never reported as stale.
+func (s *Service) pinFileCommits(ctx context.Context, memoryID string) {
+	// The memory's files or pins change, so cached staleness may be wrong.
+	s.mu.Lock()
+	s.staleHead = ""
+	s.mu.Unlock()
+
+	chk := staleChecker()
+	if chk == nil {
+		return
+	}
+	sha, err := chk.HeadSHA(ctx)
+	if err != nil {
+		slog.Debug("pinFileCommits: no HEAD commit", "err", err)
+		return
+	}
+	if err := s.database.SetFileCommit(memoryID, sha); err != nil {
+		slog.Warn("pinFileCommits", "err", err)
+	}
+}
+
+// checkStale runs chk over the pinned files of each memory in ids and returns
+// the drifted files keyed by memory ID. Memories with no drift are omitted.
+func (s *Service) checkStale(ctx context.Context, chk *staleness.Checker, ids []string) (map[string][]models.StaleFile, error) {
+	refs, err := s.database.FileRefs(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string][]models.StaleFile)
+	for id, files := range refs {
+		for _, ref := range files {
+			res, err := chk.Check(ctx, ref)
+			if err != nil {
+				return nil, err
+			}
+			if staleness.IsStale(res.State) {
+				out[id] = append(out[id], res)
+			}
+		}
+	}
+	return out, nil
+}
+
+// staleTTL is how long a possiblyStale result is reused while HEAD stays
+// put; uncommitted edits count too, so results do not live forever.
+const staleTTL = time.Minute
+
+// staleEntry is a cached possiblyStale result.
+type staleEntry struct {
+	stale bool
+	at    time.Time
+}
+
+// possiblyStale reports which of ids have related files that changed
+// substantially, were deleted or were renamed. Results are cached per memory
+// for the checked-out commit (see staleTTL), so that a long-running server
+// runs git only for memories it has not checked lately. Errors are logged
+// and yield no flags so that search never fails because of git.
+func (s *Service) possiblyStale(ctx context.Context, ids []string) map[string]bool {
+	flags := make(map[string]bool)
+	if len(ids) == 0 {
+		return flags
+	}
+	chk := staleChecker()
+	if chk == nil {
+		return flags
+	}
+	head, err := chk.HeadSHA(ctx)
+	if err != nil {
+		slog.Debug("possiblyStale", "err", err)
+		return flags
+	}
+	key := chk.Root + "@" + head
+
+	now := time.Now()
+	var unchecked []string
+	s.mu.Lock()
+	if s.staleHead != key {
+		s.staleHead, s.staleFlags = key, make(map[string]staleEntry)
+	}
+	for _, id := range ids {
+		if e, ok := s.staleFlags[id]; ok && now.Sub(e.at) < staleTTL {
+			flags[id] = e.stale
+		} else {
+			unchecked = append(unchecked, id)
+		}
+	}
+	s.mu.Unlock()
+	if len(unchecked) == 0 {
+		return flags
+	}
+
+	drift, err := s.checkStale(ctx, chk, unchecked)
+	if err != nil {
+		slog.Debug("possiblyStale", "err", err)
+		return flags
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range unchecked {
+		_, stale := drift[id]
+		flags[id] = stale
+		if s.staleHead == key {
+			s.staleFlags[id] = staleEntry{stale: stale, at: now}
+		}
+	}
+	return flags
+}
+
+// annotateStale sets "possibly_stale" on each row map.
+func (s *Service) annotateStale(ctx context.Context, rows []map[string]any) {
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		if id, ok := r["id"].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	stale := s.possiblyStale(ctx, ids)
+	for _, r := range rows {
+		id, _ := r["id"].(string)
+		r["possibly_stale"] = stale[id]
+	}
+}
+
+// Stale reports memories, optionally scoped to project, whose related files
+// were deleted, renamed, or changed by at least threshold (a fraction in
+// (0, 1]; <= 0 uses staleness.DefaultThreshold) since the memory was written.
+// Only memories pinned to commits of the repository containing the working
+// directory can be checked.
+func (s *Service) Stale(ctx context.Context, project string, threshold float64) ([]models.StaleMemory, error) {
+	root := filepaths.CurrentRepoRoot()
+	if root == "" {
+		return nil, fmt.Errorf("Stale: not inside a git repository")
+	}
+	chk, err := staleness.NewChecker(root)
+	if err != nil {
+		return nil, fmt.Errorf("Stale: %w", err)
+	}
+	if threshold > 0 {
+		chk.Threshold = threshold
+	}
+
+	rows, err := s.database.ListWithFileCommits(project)
+	if err != nil {
+		return nil, fmt.Errorf("Stale: %w", err)
+	}
+	ids := make([]string, len(rows))
+	for i, r := range rows {
+		ids[i], _ = r["id"].(string)
+	}
+	drift, err := s.checkStale(ctx, chk, ids)
+	if err != nil {
+		return nil, fmt.Errorf("Stale: %w", err)
+	}
+
+	out := make([]models.StaleMemory, 0, len(drift))
+	for _, r := range rows {
+		id, _ := r["id"].(string)
+		files, ok := drift[id]
+		if !ok {
+			continue
+		}
+		m := models.StaleMemory{ID: id, Files: files}
+		m.Title, _ = r["title"].(string)
+		m.Category, _ = r["category"].(string)
+		m.Project, _ = r["project"].(string)
+		m.CreatedAt, _ = r["created_at"].(string)
+		out = append(out, m)
+	}
+	return out, nil
+}
+
 // ---------------------------------------------------------------------------
 // GetDetails / Delete / CountMemories
 // ---------------------------------------------------------------------------
@@ -525,21 +1174,169 @@ func (s *Service) DeleteByFilter(project, category string, olderThanDays int) (i
 	return s.database.DeleteByFilter(project, category, before)
 }
 
-// reembedMemory re-generates and stores the embedding for an existing memory
-// identified by id. All errors are logged as warnings and do not block the caller.
-func (s *Service) reembedMemory(ctx context.Context, id, embedText string) {
-	ep, err := s.embeddingProvider(ctx)
-	if err != nil || ep == nil {
-		return
+// embedChunks embeds the chunks of a memory's details body; a blank body,
+// or chunking disabled, yields none.
+func (s *Service) embedChunks(ctx context.Context, ep embeddings.Provider, details string) ([][]float32, error) {
+	chunks := embeddings.Chunks(details, s.Config.Embedding.ChunkSize, s.Config.Embedding.ChunkOverlap)
+	if len(chunks) == 0 {
+		return nil, nil
 	}
-	embedding, err := ep.Embed(ctx, embedText)
+	return ep.EmbedBatch(ctx, chunks)
+}
+
+// storeVectors stores the summary embedding of the memory with the given
+// rowid, embeds and stores its detail chunks, and records what they were
+// computed from so that reindexing can skip it while nothing changes.
+func (s *Service) storeVectors(ctx context.Context, r *embeddings.Route, rowid int64, embedding []float32, embedText, details string) error {
+	if err := s.database.InsertVector(rowid, embedding); err != nil {
+		return err
+	}
+	chunks, err := s.embedChunks(ctx, r, details)
 	if err != nil {
-		slog.Warn("reembedMemory: embedding failed", "err", err)
-		return
+		return fmt.Errorf("embed details: %w", err)
 	}
-	if !s.ensureVectors(embedding) {
+	if err := s.database.InsertChunkVectors(rowid, chunks); err != nil {
+		return err
+	}
+	if err := s.database.SetEmbeddingState(rowid, s.embeddingState(r, embedText, details)); err != nil {
+		return err
+	}
+	return s.database.DequeueEmbedding(rowid)
+}
+
+// embeddingState identifies the vectors computed from embedText and details
+// by the model of r, the chunking settings and the document template.
+func (s *Service) embeddingState(r *embeddings.Route, embedText, details string) db.EmbeddingState {
+	ec := s.Config.Embedding
+	key := fmt.Appendf(nil, "%s\x00%s\x00%d\x00%d", embedText, details, ec.ChunkSize, ec.ChunkOverlap)
+	// The document template changes the vectors too; it is only part of the
+	// key when set so that vectors embedded without one stay current.
+	if doc := embeddings.PrefixesFor(r.Config).Document; doc != "" {
+		key = fmt.Appendf(key, "\x00%s", doc)
+	}
+	sum := sha256.Sum256(key)
+	return db.EmbeddingState{
+		Hash:  hex.EncodeToString(sum[:]),
+		Model: r.Model,
+	}
+}
+
+// saveVectors embeds the memory with the given rowid unless embedding is
+// disabled. When the provider cannot be set up, or stale is set because the
+// stored vectors no longer fit the configured model, the memory is queued
+// instead. Errors are logged as warnings prefixed with op.
+func (s *Service) saveVectors(ctx context.Context, op string, stale bool, rowid int64, embedText, details string) {
+	r, err := s.embeddingProvider(ctx)
+	switch {
+	case err != nil:
+		slog.Warn(op+": embedding provider, queued for later", "err", err)
+		s.queueEmbedding(op, rowid, "embedding provider: "+err.Error())
+	case r == nil:
+		// Embedding is disabled.
+	case stale:
+		s.queueEmbedding(op, rowid, "stale vectors")
+	default:
+		s.embedMemory(ctx, op, r, rowid, embedText, details)
+	}
+}
+
+// queueEmbedding queues the memory with the given rowid to be embedded
+// later, logging a failure as a warning prefixed with op.
+func (s *Service) queueEmbedding(op string, rowid int64, reason string) {
+	if err := s.database.QueueEmbedding(rowid, reason); err != nil {
+		slog.Warn(op+": queue embedding", "err", err)
+	}
+}
+
+// embedMemory embeds and stores the vectors of the memory with the given
+// rowid. When that fails the memory is queued to be embedded later, and on
+// success a few queued memories are embedded too. Errors are logged as
+// warnings prefixed with op and do not block the caller.
+func (s *Service) embedMemory(ctx context.Context, op string, r *embeddings.Route, rowid int64, embedText, details string) {
+	embedding, err := r.Embed(ctx, embedText)
+	if err == nil {
+		if !s.ensureVectors(embedding) {
+			slog.Warn(op + ": vector dimension mismatch — run 'memory reindex' to rebuild")
+			s.queueEmbedding(op, rowid, "vector dimension mismatch")
+			return
+		}
+		err = s.storeVectors(ctx, r, rowid, embedding, embedText, details)
+	}
+	if err != nil {
+		slog.Warn(op+": embedding failed, queued for later", "err", err)
+		s.queueEmbedding(op, rowid, err.Error())
 		return
 	}
+	if _, err := s.drainPending(ctx, r, drainBatch); err != nil {
+		slog.Warn(op+": embed pending memories", "err", err)
+	}
+}
+
+// drainBatch is how many queued memories a successful embedding pulls along.
+const drainBatch = 10
+
+// drainPending embeds up to limit memories queued after a failed embedding,
+// oldest first; limit <= 0 means all of them. It stops at the first failure,
+// which stays queued, and returns how many were embedded. It returns at
+// once while another drain is running.
+func (s *Service) drainPending(ctx context.Context, r *embeddings.Route, limit int) (int, error) {
+	if !s.draining.CompareAndSwap(false, true) {
+		return 0, nil
+	}
+	defer s.draining.Store(false)
+	pending, err := s.database.PendingEmbeddings(limit)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, mem := range pending {
+		rowid, ok := mem["rowid"].(int64)
+		if !ok {
+			continue
+		}
+		embedText, details := embedTextOf(mem)
+		embedding, err := r.Embed(ctx, embedText)
+		if err == nil {
+			if !s.ensureVectors(embedding) {
+				return n, errors.New("vector dimension mismatch — run 'memory reindex' to rebuild")
+			}
+			err = s.storeVectors(ctx, r, rowid, embedding, embedText, details)
+		}
+		if err != nil {
+			if qErr := s.database.QueueEmbedding(rowid, err.Error()); qErr != nil {
+				return n, qErr
+			}
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// embedTextOf returns the text a memory row is embedded from, and its
+// details body.
+func embedTextOf(mem map[string]any) (embedText, details string) {
+	tags := ""
+	if tagsRaw, ok := mem["tags"].(string); ok && tagsRaw != "" {
+		var tagSlice []string
+		if jsonErr := json.Unmarshal([]byte(tagsRaw), &tagSlice); jsonErr == nil {
+			tags = strings.Join(tagSlice, " ")
+		} else {
+			tags = tagsRaw
+		}
+	}
+	title, _ := mem["title"].(string)
+	what, _ := mem["what"].(string)
+	why, _ := mem["why"].(string)
+	impact, _ := mem["impact"].(string)
+	details, _ = mem["details"].(string)
+	return fmt.Sprintf("%s %s %s %s %s", title, what, why, impact, tags), details
+}
+
+// reembedMemory re-generates and stores the embeddings for an existing memory
+// identified by id and its details body. All errors are logged as warnings
+// and do not block the caller.
+func (s *Service) reembedMemory(ctx context.Context, id, embedText, details string) {
 	mem, found, err := s.database.GetMemory(id)
 	if err != nil || !found {
 		return
@@ -548,9 +1345,40 @@ func (s *Service) reembedMemory(ctx context.Context, id, embedText string) {
 	if !ok {
 		return
 	}
-	if err := s.database.InsertVector(rowid, embedding); err != nil {
-		slog.Warn("reembedMemory: insert vector", "err", err)
+	s.saveVectors(ctx, "reembedMemory", s.VectorStatus().Stale, rowid, embedText, details)
+}
+
+// EmbedPending embeds up to limit of the memories queued because their
+// vectors could not be computed, oldest first; limit <= 0 embeds all of
+// them. Memories without a vector that were never queued, such as those
+// saved while embedding was disabled, are queued first. It stops at the
+// first memory that fails, which stays queued; the result is returned along
+// with that error.
+func (s *Service) EmbedPending(ctx context.Context, limit int) (*models.EmbedPendingResult, error) {
+	r, err := s.embeddingProvider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("EmbedPending: embedding provider: %w", err)
+	}
+	if r == nil {
+		return nil, fmt.Errorf("EmbedPending: no embedding provider configured")
 	}
+	if st := s.VectorStatus(); st.Stale {
+		return nil, fmt.Errorf("EmbedPending: %s", st.Warning)
+	}
+
+	res := &models.EmbedPendingResult{}
+	if res.Queued, err = s.database.QueueMissingEmbeddings("no vector"); err != nil {
+		return nil, fmt.Errorf("EmbedPending: %w", err)
+	}
+	res.Embedded, err = s.drainPending(ctx, r, limit)
+	var countErr error
+	if res.Pending, countErr = s.database.PendingCount(); countErr != nil && err == nil {
+		err = countErr
+	}
+	if err != nil {
+		return res, fmt.Errorf("EmbedPending: %w", err)
+	}
+	return res, nil
 }
 
 // Replace fully overwrites an existing memory's content and re-embeds it.
@@ -569,6 +1397,7 @@ func (s *Service) Replace(ctx context.Context, id string, raw *models.RawMemoryI
 	if raw.Details != "" {
 		raw.Details = redaction.Redact(raw.Details, patterns)
 	}
+	raw.RelatedFiles = filepaths.NormalizeAll(raw.RelatedFiles, filepaths.CurrentRepoRoot())
 
 	found, err := s.database.ReplaceMemory(
 		id, raw.Title, raw.What, raw.Why, raw.Impact,
@@ -580,11 +1409,12 @@ func (s *Service) Replace(ctx context.Context, id string, raw *models.RawMemoryI
 	if !found {
 		return nil, fmt.Errorf("Replace: memory %q not found", id)
 	}
+	s.pinFileCommits(ctx, id)
 
 	// Re-embed the replaced memory (non-fatal).
 	tagsStr := strings.Join(raw.Tags, " ")
 	embedText := fmt.Sprintf("%s %s %s %s %s", raw.Title, raw.What, raw.Why, raw.Impact, tagsStr)
-	s.reembedMemory(ctx, id, embedText)
+	s.reembedMemory(ctx, id, embedText, raw.Details)
 
 	return &models.SaveResult{
 		ID:     id,
@@ -601,81 +1431,235 @@ func (s *Service) CountMemories(project, source string) (int, error) {
 // Reindex
 // ---------------------------------------------------------------------------
 
-// Reindex rebuilds the vector table using the current embedding provider.
-// progress is called with (current, total) after each memory is embedded; may be nil.
-func (s *Service) Reindex(ctx context.Context, progress func(current, total int)) (*models.ReindexResult, error) {
-	ep, err := s.embeddingProvider(ctx)
+// Reindex rebuilds the vector tables, memory summaries and detail chunks,
+// using the current embedding provider. The new vectors are staged next to
+// the live ones, which keep serving searches until they are swapped in at
+// the end; an interrupted reindex resumes where it stopped. Texts are sent
+// in batches of embedding.batch_size, embedding.concurrency requests at a
+// time.
+//
+// With changedOnly, memories whose content and model are unchanged since
+// they were last embedded keep their vectors instead of being re-embedded.
+// progress is called with (current, total) after each memory that needed
+// embedding is done; may be nil.
+func (s *Service) Reindex(ctx context.Context, changedOnly bool, progress func(current, total int)) (*models.ReindexResult, error) {
+	chain, err := s.embeddingChain()
 	if err != nil {
 		return nil, fmt.Errorf("Reindex: embedding provider: %w", err)
 	}
-	if ep == nil {
+	if chain == nil {
 		return nil, fmt.Errorf("Reindex: no embedding provider configured")
 	}
 
-	// Detect dimension from provider.
-	probe, err := ep.Embed(ctx, "dimension probe")
-	if err != nil {
-		return nil, fmt.Errorf("Reindex: probe embed: %w", err)
+	// Rebuild with the first model of the chain that answers, and detect
+	// its dimension.
+	var r *embeddings.Route
+	var probe []float32
+	var probeErrs []error
+	for _, m := range chain.Models() {
+		candidate := chain.Route(m)
+		v, err := candidate.Embed(ctx, "dimension probe")
+		if err != nil {
+			probeErrs = append(probeErrs, err)
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		r, probe = candidate, v
+		break
+	}
+	if r == nil {
+		return nil, fmt.Errorf("Reindex: probe embed: %w", errors.Join(probeErrs...))
 	}
 	dim := len(probe)
+	model := r.Model
 
-	// Rebuild vec table.
-	if err := s.database.DropVecTable(); err != nil {
-		return nil, fmt.Errorf("Reindex: drop vec table: %w", err)
-	}
-	if err := s.database.SetEmbeddingDim(dim); err != nil {
-		return nil, fmt.Errorf("Reindex: set embedding dim: %w", err)
-	}
-	if err := s.database.CreateVecTable(dim); err != nil {
-		return nil, fmt.Errorf("Reindex: create vec table: %w", err)
+	staged, err := s.database.BeginReindex(dim, model)
+	if err != nil {
+		return nil, fmt.Errorf("Reindex: %w", err)
 	}
 
-	// Re-embed all memories.
-	memories, err := s.database.ListAllForReindex()
+	// Current vectors can only be kept when their dimension still fits and
+	// they convert to the configured metric and quantization.
+	var current map[int64]db.EmbeddingState
+	reusable, err := s.database.CanStageCurrent()
 	if err != nil {
-		return nil, fmt.Errorf("Reindex: list memories: %w", err)
+		return nil, fmt.Errorf("Reindex: %w", err)
+	}
+	if liveDim, ok, err := s.database.GetEmbeddingDim(); err != nil {
+		return nil, fmt.Errorf("Reindex: %w", err)
+	} else if changedOnly && ok && liveDim == dim && reusable {
+		if current, err = s.database.EmbeddingStates(); err != nil {
+			return nil, fmt.Errorf("Reindex: %w", err)
+		}
 	}
-	total := len(memories)
 
-	for i, mem := range memories {
-		tags := ""
-		if tagsRaw, ok := mem["tags"].(string); ok && tagsRaw != "" {
-			var tagSlice []string
-			if jsonErr := json.Unmarshal([]byte(tagsRaw), &tagSlice); jsonErr == nil {
-				tags = strings.Join(tagSlice, " ")
-			} else {
-				tags = tagsRaw
-			}
+	result := &models.ReindexResult{Dim: dim, Model: r.Config.Embedding.Model}
+
+	// Memories saved or changed while reindexing are picked up by another
+	// pass, until a pass finds everything staged. Only the first pass
+	// reports progress.
+	for pass := 0; ; pass++ {
+		memories, err := s.database.ListAllForReindex()
+		if err != nil {
+			return nil, fmt.Errorf("Reindex: list memories: %w", err)
 		}
+		result.Count = len(memories)
 
-		title, _ := mem["title"].(string)
-		what, _ := mem["what"].(string)
-		why, _ := mem["why"].(string)
-		impact, _ := mem["impact"].(string)
-		embedText := fmt.Sprintf("%s %s %s %s %s", title, what, why, impact, tags)
+		jobs, reused, err := s.planReindex(r, memories, staged, current)
+		if err != nil {
+			return nil, err
+		}
+		if len(jobs) == 0 && reused == 0 {
+			break
+		}
+		result.Reused += reused
 
-		embedding, err := ep.Embed(ctx, embedText)
+		err = s.embedJobs(ctx, r, jobs, func(j *reindexJob) error {
+			if err := s.database.StageVectors(j.rowid, j.state, j.vectors[0], j.vectors[1:]); err != nil {
+				return fmt.Errorf("Reindex: %w", err)
+			}
+			staged[j.rowid] = j.state
+			result.Embedded++
+			if progress != nil && pass == 0 {
+				progress(result.Embedded, len(jobs))
+			}
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("Reindex: embed memory: %w", err)
+			return nil, err
 		}
+	}
+
+	if err := s.database.FinishReindex(dim, model); err != nil {
+		return nil, fmt.Errorf("Reindex: %w", err)
+	}
+	s.setVectorsOK(true)
+	return result, nil
+}
+
+// reindexJob is a memory Reindex needs to embed.
+type reindexJob struct {
+	rowid   int64
+	state   db.EmbeddingState
+	texts   []string    // summary text, then the detail chunks
+	vectors [][]float32 // one per text
+}
 
+// planReindex returns the memories Reindex still needs to embed. Memories
+// already staged are skipped, and those whose current vectors match are
+// staged as they are; their count is returned as reused.
+func (s *Service) planReindex(r *embeddings.Route, memories []map[string]any, staged, current map[int64]db.EmbeddingState) (jobs []*reindexJob, reused int, err error) {
+	ec := s.Config.Embedding
+	for _, mem := range memories {
 		rowid, ok := mem["rowid"].(int64)
 		if !ok {
 			continue
 		}
-		if err := s.database.InsertVector(rowid, embedding); err != nil {
-			return nil, fmt.Errorf("Reindex: insert vector: %w", err)
+		embedText, details := embedTextOf(mem)
+		st := s.embeddingState(r, embedText, details)
+
+		switch {
+		case staged[rowid] == st:
+		case current[rowid] == st:
+			if err := s.database.StageCurrent(rowid, st); err != nil {
+				return nil, 0, fmt.Errorf("Reindex: %w", err)
+			}
+			staged[rowid] = st
+			reused++
+		default:
+			jobs = append(jobs, &reindexJob{
+				rowid: rowid,
+				state: st,
+				texts: append([]string{embedText}, embeddings.Chunks(details, ec.ChunkSize, ec.ChunkOverlap)...),
+			})
 		}
+	}
+	return jobs, reused, nil
+}
 
-		if progress != nil {
-			progress(i+1, total)
+// embedJobs embeds the texts of jobs, grouped into batches of about
+// embedding.batch_size texts with up to embedding.concurrency batches in
+// flight, and hands each finished job to done on the calling goroutine.
+// Rate-limit responses pause all workers. The first error stops the work.
+func (s *Service) embedJobs(ctx context.Context, ep embeddings.Provider, jobs []*reindexJob, done func(*reindexJob) error) error {
+	ec := s.Config.Embedding
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type batchResult struct {
+		jobs []*reindexJob
+		err  error
+	}
+	batches := make(chan []*reindexJob)
+	results := make(chan batchResult)
+	var throttle embeddings.Throttle
+	var wg sync.WaitGroup
+	for range max(ec.Concurrency, 1) {
+		wg.Go(func() {
+			for batch := range batches {
+				var texts []string
+				for _, j := range batch {
+					texts = append(texts, j.texts...)
+				}
+				vecs, err := embeddings.EmbedBatches(ctx, ep, texts, ec.BatchSize, &throttle)
+				if err == nil {
+					for _, j := range batch {
+						j.vectors, vecs = vecs[:len(j.texts)], vecs[len(j.texts):]
+					}
+				}
+				select {
+				case results <- batchResult{batch, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+	}
+	go func() {
+		defer close(batches)
+		for _, batch := range groupJobs(jobs, ec.BatchSize) {
+			select {
+			case batches <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if r.err != nil {
+			return fmt.Errorf("Reindex: embed memories: %w", r.err)
+		}
+		for _, j := range r.jobs {
+			if err := done(j); err != nil {
+				return err
+			}
 		}
 	}
+	return ctx.Err()
+}
 
-	s.setVectorsOK(true)
-	return &models.ReindexResult{
-		Count: total,
-		Dim:   dim,
-		Model: s.Config.Embedding.Model,
-	}, nil
+// groupJobs groups consecutive jobs into batches of at least size texts,
+// except for the last one.
+func groupJobs(jobs []*reindexJob, size int) [][]*reindexJob {
+	var out [][]*reindexJob
+	var batch []*reindexJob
+	n := 0
+	for _, j := range jobs {
+		batch = append(batch, j)
+		if n += len(j.texts); n >= size {
+			out = append(out, batch)
+			batch, n = nil, 0
+		}
+	}
+	if len(batch) > 0 {
+		out = append(out, batch)
+	}
+	return out
 }
@@ -1,7 +1,13 @@
 package service
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
 
@@ -157,3 +163,115 @@ func TestResultsToMaps_HappyPath(t *testing.T) {
 		c.Assert(m["score"], qt.Equals, 0.85)
 	})
 }
+
+// ---------------------------------------------------------------------------
+// possiblyStale
+// ---------------------------------------------------------------------------
+
+func TestPossiblyStale_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	if _, err := exec.LookPath("git"); err != nil {
+		c.Skip("git not installed")
+	}
+
+	repo := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		c.Assert(err, qt.IsNil, qt.Commentf("git %v: %s", args, out))
+	}
+	file := filepath.Join(repo, "pool.go")
+	c.Assert(os.WriteFile(file, []byte("package pool\n\nconst size = 10\n"), 0o600), qt.IsNil)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "initial")
+	t.Chdir(repo)
+
+	home := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte("embedding:\n  provider: none\n"), 0o600), qt.IsNil)
+	svc, err := New(home)
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = svc.Close() })
+	res, err := svc.Save(context.Background(), &models.RawMemoryInput{
+		Title: "Pool size", What: "The pool holds 10 connections", RelatedFiles: []string{"pool.go"},
+	}, "proj")
+	c.Assert(err, qt.IsNil)
+	ids := []string{res.ID}
+
+	c.Assert(svc.possiblyStale(context.Background(), ids)[res.ID], qt.IsFalse)
+
+	// Rewriting the file is not seen while HEAD stays put...
+	c.Assert(os.WriteFile(file, []byte("package pool\n\nfunc Size() int { return 64 }\n"), 0o600), qt.IsNil)
+	c.Assert(svc.possiblyStale(context.Background(), ids)[res.ID], qt.IsFalse)
+
+	// ...but is once HEAD moves.
+	git("commit", "-qam", "rewrite")
+	c.Assert(svc.possiblyStale(context.Background(), ids)[res.ID], qt.IsTrue)
+}
+
+// ---------------------------------------------------------------------------
+// topUp
+// ---------------------------------------------------------------------------
+
+func TestGetContext_TopUpDiversity_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte("embedding:\n  provider: none\n"), 0o600), qt.IsNil)
+	svc, err := New(home)
+	c.Assert(err, qt.IsNil)
+	c.Cleanup(func() { _ = svc.Close() })
+	svc.Config.Search.Diversity = 0.5
+
+	// The memories share a title; only what tells the newest two apart
+	// from the oldest.
+	base := time.Now().UTC().Add(-time.Hour)
+	for i, what := range []string{
+		"Database migration finished for billing",
+		"Rolled out the pool change to staging",
+		"Rolled out the pool change to staging",
+	} {
+		at := base.Add(time.Duration(i) * time.Minute)
+		_, err := svc.database.InsertMemory(&models.Memory{
+			ID: fmt.Sprintf("m%d", i), Title: "Deploy notes", What: what, Category: "context",
+			Project: "proj", FilePath: "/vault/proj/session.md", CreatedAt: at, UpdatedAt: at,
+		}, "")
+		c.Assert(err, qt.IsNil)
+	}
+
+	rows, _, _, err := svc.GetContext(context.Background(), 2, "proj", "", "zzzz", "never", "", true)
+	c.Assert(err, qt.IsNil)
+	ids := make([]any, len(rows))
+	for i, r := range rows {
+		ids[i] = r["id"]
+	}
+	c.Assert(ids, qt.DeepEquals, []any{"m2", "m0"})
+
+	c.Run("recent memories repeating a search hit are passed over", func(c *qt.C) {
+		// Without vectors, MMR compares title and what: q2 repeats the hit's,
+		// q1 only its title.
+		for i, m := range []models.Memory{
+			{Title: "Queue notes", What: "Consumer lag fixed by raising partitions", Why: "zebra outage"},
+			{Title: "Queue notes", What: "Billing export moved to nightly"},
+			{Title: "Queue notes", What: "Consumer lag fixed by raising partitions"},
+		} {
+			at := base.Add(time.Duration(10+i) * time.Minute)
+			m.ID, m.Category, m.Project = fmt.Sprintf("q%d", i), "context", "queues"
+			m.FilePath, m.CreatedAt, m.UpdatedAt = "/vault/queues/session.md", at, at
+			_, err := svc.database.InsertMemory(&m, "")
+			c.Assert(err, qt.IsNil)
+		}
+
+		rows, _, _, err := svc.GetContext(context.Background(), 2, "queues", "", "zebra", "never", "", true)
+		c.Assert(err, qt.IsNil)
+		ids := make([]any, len(rows))
+		for i, r := range rows {
+			ids[i] = r["id"]
+		}
+		c.Assert(ids, qt.DeepEquals, []any{"q0", "q1"})
+	})
+}
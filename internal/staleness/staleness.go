@@ -0,0 +1,293 @@
+// Package staleness detects memories whose related files have drifted since
+// the memory was written, by diffing the recorded commit against the local
+// working tree with the git binary.
+package staleness
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-ports/echovault/internal/models"
+)
+
+// DefaultThreshold is the fraction of a file (or line range) that must have
+// changed before it is reported as substantially changed.
+const DefaultThreshold = 0.3
+
+// File states reported by Check.
+const (
+	StateUnchanged = "unchanged" // identical to the recorded commit
+	StateModified  = "modified"  // changed, but below the threshold
+	StateChanged   = "changed"   // changed at or above the threshold
+	StateDeleted   = "deleted"
+	StateRenamed   = "renamed"
+	StateUnknown   = "unknown" // no commit recorded, or the commit is not in this repository
+)
+
+// ErrNoGit is returned when the git binary cannot be found on PATH.
+var ErrNoGit = errors.New("git binary not found")
+
+// hunkRe matches the old-side coordinates of a unified diff hunk header.
+var hunkRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,(\d+))? @@`)
+
+// fileDiff is the numstat summary of one path between a commit and the worktree.
+type fileDiff struct {
+	added, deleted int
+	renamedTo      string
+}
+
+// Checker compares file references against a git working tree. Results of
+// git invocations are cached per commit, so a Checker should be short-lived
+// (one command or one request).
+type Checker struct {
+	Root      string
+	Threshold float64
+
+	commits map[string]bool
+	diffs   map[string]map[string]fileDiff
+}
+
+// NewChecker returns a Checker for the repository rooted at root.
+// Returns ErrNoGit when git is not installed.
+func NewChecker(root string) (*Checker, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, ErrNoGit
+	}
+	return &Checker{
+		Root:      root,
+		Threshold: DefaultThreshold,
+		commits:   make(map[string]bool),
+		diffs:     make(map[string]map[string]fileDiff),
+	}, nil
+}
+
+// HeadSHA returns the commit currently checked out in the repository.
+func (c *Checker) HeadSHA(ctx context.Context) (string, error) {
+	out, err := c.git(ctx, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsStale reports whether state means the memory should be double-checked.
+func IsStale(state string) bool {
+	return state == StateChanged || state == StateDeleted || state == StateRenamed
+}
+
+// Check reports how ref has drifted since its recorded commit.
+func (c *Checker) Check(ctx context.Context, ref models.FileRef) (models.StaleFile, error) {
+	res := models.StaleFile{FileRef: ref, State: StateUnknown}
+	if ref.CommitSHA == "" || !c.hasCommit(ctx, ref.CommitSHA) {
+		return res, nil
+	}
+	diffs, err := c.diff(ctx, ref.CommitSHA)
+	if err != nil {
+		return res, err
+	}
+
+	// Collect numstat entries for the path itself or, for a directory, every
+	// file beneath it.
+	var added, deleted, touched int
+	prefix := ref.Path + "/"
+	for p, fd := range diffs {
+		if p != ref.Path && !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if p == ref.Path && fd.renamedTo != "" {
+			res.State = StateRenamed
+			res.RenamedTo = fd.renamedTo
+			res.ChangeRatio = 1
+			return res, nil
+		}
+		added += fd.added
+		deleted += fd.deleted
+		touched++
+	}
+
+	if _, err := os.Stat(filepath.Join(c.Root, filepath.FromSlash(ref.Path))); errors.Is(err, os.ErrNotExist) {
+		res.State = StateDeleted
+		res.ChangeRatio = 1
+		return res, nil
+	}
+	if touched == 0 {
+		res.State = StateUnchanged
+		return res, nil
+	}
+
+	ratio, err := c.changeRatio(ctx, ref, added, deleted)
+	if err != nil {
+		return res, err
+	}
+	res.ChangeRatio = ratio
+	res.State = StateModified
+	if ratio >= c.Threshold {
+		res.State = StateChanged
+	}
+	return res, nil
+}
+
+// changeRatio returns the changed fraction of ref: of its line range when one
+// is recorded, otherwise of the whole file (or directory) at the commit.
+func (c *Checker) changeRatio(ctx context.Context, ref models.FileRef, added, deleted int) (float64, error) {
+	if ref.LineStart > 0 {
+		changed, err := c.changedInRange(ctx, ref)
+		if err != nil {
+			return 0, err
+		}
+		return clampRatio(float64(changed) / float64(ref.LineEnd-ref.LineStart+1)), nil
+	}
+	lines, err := c.linesAt(ctx, ref.CommitSHA, ref.Path)
+	if err != nil {
+		return 0, err
+	}
+	if lines == 0 {
+		return 1, nil
+	}
+	return clampRatio(float64(added+deleted) / float64(lines)), nil
+}
+
+// changedInRange counts lines inside ref's range (old-side coordinates) that
+// were rewritten, removed, or had new lines inserted among them.
+func (c *Checker) changedInRange(ctx context.Context, ref models.FileRef) (int, error) {
+	out, err := c.git(ctx, "diff", "-U0", "--no-color", ref.CommitSHA, "--", ref.Path)
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		m := hunkRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		oldStart, _ := strconv.Atoi(m[1])
+		oldCount := 1
+		if m[2] != "" {
+			oldCount, _ = strconv.Atoi(m[2])
+		}
+		newCount := 1
+		if m[3] != "" {
+			newCount, _ = strconv.Atoi(m[3])
+		}
+		if oldCount == 0 {
+			// Pure insertion after oldStart.
+			if oldStart >= ref.LineStart && oldStart < ref.LineEnd {
+				changed += newCount
+			}
+			continue
+		}
+		lo := max(oldStart, ref.LineStart)
+		hi := min(oldStart+oldCount-1, ref.LineEnd)
+		if hi >= lo {
+			changed += hi - lo + 1
+		}
+	}
+	return changed, nil
+}
+
+// linesAt counts the lines of path (a file or every file under a directory)
+// as of commit sha.
+func (c *Checker) linesAt(ctx context.Context, sha, path string) (int, error) {
+	out, err := c.git(ctx, "grep", "-c", "", sha, "--", path)
+	if err != nil {
+		// git grep exits 1 when nothing matched (e.g. an empty file).
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return 0, nil
+		}
+		return 0, err
+	}
+	total := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		idx := strings.LastIndexByte(line, ':')
+		if idx < 0 {
+			continue
+		}
+		n, _ := strconv.Atoi(line[idx+1:])
+		total += n
+	}
+	return total, nil
+}
+
+// hasCommit reports whether sha names a commit in the repository.
+func (c *Checker) hasCommit(ctx context.Context, sha string) bool {
+	if ok, cached := c.commits[sha]; cached {
+		return ok
+	}
+	_, err := c.git(ctx, "cat-file", "-e", sha+"^{commit}")
+	c.commits[sha] = err == nil
+	return err == nil
+}
+
+// diff returns per-path numstat entries between sha and the working tree,
+// with renames detected.
+func (c *Checker) diff(ctx context.Context, sha string) (map[string]fileDiff, error) {
+	if d, ok := c.diffs[sha]; ok {
+		return d, nil
+	}
+	out, err := c.git(ctx, "diff", "-M", "--numstat", "-z", sha, "--")
+	if err != nil {
+		return nil, err
+	}
+	d := parseNumstat(out)
+	c.diffs[sha] = d
+	return d, nil
+}
+
+// parseNumstat parses `git diff --numstat -z` output. Renames appear as
+// "added\tdeleted\t\0old\0new\0"; binary files report "-" counts, which are
+// treated as one changed line.
+func parseNumstat(out []byte) map[string]fileDiff {
+	d := make(map[string]fileDiff)
+	tokens := strings.Split(string(out), "\x00")
+	for i := 0; i < len(tokens); i++ {
+		fields := strings.SplitN(tokens[i], "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		fd := fileDiff{added: numstatCount(fields[0]), deleted: numstatCount(fields[1])}
+		path := fields[2]
+		if path == "" && i+2 < len(tokens) {
+			path = tokens[i+1]
+			fd.renamedTo = tokens[i+2]
+			i += 2
+		}
+		d[path] = fd
+	}
+	return d
+}
+
+func numstatCount(s string) int {
+	if s == "-" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func clampRatio(r float64) float64 {
+	if r > 1 {
+		return 1
+	}
+	return r
+}
+
+// git runs a git subcommand inside the repository root.
+func (c *Checker) git(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", c.Root}, args...)...) // #nosec G204 -- fixed binary; arguments are git subcommands, commit SHAs and repo paths
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
@@ -0,0 +1,173 @@
+package staleness_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/models"
+	"github.com/go-ports/echovault/internal/staleness"
+)
+
+// newRepo creates a git repository in a temp dir with the given files committed
+// and returns the checker and the commit SHA.
+func newRepo(c *qt.C, files map[string]string) (*staleness.Checker, string) {
+	c.TB.Helper()
+	root := c.TB.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		c.Assert(err, qt.IsNil, qt.Commentf("git %v: %s", args, out))
+	}
+	git("init", "-q")
+	for name, body := range files {
+		writeFile(c, root, name, body)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+
+	chk, err := staleness.NewChecker(root)
+	c.Assert(err, qt.IsNil)
+	sha, err := chk.HeadSHA(context.Background())
+	c.Assert(err, qt.IsNil)
+	return chk, sha
+}
+
+func writeFile(c *qt.C, root, name, body string) {
+	c.TB.Helper()
+	p := filepath.Join(root, filepath.FromSlash(name))
+	c.Assert(os.MkdirAll(filepath.Dir(p), 0o755), qt.IsNil)
+	c.Assert(os.WriteFile(p, []byte(body), 0o600), qt.IsNil)
+}
+
+// lines returns n numbered lines.
+func lines(n int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		b.WriteString("line ")
+		b.WriteString(strings.Repeat("x", i%7))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func TestCheck_HappyPath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	c := qt.New(t)
+	ctx := context.Background()
+
+	c.Run("untouched file is unchanged", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"a.go": lines(10)})
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateUnchanged)
+		c.Assert(staleness.IsStale(got.State), qt.IsFalse)
+	})
+
+	c.Run("small edit is modified but not stale", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"a.go": lines(20)})
+		writeFile(c, chk.Root, "a.go", lines(20)+"extra\n")
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateModified)
+		c.Assert(got.ChangeRatio, qt.Equals, 0.05)
+	})
+
+	c.Run("rewrite is changed", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"a.go": lines(10)})
+		writeFile(c, chk.Root, "a.go", "package rewritten\n")
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateChanged)
+		c.Assert(staleness.IsStale(got.State), qt.IsTrue)
+	})
+
+	c.Run("removed file is deleted", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"a.go": lines(10), "b.go": "b\n"})
+		c.Assert(os.Remove(filepath.Join(chk.Root, "a.go")), qt.IsNil)
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateDeleted)
+	})
+
+	c.Run("committed move is renamed", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"old/a.go": lines(30)})
+		cmd := exec.Command("git", "-C", chk.Root, "mv", "old/a.go", "new/a.go")
+		c.Assert(os.MkdirAll(filepath.Join(chk.Root, "new"), 0o755), qt.IsNil)
+		out, err := cmd.CombinedOutput()
+		c.Assert(err, qt.IsNil, qt.Commentf("%s", out))
+
+		got, err := chk.Check(ctx, models.FileRef{Path: "old/a.go", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateRenamed)
+		c.Assert(got.RenamedTo, qt.Equals, "new/a.go")
+	})
+
+	c.Run("line range only counts changes inside the range", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"a.go": lines(40)})
+		body := strings.Split(lines(40), "\n")
+		for i := 0; i < 10; i++ {
+			body[i] = "rewritten"
+		}
+		writeFile(c, chk.Root, "a.go", strings.Join(body, "\n"))
+
+		outside, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha, LineStart: 30, LineEnd: 40})
+		c.Assert(err, qt.IsNil)
+		c.Assert(outside.State, qt.Equals, staleness.StateModified)
+		c.Assert(outside.ChangeRatio, qt.Equals, 0.0)
+
+		inside, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: sha, LineStart: 1, LineEnd: 10})
+		c.Assert(err, qt.IsNil)
+		c.Assert(inside.State, qt.Equals, staleness.StateChanged)
+		c.Assert(inside.ChangeRatio, qt.Equals, 1.0)
+	})
+
+	c.Run("directory aggregates files beneath it", func(c *qt.C) {
+		chk, sha := newRepo(c, map[string]string{"pkg/a.go": lines(5), "pkg/b.go": lines(5)})
+		writeFile(c, chk.Root, "pkg/a.go", "gone\n")
+		got, err := chk.Check(ctx, models.FileRef{Path: "pkg", CommitSHA: sha})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateChanged)
+	})
+}
+
+func TestCheck_FailurePath(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	c := qt.New(t)
+	ctx := context.Background()
+
+	c.Run("missing commit SHA is unknown", func(c *qt.C) {
+		chk, _ := newRepo(c, map[string]string{"a.go": "a\n"})
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go"})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateUnknown)
+	})
+
+	c.Run("commit from another repository is unknown", func(c *qt.C) {
+		chk, _ := newRepo(c, map[string]string{"a.go": "a\n"})
+		got, err := chk.Check(ctx, models.FileRef{Path: "a.go", CommitSHA: strings.Repeat("ab", 20)})
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.State, qt.Equals, staleness.StateUnknown)
+		c.Assert(staleness.IsStale(got.State), qt.IsFalse)
+	})
+
+	c.Run("HeadSHA outside a repository returns error", func(c *qt.C) {
+		chk, err := staleness.NewChecker(c.TB.TempDir())
+		c.Assert(err, qt.IsNil)
+		_, err = chk.HeadSHA(ctx)
+		c.Assert(err, qt.IsNotNil)
+	})
+}
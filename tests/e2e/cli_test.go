@@ -7,6 +7,9 @@ package e2e_test
 import (
 	"bytes"
 	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -147,6 +150,175 @@ func TestSearch_HappyPath(t *testing.T) {
 	c.Assert(out, qt.Contains, "CGO required for sqlite")
 }
 
+func TestSearch_Structured_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	for _, m := range []struct{ title, category, tags string }{
+		{"Session token expiry bug", "bug", "auth"},
+		{"Session tokens stored in redis", "decision", "auth,redis"},
+		{"Session keys redistributed across shards", "pattern", "cluster"},
+	} {
+		_, err := runCmd(t, "--memory-home", home, "save",
+			"--title", m.title,
+			"--what", m.title,
+			"--category", m.category,
+			"--tags", m.tags,
+			"--project", "testproject",
+		)
+		c.Assert(err, qt.IsNil)
+	}
+
+	c.Run("inline filters narrow results", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "session category:bug tag:auth since:2020-01")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Session token expiry bug")
+		c.Assert(out, qt.Not(qt.Contains), "stored in redis")
+	})
+
+	c.Run("excluded term removes matches", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "session -redis")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Session token expiry bug")
+		c.Assert(out, qt.Contains, "redistributed across shards")
+		c.Assert(out, qt.Not(qt.Contains), "stored in redis")
+
+		out, err = runCmd(t, "--memory-home", home, "search", "session -redis*")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Session token expiry bug")
+		c.Assert(out, qt.Not(qt.Contains), "redistributed across shards")
+	})
+
+	c.Run("a filter cannot be an alternative to other terms", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "search", "category:bug OR tag:auth")
+		c.Assert(err, qt.ErrorMatches, `.*category:bug: a filter narrows the whole query and cannot be an alternative to other terms; list alternative values as category:a,b`)
+	})
+
+	c.Run("--set overrides the search config for one query", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "session", "--set", "fusion=rrf")
+		c.Assert(err, qt.IsNil)
+		// A single keyword list under RRF scores 0.3/(60+rank), before the
+		// category boost.
+		c.Assert(out, qt.Matches, `(?s).*\(score: 0\.0\d\).*`)
+		c.Assert(out, qt.Not(qt.Contains), "(score: 1.00)")
+
+		out, err = runCmd(t, "--memory-home", home, "search", "session", "--set", "min_score=2")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "No results found.")
+	})
+
+	c.Run("filter flags work without a query", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "--category", "decision", "--since", "30d")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "stored in redis")
+		c.Assert(out, qt.Not(qt.Contains), "expiry bug")
+	})
+}
+
+func TestSearch_Details_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	_, err := runCmd(t, "--memory-home", home, "save",
+		"--title", "Flaky CI job",
+		"--what", "The integration job fails intermittently",
+		"--details", "Root cause: the fixture server binds port 8080 before the previous run releases it.",
+		"--project", "testproject",
+	)
+	c.Assert(err, qt.IsNil)
+
+	out, err := runCmd(t, "--memory-home", home, "search", "fixture")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Flaky CI job")
+	c.Assert(out, qt.Contains, "Match: ")
+	c.Assert(out, qt.Contains, "**fixture**")
+}
+
+func TestSearch_Paging_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	for _, title := range []string{"Webhook retries", "Webhook signatures", "Webhook timeouts"} {
+		_, err := runCmd(t, "--memory-home", home, "save",
+			"--title", title, "--what", title, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	first, err := runCmd(t, "--memory-home", home, "search", "webhook", "--limit", "2")
+	c.Assert(err, qt.IsNil)
+	c.Assert(first, qt.Contains, "Results (2 found)")
+	c.Assert(first, qt.Contains, "rerun with --page 2")
+
+	second, err := runCmd(t, "--memory-home", home, "search", "webhook", "--limit", "2", "--page", "2")
+	c.Assert(err, qt.IsNil)
+	c.Assert(second, qt.Contains, "Results (1 found)")
+	c.Assert(second, qt.Not(qt.Contains), "More results")
+
+	// The cursor printed after page 1 reaches the same page.
+	idx := strings.Index(first, "--cursor ")
+	c.Assert(idx >= 0, qt.IsTrue)
+	cur := strings.TrimRight(strings.Fields(first[idx+len("--cursor "):])[0], ")")
+	viaCursor, err := runCmd(t, "--memory-home", home, "search", "webhook", "--limit", "2", "--cursor", cur)
+	c.Assert(err, qt.IsNil)
+	c.Assert(viaCursor, qt.Contains, "Results (1 found)")
+
+	_, err = runCmd(t, "--memory-home", home, "search", "webhook", "--page", "2", "--cursor", cur)
+	c.Assert(err, qt.IsNotNil)
+
+	// Other ranking settings rank differently, so the cursor is rejected.
+	_, err = runCmd(t, "--memory-home", home, "search", "webhook", "--limit", "2", "--cursor", cur, "--set", "fusion=rrf")
+	c.Assert(err, qt.ErrorMatches, "(?s).*invalid cursor: issued for a different query.*")
+}
+
+func TestSearch_CategoryBoost_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	for _, m := range []struct{ title, category string }{
+		{"Queue consumer notes", "context"},
+		{"Queue consumer choice", "decision"},
+	} {
+		_, err := runCmd(t, "--memory-home", home, "save",
+			"--title", m.title, "--what", "queue consumer", "--category", m.category, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	// Both match equally; boosting decisions puts it first.
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"),
+		[]byte("scoring:\n  category_boost:\n    decision: 1.2\n"), 0o600), qt.IsNil)
+	out, err := runCmd(t, "--memory-home", home, "search", "queue consumer")
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.Index(out, "Queue consumer choice") < strings.Index(out, "Queue consumer notes"), qt.IsTrue)
+
+	// Boosting context instead reverses the order.
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"),
+		[]byte("scoring:\n  category_boost:\n    decision: 1\n    context: 5\n"), 0o600), qt.IsNil)
+	out, err = runCmd(t, "--memory-home", home, "search", "queue consumer")
+	c.Assert(err, qt.IsNil)
+	c.Assert(strings.Index(out, "Queue consumer notes") < strings.Index(out, "Queue consumer choice"), qt.IsTrue)
+}
+
+func TestSearch_Explain_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	_, err := runCmd(t, "--memory-home", home, "save",
+		"--title", "Session token expiry bug", "--what", "Session tokens expired early",
+		"--category", "bug", "--project", "testproject")
+	c.Assert(err, qt.IsNil)
+
+	out, err := runCmd(t, "--memory-home", home, "search", "session token", "--explain")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Mode: tiered, min_fts 3 | keyword hits: 1")
+	c.Assert(out, qt.Matches, `(?s).*Explain: keyword #1 \(bm25 \S+, normalized 1 × weight 1 = 1\) = fused 1 \(weighted\).*`)
+	c.Assert(out, qt.Contains, "1 × decay 1 × category 1 × project 1 = 1")
+	c.Assert(out, qt.Contains, "terms: session, token")
+
+	out, err = runCmd(t, "--memory-home", home, "search", "session token")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Not(qt.Contains), "Explain:")
+}
+
 func TestSearch_EmptyVault_HappyPath(t *testing.T) {
 	c := qt.New(t)
 
@@ -165,6 +337,26 @@ func TestSearch_FailurePath(t *testing.T) {
 		_, err := runCmd(t, "--memory-home", home, "search")
 		c.Assert(err, qt.IsNotNil)
 	})
+
+	c.Run("malformed query returns syntax error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "search", `"unterminated`)
+		c.Assert(err, qt.ErrorMatches, `invalid query: .*`)
+	})
+
+	c.Run("invalid since flag returns error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "search", "x", "--since", "yesterday")
+		c.Assert(err, qt.ErrorMatches, `invalid query: since:yesterday: .*`)
+	})
+
+	c.Run("malformed --set returns error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "search", "x", "--set", "fusion")
+		c.Assert(err, qt.ErrorMatches, `--set "fusion": expected key=value`)
+	})
+
+	c.Run("unknown --set value returns error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "search", "x", "--set", "fusion=borda")
+		c.Assert(err, qt.ErrorMatches, `--set: fusion: "borda" is not weighted or rrf`)
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -283,3 +475,270 @@ func TestContext_EmptyVault_HappyPath(t *testing.T) {
 	c.Assert(err, qt.IsNil)
 	c.Assert(out, qt.Contains, "No memories found")
 }
+
+func TestContext_DiverseTopup_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	cfg := "embedding:\n  provider: none\nsearch:\n  diversity: 0.7\n"
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	for _, m := range []struct{ title, what string }{
+		{"Redis cache eviction policy", "Switched the cache to allkeys-lru"},
+		{"Postgres connection pool tuning", "Raised max connections for the pool"},
+		{"Postgres migration step one", "Migrating the orders table to Postgres with pgloader, batch one"},
+		{"Postgres migration step two", "Migrating the orders table to Postgres with pgloader, batch two"},
+		{"Postgres migration step three", "Migrating the orders table to Postgres with pgloader, batch three"},
+	} {
+		_, err := runCmd(t, "--memory-home", home, "save",
+			"--title", m.title, "--what", m.what, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	// Only the pool memory matches; the other two slots are topped up from
+	// recent memories, skipping near-duplicates of those already chosen.
+	out, err := runCmd(t, "--memory-home", home, "context", "--query", "tuning", "--limit", "3")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Postgres connection pool tuning")
+	c.Assert(out, qt.Contains, "Redis cache eviction policy")
+	c.Assert(strings.Count(out, "Postgres migration step"), qt.Equals, 1)
+}
+
+// ---------------------------------------------------------------------------
+// For-file
+// ---------------------------------------------------------------------------
+
+func TestForFile_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	_, saveErr := runCmd(t, "--memory-home", home, "save",
+		"--title", "Vec cleanup must precede row delete",
+		"--what", "DeleteMemory removes the vector row before the memory row",
+		"--category", "bug",
+		"--related-files", "internal/db/db.go,internal/service/service.go",
+		"--project", "testproject",
+	)
+	c.Assert(saveErr, qt.IsNil)
+
+	c.Run("exact path", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "for-file", "internal/db/db.go")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Vec cleanup must precede row delete")
+		c.Assert(out, qt.Contains, "Files: internal/db/db.go")
+	})
+
+	c.Run("directory prefix", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "for-file", "internal/service")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Vec cleanup must precede row delete")
+	})
+
+	c.Run("glob", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "for-file", "internal/*/db.go")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Vec cleanup must precede row delete")
+	})
+
+	c.Run("unrelated file", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "for-file", "cmd/memory/main.go")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "No memories found")
+	})
+}
+
+func TestForFile_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+
+	c.Run("missing path argument returns error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "for-file")
+		c.Assert(err, qt.IsNotNil)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Stale
+// ---------------------------------------------------------------------------
+
+// initGitRepo creates a git repository with the given files committed, and
+// changes the working directory into it for the rest of the test.
+func initGitRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", root}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com",
+		)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "initial")
+	t.Chdir(root)
+	return root
+}
+
+func TestStale_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	root := initGitRepo(t, map[string]string{
+		"cache.go": "package cache\n\nfunc Get() {}\n",
+		"store.go": "package store\n\nfunc Put() {}\n",
+	})
+	home := t.TempDir()
+
+	_, err := runCmd(t, "--memory-home", home, "save",
+		"--title", "Cache is write-through",
+		"--what", "Get never misses because Put populates the cache",
+		"--category", "decision",
+		"--related-files", filepath.Join(root, "cache.go"),
+		"--project", "testproject",
+	)
+	c.Assert(err, qt.IsNil)
+	_, err = runCmd(t, "--memory-home", home, "save",
+		"--title", "Store layout",
+		"--what", "Store keeps one file per key",
+		"--category", "context",
+		"--related-files", "store.go",
+		"--project", "testproject",
+	)
+	c.Assert(err, qt.IsNil)
+
+	c.Run("nothing is stale before the code changes", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "stale")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "No stale memories found")
+	})
+
+	c.Assert(os.Remove(filepath.Join(root, "cache.go")), qt.IsNil)
+
+	c.Run("deleted file is reported", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "stale")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Cache is write-through")
+		c.Assert(out, qt.Contains, "cache.go: deleted")
+		c.Assert(out, qt.Not(qt.Contains), "Store layout")
+	})
+
+	c.Run("search flags the memory as possibly stale", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "cache")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "[possibly stale]")
+	})
+}
+
+func TestStale_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	t.Chdir(t.TempDir())
+	home := t.TempDir()
+
+	c.Run("outside a git repository returns error", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "stale")
+		c.Assert(err, qt.ErrorMatches, ".*not inside a git repository.*")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Config migrate-key
+// ---------------------------------------------------------------------------
+
+func TestConfigMigrateKey_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	// writeConfig writes a config holding literal keys and returns its path.
+	writeConfig := func(c *qt.C, home string) string {
+		path := filepath.Join(home, "config.yaml")
+		cfg := "embedding:\n" +
+			"  provider: openai\n" +
+			"  model: text-embedding-3-small\n" +
+			"  api_key: 'sk-abc'   # primary\n" +
+			"  fallbacks:\n" +
+			"    - provider: cohere\n" +
+			"      model: embed-english-v3.0\n" +
+			"      api_key: \"co-xyz\"\n"
+		c.Assert(os.WriteFile(path, []byte(cfg), 0o600), qt.IsNil)
+		return path
+	}
+
+	c.Run("keys move to the providers' environment variables", func(c *qt.C) {
+		home := c.TempDir()
+		path := writeConfig(c, home)
+		c.Setenv("OPENAI_API_KEY", "sk-abc")
+		c.Setenv("COHERE_API_KEY", "")
+
+		out, err := runCmd(t, "--memory-home", home, "config", "migrate-key")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Replaced embedding.api_key with api_key_env: OPENAI_API_KEY\n")
+		c.Assert(out, qt.Contains, "Replaced embedding.fallbacks[0].api_key with api_key_env: COHERE_API_KEY\n")
+		c.Assert(out, qt.Contains, "  export COHERE_API_KEY='co-xyz'\n")
+		c.Assert(out, qt.Not(qt.Contains), "export OPENAI_API_KEY")
+
+		data, err := os.ReadFile(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(data), qt.Equals, "embedding:\n"+
+			"  provider: openai\n"+
+			"  model: text-embedding-3-small\n"+
+			"  api_key_env: OPENAI_API_KEY   # primary\n"+
+			"  fallbacks:\n"+
+			"    - provider: cohere\n"+
+			"      model: embed-english-v3.0\n"+
+			"      api_key_env: COHERE_API_KEY\n")
+
+		out, err = runCmd(t, "--memory-home", home, "config", "migrate-key")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "No literal API key in")
+	})
+
+	c.Run("a key moves to a command printing it", func(c *qt.C) {
+		home := c.TempDir()
+		path := filepath.Join(home, "config.yaml")
+		c.Assert(os.WriteFile(path, []byte("embedding:\n  provider: openai\n  api_key: sk-abc\n"), 0o600), qt.IsNil)
+
+		out, err := runCmd(t, "--memory-home", home, "config", "migrate-key", "--cmd", "echo sk-abc")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Equals, "Replaced embedding.api_key with api_key_cmd: echo sk-abc\n")
+		data, err := os.ReadFile(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(data), qt.Equals, "embedding:\n  provider: openai\n  api_key_cmd: echo sk-abc\n")
+	})
+}
+
+func TestConfigMigrateKey_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	c.Run("a command that does not print the key", func(c *qt.C) {
+		home := c.TempDir()
+		path := filepath.Join(home, "config.yaml")
+		cfg := "embedding:\n  provider: openai\n  api_key: sk-abc\n"
+		c.Assert(os.WriteFile(path, []byte(cfg), 0o600), qt.IsNil)
+
+		_, err := runCmd(t, "--memory-home", home, "config", "migrate-key", "--cmd", "echo sk-other")
+		c.Assert(err, qt.ErrorMatches, `config migrate-key: "echo sk-other" does not print the key in embedding.api_key; .*`)
+		data, err := os.ReadFile(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(string(data), qt.Equals, cfg)
+	})
+
+	c.Run("--env with several keys", func(c *qt.C) {
+		home := c.TempDir()
+		cfg := "embedding:\n  api_key: a\n  fallbacks:\n    - provider: cohere\n      api_key: b\n"
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+
+		_, err := runCmd(t, "--memory-home", home, "config", "migrate-key", "--env", "MY_KEY")
+		c.Assert(err, qt.ErrorMatches, "config migrate-key: --env and --cmd apply to a single key, but .* has 2; run without them")
+	})
+}
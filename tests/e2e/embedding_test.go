@@ -6,9 +6,16 @@ package e2e_test
 
 import (
 	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 
 	qt "github.com/frankban/quicktest"
+
+	"github.com/go-ports/echovault/internal/service"
 )
 
 // ---------------------------------------------------------------------------
@@ -125,10 +132,405 @@ func TestMCPSearchWithEmbedding_HappyPath(t *testing.T) {
 				"project": "echovault",
 			})
 
-			var results []map[string]any
-			c.Assert(json.Unmarshal([]byte(text), &results), qt.IsNil)
+			results := decodeSearch(c, text).Results
 			c.Assert(results, qt.HasLen, 1)
 			c.Assert(results[0]["title"], qt.Equals, "MCP vector search test")
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// CLI — detail chunks
+// ---------------------------------------------------------------------------
+
+// TestCLISearch_DetailChunks_HappyPath verifies that a memory is found by
+// semantic search through its details alone, before and after a reindex.
+func TestCLISearch_DetailChunks_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newKeywordEmbeddingServer(t, "pgbouncer", "pooler")
+	home := t.TempDir()
+	writeEmbeddingCfg(t, home, "ollama", srv.URL)
+
+	for _, m := range []struct{ title, details string }{
+		{"Database outage postmortem", "Context: the app exhausted Postgres connections. Decision: put pgbouncer in transaction mode in front of the primary."},
+		{"Release checklist", ""},
+	} {
+		args := []string{"--memory-home", home, "save", "--title", m.title, "--what", m.title, "--project", "testproject"}
+		if m.details != "" {
+			args = append(args, "--details", m.details)
+		}
+		_, err := runCmd(t, args...)
+		c.Assert(err, qt.IsNil)
+	}
+
+	// No memory contains these words, so only the detail chunk vector can
+	// match.
+	search := func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "connection pooler", "--explain")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Matches, `(?s).*\[1\] Database outage postmortem.*vector #1 \(distance 0,.*`)
+	}
+	c.Run("after save", search)
+
+	_, err := runCmd(t, "--memory-home", home, "reindex")
+	c.Assert(err, qt.IsNil)
+	c.Run("after reindex", search)
+}
+
+// ---------------------------------------------------------------------------
+// CLI — reindex
+// ---------------------------------------------------------------------------
+
+// TestCLIReindex_ChangedOnly_HappyPath verifies that reindex --changed-only
+// keeps the vectors of memories whose content and model are unchanged.
+func TestCLIReindex_ChangedOnly_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newKeywordEmbeddingServer(t, "pgbouncer")
+	home := t.TempDir()
+	writeEmbeddingCfg(t, home, "ollama", srv.URL)
+	for _, title := range []string{"Pgbouncer transaction mode", "Release checklist"} {
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", title, "--what", title, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	out, err := runCmd(t, "--memory-home", home, "reindex", "--changed-only")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Re-indexed 2 memories with test-model (4 dims): 0 embedded, 2 unchanged")
+
+	_, err = runCmd(t, "--memory-home", home, "save", "--title", "Deploy freeze", "--what", "No deploys on Fridays", "--project", "testproject")
+	c.Assert(err, qt.IsNil)
+	out, err = runCmd(t, "--memory-home", home, "reindex")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Re-indexed 3 memories with test-model (4 dims): 3 embedded, 0 unchanged")
+
+	// Vectors still serve searches after the swap.
+	out, err = runCmd(t, "--memory-home", home, "search", "pgbouncer pooling", "--explain")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Matches, `(?s).*\[1\] Pgbouncer transaction mode.*vector #1 \(distance 0,.*`)
+}
+
+// TestCLIReindex_Batched_HappyPath verifies that reindex sends the memories
+// to the provider in batches of embedding.batch_size texts.
+func TestCLIReindex_Batched_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv, batches := newCountingOllamaServer(t)
+	home := t.TempDir()
+	cfg := "embedding:\n  provider: ollama\n  model: test-model\n  base_url: " + srv.URL + "\n  batch_size: 2\n  concurrency: 2\n"
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	for i := range 5 {
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", fmt.Sprintf("Memory %d", i), "--what", "Batching", "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	*batches = nil
+	out, err := runCmd(t, "--memory-home", home, "reindex")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Re-indexed 5 memories with test-model (4 dims): 5 embedded, 0 unchanged")
+	// The dimension probe, then the five summaries two at a time.
+	sort.Ints((*batches)[1:])
+	c.Assert(*batches, qt.DeepEquals, []int{1, 1, 2, 2})
+}
+
+// ---------------------------------------------------------------------------
+// Embedding model changes
+// ---------------------------------------------------------------------------
+
+// TestModelChange_HappyPath verifies that switching to another embedding
+// model of the same dimension marks the stored vectors stale, with warnings,
+// until a reindex rebuilds them.
+func TestModelChange_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newOllamaMockServer(t, "test-model")
+	home := t.TempDir()
+	writeCfg := func(model string, autoReindex bool) {
+		cfg := fmt.Sprintf("embedding:\n  provider: ollama\n  model: %s\n  base_url: %s\n  auto_reindex: %t\n", model, srv.URL, autoReindex)
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	}
+	writeCfg("test-model", false)
+	_, err := runCmd(t, "--memory-home", home, "save", "--title", "Pool sizing", "--what", "Pool sizing", "--project", "testproject")
+	c.Assert(err, qt.IsNil)
+
+	out, err := runCmd(t, "--memory-home", home, "config")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "vectors:\n    model: ollama/test-model\n    stale: false\n")
+
+	writeCfg("other-model", false)
+	const warning = "stored vectors were built with ollama/test-model but the configured embedding model is ollama/other-model"
+
+	c.Run("memory config warns", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "config")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "stale: true")
+		c.Assert(out, qt.Contains, warning)
+	})
+
+	c.Run("saves skip the vector and warn", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "save", "--title", "Retry budget", "--what", "Retry budget", "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Warning: Saved without a vector: "+warning)
+	})
+
+	c.Run("search falls back to keywords", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "search", "pool", "--explain")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "[1] Pool sizing")
+		c.Assert(out, qt.Contains, "vector search: skipped")
+	})
+
+	c.Run("MCP responses warn", func(c *qt.C) {
+		cl := newMCPClientAt(c, home)
+		text := callTool(c, cl, "memory_search", map[string]any{"query": "pool"})
+		var resp map[string]any
+		c.Assert(json.Unmarshal([]byte(text), &resp), qt.IsNil)
+		c.Assert(resp["vector_warning"], qt.Matches, "WARNING: "+warning+"; .*'memory reindex'.*")
+	})
+
+	c.Run("a background reindex rebuilds the vectors", func(c *qt.C) {
+		writeCfg("other-model", true)
+		svc, err := service.New(home)
+		c.Assert(err, qt.IsNil)
+		defer svc.Close()
+		c.Assert(svc.StartBackgroundReindex(), qt.IsTrue)
+		deadline := time.Now().Add(10 * time.Second)
+		for svc.VectorStatus().Reindexing && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		st := svc.VectorStatus()
+		c.Assert(st.Stale, qt.IsFalse)
+		c.Assert(st.Model, qt.Equals, "ollama/other-model")
+		c.Assert(svc.StartBackgroundReindex(), qt.IsFalse)
+	})
+}
+
+// TestCLIDoctor_HappyPath verifies that memory doctor reports the provider,
+// the vector index and the distance metric in use, and flags a configured
+// metric the index was not built with until a reindex.
+func TestCLIDoctor_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newOllamaMockServer(t, "test-model")
+	home := t.TempDir()
+	writeCfg := func(metric string) {
+		cfg := fmt.Sprintf("embedding:\n  provider: ollama\n  model: test-model\n  base_url: %s\n  distance_metric: %s\n", srv.URL, metric)
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	}
+	writeCfg("cosine")
+	_, err := runCmd(t, "--memory-home", home, "save", "--title", "Pool sizing", "--what", "Pool sizing", "--project", "testproject")
+	c.Assert(err, qt.IsNil)
+
+	out, err := runCmd(t, "--memory-home", home, "doctor")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "[ok] embedding provider: ollama/test-model returns 4 dimensions\n")
+	c.Assert(out, qt.Contains, "[ok] vector index: 4 dimensions, built with ollama/test-model\n")
+	c.Assert(out, qt.Contains, "[ok] distance metric: cosine\n")
+
+	writeCfg("l2")
+	out, err = runCmd(t, "--memory-home", home, "doctor")
+	c.Assert(err, qt.ErrorMatches, `1 problem\(s\) found`)
+	c.Assert(out, qt.Contains, "[!!] distance metric: cosine in use, l2 configured; run 'memory reindex' to switch\n")
+
+	_, err = runCmd(t, "--memory-home", home, "reindex")
+	c.Assert(err, qt.IsNil)
+	out, err = runCmd(t, "--memory-home", home, "doctor")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "[ok] distance metric: l2\n")
+}
+
+// TestCLIStats_HappyPath verifies that memory stats reports the vectors and
+// index size, with vectors truncated to embedding.dimensions and stored as
+// configured by embedding.quantization.
+func TestCLIStats_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newOllamaMockServer(t, "test-model")
+	home := t.TempDir()
+
+	out, err := runCmd(t, "--memory-home", home, "stats")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Memories:     0\nVectors:      none\n")
+
+	cfg := fmt.Sprintf("embedding:\n  provider: ollama\n  model: test-model\n  base_url: %s\n  dimensions: 2\n  quantization: int8\n", srv.URL)
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	for _, title := range []string{"Pool sizing", "Retry budget"} {
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", title, "--what", title, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	out, err = runCmd(t, "--memory-home", home, "stats")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Memories:     2\n")
+	c.Assert(out, qt.Contains, "Vectors:      2 memories, 0 detail chunks\n")
+	c.Assert(out, qt.Contains, "Model:        ollama/test-model (2 dims)\n")
+	c.Assert(out, qt.Contains, "Storage:      cosine, int8\n")
+	c.Assert(out, qt.Contains, "Vector data:  4 B (estimated)\n")
+	c.Assert(out, qt.Contains, "Coverage:     100% embedded\n")
+	c.Assert(out, qt.Matches, `(?s).*Index size:   [0-9.]+ [KM]iB\n`)
+}
+
+// ---------------------------------------------------------------------------
+// Fallback chain
+// ---------------------------------------------------------------------------
+
+// TestFallbackChain_HappyPath verifies that a provider that is down falls
+// back to the next one serving the same model, and that memories which could
+// not be embedded at all are embedded along with the next one that is.
+func TestFallbackChain_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newOllamaMockServer(t, "test-model")
+	down := newOllamaMockServer(t, "test-model")
+	down.Close()
+	home := t.TempDir()
+	writeCfg := func(urls ...string) {
+		cfg := fmt.Sprintf("embedding:\n  provider: ollama\n  model: test-model\n  base_url: %s\n  fallbacks:\n", urls[0])
+		for _, u := range urls[1:] {
+			cfg += fmt.Sprintf("    - provider: ollama\n      model: test-model\n      base_url: %s\n", u)
+		}
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	}
+
+	c.Run("a failing provider queues the memory", func(c *qt.C) {
+		writeCfg(down.URL, down.URL)
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", "Pool sizing", "--what", "Pool sizing", "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+		out, err := runCmd(t, "--memory-home", home, "stats")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Vectors:      none\n")
+	})
+
+	c.Run("the fallback embeds the next memory and the queued one", func(c *qt.C) {
+		writeCfg(down.URL, srv.URL)
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", "Retry budget", "--what", "Retry budget", "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+		out, err := runCmd(t, "--memory-home", home, "stats")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "Vectors:      2 memories, 0 detail chunks\n")
+	})
+
+	c.Run("doctor probes every provider", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "doctor")
+		c.Assert(err, qt.ErrorMatches, `1 problem\(s\) found`)
+		c.Assert(out, qt.Contains, "[!!] embedding provider: ollama/test-model")
+		c.Assert(out, qt.Contains, "[ok] fallback provider 1: ollama/test-model returns 4 dimensions\n")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Pending embeddings
+// ---------------------------------------------------------------------------
+
+// TestCLIEmbedPending_HappyPath verifies that memories saved while the
+// provider is down are reported as pending and embedded by
+// memory embed --pending once it is back, along with memories saved while
+// embedding was disabled.
+func TestCLIEmbedPending_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	srv := newOllamaMockServer(t, "test-model")
+	down := newOllamaMockServer(t, "test-model")
+	down.Close()
+	home := t.TempDir()
+	writeCfg := func(provider, url string) {
+		cfg := fmt.Sprintf("embedding:\n  provider: %s\n  model: test-model\n  base_url: %s\n", provider, url)
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	}
+	save := func(title string) {
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", title, "--what", title, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	writeCfg("none", srv.URL)
+	save("Cache warmup")
+	writeCfg("ollama", srv.URL)
+	save("Pool sizing")
+	writeCfg("ollama", down.URL)
+	save("Retry budget")
+	save("Release checklist")
+
+	out, err := runCmd(t, "--memory-home", home, "stats")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Coverage:     25% embedded (2 pending; run 'memory embed --pending')\n")
+	out, err = runCmd(t, "--memory-home", home, "doctor")
+	c.Assert(err, qt.IsNotNil)
+	c.Assert(out, qt.Contains, "[!!] pending embeddings: 2 memories are waiting for vectors; run 'memory embed --pending'\n")
+
+	writeCfg("ollama", srv.URL)
+	out, err = runCmd(t, "--memory-home", home, "embed", "--pending", "--limit", "2")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Equals, "Queued 1 memories without a vector.\nEmbedded 2 memories, 1 still pending.\n")
+
+	out, err = runCmd(t, "--memory-home", home, "embed", "--pending")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Equals, "Embedded 1 memories, 0 still pending.\n")
+	out, err = runCmd(t, "--memory-home", home, "stats")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Vectors:      4 memories, 0 detail chunks\n")
+	c.Assert(out, qt.Contains, "Coverage:     100% embedded\n")
+}
+
+// TestCLIEmbedPending_FailurePath verifies that memory embed reports what
+// stops it and leaves the queue as it was.
+func TestCLIEmbedPending_FailurePath(t *testing.T) {
+	c := qt.New(t)
+
+	down := newOllamaMockServer(t, "test-model")
+	down.Close()
+	home := t.TempDir()
+	cfg := fmt.Sprintf("embedding:\n  provider: ollama\n  model: test-model\n  base_url: %s\n", down.URL)
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(cfg), 0o600), qt.IsNil)
+	_, err := runCmd(t, "--memory-home", home, "save", "--title", "Pool sizing", "--what", "Pool sizing", "--project", "testproject")
+	c.Assert(err, qt.IsNil)
+
+	c.Run("without --pending", func(c *qt.C) {
+		_, err := runCmd(t, "--memory-home", home, "embed")
+		c.Assert(err, qt.ErrorMatches, "nothing to embed; pass --pending")
+	})
+
+	c.Run("the provider is still down", func(c *qt.C) {
+		out, err := runCmd(t, "--memory-home", home, "embed", "--pending")
+		c.Assert(err, qt.ErrorMatches, "EmbedPending: .*connection refused")
+		c.Assert(out, qt.Equals, "Embedded 0 memories, 1 still pending.\n")
+	})
+
+	c.Run("embedding is disabled", func(c *qt.C) {
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte("embedding:\n  provider: none\n"), 0o600), qt.IsNil)
+		_, err := runCmd(t, "--memory-home", home, "embed", "--pending")
+		c.Assert(err, qt.ErrorMatches, "EmbedPending: no embedding provider configured")
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Local provider
+// ---------------------------------------------------------------------------
+
+// TestCLILocalEmbedding_HappyPath verifies that config init selects the local
+// provider and that memories are then found by meaning with an embedding
+// table under the memory home, without any server.
+func TestCLILocalEmbedding_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	out, err := runCmd(t, "--memory-home", home, "config", "init", "--provider", "local", "--model", "glove.txt")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Put a GloVe or word2vec text file, or a model2vec model directory, at "+filepath.Join(home, "glove.txt"))
+
+	table := "database 1 0 0\npostgres 0.9 0.1 0\npooler 0.95 0 0.05\nrelease 0 1 0\nchecklist 0 0.9 0.1\n"
+	c.Assert(os.WriteFile(filepath.Join(home, "glove.txt"), []byte(table), 0o600), qt.IsNil)
+	for _, title := range []string{"Database outage", "Release checklist", "Zzz qqq"} {
+		_, err := runCmd(t, "--memory-home", home, "save", "--title", title, "--what", title, "--project", "testproject")
+		c.Assert(err, qt.IsNil)
+	}
+
+	// No memory contains the word, so only the vectors can match.
+	out, err = runCmd(t, "--memory-home", home, "search", "pooler", "--explain")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Matches, `(?s).*\[1\] Database outage.*vector #1 .*`)
+
+	out, err = runCmd(t, "--memory-home", home, "stats")
+	c.Assert(err, qt.IsNil)
+	c.Assert(out, qt.Contains, "Model:        local/glove.txt (3 dims)\n")
+}
@@ -10,6 +10,8 @@ package e2e_test
 import (
 	"context"
 	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	qt "github.com/frankban/quicktest"
@@ -54,8 +56,15 @@ func newMCPClientWithDisabledTools(c *qt.C, disabledTools []string) *mcpclient.C
 // is returned; cleanup is registered on c automatically.
 func newMCPClient(c *qt.C) *mcpclient.Client {
 	c.TB.Helper()
+	return newMCPClientAt(c, c.TB.TempDir())
+}
 
-	svc, err := service.New(c.TB.TempDir())
+// newMCPClientAt is newMCPClient for a service rooted at home, so tests can
+// write a config.yaml first.
+func newMCPClientAt(c *qt.C, home string) *mcpclient.Client {
+	c.TB.Helper()
+
+	svc, err := service.New(home)
 	c.Assert(err, qt.IsNil)
 	c.TB.Cleanup(func() { _ = svc.Close() })
 
@@ -91,6 +100,20 @@ func callTool(c *qt.C, cl *mcpclient.Client, name string, args map[string]any) s
 	return tc.Text
 }
 
+// searchResponse is the memory_search result envelope.
+type searchResponse struct {
+	Results    []map[string]any `json:"results"`
+	HasMore    bool             `json:"has_more"`
+	NextCursor string           `json:"next_cursor"`
+}
+
+func decodeSearch(c *qt.C, text string) searchResponse {
+	c.TB.Helper()
+	var resp searchResponse
+	c.Assert(json.Unmarshal([]byte(text), &resp), qt.IsNil)
+	return resp
+}
+
 // ---------------------------------------------------------------------------
 // ListTools
 // ---------------------------------------------------------------------------
@@ -101,7 +124,7 @@ func TestMCPListTools_HappyPath(t *testing.T) {
 
 	result, err := cl.ListTools(context.Background(), mcp.ListToolsRequest{})
 	c.Assert(err, qt.IsNil)
-	c.Assert(result.Tools, qt.HasLen, 5)
+	c.Assert(result.Tools, qt.HasLen, 6)
 
 	names := make([]string, len(result.Tools))
 	for i, tool := range result.Tools {
@@ -110,6 +133,7 @@ func TestMCPListTools_HappyPath(t *testing.T) {
 	c.Assert(names, qt.Contains, "memory_save")
 	c.Assert(names, qt.Contains, "memory_search")
 	c.Assert(names, qt.Contains, "memory_context")
+	c.Assert(names, qt.Contains, "memory_for_files")
 	c.Assert(names, qt.Contains, "memory_delete")
 	c.Assert(names, qt.Contains, "memory_replace")
 }
@@ -200,10 +224,11 @@ func TestMCPMemorySearch_HappyPath(t *testing.T) {
 		"project": "echovault",
 	})
 
-	var results []map[string]any
-	c.Assert(json.Unmarshal([]byte(text), &results), qt.IsNil)
+	results := decodeSearch(c, text).Results
 	c.Assert(results, qt.HasLen, 1)
 	c.Assert(results[0]["title"], qt.Equals, "CGO required for sqlite")
+	c.Assert(results[0]["possibly_stale"], qt.Equals, false)
+	c.Assert(results[0]["snippet"], qt.Contains, "**sqlite**")
 }
 
 func TestMCPMemorySearch_EmptyVault_HappyPath(t *testing.T) {
@@ -214,11 +239,106 @@ func TestMCPMemorySearch_EmptyVault_HappyPath(t *testing.T) {
 		"query": "anything",
 	})
 
-	var results []map[string]any
-	c.Assert(json.Unmarshal([]byte(text), &results), qt.IsNil)
+	results := decodeSearch(c, text).Results
 	c.Assert(results, qt.HasLen, 0)
 }
 
+func TestMCPMemorySearch_Structured_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	callTool(c, cl, "memory_save", map[string]any{
+		"title":    "Retry loop leaks goroutines",
+		"what":     "The retry loop never cancels its timer goroutine",
+		"category": "bug",
+		"tags":     []string{"concurrency"},
+		"project":  "echovault",
+	})
+	callTool(c, cl, "memory_save", map[string]any{
+		"title":    "Retry with exponential backoff",
+		"what":     "All outbound HTTP calls retry with exponential backoff",
+		"category": "decision",
+		"project":  "echovault",
+	})
+
+	text := callTool(c, cl, "memory_search", map[string]any{
+		"query": "retry category:bug tag:concurrency",
+	})
+
+	results := decodeSearch(c, text).Results
+	c.Assert(results, qt.HasLen, 1)
+	c.Assert(results[0]["title"], qt.Equals, "Retry loop leaks goroutines")
+}
+
+func TestMCPMemorySearch_Paging_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	for _, title := range []string{"Paging alpha", "Paging beta", "Paging gamma"} {
+		callTool(c, cl, "memory_save", map[string]any{
+			"title": title, "what": title + " about paging", "project": "echovault",
+		})
+	}
+
+	first := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{
+		"query": "paging", "limit": 2,
+	}))
+	c.Assert(first.Results, qt.HasLen, 2)
+	c.Assert(first.HasMore, qt.IsTrue)
+	c.Assert(first.NextCursor, qt.Not(qt.Equals), "")
+
+	// A memory saved between pages does not shift the next page.
+	callTool(c, cl, "memory_save", map[string]any{
+		"title": "Paging delta", "what": "Paging delta about paging", "project": "echovault",
+	})
+
+	second := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{
+		"query": "paging", "limit": 2, "cursor": first.NextCursor,
+	}))
+	c.Assert(second.Results, qt.HasLen, 1)
+	c.Assert(second.HasMore, qt.IsFalse)
+
+	seen := map[any]bool{}
+	for _, r := range append(first.Results, second.Results...) {
+		c.Assert(seen[r["id"]], qt.IsFalse)
+		seen[r["id"]] = true
+		c.Assert(r["title"], qt.Not(qt.Equals), "Paging delta")
+	}
+}
+
+func TestMCPMemorySearch_FailurePath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	call := func(args map[string]any) *mcp.CallToolResult {
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "memory_search"
+		req.Params.Arguments = args
+		result, err := cl.CallTool(context.Background(), req)
+		c.Assert(err, qt.IsNil)
+		return result
+	}
+
+	c.Run("malformed query returns error", func(c *qt.C) {
+		c.Assert(call(map[string]any{"query": "(unbalanced OR"}).IsError, qt.IsTrue)
+	})
+
+	c.Run("garbage cursor returns error", func(c *qt.C) {
+		c.Assert(call(map[string]any{"query": "x", "cursor": "not-a-cursor"}).IsError, qt.IsTrue)
+	})
+
+	c.Run("cursor from another query returns error", func(c *qt.C) {
+		for _, title := range []string{"Cursor one", "Cursor two"} {
+			callTool(c, cl, "memory_save", map[string]any{"title": title, "what": title, "project": "echovault"})
+		}
+		first := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "cursor", "limit": 1}))
+		c.Assert(first.HasMore, qt.IsTrue)
+		c.Assert(call(map[string]any{"query": "other", "cursor": first.NextCursor}).IsError, qt.IsTrue)
+		c.Assert(call(map[string]any{"query": "cursor", "limit": 1, "diverse": true, "cursor": first.NextCursor}).IsError, qt.IsTrue)
+		c.Assert(call(map[string]any{"query": "cursor", "limit": 1, "cursor": first.NextCursor}).IsError, qt.IsFalse)
+	})
+}
+
 // ---------------------------------------------------------------------------
 // memory_context
 // ---------------------------------------------------------------------------
@@ -257,6 +377,39 @@ func TestMCPMemoryContext_EmptyVault_HappyPath(t *testing.T) {
 	c.Assert(text, checkers.JSONPathEquals("$.total"), float64(0))
 }
 
+func TestMCPMemoryContext_Paging_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	for _, title := range []string{"Context one", "Context two", "Context three"} {
+		callTool(c, cl, "memory_save", map[string]any{"title": title, "what": title, "project": "echovault"})
+	}
+
+	first := callTool(c, cl, "memory_context", map[string]any{"project": "echovault", "limit": 2})
+	c.Assert(first, checkers.JSONPathEquals("$.showing"), float64(2))
+	c.Assert(first, checkers.JSONPathEquals("$.has_more"), true)
+
+	var page struct {
+		Memories   []map[string]any `json:"memories"`
+		NextCursor string           `json:"next_cursor"`
+	}
+	c.Assert(json.Unmarshal([]byte(first), &page), qt.IsNil)
+
+	second := callTool(c, cl, "memory_context", map[string]any{
+		"project": "echovault", "limit": 2, "cursor": page.NextCursor,
+	})
+	c.Assert(second, checkers.JSONPathEquals("$.showing"), float64(1))
+	c.Assert(second, checkers.JSONPathEquals("$.has_more"), false)
+
+	var rest struct {
+		Memories []map[string]any `json:"memories"`
+	}
+	c.Assert(json.Unmarshal([]byte(second), &rest), qt.IsNil)
+	for _, m := range page.Memories {
+		c.Assert(m["id"], qt.Not(qt.Equals), rest.Memories[0]["id"])
+	}
+}
+
 func TestMCPMemoryContext_FailurePath(t *testing.T) {
 	c := qt.New(t)
 
@@ -273,6 +426,55 @@ func TestMCPMemoryContext_FailurePath(t *testing.T) {
 	})
 }
 
+// ---------------------------------------------------------------------------
+// memory_for_files
+// ---------------------------------------------------------------------------
+
+func TestMCPMemoryForFiles_HappyPath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	callTool(c, cl, "memory_save", map[string]any{
+		"title":         "FTS5 needs a build tag",
+		"what":          "Build with CGO_CFLAGS=-DSQLITE_ENABLE_FTS5 or schema creation fails",
+		"category":      "learning",
+		"related_files": []string{"Makefile", "internal/db/db.go"},
+		"project":       "echovault",
+	})
+	callTool(c, cl, "memory_save", map[string]any{
+		"title":         "Unrelated memory",
+		"what":          "Something about the README",
+		"category":      "context",
+		"related_files": []string{"README.md"},
+		"project":       "echovault",
+	})
+
+	text := callTool(c, cl, "memory_for_files", map[string]any{
+		"files": []string{"internal/db"},
+	})
+
+	var results []map[string]any
+	c.Assert(json.Unmarshal([]byte(text), &results), qt.IsNil)
+	c.Assert(results, qt.HasLen, 1)
+	c.Assert(results[0]["title"], qt.Equals, "FTS5 needs a build tag")
+	c.Assert(text, checkers.JSONPathEquals("$[0].matched_files"), []any{"internal/db/db.go"})
+}
+
+func TestMCPMemoryForFiles_FailurePath(t *testing.T) {
+	c := qt.New(t)
+	cl := newMCPClient(c)
+
+	c.Run("empty files list returns tool error", func(c *qt.C) {
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "memory_for_files"
+		req.Params.Arguments = map[string]any{"files": []string{}}
+
+		result, err := cl.CallTool(context.Background(), req)
+		c.Assert(err, qt.IsNil)
+		c.Assert(result.IsError, qt.IsTrue)
+	})
+}
+
 // ---------------------------------------------------------------------------
 // memory_delete
 // ---------------------------------------------------------------------------
@@ -405,7 +607,7 @@ func TestMCPDisabledTools_HappyPath(t *testing.T) {
 
 		result, err := cl.ListTools(context.Background(), mcp.ListToolsRequest{})
 		c.Assert(err, qt.IsNil)
-		c.Assert(result.Tools, qt.HasLen, 4)
+		c.Assert(result.Tools, qt.HasLen, 5)
 
 		names := make([]string, len(result.Tools))
 		for i, tool := range result.Tools {
@@ -414,6 +616,7 @@ func TestMCPDisabledTools_HappyPath(t *testing.T) {
 		c.Assert(names, qt.Contains, "memory_save")
 		c.Assert(names, qt.Contains, "memory_search")
 		c.Assert(names, qt.Contains, "memory_context")
+		c.Assert(names, qt.Contains, "memory_for_files")
 		c.Assert(names, qt.Contains, "memory_replace")
 	})
 
@@ -422,7 +625,7 @@ func TestMCPDisabledTools_HappyPath(t *testing.T) {
 
 		result, err := cl.ListTools(context.Background(), mcp.ListToolsRequest{})
 		c.Assert(err, qt.IsNil)
-		c.Assert(result.Tools, qt.HasLen, 3)
+		c.Assert(result.Tools, qt.HasLen, 4)
 	})
 }
 
@@ -443,3 +646,98 @@ func TestMCPCallTool_FailurePath(t *testing.T) {
 		c.Assert(err, qt.IsNotNil)
 	})
 }
+
+// ---------------------------------------------------------------------------
+// Diversity
+// ---------------------------------------------------------------------------
+
+// saveOverlapping saves three near-identical migration memories (newest
+// last) after two unrelated ones.
+func saveOverlapping(c *qt.C, cl *mcpclient.Client) {
+	for _, m := range []struct{ title, what string }{
+		{"Redis cache eviction policy", "Switched the cache to allkeys-lru"},
+		{"Postgres connection pool tuning", "Raised max connections for the pool"},
+		{"Postgres migration step one", "Migrating the orders table to Postgres with pgloader, batch one"},
+		{"Postgres migration step two", "Migrating the orders table to Postgres with pgloader, batch two"},
+		{"Postgres migration step three", "Migrating the orders table to Postgres with pgloader, batch three"},
+	} {
+		callTool(c, cl, "memory_save", map[string]any{
+			"title": m.title, "what": m.what, "category": "context", "project": "testproject",
+		})
+	}
+}
+
+func TestMCPMemorySearch_Diverse_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	home := t.TempDir()
+	c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte("search:\n  diversity: 0.7\n"), 0o600), qt.IsNil)
+	cl := newMCPClientAt(c, home)
+	saveOverlapping(c, cl)
+
+	titles := func(resp searchResponse) []string {
+		var out []string
+		for _, r := range resp.Results {
+			out = append(out, r["title"].(string))
+		}
+		return out
+	}
+
+	c.Run("configured diversity replaces near-duplicates", func(c *qt.C) {
+		resp := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "postgres migration", "limit": 2}))
+		got := titles(resp)
+		c.Assert(got, qt.HasLen, 2)
+		c.Assert(got[0], qt.Matches, "Postgres migration step .*")
+		c.Assert(got[1], qt.Equals, "Postgres connection pool tuning")
+	})
+
+	c.Run("diverse false keeps the relevance order", func(c *qt.C) {
+		resp := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "postgres migration", "limit": 2, "diverse": false}))
+		for _, title := range titles(resp) {
+			c.Assert(title, qt.Matches, "Postgres migration step .*")
+		}
+	})
+}
+
+func TestMCPMemorySearch_Diverse_Default_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	cl := newMCPClient(c)
+	saveOverlapping(c, cl)
+
+	resp := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "postgres migration", "limit": 3}))
+	c.Assert(resp.Results, qt.HasLen, 3)
+	for _, r := range resp.Results {
+		c.Assert(r["title"], qt.Matches, "Postgres migration step .*")
+	}
+}
+
+func TestMCPMemorySearch_Explain_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	cl := newMCPClient(c)
+	saveOverlapping(c, cl)
+
+	c.Run("explain adds the score breakdown", func(c *qt.C) {
+		resp := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "postgres pool", "limit": 1, "explain": true}))
+		c.Assert(resp.Results, qt.HasLen, 1)
+		c.Assert(resp.Results[0]["title"], qt.Equals, "Postgres connection pool tuning")
+		explain, ok := resp.Results[0]["explain"].(map[string]any)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(explain["fusion"], qt.Equals, "weighted")
+		c.Assert(explain["matched_terms"], qt.DeepEquals, []any{"postgres", "pool"})
+		fts, ok := explain["fts"].(map[string]any)
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(fts["rank"], qt.Equals, 1.0)
+		c.Assert(fts["bm25"].(float64) > 0, qt.IsTrue)
+		c.Assert(explain["vector"], qt.IsNil)
+		c.Assert(explain["category_boost"], qt.Equals, 1.0)
+	})
+
+	c.Run("results omit explain by default", func(c *qt.C) {
+		resp := decodeSearch(c, callTool(c, cl, "memory_search", map[string]any{"query": "postgres pool", "limit": 1}))
+		c.Assert(resp.Results, qt.HasLen, 1)
+		_, ok := resp.Results[0]["explain"]
+		c.Assert(ok, qt.IsFalse)
+	})
+}
@@ -4,21 +4,18 @@
 package e2e_test
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	qt "github.com/frankban/quicktest"
 	mcpclient "github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
-
-	internalmcp "github.com/go-ports/echovault/internal/mcp"
-	"github.com/go-ports/echovault/internal/service"
 )
 
 // fixedEmbeddingVec is the deterministic vector returned by every mock embedding
@@ -51,7 +48,7 @@ var embeddingCases = []embeddingCase{
 // newOllamaMockServer starts a test HTTP server that mimics the Ollama embedding
 // API. It responds to:
 //   - GET /api/ps          — reports model as loaded (satisfies "auto" semantic mode)
-//   - POST /api/embeddings — returns fixedEmbeddingVec for every request
+//   - POST /api/embed      — returns fixedEmbeddingVec for every input text
 //
 // Cleanup is registered on tb automatically.
 func newOllamaMockServer(tb testing.TB, model string) *httptest.Server {
@@ -64,9 +61,17 @@ func newOllamaMockServer(tb testing.TB, model string) *httptest.Server {
 			"models": []map[string]any{{"name": model, "model": model}},
 		})
 	})
-	mux.HandleFunc("/api/embeddings", func(w http.ResponseWriter, _ *http.Request) {
+	mux.HandleFunc("/api/embed", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := make([][]float32, len(req.Input))
+		for i := range out {
+			out[i] = fixedEmbeddingVec
+		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"embedding": fixedEmbeddingVec})
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": out})
 	})
 
 	srv := httptest.NewServer(mux)
@@ -74,6 +79,62 @@ func newOllamaMockServer(tb testing.TB, model string) *httptest.Server {
 	return srv
 }
 
+// newCountingOllamaServer starts an Ollama-style mock like
+// newOllamaMockServer that also records the number of input texts of each
+// POST /api/embed request in *batches.
+func newCountingOllamaServer(tb testing.TB) (*httptest.Server, *[]int) {
+	tb.Helper()
+
+	var (
+		mu      sync.Mutex
+		batches []int
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		batches = append(batches, len(req.Input))
+		mu.Unlock()
+		out := make([][]float32, len(req.Input))
+		for i := range out {
+			out[i] = fixedEmbeddingVec
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": out})
+	}))
+	tb.Cleanup(srv.Close)
+	return srv, &batches
+}
+
+// newKeywordEmbeddingServer starts an Ollama-style mock whose embeddings
+// point one way for texts mentioning any of keywords and another way for
+// everything else, so tests can tell which text a vector came from.
+func newKeywordEmbeddingServer(tb testing.TB, keywords ...string) *httptest.Server {
+	tb.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Input []string `json:"input"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := make([][]float32, len(req.Input))
+		for i, text := range req.Input {
+			out[i] = []float32{0, 1, 0, 0}
+			for _, k := range keywords {
+				if strings.Contains(strings.ToLower(text), k) {
+					out[i] = []float32{1, 0, 0, 0}
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": out})
+	}))
+	tb.Cleanup(srv.Close)
+	return srv
+}
+
 // newOpenAIMockServer starts a test HTTP server that mimics the OpenAI embeddings
 // API (POST /embeddings). It builds a correctly-indexed data entry for every input
 // text in the request body, returning fixedEmbeddingVec for each.
@@ -128,22 +189,5 @@ func newMCPClientWithEmbedding(c *qt.C, provider, baseURL string) *mcpclient.Cli
 
 	home := c.TB.TempDir()
 	writeEmbeddingCfg(c.TB, home, provider, baseURL)
-
-	svc, err := service.New(home)
-	c.Assert(err, qt.IsNil)
-	c.TB.Cleanup(func() { _ = svc.Close() })
-
-	cl, err := mcpclient.NewInProcessClient(internalmcp.NewServer(svc, nil))
-	c.Assert(err, qt.IsNil)
-	c.TB.Cleanup(func() { _ = cl.Close() })
-
-	c.Assert(cl.Start(context.Background()), qt.IsNil)
-
-	initReq := mcp.InitializeRequest{}
-	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initReq.Params.ClientInfo = mcp.Implementation{Name: "e2e-test", Version: "0.0.1"}
-	_, err = cl.Initialize(context.Background(), initReq)
-	c.Assert(err, qt.IsNil)
-
-	return cl
+	return newMCPClientAt(c, home)
 }
@@ -0,0 +1,87 @@
+package e2e_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// newTEIRerankMockServer starts a test HTTP server that mimics the TEI
+// /rerank endpoint, scoring documents that contain favour above the rest.
+// With favour "" it fails every request.
+func newTEIRerankMockServer(tb testing.TB, favour string) *httptest.Server {
+	tb.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if favour == "" {
+			http.Error(w, "model not loaded", http.StatusServiceUnavailable)
+			return
+		}
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		out := make([]map[string]any, len(req.Texts))
+		for i, text := range req.Texts {
+			score := 0.1
+			if strings.Contains(text, favour) {
+				score = 0.9
+			}
+			out[i] = map[string]any{"index": i, "score": score}
+		}
+		_ = json.NewEncoder(w).Encode(out)
+	}))
+	tb.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSearch_Rerank_HappyPath(t *testing.T) {
+	c := qt.New(t)
+
+	save := func(c *qt.C, home string) {
+		for _, m := range []struct{ title, what string }{
+			// The keyword-heavy memory ranks first without reranking.
+			{"Redis redis redis cache keys", "Redis cache keys use the redis prefix redis:"},
+			{"Moved sessions off Redis", "Sessions now live in Postgres"},
+		} {
+			_, err := runCmd(t, "--memory-home", home, "save",
+				"--title", m.title, "--what", m.what, "--project", "testproject")
+			c.Assert(err, qt.IsNil)
+		}
+	}
+	writeCfg := func(c *qt.C, home, url string) {
+		content := fmt.Sprintf("embedding:\n  provider: none\nrerank:\n  provider: tei\n  base_url: %s\n", url)
+		c.Assert(os.WriteFile(filepath.Join(home, "config.yaml"), []byte(content), 0o600), qt.IsNil)
+	}
+
+	c.Run("reranker reorders the fused candidates", func(c *qt.C) {
+		home := t.TempDir()
+		writeCfg(c, home, newTEIRerankMockServer(t, "Postgres").URL)
+		save(c, home)
+
+		out, err := runCmd(t, "--memory-home", home, "search", "redis")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "[1] Moved sessions off Redis (score: 0.90)")
+	})
+
+	c.Run("rerank failure keeps the fused order", func(c *qt.C) {
+		home := t.TempDir()
+		writeCfg(c, home, newTEIRerankMockServer(t, "").URL)
+		save(c, home)
+
+		out, err := runCmd(t, "--memory-home", home, "search", "redis")
+		c.Assert(err, qt.IsNil)
+		c.Assert(out, qt.Contains, "[1] Redis redis redis cache keys")
+		c.Assert(out, qt.Contains, "Moved sessions off Redis")
+	})
+}